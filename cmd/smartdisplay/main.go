@@ -9,9 +9,10 @@ import (
 	"smartdisplay-core/internal/alarm"
 	"smartdisplay-core/internal/alarm/countdown"
 	"smartdisplay-core/internal/api"
+	"smartdisplay-core/internal/audit"
 	"smartdisplay-core/internal/config"
 	"smartdisplay-core/internal/firstboot"
-	"smartdisplay-core/internal/guest"
+	"smartdisplay-core/internal/ha/alarmo"
 	"smartdisplay-core/internal/haadapter"
 	"smartdisplay-core/internal/hal"
 	"smartdisplay-core/internal/hanotify"
@@ -31,6 +32,9 @@ func main() {
 	// Sade başlatma ve shutdown
 	logger.Init()
 	logger.Info("SmartDisplay v" + version.Version)
+	if err := audit.Init("data/audit"); err != nil {
+		logger.Error("audit trail init failed (memory only): " + err.Error())
+	}
 	setGOMAXPROCS()
 	runtimeCfg, err := loadRuntimeConfig()
 	if err != nil {
@@ -51,7 +55,7 @@ func main() {
 		os.Exit(1)
 	}
 	logger.Info("ui api ready")
-	handleGracefulShutdown(apiServer, pollCancel)
+	handleGracefulShutdown(apiServer, coord, pollCancel)
 }
 
 // setGOMAXPROCS sets GOMAXPROCS if env var is set
@@ -97,15 +101,9 @@ func initializeCoordinator(cfg config.Config, runtimeCfg *config.RuntimeConfig)
 		logger.Error("ha adapter init failed: " + err.Error())
 		os.Exit(1)
 	}
-	if err := adapter.Start(); err != nil {
-		logger.Error("ha adapter start failed: " + err.Error())
-		os.Exit(1)
-	}
-	logger.Info("ha adapter ready")
 
 	// Initialize state machines
 	alarmSM := alarm.NewStateMachine()
-	guestSM := guest.NewStateMachine()
 	cd := countdown.New(30)
 	notifier := &hanotify.StubNotifier{}
 
@@ -141,9 +139,37 @@ func initializeCoordinator(cfg config.Config, runtimeCfg *config.RuntimeConfig)
 		haToken = os.Getenv("HA_TOKEN")
 	}
 
-	coord := system.NewCoordinator(alarmSM, guestSM, cd, adapter, notifier, halReg, plat, haBaseURL, haToken)
+	coord := system.NewCoordinator(alarmSM, cd, adapter, notifier, halReg, plat, haBaseURL, haToken)
 	logger.Info("system coordinator ready")
 
+	// Keep logbook history across restarts
+	if err := coord.Logbook.Open("data/logbook"); err != nil {
+		logger.Error("logbook store open failed (memory only): " + err.Error())
+	}
+	if err := coord.GuestPasses.Open("data/guest_passes.json", "data/guest_pass.key"); err != nil {
+		logger.Error("guest pass store open failed (memory only): " + err.Error())
+	}
+	if err := coord.GuestRequest.Open("data/guest_requests.json"); err != nil {
+		logger.Error("guest request store open failed (memory only): " + err.Error())
+	}
+	if err := coord.GuestSigner.Open("data/guest_callback.key"); err != nil {
+		logger.Error("guest callback key open failed (buttons end on restart): " + err.Error())
+	}
+
+	// Configure Alarmo areas (multi-panel installs)
+	applyAlarmoPanels(coord, runtimeCfg)
+
+	// Start HA WebSocket session once credentials are resolved and events are routed
+	adapter.SetCredentials(haBaseURL, haToken)
+	if err := adapter.Start(); err != nil {
+		logger.Error("ha adapter start failed: " + err.Error())
+		os.Exit(1)
+	}
+	logger.Info("ha adapter ready")
+
+	// Re-arm saved before the restart, once Alarmo can be reached
+	coord.RestoreGuestRearm()
+
 	// Apply accessibility preferences
 	applyAccessibilityPreferences(coord, runtimeCfg)
 
@@ -156,6 +182,20 @@ func initializeCoordinator(cfg config.Config, runtimeCfg *config.RuntimeConfig)
 	return coord
 }
 
+// applyAlarmoPanels configures the Alarmo areas from runtime config
+func applyAlarmoPanels(coord *system.Coordinator, runtimeCfg *config.RuntimeConfig) {
+	if coord.AlarmoAdapter == nil || len(runtimeCfg.AlarmoPanels) == 0 {
+		return
+	}
+	panels := make([]alarmo.Panel, 0, len(runtimeCfg.AlarmoPanels))
+	for _, p := range runtimeCfg.AlarmoPanels {
+		panels = append(panels, alarmo.Panel{Area: p.Area, EntityID: p.EntityID, Name: p.Name})
+	}
+	if err := coord.SetAlarmoPanels(panels); err != nil {
+		logger.Error("alarmo panels config invalid, using default panel: " + err.Error())
+	}
+}
+
 // applyAccessibilityPreferences applies saved accessibility settings
 func applyAccessibilityPreferences(coord *system.Coordinator, runtimeCfg *config.RuntimeConfig) {
 	// TODO: Apply reduced_motion to AI engine when SetReducedMotion() method is implemented
@@ -183,7 +223,7 @@ func initializeFirstBoot(coord *system.Coordinator, runtimeCfg *config.RuntimeCo
 }
 
 // handleGracefulShutdown registers signal handlers and blocks until shutdown is complete
-func handleGracefulShutdown(apiServer *api.Server, pollCancel context.CancelFunc) {
+func handleGracefulShutdown(apiServer *api.Server, coord *system.Coordinator, pollCancel context.CancelFunc) {
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 
@@ -196,6 +236,11 @@ func handleGracefulShutdown(apiServer *api.Server, pollCancel context.CancelFunc
 		pollCancel()
 	}
 
+	// Close HA WebSocket session
+	if coord.HA != nil {
+		coord.HA.Stop()
+	}
+
 	// Graceful shutdown with 10-second timeout
 	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
@@ -204,6 +249,9 @@ func handleGracefulShutdown(apiServer *api.Server, pollCancel context.CancelFunc
 	if err := apiServer.Shutdown(shutdownCtx); err != nil {
 		logger.Error("http server shutdown error: " + err.Error())
 	}
+	// Stop the event subscribers and the stores once nothing serves requests any more
+	coord.Close()
+	audit.Close()
 
 	// Flush logs before exit
 	logger.Info("shutdown complete")
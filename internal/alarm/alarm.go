@@ -2,8 +2,10 @@ package alarm
 
 import (
 	"errors"
+	"fmt"
 	"smartdisplay-core/internal/alarm/countdown"
 	"smartdisplay-core/internal/logger"
+	"sync"
 	"time"
 )
 
@@ -11,6 +13,7 @@ const (
 	DISARMED  = "DISARMED"
 	ARMING    = "ARMING"
 	ARMED     = "ARMED"
+	PENDING   = "PENDING" // Entry delay running, trigger follows unless disarmed
 	TRIGGERED = "TRIGGERED"
 )
 
@@ -20,13 +23,45 @@ const (
 	ARM_COMPLETE   = "ARM_COMPLETE"
 	TRIGGER        = "TRIGGER"
 	RESET          = "RESET"
+	ENTRY          = "ENTRY" // A8: Protected zone opened while armed, entry delay before TRIGGER
+	SYNC           = "SYNC"  // A7: State forced from an external panel (Alarmo)
 )
 
+// Arm modes (A8)
+const (
+	ArmHome     = "home"
+	ArmAway     = "away"
+	ArmNight    = "night"
+	ArmVacation = "vacation"
+)
+
+const defaultDelaySec = 30
+
+// Delays are the countdown durations of one arm mode in seconds (0 = immediate)
+type Delays struct {
+	Exit  int // ARMING -> ARMED
+	Entry int // PENDING -> TRIGGERED
+}
+
+// ValidArmMode reports whether mode can be requested locally
+func ValidArmMode(mode string) bool {
+	switch mode {
+	case ArmHome, ArmAway, ArmNight, ArmVacation:
+		return true
+	}
+	return false
+}
+
 type StateMachine struct {
+	mu           sync.Mutex
 	currentState string
+	armedMode    string // home | away | night | vacation ... ("" unless ARMING/ARMED/PENDING)
 	lastEvent    string
 	lastTrigger  time.Time
 	cd           *countdown.Countdown
+	clock        countdown.Clock
+	delays       func(mode string) Delays
+	onTransition func(from, to, event string)
 	guest        interface{ CurrentState() string }
 }
 
@@ -34,6 +69,35 @@ func (sm *StateMachine) SetGuest(guest interface{ CurrentState() string }) {
 	sm.guest = guest
 }
 
+// SetDelayProvider sets where exit/entry durations come from (settings); nil = 30s defaults
+func (sm *StateMachine) SetDelayProvider(fn func(mode string) Delays) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.delays = fn
+}
+
+// SetClock sets the clock used by exit/entry countdowns (tests inject a FakeClock)
+func (sm *StateMachine) SetClock(clock countdown.Clock) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.clock = clock
+}
+
+// SetTransitionHandler registers a callback for every state change, including
+// countdown-driven ARM_COMPLETE/TRIGGER. Called outside the state machine lock.
+func (sm *StateMachine) SetTransitionHandler(fn func(from, to, event string)) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.onTransition = fn
+}
+
+// Countdown returns the current exit/entry countdown (nil before the first arm)
+func (sm *StateMachine) Countdown() *countdown.Countdown {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	return sm.cd
+}
+
 func (sm *StateMachine) CanDisarmRequest() bool {
 	if sm.guest == nil {
 		logger.Info("guest not set: disarm allowed")
@@ -70,18 +134,131 @@ func NewStateMachine() *StateMachine {
 }
 
 func (sm *StateMachine) CurrentState() string {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
 	return sm.currentState
 }
 
+// ArmedMode returns the arm mode while ARMING/ARMED/PENDING ("" otherwise)
+func (sm *StateMachine) ArmedMode() string {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	return sm.armedMode
+}
+
+// Sync forces the state reported by an external panel (Alarmo is the source of truth).
+// No transition validation: the panel already made the transition.
+// Returns true if state or armed mode changed.
+func (sm *StateMachine) Sync(state string, armedMode string) bool {
+	sm.mu.Lock()
+	from := sm.currentState
+	changed := sm.syncLocked(state, armedMode)
+	notify := sm.onTransition
+	sm.mu.Unlock()
+
+	if changed && notify != nil && from != state {
+		notify(from, state, SYNC)
+	}
+	return changed
+}
+
+func (sm *StateMachine) syncLocked(state string, armedMode string) bool {
+	switch state {
+	case DISARMED, TRIGGERED:
+		armedMode = ""
+	case PENDING:
+		if armedMode == "" {
+			armedMode = sm.armedMode // Panels often omit the mode during entry delay
+		}
+	case ARMING, ARMED:
+	default:
+		logger.Error("alarm: sync ignored unknown state " + state)
+		return false
+	}
+	if sm.currentState == state && sm.armedMode == armedMode {
+		return false
+	}
+
+	logger.Info("alarm: sync " + sm.currentState + " -> " + state)
+	if sm.cd != nil {
+		sm.cd.Stop() // Panel runs its own exit/entry delay
+	}
+	if state == TRIGGERED && sm.currentState != TRIGGERED {
+		sm.lastTrigger = time.Now()
+	}
+	sm.currentState = state
+	sm.armedMode = armedMode
+	sm.lastEvent = SYNC
+	return true
+}
+
+// Handle applies an event; ARM_REQUEST arms in away mode
 func (sm *StateMachine) Handle(event string) error {
+	return sm.handle(event, ArmAway)
+}
+
+// Arm requests arming in the given mode; the exit delay comes from the delay provider (A8)
+func (sm *StateMachine) Arm(mode string) error {
+	if !ValidArmMode(mode) {
+		return fmt.Errorf("invalid arm mode: %s", mode)
+	}
+	return sm.handle(ARM_REQUEST, mode)
+}
+
+func (sm *StateMachine) handle(event string, mode string) error {
+	sm.mu.Lock()
+	from := sm.currentState
+	err := sm.handleLocked(event, mode)
+	to := sm.currentState
+	notify := sm.onTransition
+	sm.mu.Unlock()
+
+	if err == nil && notify != nil && from != to {
+		notify(from, to, event)
+	}
+	return err
+}
+
+// countdownDone applies the event a finished countdown stands for (ARM_COMPLETE or TRIGGER).
+// Ignored if the countdown was superseded (disarm, re-arm, sync).
+func (sm *StateMachine) countdownDone(cd *countdown.Countdown, event string) {
+	sm.mu.Lock()
+	if sm.cd != cd {
+		sm.mu.Unlock()
+		return
+	}
+	from := sm.currentState
+	err := sm.handleLocked(event, sm.armedMode)
+	to := sm.currentState
+	notify := sm.onTransition
+	sm.mu.Unlock()
+
+	if err != nil {
+		logger.Info("alarm: countdown completion ignored in state " + from)
+		return
+	}
+	logger.Info("alarm: countdown completed, " + event + " (" + from + " -> " + to + ")")
+	if notify != nil && from != to {
+		notify(from, to, event)
+	}
+}
+
+func (sm *StateMachine) handleLocked(event string, mode string) error {
 	switch sm.currentState {
 	case DISARMED:
 		switch event {
 		case ARM_REQUEST:
-			sm.currentState = ARMING
+			sm.armedMode = mode
 			sm.lastEvent = event
-			sm.cd = countdown.New(30)
-			logger.Info("alarm countdown created")
+			exit := sm.delaysLocked(mode).Exit
+			if exit <= 0 {
+				sm.currentState = ARMED
+				logger.Info("alarm armed immediately (mode=" + mode + ", no exit delay)")
+				return nil
+			}
+			sm.currentState = ARMING
+			sm.startCountdownLocked(exit, ARM_COMPLETE)
+			logger.Info(fmt.Sprintf("alarm exit countdown created (mode=%s, %ds)", mode, exit))
 			return nil
 		}
 	case ARMING:
@@ -94,28 +271,36 @@ func (sm *StateMachine) Handle(event string) error {
 			}
 			return nil
 		case DISARM_REQUEST:
-			sm.currentState = DISARMED
-			sm.lastEvent = event
-			if sm.cd != nil {
-				sm.cd.Reset()
-				logger.Info("alarm countdown reset")
-			}
+			sm.disarmLocked(event)
 			return nil
 		}
 	case ARMED:
 		switch event {
 		case DISARM_REQUEST:
-			sm.currentState = DISARMED
+			sm.disarmLocked(event)
+			return nil
+		case ENTRY:
 			sm.lastEvent = event
-			if sm.cd != nil {
-				sm.cd.Reset()
-				logger.Info("alarm countdown reset")
+			entry := sm.delaysLocked(sm.armedMode).Entry
+			if entry <= 0 {
+				sm.triggerLocked(event)
+				return nil
 			}
+			sm.currentState = PENDING
+			sm.startCountdownLocked(entry, TRIGGER)
+			logger.Info(fmt.Sprintf("alarm entry countdown created (mode=%s, %ds)", sm.armedMode, entry))
 			return nil
 		case TRIGGER:
-			sm.currentState = TRIGGERED
-			sm.lastEvent = event
-			sm.lastTrigger = time.Now()
+			sm.triggerLocked(event)
+			return nil
+		}
+	case PENDING:
+		switch event {
+		case DISARM_REQUEST:
+			sm.disarmLocked(event)
+			return nil
+		case TRIGGER:
+			sm.triggerLocked(event)
 			return nil
 		}
 	case TRIGGERED:
@@ -129,12 +314,55 @@ func (sm *StateMachine) Handle(event string) error {
 	return errors.New("invalid transition")
 }
 
+func (sm *StateMachine) disarmLocked(event string) {
+	sm.currentState = DISARMED
+	sm.armedMode = ""
+	sm.lastEvent = event
+	if sm.cd != nil {
+		sm.cd.Stop()
+		sm.cd.Reset()
+		logger.Info("alarm countdown reset")
+	}
+}
+
+func (sm *StateMachine) triggerLocked(event string) {
+	sm.currentState = TRIGGERED
+	sm.armedMode = ""
+	sm.lastEvent = event
+	sm.lastTrigger = time.Now()
+	if sm.cd != nil {
+		sm.cd.Stop()
+	}
+}
+
+// startCountdownLocked replaces the running countdown; completion applies next
+func (sm *StateMachine) startCountdownLocked(seconds int, next string) {
+	if sm.cd != nil {
+		sm.cd.Stop()
+	}
+	cd := countdown.NewWithClock(seconds, sm.clock)
+	cd.OnComplete(func() { sm.countdownDone(cd, next) })
+	sm.cd = cd
+	cd.Start()
+}
+
+func (sm *StateMachine) delaysLocked(mode string) Delays {
+	if sm.delays == nil {
+		return Delays{Exit: defaultDelaySec, Entry: defaultDelaySec}
+	}
+	return sm.delays(mode)
+}
+
 // LastEvent returns the last event handled by the alarm state machine
 func (sm *StateMachine) LastEvent() string {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
 	return sm.lastEvent
 }
 
 // LastTriggerTime returns the last time the alarm was triggered
 func (sm *StateMachine) LastTriggerTime() time.Time {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
 	return sm.lastTrigger
 }
@@ -0,0 +1,158 @@
+package alarm
+
+import (
+	"os"
+	"smartdisplay-core/internal/alarm/countdown"
+	"smartdisplay-core/internal/logger"
+	"testing"
+	"time"
+)
+
+func TestMain(m *testing.M) {
+	os.MkdirAll("logs", 0755)
+	logger.Init()
+	os.Exit(m.Run())
+}
+
+type transition struct{ from, to, event string }
+
+// newClockedMachine returns a state machine on a fake clock that reports every transition
+func newClockedMachine(delays Delays) (*StateMachine, *countdown.FakeClock, <-chan transition) {
+	clock := countdown.NewFakeClock(time.Date(2025, 1, 1, 22, 0, 0, 0, time.UTC))
+	sm := NewStateMachine()
+	sm.SetClock(clock)
+	sm.SetDelayProvider(func(string) Delays { return delays })
+	ch := make(chan transition, 8)
+	sm.SetTransitionHandler(func(from, to, event string) { ch <- transition{from, to, event} })
+	return sm, clock, ch
+}
+
+func expectTransition(t *testing.T, ch <-chan transition, want transition) {
+	t.Helper()
+	select {
+	case got := <-ch:
+		if got != want {
+			t.Fatalf("transition = %+v, want %+v", got, want)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("no transition, want %+v", want)
+	}
+}
+
+func expectNoTransition(t *testing.T, ch <-chan transition) {
+	t.Helper()
+	select {
+	case got := <-ch:
+		t.Fatalf("unexpected transition %+v", got)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestExitCountdownCompletesArming(t *testing.T) {
+	sm, clock, ch := newClockedMachine(Delays{Exit: 20, Entry: 10})
+
+	if err := sm.Arm(ArmNight); err != nil {
+		t.Fatal(err)
+	}
+	expectTransition(t, ch, transition{DISARMED, ARMING, ARM_REQUEST})
+	if sm.ArmedMode() != ArmNight || sm.Countdown().Duration() != 20 {
+		t.Fatalf("arming mode=%q countdown=%ds", sm.ArmedMode(), sm.Countdown().Duration())
+	}
+	if !sm.Countdown().WillCompleteAt().Equal(clock.Now().Add(20 * time.Second)) {
+		t.Errorf("will complete at %v", sm.Countdown().WillCompleteAt())
+	}
+
+	clock.Advance(19 * time.Second)
+	expectNoTransition(t, ch)
+	clock.Advance(time.Second)
+	expectTransition(t, ch, transition{ARMING, ARMED, ARM_COMPLETE})
+	if sm.CurrentState() != ARMED || sm.ArmedMode() != ArmNight {
+		t.Errorf("state = %s/%s, want ARMED/night", sm.CurrentState(), sm.ArmedMode())
+	}
+}
+
+func TestEntryCountdownTriggers(t *testing.T) {
+	sm, clock, ch := newClockedMachine(Delays{Exit: 0, Entry: 10})
+
+	sm.Arm(ArmAway)
+	expectTransition(t, ch, transition{DISARMED, ARMED, ARM_REQUEST})
+
+	if err := sm.Handle(ENTRY); err != nil {
+		t.Fatal(err)
+	}
+	expectTransition(t, ch, transition{ARMED, PENDING, ENTRY})
+	clock.Advance(10 * time.Second)
+	expectTransition(t, ch, transition{PENDING, TRIGGERED, TRIGGER})
+	if sm.LastTriggerTime().IsZero() {
+		t.Error("trigger time not recorded")
+	}
+}
+
+func TestDisarmCancelsCountdown(t *testing.T) {
+	sm, clock, ch := newClockedMachine(Delays{Exit: 30, Entry: 30})
+
+	sm.Arm(ArmHome)
+	expectTransition(t, ch, transition{DISARMED, ARMING, ARM_REQUEST})
+	clock.Advance(5 * time.Second)
+	sm.Handle(DISARM_REQUEST)
+	expectTransition(t, ch, transition{ARMING, DISARMED, DISARM_REQUEST})
+
+	// Re-arm: only the new countdown may complete
+	sm.Arm(ArmAway)
+	expectTransition(t, ch, transition{DISARMED, ARMING, ARM_REQUEST})
+	clock.Advance(25 * time.Second) // Past the first window, inside the second
+	expectNoTransition(t, ch)
+	clock.Advance(5 * time.Second)
+	expectTransition(t, ch, transition{ARMING, ARMED, ARM_COMPLETE})
+}
+
+func TestZeroEntryDelayTriggersImmediately(t *testing.T) {
+	sm, _, ch := newClockedMachine(Delays{Exit: 0, Entry: 0})
+
+	sm.Arm(ArmVacation)
+	expectTransition(t, ch, transition{DISARMED, ARMED, ARM_REQUEST})
+	sm.Handle(ENTRY)
+	expectTransition(t, ch, transition{ARMED, TRIGGERED, ENTRY})
+
+	if err := sm.Arm("custom"); err == nil {
+		t.Error("invalid arm mode accepted")
+	}
+}
+
+func TestArmUsesDelaysOfItsMode(t *testing.T) {
+	sm, clock, ch := newClockedMachine(Delays{})
+	sm.SetDelayProvider(func(mode string) Delays {
+		if mode == ArmAway {
+			return Delays{Exit: 45, Entry: 20}
+		}
+		return Delays{Exit: 0, Entry: 5}
+	})
+
+	// Home: no exit delay, short entry delay
+	sm.Arm(ArmHome)
+	expectTransition(t, ch, transition{DISARMED, ARMED, ARM_REQUEST})
+	sm.Handle(ENTRY)
+	expectTransition(t, ch, transition{ARMED, PENDING, ENTRY})
+	if sm.Countdown().Duration() != 5 {
+		t.Errorf("home entry countdown = %ds, want 5", sm.Countdown().Duration())
+	}
+	clock.Advance(5 * time.Second)
+	expectTransition(t, ch, transition{PENDING, TRIGGERED, TRIGGER})
+	sm.Handle(RESET)
+	expectTransition(t, ch, transition{TRIGGERED, DISARMED, RESET})
+
+	// Away: its own exit and entry delays
+	sm.Arm(ArmAway)
+	expectTransition(t, ch, transition{DISARMED, ARMING, ARM_REQUEST})
+	if sm.Countdown().Duration() != 45 {
+		t.Errorf("away exit countdown = %ds, want 45", sm.Countdown().Duration())
+	}
+	clock.Advance(45 * time.Second)
+	expectTransition(t, ch, transition{ARMING, ARMED, ARM_COMPLETE})
+	sm.Handle(ENTRY)
+	expectTransition(t, ch, transition{ARMED, PENDING, ENTRY})
+	clock.Advance(19 * time.Second)
+	expectNoTransition(t, ch)
+	clock.Advance(time.Second)
+	expectTransition(t, ch, transition{PENDING, TRIGGERED, TRIGGER})
+}
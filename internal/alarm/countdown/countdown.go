@@ -1,48 +1,194 @@
 package countdown
 
+import (
+	"sync"
+	"time"
+)
+
+// Countdown runs on a Clock in its own goroutine and is safe for concurrent use.
+// Remaining time is derived from the clock; completion handlers fire once per run.
 type Countdown struct {
+	mu               sync.Mutex
+	clock            Clock
 	durationSeconds  int
-	remainingSeconds int
+	remainingSeconds int // Frozen value while inactive
 	active           bool
+	startedAt        time.Time
+	willCompleteAt   time.Time
+
+	gen        uint64        // Invalidates waiters of previous runs
+	stop       chan struct{} // Closed to release the current waiter
+	onComplete []func()
 }
 
 func New(durationSeconds int) *Countdown {
+	return NewWithClock(durationSeconds, RealClock{})
+}
+
+// NewWithClock creates a countdown driven by the given clock
+func NewWithClock(durationSeconds int, clock Clock) *Countdown {
+	if clock == nil {
+		clock = RealClock{}
+	}
 	return &Countdown{
+		clock:            clock,
 		durationSeconds:  durationSeconds,
 		remainingSeconds: durationSeconds,
 		active:           false,
 	}
 }
 
+// OnComplete registers a handler called (outside the lock) when a run reaches zero
+func (c *Countdown) OnComplete(fn func()) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.onComplete = append(c.onComplete, fn)
+}
+
 func (c *Countdown) Start() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	c.active = true
 	c.remainingSeconds = c.durationSeconds
+	c.startedAt = c.clock.Now()
+	c.willCompleteAt = c.startedAt.Add(time.Duration(c.durationSeconds) * time.Second)
+	c.armLocked()
 }
 
+// Stop pauses the countdown, keeping the remaining time
 func (c *Countdown) Stop() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.active {
+		return
+	}
+	c.remainingSeconds = c.remainingLocked()
 	c.active = false
+	c.releaseLocked()
 }
 
+// Reset restores the full duration; a running countdown restarts from now
 func (c *Countdown) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	c.remainingSeconds = c.durationSeconds
+	if c.active {
+		c.startedAt = c.clock.Now()
+		c.willCompleteAt = c.startedAt.Add(time.Duration(c.durationSeconds) * time.Second)
+		c.armLocked()
+	}
 }
 
+// Tick steps a running countdown one second ahead of its clock (manual stepping).
+// Returns true when this step completes it.
 func (c *Countdown) Tick() bool {
-	if c.active && c.remainingSeconds > 0 {
-		c.remainingSeconds--
-		if c.remainingSeconds == 0 {
-			return true
-		}
+	c.mu.Lock()
+	if !c.active || c.remainingLocked() <= 0 {
+		c.mu.Unlock()
+		return false
+	}
+	c.willCompleteAt = c.willCompleteAt.Add(-time.Second)
+	if c.remainingLocked() > 0 {
+		c.armLocked()
+		c.mu.Unlock()
+		return false
 	}
-	return false
+	handlers := c.completeLocked()
+	c.mu.Unlock()
+
+	notify(handlers)
+	return true
 }
 
 // IsActive returns true if countdown is currently running
 func (c *Countdown) IsActive() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	return c.active
 }
 
 // Remaining returns the number of seconds remaining
 func (c *Countdown) Remaining() int {
-	return c.remainingSeconds
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.active {
+		return c.remainingSeconds
+	}
+	return c.remainingLocked()
+}
+
+// Duration returns the configured length in seconds
+func (c *Countdown) Duration() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.durationSeconds
+}
+
+// StartedAt returns when the current/last run started (zero if never started)
+func (c *Countdown) StartedAt() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.startedAt
+}
+
+// WillCompleteAt returns when the current/last run completes (zero if never started)
+func (c *Countdown) WillCompleteAt() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.willCompleteAt
+}
+
+// remainingLocked rounds up so a countdown shows 1 until it actually completes
+func (c *Countdown) remainingLocked() int {
+	left := c.willCompleteAt.Sub(c.clock.Now())
+	if left <= 0 {
+		return 0
+	}
+	return int((left + time.Second - 1) / time.Second)
+}
+
+// armLocked replaces the waiter goroutine with one due at willCompleteAt
+func (c *Countdown) armLocked() {
+	c.releaseLocked()
+	c.gen++
+	c.stop = make(chan struct{})
+	go c.wait(c.gen, c.clock.After(c.willCompleteAt.Sub(c.clock.Now())), c.stop)
+}
+
+func (c *Countdown) releaseLocked() {
+	if c.stop != nil {
+		close(c.stop)
+		c.stop = nil
+	}
+}
+
+func (c *Countdown) wait(gen uint64, due <-chan time.Time, stop <-chan struct{}) {
+	select {
+	case <-due:
+	case <-stop:
+		return
+	}
+
+	c.mu.Lock()
+	if gen != c.gen || !c.active {
+		c.mu.Unlock()
+		return
+	}
+	handlers := c.completeLocked()
+	c.mu.Unlock()
+
+	notify(handlers)
+}
+
+func (c *Countdown) completeLocked() []func() {
+	c.active = false
+	c.remainingSeconds = 0
+	c.releaseLocked()
+	return append([]func(){}, c.onComplete...)
+}
+
+func notify(handlers []func()) {
+	for _, fn := range handlers {
+		fn()
+	}
 }
@@ -0,0 +1,163 @@
+package countdown
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+var t0 = time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+// completions counts OnComplete calls and signals each one
+func completions(cd *Countdown) (<-chan struct{}, func() int) {
+	done := make(chan struct{}, 8)
+	var mu sync.Mutex
+	count := 0
+	cd.OnComplete(func() {
+		mu.Lock()
+		count++
+		mu.Unlock()
+		done <- struct{}{}
+	})
+	return done, func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return count
+	}
+}
+
+func waitDone(t *testing.T, done <-chan struct{}) {
+	t.Helper()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("countdown did not complete")
+	}
+}
+
+func expectNotDone(t *testing.T, done <-chan struct{}) {
+	t.Helper()
+	select {
+	case <-done:
+		t.Fatal("countdown completed unexpectedly")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestCountdownCompletesOnClock(t *testing.T) {
+	clock := NewFakeClock(t0)
+	cd := NewWithClock(10, clock)
+	done, count := completions(cd)
+
+	cd.Start()
+	if !cd.StartedAt().Equal(t0) || !cd.WillCompleteAt().Equal(t0.Add(10*time.Second)) {
+		t.Fatalf("window = %v..%v, want %v..%v", cd.StartedAt(), cd.WillCompleteAt(), t0, t0.Add(10*time.Second))
+	}
+
+	clock.Advance(4 * time.Second)
+	if got := cd.Remaining(); got != 6 {
+		t.Errorf("remaining after 4s = %d, want 6", got)
+	}
+	clock.Advance(500 * time.Millisecond)
+	if got := cd.Remaining(); got != 6 {
+		t.Errorf("remaining rounds up: got %d, want 6", got)
+	}
+	expectNotDone(t, done)
+
+	clock.Advance(5500 * time.Millisecond)
+	waitDone(t, done)
+	if cd.IsActive() || cd.Remaining() != 0 {
+		t.Errorf("after completion active=%v remaining=%d", cd.IsActive(), cd.Remaining())
+	}
+
+	clock.Advance(time.Minute)
+	expectNotDone(t, done)
+	if count() != 1 {
+		t.Errorf("completion fired %d times, want 1", count())
+	}
+}
+
+func TestCountdownStopAndReset(t *testing.T) {
+	clock := NewFakeClock(t0)
+	cd := NewWithClock(10, clock)
+	done, _ := completions(cd)
+
+	cd.Start()
+	clock.Advance(3 * time.Second)
+	cd.Stop()
+	if cd.IsActive() || cd.Remaining() != 7 {
+		t.Fatalf("stopped: active=%v remaining=%d, want false/7", cd.IsActive(), cd.Remaining())
+	}
+	clock.Advance(time.Minute)
+	expectNotDone(t, done)
+
+	// Reset of a running countdown restarts the window from now
+	cd.Start()
+	clock.Advance(5 * time.Second)
+	cd.Reset()
+	if !cd.StartedAt().Equal(clock.Now()) || cd.Remaining() != 10 {
+		t.Fatalf("reset: started=%v remaining=%d", cd.StartedAt(), cd.Remaining())
+	}
+	clock.Advance(9 * time.Second)
+	expectNotDone(t, done)
+	clock.Advance(time.Second)
+	waitDone(t, done)
+}
+
+func TestCountdownTickStepsManually(t *testing.T) {
+	clock := NewFakeClock(t0)
+	cd := NewWithClock(3, clock)
+	done, count := completions(cd)
+
+	if cd.Tick() {
+		t.Fatal("tick on inactive countdown completed it")
+	}
+	cd.Start()
+	if cd.Tick() || cd.Tick() {
+		t.Fatal("completed early")
+	}
+	if cd.Remaining() != 1 {
+		t.Fatalf("remaining after 2 ticks = %d, want 1", cd.Remaining())
+	}
+	if !cd.Tick() {
+		t.Fatal("third tick did not complete")
+	}
+	waitDone(t, done)
+
+	clock.Advance(time.Minute)
+	expectNotDone(t, done)
+	if count() != 1 {
+		t.Errorf("completion fired %d times, want 1", count())
+	}
+}
+
+func TestCountdownConcurrentUse(t *testing.T) {
+	clock := NewFakeClock(t0)
+	cd := NewWithClock(5, clock)
+	cd.OnComplete(func() {})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				switch (i + j) % 5 {
+				case 0:
+					cd.Start()
+				case 1:
+					cd.Stop()
+				case 2:
+					cd.Reset()
+				case 3:
+					clock.Advance(time.Second)
+				default:
+					cd.Remaining()
+					cd.IsActive()
+					cd.WillCompleteAt()
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+}
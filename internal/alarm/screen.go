@@ -154,7 +154,8 @@ type ScreenStateManager struct {
 	armedAt           time.Time
 
 	// Configuration
-	countdownTotal int // Total countdown seconds (default 30)
+	countdownTotal   int        // Total countdown seconds (default 30)
+	countdownTotalFn func() int // Optional: total of the running countdown (overrides countdownTotal)
 
 	// Cached state
 	lastEvaluatedMode ScreenMode
@@ -236,6 +237,10 @@ func (s *ScreenStateManager) EvaluateMode() ScreenMode {
 	switch alarmState {
 	case "ARMED":
 		mode = ModeArmed
+	case "ARMING":
+		mode = ModeArming
+	case "PENDING":
+		mode = ModePending
 	case "DISARMED":
 		mode = ModeDisarmed
 	default:
@@ -370,13 +375,19 @@ func (s *ScreenStateManager) buildArmingState(state *ScreenState) {
 	state.Context = "Keep system clear until armed"
 
 	// Build countdown info
+	total := s.countdownTotal
+	if s.countdownTotalFn != nil {
+		if t := s.countdownTotalFn(); t > 0 {
+			total = t
+		}
+	}
 	startedAt := s.countdownStartedAtFn()
-	willCompleteAt := startedAt.Add(time.Duration(s.countdownTotal) * time.Second)
+	willCompleteAt := startedAt.Add(time.Duration(total) * time.Second)
 
 	state.Countdown = &CountdownInfo{
-		TotalSeconds:     s.countdownTotal,
+		TotalSeconds:     total,
 		RemainingSeconds: remaining,
-		Percentage:       (s.countdownTotal - remaining) * 100 / s.countdownTotal,
+		Percentage:       (total - remaining) * 100 / total,
 		StartedAt:        startedAt.UTC().Format(time.RFC3339),
 		WillCompleteAt:   willCompleteAt.UTC().Format(time.RFC3339),
 	}
@@ -491,3 +502,8 @@ func (s *ScreenStateManager) buildBlockedState(state *ScreenState) {
 func (s *ScreenStateManager) SetCountdownTotal(seconds int) {
 	s.countdownTotal = seconds
 }
+
+// SetCountdownTotalFn reads the duration from the running countdown instead of a fixed value
+func (s *ScreenStateManager) SetCountdownTotalFn(fn func() int) {
+	s.countdownTotalFn = fn
+}
@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"smartdisplay-core/internal/auth"
 	"smartdisplay-core/internal/health"
 	"smartdisplay-core/internal/logger"
 	"strconv"
@@ -37,7 +38,18 @@ func (s *Server) startHTTPServer(port int) error {
 	handler := corsDevMiddleware(mux)
 
 	// Wrap with auth middleware (FAZ L1: PIN-based authentication)
-	handler = authMiddleware(handler)
+	devMode := s.runtimeCfg != nil && s.runtimeCfg.DevMode
+	if devMode {
+		logger.Error("WARNING: dev mode enabled (SMARTDISPLAY_DEV_MODE): X-User-Role header grants any role, including admin, to every client")
+	}
+	handler = authMiddleware(handler, devMode)
+
+	// Resolve the client address before auth: PIN lockouts are counted per client
+	trustProxy := s.runtimeCfg != nil && s.runtimeCfg.TrustProxy
+	if trustProxy {
+		logger.Info("trust_proxy enabled: client address taken from X-Forwarded-For / X-Real-IP")
+	}
+	handler = clientIPMiddleware(handler, trustProxy)
 
 	// Wrap with request ID middleware
 	handler = requestIDMiddleware(handler)
@@ -65,77 +77,118 @@ func (s *Server) startHTTPServer(port int) error {
 	return nil
 }
 
+// route binds an API path to its handler and the permission it requires
+type route struct {
+	pattern string
+	perm    auth.Permission // auth.PermPublic = no permission needed
+	handler http.HandlerFunc
+}
+
+// routes is the permission table of the API, in registration order.
+// Every handler is reached through guard; see auth.rolePermissions for the matrix.
+func (s *Server) routes() []route {
+	return []route{
+		// Kullanıcı yönetimi
+		{"/api/users/list", auth.PermUsersManage, s.HandleUserList},
+		{"/api/users/add", auth.PermUsersManage, s.HandleUserAdd},
+		{"/api/users/update", auth.PermUsersManage, s.HandleUserUpdate},
+		{"/api/users/delete", auth.PermUsersManage, s.HandleUserDelete},
+		// Sağlık
+		{"/api/health", auth.PermPublic, health.HealthHandler},
+		{"/health", auth.PermPublic, health.HealthHandler},
+		// Login
+		{"/api/login", auth.PermPublic, s.handleLogin},
+		{"/api/logout", auth.PermPublic, s.handleLogout},
+		// Ana ve alt endpointler
+		{"/api/ui/events", auth.PermPublic, s.handleUIEvents},
+		{"/api/ui/home/state", auth.PermPublic, s.handleHomeState},
+		{"/api/ui/home/summary", auth.PermPublic, s.handleHomeSummary},
+		{"/api/ui/alarm/state", auth.PermPublic, s.handleAlarmState},
+		{"/api/ui/alarm/summary", auth.PermPublic, s.handleAlarmSummary},
+		{"/api/ui/alarm/action", auth.PermPublic, s.handleAlarmAction}, // arm/disarm checked per action
+		{"/api/ui/alarm/commands", auth.PermAlarmRead, s.handleAlarmCommands},
+		{"/api/ui/alarm/commands/", auth.PermAlarmRead, s.handleAlarmCommand},
+		{"/api/ui/alarmo/status", auth.PermPublic, s.handleAlarmoStatus},
+		{"/api/ui/alarmo/sensors", auth.PermPublic, s.handleAlarmoSensors},
+		{"/api/ui/alarmo/events", auth.PermAlarmRead, s.handleAlarmoEvents},
+		{"/api/ui/alarmo/arm", auth.PermAlarmArm, s.handleAlarmoArm},
+		{"/api/ui/alarmo/disarm", auth.PermAlarmDisarm, s.handleAlarmoDisarm},
+		{"/api/ui/guest/state", auth.PermPublic, s.handleGuestState},
+		{"/api/ui/guest/summary", auth.PermPublic, s.handleGuestSummary},
+		{"/api/ui/guest/request", auth.PermGuestRequest, s.handleGuestRequest},
+		{"/api/ui/guest/request/", auth.PermPublic, s.handleGuestRequestStatus}, // Status of the caller's own request
+		{"/api/ui/guest/exit", auth.PermGuestExit, s.handleGuestExit},
+		{"/api/ui/guest/redeem", auth.PermGuestRequest, s.handleGuestRedeem},
+		{"/api/ui/guest/rearm/cancel", auth.PermAlarmDisarm, s.handleGuestRearmCancel},
+		{"/api/ui/menu", auth.PermPublic, s.handleMenu},
+		{"/api/ui/logbook", auth.PermPublic, s.handleLogbook}, // Entries filtered by logbook.read(.safety)
+		{"/api/ui/logbook/summary", auth.PermPublic, s.handleLogbookSummary},
+		{"/api/ui/logbook/export", auth.PermPublic, s.handleLogbookExport},
+		{"/api/ui/settings", auth.PermSettingsRead, s.handleSettings},
+		{"/api/ui/settings/action", auth.PermSettingsWrite, s.handleSettingsAction},
+		{"/api/ui/accessibility", auth.PermPublic, s.handleAccessibility},
+		{"/api/ui/voice", auth.PermPublic, s.handleVoice},
+		{"/api/ai/daily", auth.PermPublic, s.handleAIDaily},
+		{"/api/ai/anomalies", auth.PermPublic, s.handleAIAnomalies},
+		{"/api/ai/morning", auth.PermPublic, s.handleAIMorning},
+		{"/api/ai/insight", auth.PermPublic, s.handleAIInsight},
+		{"/api/ai/insight/explain", auth.PermPublic, s.handleAIExplain},
+		{"/api/ai/history", auth.PermPublic, s.handleAIHistory},
+		{"/api/overview", auth.PermAlarmRead, s.handleOverview},
+		{"/api/alarm/arm", auth.PermAlarmArm, s.handleAlarmArm},
+		{"/api/alarm/disarm", auth.PermAlarmDisarm, s.handleAlarmDisarm},
+		{"/api/guest/approve", auth.PermPublic, s.handleGuestApprove}, // Signed decision token
+		{"/api/guest/deny", auth.PermGuestApprove, s.handleGuestDeny},
+		{"/api/guest/passes", auth.PermGuestPasses, s.handleGuestPasses},
+		{"/api/guest/passes/delete", auth.PermGuestPasses, s.handleGuestPassDelete},
+		{"/api/failsafe", auth.PermPublic, s.handleFailsafe},
+		{"/api/logbook", auth.PermPublic, s.handleLogbook},
+		{"/api/setup/firstboot/status", auth.PermPublic, s.handleFirstBootStatus},
+		{"/api/setup/firstboot/next", auth.PermPublic, s.handleFirstBootNext},
+		{"/api/setup/firstboot/back", auth.PermPublic, s.handleFirstBootBack},
+		{"/api/setup/firstboot/complete", auth.PermPublic, s.handleFirstBootComplete},
+		{"/api/ui/help", auth.PermPublic, s.handleUIHelp},
+		{"/api/ui/scorecard", auth.PermPublic, s.handleUIScorecard},
+		// Admin ve ayar endpointleri
+		{"/api/admin/smoke", auth.PermSystemAdmin, s.handleAdminSmoke},
+		{"/api/admin/audit", auth.PermAuditRead, s.handleAdminAudit},
+		{"/api/admin/audit/verify", auth.PermAuditRead, s.handleAdminAuditVerify},
+		{"/api/admin/restart", auth.PermSystemAdmin, s.handleAdminRestart},
+		{"/api/admin/backup", auth.PermSystemAdmin, s.handleAdminBackup},
+		{"/api/admin/restore", auth.PermSystemAdmin, s.handleAdminRestore},
+		{"/api/admin/telemetry/summary", auth.PermSystemAdmin, s.handleTelemetrySummary},
+		{"/api/admin/telemetry/optin", auth.PermSystemAdmin, s.handleTelemetryOptIn},
+		{"/api/admin/update/status", auth.PermSystemAdmin, s.handleUpdateStatus},
+		{"/api/admin/update/stage", auth.PermSystemAdmin, s.handleUpdateStage},
+		{"/api/settings/homeassistant", auth.PermSettingsWrite, s.handleHASettingsSave},
+		{"/api/settings/homeassistant/status", auth.PermSettingsRead, s.handleHASettingsStatus},
+		{"/api/settings/homeassistant/test", auth.PermSettingsWrite, s.handleHASettingsTest},
+		{"/api/settings/homeassistant/sync", auth.PermSettingsWrite, s.handleHAInitialSync},
+		{"/api/devices/lights", auth.PermLightsRead, s.handleDevicesLights},
+		{"/api/devices/lights/toggle", auth.PermLightsControl, s.handleDevicesLightsToggle},
+		{"/api/devices/lights/set", auth.PermLightsControl, s.handleDevicesLightsSet},
+	}
+}
+
+// guard runs h only if checkPerm allows the caller
+func (s *Server) guard(perm auth.Permission, h http.HandlerFunc) http.HandlerFunc {
+	if perm == auth.PermPublic {
+		return h
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		if _, allowed := s.checkPerm(w, r, perm); !allowed {
+			return
+		}
+		h(w, r)
+	}
+}
+
 // registerRoutes sets up all HTTP routes in deterministic order
 func (s *Server) registerRoutes() *http.ServeMux {
 	mux := http.NewServeMux()
-	// Kullanıcı yönetimi
-	mux.HandleFunc("/api/users/list", s.HandleUserList)
-	mux.HandleFunc("/api/users/add", s.HandleUserAdd)
-	mux.HandleFunc("/api/users/update", s.HandleUserUpdate)
-	mux.HandleFunc("/api/users/delete", s.HandleUserDelete)
-	// Sağlık
-	mux.HandleFunc("/api/health", health.HealthHandler)
-	mux.HandleFunc("/health", health.HealthHandler)
-	// Login
-	mux.HandleFunc("/api/login", s.handleLogin)
-	// Ana ve alt endpointler
-	mux.HandleFunc("/api/ui/home/state", s.handleHomeState)
-	mux.HandleFunc("/api/ui/home/summary", s.handleHomeSummary)
-	mux.HandleFunc("/api/ui/alarm/state", s.handleAlarmState)
-	mux.HandleFunc("/api/ui/alarm/summary", s.handleAlarmSummary)
-	mux.HandleFunc("/api/ui/alarm/action", s.handleAlarmAction)
-	mux.HandleFunc("/api/ui/alarmo/status", s.handleAlarmoStatus)
-	mux.HandleFunc("/api/ui/alarmo/sensors", s.handleAlarmoSensors)
-	mux.HandleFunc("/api/ui/alarmo/events", s.handleAlarmoEvents)
-	mux.HandleFunc("/api/ui/alarmo/arm", s.handleAlarmoArm)
-	mux.HandleFunc("/api/ui/alarmo/disarm", s.handleAlarmoDisarm)
-	mux.HandleFunc("/api/ui/guest/state", s.handleGuestState)
-	mux.HandleFunc("/api/ui/guest/summary", s.handleGuestSummary)
-	mux.HandleFunc("/api/ui/guest/request", s.handleGuestRequest)
-	mux.HandleFunc("/api/ui/guest/request/", s.handleGuestRequestStatus)
-	mux.HandleFunc("/api/ui/guest/exit", s.handleGuestExit)
-	mux.HandleFunc("/api/ui/menu", s.handleMenu)
-	mux.HandleFunc("/api/ui/logbook", s.handleLogbook)
-	mux.HandleFunc("/api/ui/logbook/summary", s.handleLogbookSummary)
-	mux.HandleFunc("/api/ui/settings", s.handleSettings)
-	mux.HandleFunc("/api/ui/settings/action", s.handleSettingsAction)
-	mux.HandleFunc("/api/ui/accessibility", s.handleAccessibility)
-	mux.HandleFunc("/api/ui/voice", s.handleVoice)
-	mux.HandleFunc("/api/ai/daily", s.handleAIDaily)
-	mux.HandleFunc("/api/ai/anomalies", s.handleAIAnomalies)
-	mux.HandleFunc("/api/ai/morning", s.handleAIMorning)
-	mux.HandleFunc("/api/ai/insight", s.handleAIInsight)
-	mux.HandleFunc("/api/ai/insight/explain", s.handleAIExplain)
-	mux.HandleFunc("/api/ai/history", s.handleAIHistory)
-	mux.HandleFunc("/api/overview", s.handleOverview)
-	mux.HandleFunc("/api/alarm/arm", s.handleAlarmArm)
-	mux.HandleFunc("/api/alarm/disarm", s.handleAlarmDisarm)
-	mux.HandleFunc("/api/guest/approve", s.handleGuestApprove)
-	mux.HandleFunc("/api/guest/deny", s.handleGuestDeny)
-	mux.HandleFunc("/api/failsafe", s.handleFailsafe)
-	mux.HandleFunc("/api/logbook", s.handleLogbook)
-	mux.HandleFunc("/api/setup/firstboot/status", s.handleFirstBootStatus)
-	mux.HandleFunc("/api/setup/firstboot/next", s.handleFirstBootNext)
-	mux.HandleFunc("/api/setup/firstboot/back", s.handleFirstBootBack)
-	mux.HandleFunc("/api/setup/firstboot/complete", s.handleFirstBootComplete)
-	mux.HandleFunc("/api/ui/help", s.handleUIHelp)
-	mux.HandleFunc("/api/ui/scorecard", s.handleUIScorecard)
-	// Admin ve ayar endpointleri
-	mux.HandleFunc("/api/admin/smoke", s.handleAdminSmoke)
-	mux.HandleFunc("/api/admin/restart", s.handleAdminRestart)
-	mux.HandleFunc("/api/admin/backup", s.handleAdminBackup)
-	mux.HandleFunc("/api/admin/restore", s.handleAdminRestore)
-	mux.HandleFunc("/api/admin/telemetry/summary", s.handleTelemetrySummary)
-	mux.HandleFunc("/api/admin/telemetry/optin", s.handleTelemetryOptIn)
-	mux.HandleFunc("/api/admin/update/status", s.handleUpdateStatus)
-	mux.HandleFunc("/api/admin/update/stage", s.handleUpdateStage)
-	mux.HandleFunc("/api/settings/homeassistant", s.handleHASettingsSave)
-	mux.HandleFunc("/api/settings/homeassistant/status", s.handleHASettingsStatus)
-	mux.HandleFunc("/api/settings/homeassistant/test", s.handleHASettingsTest)
-	mux.HandleFunc("/api/settings/homeassistant/sync", s.handleHAInitialSync)
-	mux.HandleFunc("/api/devices/lights", s.handleDevicesLights)
-	mux.HandleFunc("/api/devices/lights/toggle", s.handleDevicesLightsToggle)
-	mux.HandleFunc("/api/devices/lights/set", s.handleDevicesLightsSet)
+	for _, rt := range s.routes() {
+		mux.HandleFunc(rt.pattern, s.guard(rt.perm, rt.handler))
+	}
 
 	// Static file handler (EN SONDA ve sadece bir kez)
 	webDir := filepath.Join(os.Getenv("PWD"), "web")
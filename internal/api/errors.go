@@ -18,6 +18,7 @@ const (
 	CodeNotFound           ErrorCode = "not_found"           // 404
 	CodeMethodNotAllowed   ErrorCode = "method_not_allowed"  // 405
 	CodeConflict           ErrorCode = "conflict"            // 409
+	CodeTooManyRequests    ErrorCode = "too_many_requests"   // 429
 	CodeInternalError      ErrorCode = "internal_error"      // 500
 	CodeUpstreamError      ErrorCode = "upstream_error"      // 502
 	CodeServiceUnavailable ErrorCode = "service_unavailable" // 503
@@ -46,6 +47,8 @@ func (e ErrorCode) StatusCode() int {
 		return http.StatusMethodNotAllowed
 	case CodeConflict:
 		return http.StatusConflict
+	case CodeTooManyRequests:
+		return http.StatusTooManyRequests
 	case CodeUpstreamError:
 		return http.StatusBadGateway
 	case CodeInternalError:
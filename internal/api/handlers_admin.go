@@ -1,21 +1,18 @@
 package api
 
 import (
+	"fmt"
 	"net/http"
 	"os"
 	"smartdisplay-core/internal/audit"
-	"smartdisplay-core/internal/auth"
+	"smartdisplay-core/internal/logger"
+	"strconv"
 	"syscall"
 	"time"
 )
 
-// handleAdminSmoke runs self-check and returns summary (admin-only)
+// handleAdminSmoke runs self-check and returns summary (system.admin)
 func (s *Server) handleAdminSmoke(w http.ResponseWriter, r *http.Request) {
-	role := getRole(r)
-	if role != auth.Admin {
-		s.respondError(w, r, CodeForbidden, "admin required")
-		return
-	}
 	if r.Method != "POST" {
 		s.respondError(w, r, CodeMethodNotAllowed, "POST required")
 		return
@@ -28,17 +25,12 @@ func (s *Server) handleAdminSmoke(w http.ResponseWriter, r *http.Request) {
 		"hardware":     res.Hardware,
 		"details":      res.Details,
 	}
-	audit.Record("smoke_test", "admin ran smoke test")
+	audit.RecordAs(auditActor(r), "smoke_test", "admin ran smoke test")
 	s.respond(w, true, summary, "", 200)
 }
 
-// handleAdminRestart triggers graceful shutdown and exits with code 42 (admin-only)
+// handleAdminRestart triggers graceful shutdown and exits with code 42 (system.admin)
 func (s *Server) handleAdminRestart(w http.ResponseWriter, r *http.Request) {
-	role := getRole(r)
-	if role != auth.Admin {
-		s.respondError(w, r, CodeForbidden, "admin required")
-		return
-	}
 	go func() {
 		// Give response before shutting down
 		time.Sleep(200 * time.Millisecond)
@@ -51,3 +43,70 @@ func (s *Server) handleAdminRestart(w http.ResponseWriter, r *http.Request) {
 	}()
 	s.respond(w, true, map[string]string{"result": "restarting"}, "", 200)
 }
+
+// handleAdminAudit queries the audit trail, newest first (audit.read)
+// Query: action, user, role, since, until (RFC 3339), limit (default 100), offset
+func (s *Server) handleAdminAudit(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		s.respondError(w, r, CodeMethodNotAllowed, "GET required")
+		return
+	}
+
+	params := r.URL.Query()
+	q := audit.Query{
+		Action: params.Get("action"),
+		User:   params.Get("user"),
+		Role:   params.Get("role"),
+		Limit:  100,
+	}
+	for name, dst := range map[string]*time.Time{"since": &q.Since, "until": &q.Until} {
+		if raw := params.Get(name); raw != "" {
+			t, err := time.Parse(time.RFC3339, raw)
+			if err != nil {
+				s.respondError(w, r, CodeBadRequest, name+" must be RFC 3339")
+				return
+			}
+			*dst = t
+		}
+	}
+	for name, dst := range map[string]*int{"limit": &q.Limit, "offset": &q.Offset} {
+		if raw := params.Get(name); raw != "" {
+			n, err := strconv.Atoi(raw)
+			if err != nil || n < 0 {
+				s.respondError(w, r, CodeBadRequest, name+" must be a non-negative integer")
+				return
+			}
+			*dst = n
+		}
+	}
+
+	entries, total, err := audit.Search(q)
+	if err != nil {
+		s.respondError(w, r, CodeInternalError, "audit trail unreadable")
+		return
+	}
+	s.respond(w, true, map[string]interface{}{
+		"entries": entries,
+		"total":   total,
+		"limit":   q.Limit,
+		"offset":  q.Offset,
+	}, "", 200)
+}
+
+// handleAdminAuditVerify checks the audit hash chain (audit.read)
+func (s *Server) handleAdminAuditVerify(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		s.respondError(w, r, CodeMethodNotAllowed, "GET required")
+		return
+	}
+
+	res, err := audit.VerifyChain()
+	if err != nil {
+		s.respondError(w, r, CodeInternalError, "audit trail unreadable")
+		return
+	}
+	if !res.OK {
+		logger.Error(fmt.Sprintf("audit: chain broken at seq %d: %s", res.BrokenAt, res.Reason))
+	}
+	s.respond(w, true, res, "", 200)
+}
@@ -7,18 +7,12 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
-	"smartdisplay-core/internal/auth"
 	"smartdisplay-core/internal/logger"
 	"strings"
 )
 
-// handleAdminBackup streams a zip of config/runtime files (admin-only)
+// handleAdminBackup streams a zip of config/runtime files (system.admin)
 func (s *Server) handleAdminBackup(w http.ResponseWriter, r *http.Request) {
-	role := getRole(r)
-	if role != auth.Admin {
-		s.respondError(w, r, CodeForbidden, "admin required")
-		return
-	}
 	w.Header().Set("Content-Type", "application/zip")
 	w.Header().Set("Content-Disposition", "attachment; filename=backup.zip")
 	zw := zip.NewWriter(w)
@@ -45,13 +39,8 @@ func (s *Server) handleAdminBackup(w http.ResponseWriter, r *http.Request) {
 	zw.Close()
 }
 
-// handleAdminRestore accepts a zip, validates, and atomically restores config/runtime files (admin-only)
+// handleAdminRestore accepts a zip, validates, and atomically restores config/runtime files (system.admin)
 func (s *Server) handleAdminRestore(w http.ResponseWriter, r *http.Request) {
-	role := getRole(r)
-	if role != auth.Admin {
-		s.respondError(w, r, CodeForbidden, "admin required")
-		return
-	}
 	if r.Method != "POST" {
 		s.respondError(w, r, CodeMethodNotAllowed, "POST required")
 		return
@@ -0,0 +1,404 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"smartdisplay-core/internal/alarm"
+	"smartdisplay-core/internal/auth"
+	"smartdisplay-core/internal/eventbus"
+	"smartdisplay-core/internal/logger"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// === UI EVENT STREAM (SSE) ===
+// /api/ui/events pushes home state, alarm mode, countdown ticks, guest request
+// changes and failsafe transitions. Payloads are recomputed on every bus event
+// and once per second; a kind is only sent again when its JSON changes.
+
+// Stream event kinds
+const (
+	streamHomeState    = "home_state"
+	streamAlarmMode    = "alarm_mode"
+	streamCountdown    = "countdown"
+	streamGuestRequest = "guest_request"
+	streamFailsafe     = "failsafe"
+)
+
+const (
+	streamHistory   = 256  // Events kept for Last-Event-ID resume
+	streamClientBuf = 32   // Per-client queue; a full queue drops the client
+	streamRetryMs   = 3000 // Reconnect hint sent to EventSource
+)
+
+var (
+	streamRefreshInterval   = time.Second
+	streamHeartbeatInterval = 15 * time.Second
+)
+
+// streamEvent is one SSE message. GuestData is the variant sent to guests;
+// nil hides the event from them.
+type streamEvent struct {
+	ID        uint64
+	Kind      string
+	Data      []byte
+	GuestData []byte
+}
+
+// dataFor returns the payload visible to role (nil = not visible)
+func (e streamEvent) dataFor(role auth.Role) []byte {
+	if role == auth.Guest {
+		return e.GuestData
+	}
+	return e.Data
+}
+
+type streamClient struct {
+	role auth.Role
+	ch   chan streamEvent
+}
+
+// uiStream keeps the last event per kind, a replay ring and the connected clients
+type uiStream struct {
+	refreshMu sync.Mutex // Serializes refreshes so older snapshots never overtake newer ones
+	startOnce sync.Once
+
+	mu      sync.Mutex
+	nextID  uint64
+	latest  map[string]streamEvent
+	history []streamEvent
+	clients map[*streamClient]struct{}
+}
+
+func newUIStream() *uiStream {
+	return &uiStream{
+		latest:  make(map[string]streamEvent),
+		clients: make(map[*streamClient]struct{}),
+	}
+}
+
+// publish records a changed payload and fans it out; unchanged payloads are ignored
+func (h *uiStream) publish(kind string, data, guestData []byte) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if prev, ok := h.latest[kind]; ok && bytes.Equal(prev.Data, data) && bytes.Equal(prev.GuestData, guestData) {
+		return
+	}
+	h.nextID++
+	ev := streamEvent{ID: h.nextID, Kind: kind, Data: data, GuestData: guestData}
+	h.latest[kind] = ev
+	h.history = append(h.history, ev)
+	if len(h.history) > streamHistory {
+		h.history = h.history[len(h.history)-streamHistory:]
+	}
+
+	for c := range h.clients {
+		select {
+		case c.ch <- ev:
+		default:
+			// Slow client: drop it, EventSource reconnects and resumes from its last ID
+			delete(h.clients, c)
+			close(c.ch)
+			logger.Error("ui stream: client queue full, dropping connection")
+		}
+	}
+}
+
+// subscribe registers a client and returns what it has to catch up on:
+// the missed events when lastID is still in the replay ring, otherwise the
+// latest event of every kind
+func (h *uiStream) subscribe(role auth.Role, lastID string) (*streamClient, []streamEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	c := &streamClient{role: role, ch: make(chan streamEvent, streamClientBuf)}
+	h.clients[c] = struct{}{}
+
+	if last, err := strconv.ParseUint(lastID, 10, 64); err == nil && h.canResume(last) {
+		var backlog []streamEvent
+		for _, ev := range h.history {
+			if ev.ID > last {
+				backlog = append(backlog, ev)
+			}
+		}
+		return c, backlog
+	}
+
+	backlog := make([]streamEvent, 0, len(h.latest))
+	for _, ev := range h.latest {
+		backlog = append(backlog, ev)
+	}
+	sort.Slice(backlog, func(i, j int) bool { return backlog[i].ID < backlog[j].ID })
+	return c, backlog
+}
+
+// canResume reports whether every event after last is still in the ring.
+// IDs from a previous process (last > nextID) cannot be resumed.
+func (h *uiStream) canResume(last uint64) bool {
+	if last > h.nextID {
+		return false
+	}
+	return len(h.history) == 0 || h.history[0].ID <= last+1
+}
+
+func (h *uiStream) unsubscribe(c *streamClient) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.clients[c]; ok {
+		delete(h.clients, c)
+		close(c.ch)
+	}
+}
+
+// publishJSON marshals both variants; a nil guest payload hides the kind from guests
+func (h *uiStream) publishJSON(kind string, payload, guestPayload interface{}) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		logger.Error("ui stream: marshal " + kind + ": " + err.Error())
+		return
+	}
+	var guestData []byte
+	if guestPayload != nil {
+		if guestData, err = json.Marshal(guestPayload); err != nil {
+			logger.Error("ui stream: marshal " + kind + ": " + err.Error())
+			return
+		}
+	}
+	h.publish(kind, data, guestData)
+}
+
+// writeStreamEvent writes ev in SSE framing if role may see it
+func writeStreamEvent(w http.ResponseWriter, ev streamEvent, role auth.Role) error {
+	data := ev.dataFor(role)
+	if data == nil {
+		return nil
+	}
+	_, err := fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", ev.ID, ev.Kind, data)
+	return err
+}
+
+// startStream launches the refresh loop on first use
+func (s *Server) startStream() {
+	s.stream.startOnce.Do(func() {
+		s.refreshStream()
+		go s.runStream()
+	})
+}
+
+// runStream refreshes payloads after bus events and on a ticker (countdown ticks)
+func (s *Server) runStream() {
+	trigger := make(chan struct{}, 1)
+	if s.coord.Events != nil {
+		sub := s.coord.Events.Subscribe("ui-stream", func(eventbus.Event) {
+			select {
+			case trigger <- struct{}{}:
+			default:
+			}
+		}, eventbus.Buffer(8), eventbus.Policy(eventbus.DropOldest))
+		defer sub.Unsubscribe()
+	}
+
+	ticker := time.NewTicker(streamRefreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.shutdownCtx.Done():
+			return
+		case <-trigger:
+		case <-ticker.C:
+		}
+		s.refreshStream()
+	}
+}
+
+// refreshStream recomputes every stream payload
+func (s *Server) refreshStream() {
+	s.stream.refreshMu.Lock()
+	defer s.stream.refreshMu.Unlock()
+
+	if s.coord.Home != nil {
+		home := s.coord.Home.GetStateResponse()
+		home.Summary.CurrentTime = "" // Would change every second; clients have a clock
+		s.stream.publishJSON(streamHomeState, home, home)
+	}
+
+	alarmMode := s.streamAlarmMode()
+	s.stream.publishJSON(streamAlarmMode, alarmMode, alarmMode)
+
+	cd := s.streamCountdown()
+	s.stream.publishJSON(streamCountdown, cd, cd)
+
+	if s.coord.GuestRequest != nil {
+		full, guestView := s.streamGuestRequest()
+		s.stream.publishJSON(streamGuestRequest, full, guestView)
+	}
+
+	failsafe := map[string]interface{}{
+		"active":      s.coord.InFailsafeMode(),
+		"explanation": s.coord.FailsafeExplanation(),
+	}
+	s.stream.publishJSON(streamFailsafe, failsafe, failsafe)
+}
+
+// streamAlarmMode mirrors handleAlarmState: Alarmo mode wins over the local machine (A2)
+func (s *Server) streamAlarmMode() map[string]interface{} {
+	s.coord.AlarmoMu.RLock()
+	alarmoState := s.coord.AlarmoState
+	s.coord.AlarmoMu.RUnlock()
+
+	out := map[string]interface{}{
+		"alarmo_state": alarmoState.Mode,
+		"triggered":    alarmoState.Triggered,
+	}
+	if s.coord.AlarmScreen != nil {
+		out["mode"] = s.coord.AlarmScreen.EvaluateMode()
+	}
+	if mode, ok := alarmoScreenMode(alarmoState.Mode); ok {
+		out["mode"] = mode
+	}
+	if s.coord.Alarm != nil {
+		out["state"] = s.coord.Alarm.CurrentState()
+		out["armed_mode"] = s.coord.Alarm.ArmedMode()
+	}
+	return out
+}
+
+// streamCountdown reports the running exit/entry countdown; the local countdown
+// wins, otherwise the delay reported by Alarmo is used
+func (s *Server) streamCountdown() map[string]interface{} {
+	if s.coord.Alarm != nil {
+		if cd := s.coord.Alarm.Countdown(); cd != nil && cd.IsActive() {
+			delayType := "exit"
+			if s.coord.Alarm.CurrentState() == alarm.PENDING {
+				delayType = "entry"
+			}
+			return map[string]interface{}{
+				"active":           true,
+				"source":           "local",
+				"type":             delayType,
+				"remaining":        cd.Remaining(),
+				"total":            cd.Duration(),
+				"will_complete_at": cd.WillCompleteAt().UTC().Format(time.RFC3339),
+			}
+		}
+	}
+
+	s.coord.AlarmoMu.RLock()
+	alarmoState := s.coord.AlarmoState
+	s.coord.AlarmoMu.RUnlock()
+	if alarmoState.DelayRemaining > 0 {
+		return map[string]interface{}{
+			"active":    true,
+			"source":    "alarmo",
+			"type":      alarmoState.DelayType,
+			"remaining": alarmoState.DelayRemaining,
+		}
+	}
+	return map[string]interface{}{"active": false}
+}
+
+// streamGuestRequest returns the full view and the guest view (without target user)
+// of the newest guest request, with the number of pending requests and active guests
+// and the re-arm counting down after the last guest left
+func (s *Server) streamGuestRequest() (map[string]interface{}, map[string]interface{}) {
+	req, ok := s.coord.GuestRequest.Latest()
+	if !ok {
+		none := map[string]interface{}{"active": false}
+		return none, none
+	}
+	pending, active := s.coord.GuestRequest.Counts()
+	guestView := map[string]interface{}{
+		"active":        true,
+		"request_id":    req.ID,
+		"status":        req.Status,
+		"requested_at":  req.RequestedAt.UTC().Format(time.RFC3339),
+		"expires_at":    req.ExpiresAt.UTC().Format(time.RFC3339),
+		"pending":       pending,
+		"active_guests": active,
+	}
+	if rearm := s.coord.GuestRearmInfo(); rearm != nil {
+		guestView["rearm"] = rearm
+	}
+	full := make(map[string]interface{}, len(guestView)+1)
+	for k, v := range guestView {
+		full[k] = v
+	}
+	full["target_user"] = req.TargetUser
+	return full, guestView
+}
+
+// handleUIEvents streams UI state as Server-Sent Events.
+// Resume with the Last-Event-ID header (or ?last_event_id= for clients that cannot set it).
+func (s *Server) handleUIEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		s.respondError(w, r, CodeMethodNotAllowed, "GET required")
+		return
+	}
+
+	rc := http.NewResponseController(w)
+	// The server WriteTimeout would otherwise cut the stream
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		s.respondError(w, r, CodeInternalError, "streaming not supported")
+		return
+	}
+
+	role := getRole(r)
+	lastID := r.Header.Get("Last-Event-ID")
+	if lastID == "" {
+		lastID = r.URL.Query().Get("last_event_id")
+	}
+
+	s.startStream()
+	client, backlog := s.stream.subscribe(role, lastID)
+	defer s.stream.unsubscribe(client)
+
+	h := w.Header()
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("Cache-Control", "no-cache")
+	h.Set("Connection", "keep-alive")
+	h.Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	fmt.Fprintf(w, "retry: %d\n\n", streamRetryMs)
+	for _, ev := range backlog {
+		if err := writeStreamEvent(w, ev, role); err != nil {
+			return
+		}
+	}
+	if err := rc.Flush(); err != nil {
+		logger.Error("ui stream: flush not supported: " + err.Error())
+		return
+	}
+	logger.Info(fmt.Sprintf("ui stream: client connected role=%s resume=%q backlog=%d", role, lastID, len(backlog)))
+
+	heartbeat := time.NewTicker(streamHeartbeatInterval)
+	defer heartbeat.Stop()
+	for {
+		var err error
+		select {
+		case <-r.Context().Done():
+			return
+		case <-s.shutdownCtx.Done():
+			return
+		case ev, ok := <-client.ch:
+			if !ok {
+				return // Dropped as too slow
+			}
+			err = writeStreamEvent(w, ev, role)
+		case <-heartbeat.C:
+			_, err = fmt.Fprint(w, ": heartbeat\n\n")
+		}
+		if err == nil {
+			err = rc.Flush()
+		}
+		if err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package api
+
+import (
+	"bufio"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"smartdisplay-core/internal/auth"
+)
+
+func TestUIStreamResumeAndRoleFiltering(t *testing.T) {
+	h := newUIStream()
+	h.publish(streamFailsafe, []byte(`{"active":false}`), []byte(`{"active":false}`))
+	h.publish(streamGuestRequest, []byte(`{"target_user":"ali"}`), []byte(`{}`))
+	h.publish(streamFailsafe, []byte(`{"active":false}`), []byte(`{"active":false}`)) // Unchanged: no new ID
+	h.publish(streamFailsafe, []byte(`{"active":true}`), []byte(`{"active":true}`))
+
+	// Fresh client gets the latest event per kind, in ID order
+	c, backlog := h.subscribe(auth.UserRole, "")
+	if len(backlog) != 2 || backlog[0].ID != 2 || backlog[1].ID != 3 {
+		t.Fatalf("snapshot backlog = %+v", backlog)
+	}
+	h.unsubscribe(c)
+
+	// Resume replays only what was missed
+	c, backlog = h.subscribe(auth.Guest, "1")
+	if len(backlog) != 2 || backlog[0].Kind != streamGuestRequest || string(backlog[0].dataFor(auth.Guest)) != `{}` {
+		t.Fatalf("resume backlog = %+v", backlog)
+	}
+	h.publish(streamHomeState, []byte(`{"state":"idle"}`), nil)
+	select {
+	case ev := <-c.ch:
+		if ev.ID != 4 || ev.dataFor(auth.Guest) != nil || ev.dataFor(auth.Admin) == nil {
+			t.Fatalf("live event = %+v", ev)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("live event not delivered")
+	}
+	h.unsubscribe(c)
+
+	// An ID from a previous process falls back to the snapshot
+	_, backlog = h.subscribe(auth.Admin, "999")
+	if len(backlog) != 3 {
+		t.Fatalf("unknown ID backlog has %d events, want 3", len(backlog))
+	}
+}
+
+func TestUIEventsEndpointStreamsSnapshot(t *testing.T) {
+	ts := startTestServer(t, TestConfig{WizardCompleted: true})
+	defer ts.Shutdown()
+
+	resp, err := http.Get(ts.URL + "/api/ui/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("content type = %q", ct)
+	}
+
+	seen := map[string]bool{}
+	lines := make(chan string, 64)
+	go func() {
+		sc := bufio.NewScanner(resp.Body)
+		for sc.Scan() {
+			lines <- sc.Text()
+		}
+		close(lines)
+	}()
+	deadline := time.After(3 * time.Second)
+	for len(seen) < 5 {
+		select {
+		case line, ok := <-lines:
+			if !ok {
+				t.Fatalf("stream closed, saw %v", seen)
+			}
+			if kind, found := strings.CutPrefix(line, "event: "); found {
+				seen[kind] = true
+			}
+		case <-deadline:
+			t.Fatalf("snapshot incomplete, saw %v", seen)
+		}
+	}
+	for _, kind := range []string{streamHomeState, streamAlarmMode, streamCountdown, streamGuestRequest, streamFailsafe} {
+		if !seen[kind] {
+			t.Errorf("missing %s event", kind)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"smartdisplay-core/internal/audit"
+	"smartdisplay-core/internal/auth"
+	"smartdisplay-core/internal/guest"
+	"smartdisplay-core/internal/logger"
+	"strconv"
+	"time"
+)
+
+// passCodeLimiter counts wrong pass codes per client, like failed PINs
+var passCodeLimiter = auth.NewLimiter(auth.DefaultLockoutPolicy, nil)
+
+// handleGuestPasses lists (GET) or creates (POST) guest passes (guest.passes)
+// POST body: {"name", "code"?, "card_id"?, "target_user"?, "valid_from", "valid_until",
+// "days"?, "recurring"?, "access_minutes"?}. Without code and card_id a code is generated;
+// the code is returned only in this response.
+func (s *Server) handleGuestPasses(w http.ResponseWriter, r *http.Request) {
+	if s.coord.GuestPasses == nil {
+		s.respondError(w, r, CodeInternalError, "guest passes not initialized")
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		s.respond(w, true, map[string]interface{}{"passes": s.coord.GuestPasses.List()}, "", 200)
+		return
+	case http.MethodPost:
+	default:
+		s.respondError(w, r, CodeMethodNotAllowed, "GET or POST required")
+		return
+	}
+
+	var req struct {
+		guest.Pass
+		Code string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.respondError(w, r, CodeBadRequest, "invalid json")
+		return
+	}
+	if req.Code != "" && len(req.Code) < guest.MinPassCodeLength {
+		s.respondError(w, r, CodeBadRequest, fmt.Sprintf("code must have at least %d characters", guest.MinPassCodeLength))
+		return
+	}
+	pass := req.Pass
+	pass.CodeHash = ""
+	pass.CreatedBy = getAuthContext(r).Username
+	code := req.Code
+	if code == "" && pass.CardID == "" {
+		var err error
+		if code, err = guest.NewPassCode(); err != nil {
+			s.respondError(w, r, CodeInternalError, "code generation failed")
+			return
+		}
+	}
+
+	created, err := s.coord.GuestPasses.Add(pass, code)
+	if err != nil {
+		s.respondError(w, r, CodeBadRequest, err.Error())
+		return
+	}
+	audit.RecordAs(auditActor(r), "guest_pass_create", "id="+created.ID+" name="+created.Name)
+	created.CodeHash = ""
+	s.respond(w, true, map[string]interface{}{"pass": created, "code": code}, "", 200)
+}
+
+// handleGuestPassDelete revokes a guest pass and ends access already given with it (guest.passes)
+// POST body: {"id": "..."}
+func (s *Server) handleGuestPassDelete(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		s.respondError(w, r, CodeMethodNotAllowed, "POST required")
+		return
+	}
+	var req struct {
+		ID string `json:"id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.ID == "" {
+		s.respondError(w, r, CodeBadRequest, "id required")
+		return
+	}
+	if err := s.coord.RemoveGuestPass(req.ID); err != nil {
+		if errors.Is(err, guest.ErrPassNotFound) {
+			s.respondError(w, r, CodeNotFound, err.Error())
+			return
+		}
+		s.respondError(w, r, CodeInternalError, err.Error())
+		return
+	}
+	audit.RecordAs(auditActor(r), "guest_pass_delete", "id="+req.ID)
+	s.respond(w, true, map[string]string{"result": "ok"}, "", 200)
+}
+
+// handleGuestRedeem admits a guest with a pass code typed at the display
+// POST /api/ui/guest/redeem
+// Body: {"code": "123456"}
+func (s *Server) handleGuestRedeem(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		s.respondError(w, r, CodeMethodNotAllowed, "POST required")
+		return
+	}
+	var req struct {
+		Code string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Code == "" {
+		s.respondError(w, r, CodeBadRequest, "code required")
+		return
+	}
+
+	client := clientKey(r)
+	if err := passCodeLimiter.Check(client); err != nil {
+		var lockout *auth.LockoutError
+		if errors.As(err, &lockout) {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(lockout.RetryAfter.Seconds()))))
+		}
+		s.respondError(w, r, CodeTooManyRequests, "too many wrong codes, try again later")
+		return
+	}
+
+	guestReq, err := s.coord.RedeemGuestPass(req.Code, "", client)
+	switch {
+	case err == nil:
+	case errors.Is(err, guest.ErrPassNotFound):
+		if lockout := passCodeLimiter.Failure(client); lockout != nil {
+			logger.Error("guest pass code lockout: client=" + client)
+			audit.Record("guest_pass_lockout", "client="+client)
+		}
+		s.respondError(w, r, CodeForbidden, "invalid code")
+		return
+	case errors.Is(err, guest.ErrPassInvalid):
+		s.respondError(w, r, CodeForbidden, err.Error())
+		return
+	case errors.Is(err, guest.ErrGuestLimit):
+		s.respondError(w, r, CodeConflict, err.Error())
+		return
+	default:
+		s.respondError(w, r, CodeBadRequest, err.Error())
+		return
+	}
+	passCodeLimiter.Success(client)
+
+	s.respond(w, true, map[string]interface{}{
+		"request_id": guestReq.ID,
+		"status":     guestReq.Status,
+		"guest_name": guestReq.GuestName,
+		"expires_at": guestReq.ExpiresAt.UTC().Format(time.RFC3339),
+	}, "", 200)
+}
@@ -6,7 +6,6 @@ import (
 	"net/http"
 	"net/url"
 	"smartdisplay-core/internal/audit"
-	"smartdisplay-core/internal/auth"
 	"smartdisplay-core/internal/ha/alarmo"
 	"smartdisplay-core/internal/logger"
 	"smartdisplay-core/internal/security"
@@ -15,19 +14,11 @@ import (
 	"time"
 )
 
-// handleHASettingsSave saves Home Assistant credentials securely (admin-only).
+// handleHASettingsSave saves Home Assistant credentials securely (settings.write).
 // POST /api/settings/homeassistant
 // FAZ S2: Accept server address and token, encrypt token, persist.
 // Response NEVER includes token.
 func (s *Server) handleHASettingsSave(w http.ResponseWriter, r *http.Request) {
-	// Check admin role
-	role := getRole(r)
-	if role != auth.Admin {
-		logger.Error("HA credentials save blocked: insufficient role=" + string(role))
-		s.respondError(w, r, CodeForbidden, "admin required")
-		return
-	}
-
 	if r.Method != http.MethodPost {
 		s.respondError(w, r, CodeMethodNotAllowed, "POST required")
 		return
@@ -75,7 +66,7 @@ func (s *Server) handleHASettingsSave(w http.ResponseWriter, r *http.Request) {
 		logger.Error("HA credentials encryption failed: " + err.Error())
 		// Don't log the token or encrypted token
 		s.respondError(w, r, CodeInternalError, "failed to encrypt token")
-		audit.Record("ha_config_error", "failed to encrypt token")
+		audit.RecordAs(auditActor(r), "ha_config_error", "failed to encrypt token")
 		return
 	}
 
@@ -90,13 +81,13 @@ func (s *Server) handleHASettingsSave(w http.ResponseWriter, r *http.Request) {
 	if err := settings.SaveHAConfig(cfg); err != nil {
 		logger.Error("HA config save failed: " + err.Error())
 		s.respondError(w, r, CodeInternalError, "failed to save configuration")
-		audit.Record("ha_config_error", "failed to save configuration")
+		audit.RecordAs(auditActor(r), "ha_config_error", "failed to save configuration")
 		return
 	}
 
 	// Log event WITHOUT token
 	logger.Info("HA configuration updated: server_url=" + cleanURL)
-	audit.Record("ha_config_updated", "server_url="+cleanURL+", token="+security.Redact(req.Token))
+	audit.RecordAs(auditActor(r), "ha_config_updated", "server_url="+cleanURL+", token="+security.Redact(req.Token))
 
 	// FAZ S4: Reset global connection state when credentials change
 	// New credentials must be tested again before marking as connected
@@ -111,8 +102,13 @@ func (s *Server) handleHASettingsSave(w http.ResponseWriter, r *http.Request) {
 
 	// Reinitialize Alarmo adapter immediately with new credentials to avoid restart requirement
 	if s.coord != nil {
+		newAdapter := alarmo.New(cleanURL, req.Token)
 		s.coord.AlarmoMu.Lock()
-		s.coord.AlarmoAdapter = alarmo.New(cleanURL, req.Token)
+		if s.coord.AlarmoAdapter != nil {
+			// Keep configured Alarmo areas across credential changes
+			_ = newAdapter.SetPanels(s.coord.AlarmoAdapter.Panels())
+		}
+		s.coord.AlarmoAdapter = newAdapter
 		s.coord.AlarmoMu.Unlock()
 		logger.Info("alarmo adapter reinitialized with new HA configuration")
 	}
@@ -127,18 +123,10 @@ func (s *Server) handleHASettingsSave(w http.ResponseWriter, r *http.Request) {
 	}, "", http.StatusOK)
 }
 
-// handleHASettingsStatus returns safe HA status information (admin-only).
+// handleHASettingsStatus returns safe HA status information (settings.read).
 // GET /api/settings/homeassistant/status
 // FAZ S2: Returns is_configured and configured_at, NEVER returns token or server_url.
 func (s *Server) handleHASettingsStatus(w http.ResponseWriter, r *http.Request) {
-	// Check admin role
-	role := getRole(r)
-	if role != auth.Admin {
-		logger.Error("HA status check blocked: insufficient role=" + string(role))
-		s.respondError(w, r, CodeForbidden, "admin required")
-		return
-	}
-
 	if r.Method != http.MethodGet {
 		s.respondError(w, r, CodeMethodNotAllowed, "GET required")
 		return
@@ -195,19 +183,11 @@ func (s *Server) handleHASettingsStatus(w http.ResponseWriter, r *http.Request)
 	s.respond(w, true, response, "", http.StatusOK)
 }
 
-// handleHASettingsTest performs a connection test against Home Assistant (admin-only).
+// handleHASettingsTest performs a connection test against Home Assistant (settings.write).
 // POST /api/settings/homeassistant/test
 // FAZ S3: Connection test engine. Returns success/stage/message, never returns token or URL.
 // Stops on first failure. Updates last_tested_at on success.
 func (s *Server) handleHASettingsTest(w http.ResponseWriter, r *http.Request) {
-	// Check admin role
-	role := getRole(r)
-	if role != auth.Admin {
-		logger.Error("HA test blocked: insufficient role=" + string(role))
-		s.respondError(w, r, CodeForbidden, "admin required")
-		return
-	}
-
 	if r.Method != http.MethodPost {
 		s.respondError(w, r, CodeMethodNotAllowed, "POST required")
 		return
@@ -221,14 +201,14 @@ func (s *Server) handleHASettingsTest(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		logger.Error("HA connection test error: " + err.Error())
 		s.respondError(w, r, CodeInternalError, err.Error())
-		audit.Record("ha_test_error", err.Error())
+		audit.RecordAs(auditActor(r), "ha_test_error", err.Error())
 		return
 	}
 
 	// Log result
 	if result.Success {
 		logger.Info("HA connection test succeeded: stage=" + result.Stage)
-		audit.Record("ha_test_success", "stage="+result.Stage)
+		audit.RecordAs(auditActor(r), "ha_test_success", "stage="+result.Stage)
 
 		// FAZ S4: Update global HA connection state
 		if result.Stage == settings.StageOK {
@@ -243,7 +223,7 @@ func (s *Server) handleHASettingsTest(w http.ResponseWriter, r *http.Request) {
 		}
 	} else {
 		logger.Error("HA connection test failed: stage=" + result.Stage)
-		audit.Record("ha_test_failed", "stage="+result.Stage)
+		audit.RecordAs(auditActor(r), "ha_test_failed", "stage="+result.Stage)
 
 		// FAZ S4: Update global HA connection state
 		// Connection failed - mark as disconnected, but keep last_tested_at for reference
@@ -259,19 +239,11 @@ func (s *Server) handleHASettingsTest(w http.ResponseWriter, r *http.Request) {
 	s.respond(w, true, result, "", http.StatusOK)
 }
 
-// handleHAInitialSync performs a one-time initial HA synchronization (admin-only).
+// handleHAInitialSync performs a one-time initial HA synchronization (settings.write).
 // POST /api/settings/homeassistant/sync
 // FAZ S5: Bootstrap sync that extracts safe metadata, confirms Alarmo, counts entities.
 // Runs ONLY when ha_connected=true AND initial_sync_done=false.
 func (s *Server) handleHAInitialSync(w http.ResponseWriter, r *http.Request) {
-	// Check admin role
-	role := getRole(r)
-	if role != auth.Admin {
-		logger.Error("HA sync blocked: insufficient role=" + string(role))
-		s.respondError(w, r, CodeForbidden, "admin required")
-		return
-	}
-
 	if r.Method != http.MethodPost {
 		s.respondError(w, r, CodeMethodNotAllowed, "POST required")
 		return
@@ -303,14 +275,14 @@ func (s *Server) handleHAInitialSync(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		logger.Error("HA initial sync error: " + err.Error())
 		s.respondError(w, r, CodeInternalError, err.Error())
-		audit.Record("ha_sync_error", err.Error())
+		audit.RecordAs(auditActor(r), "ha_sync_error", err.Error())
 		return
 	}
 
 	// Log result
 	if result.Success {
 		logger.Info("HA initial sync succeeded")
-		audit.Record("ha_sync_success", "entities_total="+
+		audit.RecordAs(auditActor(r), "ha_sync_success", "entities_total="+
 			fmt.Sprintf("%d", result.Counts.Lights+result.Counts.Sensors+result.Counts.Switches+result.Counts.Others))
 
 		// FAZ S5: Update runtime config with sync metadata
@@ -354,7 +326,7 @@ func (s *Server) handleHAInitialSync(w http.ResponseWriter, r *http.Request) {
 		}, "", http.StatusOK)
 	} else {
 		logger.Error("HA initial sync failed: " + result.Message)
-		audit.Record("ha_sync_failed", result.Message)
+		audit.RecordAs(auditActor(r), "ha_sync_failed", result.Message)
 
 		// Failure does NOT change initial_sync_done (remains false)
 		s.respond(w, true, map[string]interface{}{
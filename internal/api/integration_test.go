@@ -15,12 +15,15 @@ import (
 
 	"smartdisplay-core/internal/alarm"
 	"smartdisplay-core/internal/alarm/countdown"
+	"smartdisplay-core/internal/auth"
 	"smartdisplay-core/internal/config"
+	"smartdisplay-core/internal/eventbus"
 	"smartdisplay-core/internal/firstboot"
 	"smartdisplay-core/internal/guest"
 	"smartdisplay-core/internal/ha/alarmo"
 	"smartdisplay-core/internal/hal"
 	"smartdisplay-core/internal/hanotify"
+	"smartdisplay-core/internal/logbook"
 	"smartdisplay-core/internal/logger"
 	"smartdisplay-core/internal/menu"
 	"smartdisplay-core/internal/platform"
@@ -32,6 +35,7 @@ import (
 type TestConfig struct {
 	WizardCompleted bool
 	ReducedMotion   bool
+	ProductionAuth  bool // Dev mode off: X-User-Role is ignored
 }
 
 // TestServer wraps HTTP test server with coordinator and helpers
@@ -66,14 +70,13 @@ func startTestServer(t *testing.T, cfg TestConfig) *TestServer {
 
 	// Initialize subsystems (minimal for testing)
 	alarmSM := alarm.NewStateMachine()
-	guestSM := guest.NewStateMachine()
 	cd := countdown.New(30)
 	notifier := &hanotify.StubNotifier{}
 	halReg := hal.NewRegistry()
 	plat := platform.DetectPlatform()
 
 	// Create coordinator (integrates all subsystems)
-	coord := system.NewCoordinator(alarmSM, guestSM, cd, nil, notifier, halReg, plat, "", "")
+	coord := system.NewCoordinator(alarmSM, cd, nil, notifier, halReg, plat, "", "")
 
 	// Configure first-boot manager according to test config
 	coord.FirstBoot = firstboot.New(cfg.WizardCompleted)
@@ -88,8 +91,8 @@ func startTestServer(t *testing.T, cfg TestConfig) *TestServer {
 				return coord.InFailsafeMode()
 			},
 			func() bool {
-				if coord.Guest != nil {
-					return coord.Guest.HasPendingRequest()
+				if coord.GuestRequest != nil {
+					return coord.GuestRequest.HasPendingRequest()
 				}
 				return false
 			},
@@ -100,9 +103,13 @@ func startTestServer(t *testing.T, cfg TestConfig) *TestServer {
 	// Create API server
 	server := NewServer(coord, runtimeCfg)
 
-	// Build handler manually for testing (without real listen port)
+	// Build handler manually for testing (without real listen port).
+	// Tests pick roles with X-User-Role, so the auth middleware runs in dev mode
+	// unless the test asks for production behaviour.
 	mux := server.registerRoutes()
-	handler := requestIDMiddleware(mux)
+	handler := authMiddleware(mux, !cfg.ProductionAuth)
+	handler = clientIPMiddleware(handler, false)
+	handler = requestIDMiddleware(handler)
 	handler = panicRecovery(handler)
 
 	// Start httptest server with our handler
@@ -141,6 +148,7 @@ func TestMain(m *testing.M) {
 // Shutdown gracefully closes the test server
 func (ts *TestServer) Shutdown() error {
 	ts.Server.Close()
+	ts.Coordinator.Close()
 	return nil
 }
 
@@ -753,6 +761,58 @@ func TestMultipleRoles(t *testing.T) {
 	}
 }
 
+// TestRoleOverrideRequiresDevMode verifies production ignores X-User-Role
+func TestRoleOverrideRequiresDevMode(t *testing.T) {
+	prod := startTestServer(t, TestConfig{WizardCompleted: true, ProductionAuth: true})
+	defer prod.Shutdown()
+	dev := startTestServer(t, TestConfig{WizardCompleted: true})
+	defer dev.Shutdown()
+
+	for _, ts := range []*TestServer{prod, dev} {
+		ts.Coordinator.Logbook.AddEntry(logbook.CategoryAlarm, logbook.AlarmArmed, logbook.SeverityInfo,
+			"Alarm armed", "", logbook.EntryDetail{}, logbook.RoleUser)
+	}
+
+	tests := []struct {
+		name       string
+		path       string
+		prodStatus int
+		devStatus  int
+	}{
+		{"audit trail", "/api/admin/audit", http.StatusForbidden, http.StatusOK},
+		{"settings", "/api/ui/settings", http.StatusForbidden, http.StatusOK},
+		{"telemetry", "/api/admin/telemetry/summary", http.StatusForbidden, http.StatusOK},
+		{"logbook export", "/api/ui/logbook/export?format=jsonl", http.StatusOK, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, c := range []struct {
+				ts   *TestServer
+				want int
+			}{{prod, tt.prodStatus}, {dev, tt.devStatus}} {
+				req := newTestRequest(t, "GET", c.ts.Server.URL+tt.path, "admin")
+				resp, err := http.DefaultClient.Do(req)
+				if err != nil {
+					t.Fatalf("failed to make request: %v", err)
+				}
+				body, _ := io.ReadAll(resp.Body)
+				resp.Body.Close()
+				if resp.StatusCode != c.want {
+					t.Errorf("production=%v: expected status %d, got %d. Body: %s",
+						c.ts == prod, c.want, resp.StatusCode, string(body))
+				}
+				// The export follows the caller's role: a header-only "admin" is a guest in production
+				if tt.name == "logbook export" {
+					lines := strings.Count(string(body), "\n")
+					if c.ts == prod && lines != 0 || c.ts == dev && lines == 0 {
+						t.Errorf("production=%v: export returned %d lines", c.ts == prod, lines)
+					}
+				}
+			}
+		})
+	}
+}
+
 // BenchmarkHealthCheck measures performance of /health endpoint
 func BenchmarkHealthCheck(b *testing.B) {
 	ts := startTestServer(&testing.T{}, TestConfig{
@@ -1871,6 +1931,277 @@ func TestAlarmTriggeredDuringGuestActiveAllowsExit(t *testing.T) {
 	}
 }
 
+func TestGuestApproveRequiresSignedToken(t *testing.T) {
+	ts := startTestServer(t, TestConfig{
+		WizardCompleted: true,
+	})
+	defer ts.Shutdown()
+
+	guestReq, err := ts.Coordinator.GuestRequest.CreateRequest("mobile_app_ayse", "test")
+	if err != nil {
+		t.Fatalf("create guest request: %v", err)
+	}
+	token := ts.Coordinator.GuestSigner.Issue(guestReq.ID, "approve", guestReq.ExpiresAt)
+
+	decide := func(body map[string]string) int {
+		req := newTestRequestWithBody(t, "POST", ts.Server.URL+"/api/guest/approve", "", body)
+		req.Header.Set("Authorization", "Bearer long-lived-token")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("guest approve failed: %v", err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	// A bearer token alone, or the approve token used to reject, is refused
+	if code := decide(map[string]string{"request_id": guestReq.ID, "decision": "approve"}); code != http.StatusForbidden {
+		t.Errorf("decision without token: status %d, want 403", code)
+	}
+	if code := decide(map[string]string{"request_id": guestReq.ID, "decision": "reject", "token": token}); code != http.StatusForbidden {
+		t.Errorf("approve token used to reject: status %d, want 403", code)
+	}
+	if code := decide(map[string]string{"request_id": guestReq.ID, "decision": "approve", "token": token}); code != http.StatusOK {
+		t.Fatalf("signed approval: status %d, want 200", code)
+	}
+	if code := decide(map[string]string{"request_id": guestReq.ID, "decision": "approve", "token": token}); code != http.StatusConflict {
+		t.Errorf("replayed approval: status %d, want 409", code)
+	}
+	if got, _ := ts.Coordinator.GuestRequest.Get(guestReq.ID); got.Status != guest.StatusApproved {
+		t.Errorf("request status = %s, want approved", got.Status)
+	}
+}
+
+func TestGuestDecisionTokenNotReplayableAfterRestart(t *testing.T) {
+	ts := startTestServer(t, TestConfig{
+		WizardCompleted: true,
+	})
+	defer ts.Shutdown()
+
+	keyPath := filepath.Join(t.TempDir(), "guest_callback.key")
+	restartSigner := func() {
+		signer := guest.NewCallbackSigner()
+		if err := signer.Open(keyPath); err != nil {
+			t.Fatal(err)
+		}
+		ts.Coordinator.GuestSigner = signer
+	}
+	restartSigner()
+
+	guestReq, err := ts.Coordinator.GuestRequest.CreateRequest("mobile_app_ayse", "test")
+	if err != nil {
+		t.Fatalf("create guest request: %v", err)
+	}
+	approve := ts.Coordinator.GuestSigner.Issue(guestReq.ID, "approve", guestReq.ExpiresAt)
+	reject := ts.Coordinator.GuestSigner.Issue(guestReq.ID, "reject", guestReq.ExpiresAt)
+
+	decide := func(decision, token string) int {
+		body := map[string]string{"request_id": guestReq.ID, "decision": decision, "token": token}
+		resp, err := http.DefaultClient.Do(newTestRequestWithBody(t, "POST", ts.Server.URL+"/api/guest/approve", "", body))
+		if err != nil {
+			t.Fatalf("guest approve failed: %v", err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	if code := decide("approve", approve); code != http.StatusOK {
+		t.Fatalf("signed approval: status %d, want 200", code)
+	}
+
+	// Same key, empty used-token memory: neither button works any more
+	restartSigner()
+	if code := decide("approve", approve); code != http.StatusConflict {
+		t.Errorf("approval replayed after restart: status %d, want 409", code)
+	}
+	if code := decide("reject", reject); code != http.StatusConflict {
+		t.Errorf("unused reject token after approval: status %d, want 409", code)
+	}
+	if got, _ := ts.Coordinator.GuestRequest.Get(guestReq.ID); got.Status != guest.StatusApproved {
+		t.Errorf("request status = %s, want approved", got.Status)
+	}
+}
+
+func TestDuressDisarmLooksLikeNormalDisarm(t *testing.T) {
+	ts := startTestServer(t, TestConfig{
+		WizardCompleted: true,
+	})
+	defer ts.Shutdown()
+
+	duress := make(chan eventbus.Event, 4)
+	sub := ts.Coordinator.Events.Subscribe("duress-test", func(e eventbus.Event) {
+		if e.Type == eventbus.AlarmDuress {
+			duress <- e
+		}
+	}, eventbus.Topics(eventbus.TopicAlarm))
+	defer sub.Unsubscribe()
+
+	normal, _ := auth.Sessions.Create(&auth.AuthContext{Username: "ayse", Role: auth.Admin, Authenticated: true})
+	forced, _ := auth.Sessions.Create(&auth.AuthContext{Username: "ayse", Role: auth.Admin, Authenticated: true, Duress: true})
+	defer auth.Sessions.RevokeUser("ayse")
+
+	disarm := func(token string) (int, []byte) {
+		req := newTestRequest(t, "POST", ts.Server.URL+"/api/alarm/disarm", "")
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("disarm failed: %v", err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, body
+	}
+	noDuress := func(what string) {
+		t.Helper()
+		select {
+		case e := <-duress:
+			t.Errorf("%s raised the duress signal: %v", what, e.Payload)
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+
+	ts.Coordinator.Alarm.Arm(alarm.ArmAway)
+	code, normalBody := disarm(normal)
+	if code != http.StatusOK {
+		t.Fatalf("normal disarm: status %d", code)
+	}
+	noDuress("normal disarm")
+
+	ts.Coordinator.Alarm.Arm(alarm.ArmAway)
+	code, duressBody := disarm(forced)
+	if code != http.StatusOK || !bytes.Equal(duressBody, normalBody) {
+		t.Errorf("duress disarm = %d %s, want the normal response %s", code, duressBody, normalBody)
+	}
+	select {
+	case e := <-duress:
+		if e.Payload["username"] != "ayse" {
+			t.Errorf("duress signal payload = %v", e.Payload)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("duress disarm did not raise the duress signal")
+	}
+
+	// A disarm that fails still raises it, and fails like a normal one
+	failures := []struct{ what, path string }{
+		{"already disarmed", "/api/alarm/disarm"},
+		{"alarmo disarm without HA", "/api/ui/alarmo/disarm"},
+	}
+	for _, f := range failures {
+		post := func(token string) (int, string) {
+			req := newTestRequestWithBody(t, "POST", ts.Server.URL+f.path, "", map[string]string{})
+			req.Header.Set("Authorization", "Bearer "+token)
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("%s: %v", f.what, err)
+			}
+			defer resp.Body.Close()
+			var body struct {
+				Error struct {
+					Code    string `json:"code"`
+					Message string `json:"message"`
+				} `json:"error"`
+			}
+			json.NewDecoder(resp.Body).Decode(&body)
+			return resp.StatusCode, body.Error.Code + " " + body.Error.Message
+		}
+		normalCode, normalErr := post(normal)
+		if normalCode == http.StatusOK {
+			t.Fatalf("%s: normal disarm succeeded", f.what)
+		}
+		noDuress(f.what + " normal disarm")
+		if code, duressErr := post(forced); code != normalCode || duressErr != normalErr {
+			t.Errorf("%s: duress disarm = %d %q, want %d %q", f.what, code, duressErr, normalCode, normalErr)
+		}
+		select {
+		case <-duress:
+		case <-time.After(2 * time.Second):
+			t.Errorf("%s: failed duress disarm did not raise the duress signal", f.what)
+		}
+	}
+}
+
+func TestGuestExitAndDenyNeedRequestID(t *testing.T) {
+	ts := startTestServer(t, TestConfig{
+		WizardCompleted: true,
+	})
+	defer ts.Shutdown()
+
+	guests := ts.Coordinator.GuestRequest
+	guests.SetLimits(nil) // Several guests at once
+	// The first guest asked from this test's client
+	first, _ := guests.CreateRequest("mobile_app_ayse", "127.0.0.1")
+	second, _ := guests.CreateRequest("mobile_app_ayse", "b")
+	waiting, _ := guests.CreateRequest("mobile_app_ayse", "c")
+	guests.ApproveRequest(first.ID)
+	guests.ApproveRequest(second.ID)
+
+	post := func(path, role string, body map[string]string) int {
+		resp, err := http.DefaultClient.Do(newTestRequestWithBody(t, "POST", ts.Server.URL+path, role, body))
+		if err != nil {
+			t.Fatalf("%s failed: %v", path, err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	// A guest can only end its own access
+	if code := post("/api/ui/guest/exit", "guest", map[string]string{}); code != http.StatusBadRequest {
+		t.Errorf("guest exit without request_id: status %d, want 400", code)
+	}
+	if _, active := guests.Counts(); active != 2 {
+		t.Fatalf("active guests after refused exit-all = %d, want 2", active)
+	}
+	if code := post("/api/ui/guest/exit", "guest", map[string]string{"request_id": second.ID}); code != http.StatusNotFound {
+		t.Errorf("guest exit of another client's request: status %d, want 404", code)
+	}
+	if code := post("/api/ui/guest/exit", "guest", map[string]string{"request_id": first.ID}); code != http.StatusOK {
+		t.Errorf("guest exit with request_id: status %d, want 200", code)
+	}
+	if _, active := guests.Counts(); active != 1 {
+		t.Fatalf("active guests after own exit = %d, want 1", active)
+	}
+
+	// Status: own request only, without who was asked
+	status := func(id, role string) (int, map[string]interface{}) {
+		resp, err := http.DefaultClient.Do(newTestRequest(t, "GET", ts.Server.URL+"/api/ui/guest/request/"+id, role))
+		if err != nil {
+			t.Fatalf("status failed: %v", err)
+		}
+		defer resp.Body.Close()
+		var body struct {
+			Response struct {
+				Data map[string]interface{} `json:"data"`
+			} `json:"response"`
+		}
+		json.NewDecoder(resp.Body).Decode(&body)
+		return resp.StatusCode, body.Response.Data
+	}
+	if code, _ := status(second.ID, "guest"); code != http.StatusNotFound {
+		t.Errorf("guest status of another client's request: status %d, want 404", code)
+	}
+	if code, data := status(first.ID, "guest"); code != http.StatusOK || data["target_user"] != nil {
+		t.Errorf("guest status of own request = %d %v, want 200 without target_user", code, data)
+	}
+	if code, data := status(second.ID, "admin"); code != http.StatusOK || data["target_user"] != "mobile_app_ayse" {
+		t.Errorf("admin status = %d %v, want 200 with target_user", code, data)
+	}
+
+	// Deny never guesses which request is meant
+	if code := post("/api/guest/deny", "admin", map[string]string{}); code != http.StatusBadRequest {
+		t.Errorf("deny without request_id: status %d, want 400", code)
+	}
+	if got, _ := guests.Get(waiting.ID); got.Status != guest.StatusPending {
+		t.Errorf("waiting request after deny without id = %s, want pending", got.Status)
+	}
+
+	if code := post("/api/ui/guest/exit", "admin", map[string]string{}); code != http.StatusOK {
+		t.Errorf("admin exit-all: status %d, want 200", code)
+	}
+	if _, active := guests.Counts(); active != 0 {
+		t.Errorf("active guests after admin exit-all = %d, want 0", active)
+	}
+}
+
 func TestReducedMotionCountdownStatic(t *testing.T) {
 	presetTime := time.Now().UTC()
 	preset := alarmo.AlarmoState{
@@ -1928,3 +2259,38 @@ func TestReducedMotionCountdownStatic(t *testing.T) {
 		t.Fatalf("expected reduced_motion delay to match standard delay, got %d vs %d", delayRemaining(reducedDelay), delayRemaining(standardDelay))
 	}
 }
+
+func TestClientKeyHonoursTrustProxy(t *testing.T) {
+	keyOf := func(trustProxy bool, headers map[string]string) string {
+		var got string
+		handler := clientIPMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			got = clientKey(r)
+		}), trustProxy)
+		req := httptest.NewRequest("POST", "/api/login", nil)
+		req.RemoteAddr = "10.0.0.1:51000" // The proxy
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+		return got
+	}
+
+	tests := []struct {
+		name       string
+		trustProxy bool
+		headers    map[string]string
+		want       string
+	}{
+		{"no proxy headers", true, nil, "10.0.0.1"},
+		{"headers ignored unless trusted", false, map[string]string{"X-Forwarded-For": "192.168.1.20"}, "10.0.0.1"},
+		{"forwarded for", true, map[string]string{"X-Forwarded-For": "192.168.1.20"}, "192.168.1.20"},
+		{"spoofed entries before the proxy's", true, map[string]string{"X-Forwarded-For": "1.2.3.4, 192.168.1.20"}, "192.168.1.20"},
+		{"real ip", true, map[string]string{"X-Real-IP": "192.168.1.21"}, "192.168.1.21"},
+		{"invalid header", true, map[string]string{"X-Forwarded-For": "kiosk"}, "10.0.0.1"},
+	}
+	for _, tt := range tests {
+		if got := keyOf(tt.trustProxy, tt.headers); got != tt.want {
+			t.Errorf("%s: client = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
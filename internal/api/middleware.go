@@ -5,7 +5,9 @@ import (
 	"crypto/rand"
 	"encoding/hex"
 	"encoding/json"
+	"net"
 	"net/http"
+	"smartdisplay-core/internal/audit"
 	"smartdisplay-core/internal/auth"
 	"smartdisplay-core/internal/logger"
 	"strings"
@@ -17,6 +19,16 @@ func getRole(r *http.Request) auth.Role {
 	return authCtx.Role
 }
 
+// auditActor identifies the caller for the audit trail
+func auditActor(r *http.Request) audit.Actor {
+	authCtx := getAuthContext(r)
+	return audit.Actor{
+		User:      authCtx.Username,
+		Role:      string(authCtx.Role),
+		RequestID: RequestIDFromContext(r.Context()),
+	}
+}
+
 // corsDevMiddleware adds CORS headers for local development (localhost:5500)
 // This allows frontend on different ports to call the API during development
 func corsDevMiddleware(next http.Handler) http.Handler {
@@ -88,11 +100,16 @@ func (s *Server) respondError(w http.ResponseWriter, r *http.Request, code Error
 
 // authMiddleware extracts PIN from request and validates it
 // FAZ L1: PIN-based authentication middleware
-func authMiddleware(next http.Handler) http.Handler {
+// devMode enables the X-User-Role override; in production the header is ignored.
+func authMiddleware(next http.Handler, devMode bool) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// Development/testing override: allow X-User-Role header to set role locally
 		// This is intentionally simple to ease local testing of admin-only endpoints.
 		roleHeader := r.Header.Get("X-User-Role")
+		if roleHeader != "" && !devMode {
+			logger.Info("auth: X-User-Role header ignored (dev mode off)")
+			roleHeader = ""
+		}
 		var authCtx *auth.AuthContext
 		if roleHeader != "" {
 			switch strings.ToLower(roleHeader) {
@@ -104,15 +121,18 @@ func authMiddleware(next http.Handler) http.Handler {
 				authCtx = &auth.AuthContext{Role: auth.Guest, Authenticated: false, PIN: ""}
 			}
 			logger.Info("auth: role override via X-User-Role header: " + roleHeader)
+		} else if sess, ok := auth.Sessions.Validate(sessionToken(r)); ok {
+			// Session from /api/login (cookie or bearer token)
+			authCtx = &auth.AuthContext{Role: sess.Role, Authenticated: true, Username: sess.Username, Scopes: sess.Scopes, Duress: sess.Duress}
 		} else {
-			// Extract PIN from header
+			// Extract PIN from header (scripts and clients without a session)
 			pin := r.Header.Get("X-SmartDisplay-PIN")
 
-			// Validate PIN and get auth context
-			validatedCtx, err := auth.ValidatePIN(pin)
+			// Validate PIN (with lockout) and get auth context
+			validatedCtx, err := auth.CheckPIN(clientKey(r), pin)
 			if err != nil {
-				// Invalid PIN - treat as guest
-				logger.Info("auth: invalid PIN provided (treating as guest)")
+				// Invalid PIN or locked out - treat as guest
+				logger.Info("auth: PIN check failed (treating as guest): " + err.Error())
 				authCtx = &auth.AuthContext{
 					Role:          auth.Guest,
 					Authenticated: false,
@@ -155,6 +175,79 @@ func requireAdmin(r *http.Request) bool {
 	return authCtx.Role == auth.Admin
 }
 
+// sessionCookie holds the session token issued by /api/login
+const sessionCookie = "sd_session"
+
+// sessionToken returns the session token of a request: bearer header first, then cookie
+func sessionToken(r *http.Request) string {
+	if h := r.Header.Get("Authorization"); strings.HasPrefix(h, "Bearer ") {
+		return strings.TrimPrefix(h, "Bearer ")
+	}
+	if c, err := r.Cookie(sessionCookie); err == nil {
+		return c.Value
+	}
+	return ""
+}
+
+// requireIdentifiedUser is true for a user identified by session or PIN.
+// User management needs it on top of users.manage: role overrides carry no user.
+func requireIdentifiedUser(r *http.Request) bool {
+	authCtx := getAuthContext(r)
+	return authCtx.Authenticated && authCtx.Username != ""
+}
+
+// ctxClientKey is the context key for the resolved client address
+const ctxClientKey contextKey = "client_key"
+
+// clientIPMiddleware resolves the caller's address once per request. With trustProxy
+// the address a reverse proxy reports is used: the last X-Forwarded-For entry (the
+// one the proxy added; earlier ones come from the client), else X-Real-IP.
+// Without it the headers are ignored and every client behind a proxy shares its address.
+func clientIPMiddleware(next http.Handler, trustProxy bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		client := remoteHost(r)
+		if trustProxy {
+			if ip := forwardedClient(r); ip != "" {
+				client = ip
+			}
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), ctxClientKey, client)))
+	})
+}
+
+// forwardedClient returns the client address set by a reverse proxy ("" = none or invalid)
+func forwardedClient(r *http.Request) string {
+	if xff := r.Header.Values("X-Forwarded-For"); len(xff) > 0 {
+		hops := strings.Split(xff[len(xff)-1], ",")
+		if ip := net.ParseIP(strings.TrimSpace(hops[len(hops)-1])); ip != nil {
+			return ip.String()
+		}
+		return ""
+	}
+	if ip := net.ParseIP(strings.TrimSpace(r.Header.Get("X-Real-IP"))); ip != nil {
+		return ip.String()
+	}
+	return ""
+}
+
+// remoteHost returns the IP of the connection's peer, without port
+func remoteHost(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// clientKey identifies the caller for failed-PIN counters and guest request ownership
+// (see clientIPMiddleware; the connection's peer when the middleware did not run)
+func clientKey(r *http.Request) string {
+	if client, ok := r.Context().Value(ctxClientKey).(string); ok {
+		return client
+	}
+	return remoteHost(r)
+}
+
 // boolToString converts bool to string
 func boolToString(b bool) string {
 	if b {
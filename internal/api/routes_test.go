@@ -0,0 +1,212 @@
+package api
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"net/http"
+	"strings"
+	"testing"
+
+	"smartdisplay-core/internal/auth"
+)
+
+// TestRoutePermissions pins the permission of every API route
+func TestRoutePermissions(t *testing.T) {
+	want := map[string]auth.Permission{
+		"/api/users/list":                    auth.PermUsersManage,
+		"/api/users/add":                     auth.PermUsersManage,
+		"/api/users/update":                  auth.PermUsersManage,
+		"/api/users/delete":                  auth.PermUsersManage,
+		"/api/health":                        auth.PermPublic,
+		"/health":                            auth.PermPublic,
+		"/api/login":                         auth.PermPublic,
+		"/api/logout":                        auth.PermPublic,
+		"/api/ui/events":                     auth.PermPublic,
+		"/api/ui/home/state":                 auth.PermPublic,
+		"/api/ui/home/summary":               auth.PermPublic,
+		"/api/ui/alarm/state":                auth.PermPublic,
+		"/api/ui/alarm/summary":              auth.PermPublic,
+		"/api/ui/alarm/action":               auth.PermPublic, // alarm.arm / alarm.disarm per action
+		"/api/ui/alarm/commands":             auth.PermAlarmRead,
+		"/api/ui/alarm/commands/":            auth.PermAlarmRead,
+		"/api/ui/alarmo/status":              auth.PermPublic,
+		"/api/ui/alarmo/sensors":             auth.PermPublic,
+		"/api/ui/alarmo/events":              auth.PermAlarmRead,
+		"/api/ui/alarmo/arm":                 auth.PermAlarmArm,
+		"/api/ui/alarmo/disarm":              auth.PermAlarmDisarm,
+		"/api/ui/guest/state":                auth.PermPublic,
+		"/api/ui/guest/summary":              auth.PermPublic,
+		"/api/ui/guest/request":              auth.PermGuestRequest,
+		"/api/ui/guest/request/":             auth.PermPublic,
+		"/api/ui/guest/exit":                 auth.PermGuestExit,
+		"/api/ui/guest/redeem":               auth.PermGuestRequest,
+		"/api/ui/guest/rearm/cancel":         auth.PermAlarmDisarm,
+		"/api/ui/menu":                       auth.PermPublic,
+		"/api/ui/logbook":                    auth.PermPublic,
+		"/api/ui/logbook/summary":            auth.PermPublic,
+		"/api/ui/logbook/export":             auth.PermPublic,
+		"/api/ui/settings":                   auth.PermSettingsRead,
+		"/api/ui/settings/action":            auth.PermSettingsWrite,
+		"/api/ui/accessibility":              auth.PermPublic,
+		"/api/ui/voice":                      auth.PermPublic,
+		"/api/ai/daily":                      auth.PermPublic,
+		"/api/ai/anomalies":                  auth.PermPublic,
+		"/api/ai/morning":                    auth.PermPublic,
+		"/api/ai/insight":                    auth.PermPublic,
+		"/api/ai/insight/explain":            auth.PermPublic,
+		"/api/ai/history":                    auth.PermPublic,
+		"/api/overview":                      auth.PermAlarmRead,
+		"/api/alarm/arm":                     auth.PermAlarmArm,
+		"/api/alarm/disarm":                  auth.PermAlarmDisarm,
+		"/api/guest/approve":                 auth.PermPublic, // Signed decision token
+		"/api/guest/deny":                    auth.PermGuestApprove,
+		"/api/guest/passes":                  auth.PermGuestPasses,
+		"/api/guest/passes/delete":           auth.PermGuestPasses,
+		"/api/failsafe":                      auth.PermPublic,
+		"/api/logbook":                       auth.PermPublic,
+		"/api/setup/firstboot/status":        auth.PermPublic,
+		"/api/setup/firstboot/next":          auth.PermPublic,
+		"/api/setup/firstboot/back":          auth.PermPublic,
+		"/api/setup/firstboot/complete":      auth.PermPublic,
+		"/api/ui/help":                       auth.PermPublic,
+		"/api/ui/scorecard":                  auth.PermPublic,
+		"/api/admin/smoke":                   auth.PermSystemAdmin,
+		"/api/admin/audit":                   auth.PermAuditRead,
+		"/api/admin/audit/verify":            auth.PermAuditRead,
+		"/api/admin/restart":                 auth.PermSystemAdmin,
+		"/api/admin/backup":                  auth.PermSystemAdmin,
+		"/api/admin/restore":                 auth.PermSystemAdmin,
+		"/api/admin/telemetry/summary":       auth.PermSystemAdmin,
+		"/api/admin/telemetry/optin":         auth.PermSystemAdmin,
+		"/api/admin/update/status":           auth.PermSystemAdmin,
+		"/api/admin/update/stage":            auth.PermSystemAdmin,
+		"/api/settings/homeassistant":        auth.PermSettingsWrite,
+		"/api/settings/homeassistant/status": auth.PermSettingsRead,
+		"/api/settings/homeassistant/test":   auth.PermSettingsWrite,
+		"/api/settings/homeassistant/sync":   auth.PermSettingsWrite,
+		"/api/devices/lights":                auth.PermLightsRead,
+		"/api/devices/lights/toggle":         auth.PermLightsControl,
+		"/api/devices/lights/set":            auth.PermLightsControl,
+	}
+
+	s := &Server{}
+	seen := map[string]bool{}
+	for _, rt := range s.routes() {
+		if seen[rt.pattern] {
+			t.Errorf("%s registered twice", rt.pattern)
+		}
+		seen[rt.pattern] = true
+		perm, ok := want[rt.pattern]
+		if !ok {
+			t.Errorf("%s has no entry in this test", rt.pattern)
+			continue
+		}
+		if rt.perm != perm {
+			t.Errorf("%s requires %q, want %q", rt.pattern, rt.perm, perm)
+		}
+		if rt.perm != auth.PermPublic && !auth.KnownPermission(rt.perm) {
+			t.Errorf("%s requires unknown permission %q", rt.pattern, rt.perm)
+		}
+	}
+	for pattern := range want {
+		if !seen[pattern] {
+			t.Errorf("%s is not registered", pattern)
+		}
+	}
+}
+
+// TestNoRoutesOutsideTable fails when a handler is registered with a literal path
+// instead of through the route table (and therefore without checkPerm)
+func TestNoRoutesOutsideTable(t *testing.T) {
+	fset := token.NewFileSet()
+	for _, file := range []string{"internal/api/bootstrap.go", "internal/api/server.go"} {
+		f, err := parser.ParseFile(fset, file, nil, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ast.Inspect(f, func(n ast.Node) bool {
+			call, ok := n.(*ast.CallExpr)
+			if !ok || len(call.Args) == 0 {
+				return true
+			}
+			sel, ok := call.Fun.(*ast.SelectorExpr)
+			if !ok || sel.Sel.Name != "HandleFunc" {
+				return true
+			}
+			if lit, ok := call.Args[0].(*ast.BasicLit); ok && strings.HasPrefix(lit.Value, `"/api`) {
+				t.Errorf("%s: %s registered outside the route table", fset.Position(call.Pos()), lit.Value)
+			}
+			return true
+		})
+	}
+}
+
+// TestRoutePermissionsEnforced checks every guarded route answers 403 to roles
+// without its permission
+func TestRoutePermissionsEnforced(t *testing.T) {
+	ts := startTestServer(t, TestConfig{WizardCompleted: true})
+	defer ts.Shutdown()
+
+	roles := map[string]auth.Role{"guest": auth.Guest, "user": auth.UserRole, "admin": auth.Admin}
+	for _, rt := range (&Server{}).routes() {
+		if rt.perm == auth.PermPublic {
+			continue
+		}
+		for name, role := range roles {
+			if auth.HasPermission(role, rt.perm) {
+				continue
+			}
+			for _, method := range []string{http.MethodGet, http.MethodPost} {
+				req := newTestRequest(t, method, ts.URL+rt.pattern, name)
+				resp, err := http.DefaultClient.Do(req)
+				if err != nil {
+					t.Fatalf("%s %s: %v", method, rt.pattern, err)
+				}
+				resp.Body.Close()
+				if resp.StatusCode != http.StatusForbidden {
+					t.Errorf("%s %s as %s: status %d, want 403", method, rt.pattern, name, resp.StatusCode)
+				}
+			}
+		}
+	}
+}
+
+// TestUserScopes checks per-user grants and denies on top of the role
+func TestUserScopes(t *testing.T) {
+	ts := startTestServer(t, TestConfig{WizardCompleted: true, ProductionAuth: true})
+	defer ts.Shutdown()
+
+	limited, _ := auth.Sessions.Create(&auth.AuthContext{Username: "scoped-admin", Role: auth.Admin,
+		Scopes: auth.Scopes{Deny: []auth.Permission{auth.PermSettingsRead}}})
+	extended, _ := auth.Sessions.Create(&auth.AuthContext{Username: "scoped-user", Role: auth.UserRole,
+		Scopes: auth.Scopes{Grant: []auth.Permission{auth.PermAuditRead}}})
+	defer auth.Sessions.RevokeUser("scoped-admin")
+	defer auth.Sessions.RevokeUser("scoped-user")
+
+	tests := []struct {
+		name  string
+		token string
+		path  string
+		want  int
+	}{
+		{"deny beats role", limited, "/api/ui/settings", http.StatusForbidden},
+		{"other admin routes kept", limited, "/api/admin/audit", http.StatusOK},
+		{"grant on top of role", extended, "/api/admin/audit", http.StatusOK},
+		{"role limits kept", extended, "/api/ui/settings", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := newTestRequest(t, http.MethodGet, ts.URL+tt.path, "")
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.want {
+				t.Errorf("status %d, want %d", resp.StatusCode, tt.want)
+			}
+		})
+	}
+}
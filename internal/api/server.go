@@ -4,9 +4,10 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"log"
+	"math"
 	"net/http"
 	"net/url"
 	"smartdisplay-core/internal/alarm"
@@ -14,8 +15,10 @@ import (
 	"smartdisplay-core/internal/auth"
 	"smartdisplay-core/internal/config"
 	"smartdisplay-core/internal/contexthelp"
+	"smartdisplay-core/internal/eventbus"
 	"smartdisplay-core/internal/firstboot"
 	"smartdisplay-core/internal/guest"
+	"smartdisplay-core/internal/ha/alarmo"
 	"smartdisplay-core/internal/logbook"
 	"smartdisplay-core/internal/logger"
 	"smartdisplay-core/internal/settings"
@@ -31,6 +34,8 @@ import (
 
 // Shutdown gracefully shuts down the HTTP server.
 func (s *Server) Shutdown(ctx context.Context) error {
+	// End long-lived streams first; Shutdown waits for active connections
+	s.shutdownCxl()
 	if s.httpServer != nil {
 		return s.httpServer.Shutdown(ctx)
 	}
@@ -45,20 +50,7 @@ func (s *Server) HandleUserList(w http.ResponseWriter, r *http.Request) {
 		_ = json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "message": "Method not allowed"})
 		return
 	}
-	role, pin := "", ""
-	if cookie, err := r.Cookie("sd_session"); err == nil {
-		parts := strings.SplitN(cookie.Value, ":", 2)
-		if len(parts) == 2 {
-			role = parts[0]
-			pin = parts[1]
-		}
-	}
-	if role != "admin" || pin == "" {
-		role = r.Header.Get("X-User-Role")
-		pin = r.Header.Get("X-User-Pin")
-	}
-	ctx, err := auth.ValidatePIN(pin)
-	if err != nil || !ctx.Authenticated || ctx.Role != "admin" {
+	if !requireIdentifiedUser(r) {
 		w.WriteHeader(http.StatusForbidden)
 		_ = json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "message": "Yetkisiz erişim"})
 		return
@@ -69,92 +61,103 @@ func (s *Server) HandleUserList(w http.ResponseWriter, r *http.Request) {
 		_ = json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "message": "Kullanıcılar yüklenemedi"})
 		return
 	}
+	// Alarmo kodları ve PIN hash'leri istemciye gönderilmez
+	for i := range users {
+		users[i].AlarmoCode = ""
+		users[i].PIN = ""
+		users[i].DuressPIN = ""
+	}
 	_ = json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "users": users})
 }
 
 func (s *Server) HandleUserAdd(w http.ResponseWriter, r *http.Request) {
-	role, pin := "", ""
-	if cookie, err := r.Cookie("sd_session"); err == nil {
-		parts := strings.SplitN(cookie.Value, ":", 2)
-		if len(parts) == 2 {
-			role = parts[0]
-			pin = parts[1]
-		}
-	}
-	if role != "admin" || pin == "" {
-		role = r.Header.Get("X-User-Role")
-		pin = r.Header.Get("X-User-Pin")
-	}
-	ctx, err := auth.ValidatePIN(pin)
-	if err != nil || !ctx.Authenticated || ctx.Role != "admin" {
+	if !requireIdentifiedUser(r) {
 		w.WriteHeader(http.StatusForbidden)
 		_ = json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "message": "Yetkisiz erişim"})
 		return
 	}
-	var user auth.User
-	if err := json.NewDecoder(r.Body).Decode(&user); err != nil {
+	var req struct {
+		auth.User
+		AlarmoCode *string `json:"alarmo_code"` // Plaintext Alarmo code; stored encrypted, nil = unchanged
+		DuressPIN  *string `json:"duress_pin"`  // Plaintext duress PIN; stored hashed, nil = unchanged, "" = remove
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		w.WriteHeader(http.StatusBadRequest)
 		_ = json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "message": "Geçersiz istek"})
 		return
 	}
-	err = auth.AddUser(user)
+	user := req.User
+	user.AlarmoCode = "" // Only SetAlarmoCode writes the encrypted value
+	user.DuressPIN = ""  // Only SetDuressPIN writes the hash
+	err := auth.AddUser(user)
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		_ = json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "message": err.Error()})
 		return
 	}
+	if req.AlarmoCode != nil {
+		if err := auth.SetAlarmoCode(user.Username, *req.AlarmoCode); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "message": err.Error()})
+			return
+		}
+	}
+	if req.DuressPIN != nil {
+		if err := auth.SetDuressPIN(user.Username, *req.DuressPIN); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "message": err.Error()})
+			return
+		}
+	}
 	_ = json.NewEncoder(w).Encode(map[string]interface{}{"success": true})
 }
 
 func (s *Server) HandleUserUpdate(w http.ResponseWriter, r *http.Request) {
-	role, pin := "", ""
-	if cookie, err := r.Cookie("sd_session"); err == nil {
-		parts := strings.SplitN(cookie.Value, ":", 2)
-		if len(parts) == 2 {
-			role = parts[0]
-			pin = parts[1]
-		}
-	}
-	if role != "admin" || pin == "" {
-		role = r.Header.Get("X-User-Role")
-		pin = r.Header.Get("X-User-Pin")
-	}
-	ctx, err := auth.ValidatePIN(pin)
-	if err != nil || !ctx.Authenticated || ctx.Role != "admin" {
+	if !requireIdentifiedUser(r) {
 		w.WriteHeader(http.StatusForbidden)
 		_ = json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "message": "Yetkisiz erişim"})
 		return
 	}
-	var user auth.User
-	if err := json.NewDecoder(r.Body).Decode(&user); err != nil {
+	var req struct {
+		auth.User
+		AlarmoCode *string `json:"alarmo_code"` // Plaintext Alarmo code; stored encrypted, nil = unchanged
+		DuressPIN  *string `json:"duress_pin"`  // Plaintext duress PIN; stored hashed, nil = unchanged, "" = remove
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		w.WriteHeader(http.StatusBadRequest)
 		_ = json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "message": "Geçersiz istek"})
 		return
 	}
-	err = auth.UpdateUser(user)
+	user := req.User
+	user.AlarmoCode = "" // Only SetAlarmoCode writes the encrypted value
+	user.DuressPIN = ""  // Only SetDuressPIN writes the hash
+	err := auth.UpdateUser(user)
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		_ = json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "message": err.Error()})
 		return
 	}
+	// PIN veya rol değişmiş olabilir: açık oturumlar kapatılır
+	auth.Sessions.RevokeUser(user.Username)
+	if req.AlarmoCode != nil {
+		if err := auth.SetAlarmoCode(user.Username, *req.AlarmoCode); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "message": err.Error()})
+			return
+		}
+	}
+	if req.DuressPIN != nil {
+		if err := auth.SetDuressPIN(user.Username, *req.DuressPIN); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "message": err.Error()})
+			return
+		}
+	}
 	_ = json.NewEncoder(w).Encode(map[string]interface{}{"success": true})
 }
 
 func (s *Server) HandleUserDelete(w http.ResponseWriter, r *http.Request) {
-	role, pin := "", ""
-	if cookie, err := r.Cookie("sd_session"); err == nil {
-		parts := strings.SplitN(cookie.Value, ":", 2)
-		if len(parts) == 2 {
-			role = parts[0]
-			pin = parts[1]
-		}
-	}
-	if role != "admin" || pin == "" {
-		role = r.Header.Get("X-User-Role")
-		pin = r.Header.Get("X-User-Pin")
-	}
-	ctx, err := auth.ValidatePIN(pin)
-	if err != nil || !ctx.Authenticated || ctx.Role != "admin" {
+	if !requireIdentifiedUser(r) {
 		w.WriteHeader(http.StatusForbidden)
 		_ = json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "message": "Yetkisiz erişim"})
 		return
@@ -167,12 +170,13 @@ func (s *Server) HandleUserDelete(w http.ResponseWriter, r *http.Request) {
 		_ = json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "message": "Geçersiz istek"})
 		return
 	}
-	err = auth.DeleteUser(req.Username)
+	err := auth.DeleteUser(req.Username)
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		_ = json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "message": err.Error()})
 		return
 	}
+	auth.Sessions.RevokeUser(req.Username)
 	_ = json.NewEncoder(w).Encode(map[string]interface{}{"success": true})
 }
 
@@ -273,8 +277,8 @@ func (s *Server) runHAConnectionTest() haConnectionTestResult {
 	}
 	resp.Body.Close()
 
-	// 2d. Alarmo Presence (read-only)
-	req, err = http.NewRequest("GET", baseURL+"/api/states/alarm_control_panel.alarmo", nil)
+	// 2d. Alarmo Presence (read-only, primary panel)
+	req, err = http.NewRequest("GET", baseURL+"/api/states/"+s.alarmoPanels()[0].EntityID, nil)
 	if err != nil {
 		return haConnectionTestResult{
 			Success: false,
@@ -322,11 +326,15 @@ func (s *Server) runHAConnectionTest() haConnectionTestResult {
 }
 
 // --- Register FAZ S2 endpoints ---
+// Handlers and permissions come from the route table
 func (s *Server) RegisterFAZS2Endpoints(mux *http.ServeMux) {
-	mux.HandleFunc("/api/settings/homeassistant/test", s.handleHASettingsTest)
-	mux.HandleFunc("/api/settings/homeassistant", s.handleHASettingsSave)
-	mux.HandleFunc("/api/settings/homeassistant/status", s.handleHASettingsStatus)
-	mux.HandleFunc("/api/login", s.handleLogin)
+	for _, rt := range s.routes() {
+		switch rt.pattern {
+		case "/api/settings/homeassistant/test", "/api/settings/homeassistant",
+			"/api/settings/homeassistant/status", "/api/login":
+			mux.HandleFunc(rt.pattern, s.guard(rt.perm, rt.handler))
+		}
+	}
 }
 
 // handleAIAnomalies returns grouped anomaly packets for UI
@@ -343,17 +351,18 @@ func (s *Server) handleAIDaily(w http.ResponseWriter, r *http.Request) {
 
 // getRole extracts the role from auth context (set by auth middleware)
 
-// checkPerm enforces permission, logs/audits the decision, and returns role/allowed
+// checkPerm enforces permission (role matrix plus per-user scopes) and returns role/allowed.
+// Every guarded request passes here, so only denials are audited.
 func (s *Server) checkPerm(w http.ResponseWriter, r *http.Request, perm auth.Permission) (auth.Role, bool) {
-	role := getRole(r)
-	allowed := auth.HasPermission(role, perm)
-	msg := fmt.Sprintf("role=%s perm=%s allowed=%v", role, perm, allowed)
-	log.Println("perm decision:", msg)
-	audit.Record("perm_check", msg)
+	authCtx := getAuthContext(r)
+	allowed := auth.Allowed(authCtx, perm)
 	if !allowed {
+		msg := fmt.Sprintf("role=%s perm=%s path=%s", authCtx.Role, perm, r.URL.Path)
+		logger.Info("perm denied: " + msg)
+		audit.RecordAs(auditActor(r), "perm_denied", msg)
 		s.respondError(w, r, CodeForbidden, "insufficient permissions")
 	}
-	return role, allowed
+	return authCtx.Role, allowed
 }
 
 type Server struct {
@@ -366,6 +375,7 @@ type Server struct {
 	healthMonitor *settings.RuntimeHealthMonitor
 	shutdownCtx   context.Context
 	shutdownCxl   context.CancelFunc
+	stream        *uiStream // SSE fan-out for /api/ui/events
 }
 
 type envelope struct {
@@ -387,6 +397,26 @@ func NewServer(coord *system.Coordinator, runtimeCfg *config.RuntimeConfig) *Ser
 	auditLogger := &UpdateAuditLogger{}
 	updateMgr := update.New("1.0.0", "data/staging", auditLogger)
 
+	// Session lifetimes follow the Security settings
+	if coord != nil && coord.Settings != nil {
+		sm := coord.Settings
+		auth.Sessions.SetTimeouts(func() (time.Duration, time.Duration) {
+			return time.Duration(sm.SecurityInt("session_idle_timeout_s", 900)) * time.Second,
+				time.Duration(sm.SecurityInt("session_max_age_s", 43200)) * time.Second
+		})
+	}
+
+	// PINs refused by their schedule are recorded in the logbook via the bus
+	if coord != nil {
+		bus := coord.Events
+		auth.OnScheduleDenied(func(username, reason string) {
+			bus.Publish(eventbus.TopicSystem, eventbus.PINScheduleDenied, map[string]interface{}{
+				"username": username,
+				"reason":   reason,
+			})
+		})
+	}
+
 	// Create shutdown context (will be cancelled on graceful shutdown)
 	ctx, cancel := context.WithCancel(context.Background())
 
@@ -398,6 +428,7 @@ func NewServer(coord *system.Coordinator, runtimeCfg *config.RuntimeConfig) *Ser
 		healthMonitor: healthMon,
 		shutdownCtx:   ctx,
 		shutdownCxl:   cancel,
+		stream:        newUIStream(),
 	}
 }
 
@@ -452,10 +483,6 @@ func (s *Server) handleHealth(w http.ResponseWriter, _ *http.Request) {
 }
 
 func (s *Server) handleOverview(w http.ResponseWriter, r *http.Request) {
-	_, allowed := s.checkPerm(w, r, auth.PermAlarm)
-	if !allowed {
-		return
-	}
 	s.coord.UpdateFailsafeState()
 
 	// Load accessibility and voice preferences
@@ -484,7 +511,7 @@ func (s *Server) handleOverview(w http.ResponseWriter, r *http.Request) {
 
 	overview := map[string]interface{}{
 		"alarm":         s.coord.Alarm.CurrentState(),
-		"guest":         s.coord.Guest.CurrentState(),
+		"guest":         s.coord.GuestRequest.CurrentState(),
 		"ha":            s.coord.HA.IsConnected(),
 		"ai":            s.coord.GetCurrentInsight(),
 		"accessibility": a11y,
@@ -504,31 +531,42 @@ func (s *Server) handleFailsafe(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *Server) handleAlarmArm(w http.ResponseWriter, r *http.Request) {
-	_, allowed := s.checkPerm(w, r, auth.PermAlarm)
-	if !allowed {
-		return
-	}
 	if r.Method != "POST" {
 		s.respondError(w, r, CodeMethodNotAllowed, "POST required")
 		return
 	}
-	err := s.coord.Alarm.Handle("ARM_REQUEST")
+	// A8: Optional body {"mode": "home|away|night|vacation"}, default away
+	var req struct {
+		Mode string `json:"mode"`
+	}
+	if r.Body != nil {
+		json.NewDecoder(r.Body).Decode(&req)
+	}
+	if req.Mode == "" {
+		req.Mode = alarm.ArmAway
+	}
+	if !alarm.ValidArmMode(req.Mode) {
+		s.respondError(w, r, CodeBadRequest, "invalid arm mode")
+		return
+	}
+	err := s.coord.Alarm.Arm(req.Mode)
 	if err != nil {
 		s.respondError(w, r, CodeInternalError, "alarm arm error")
 		return
 	}
-	s.respond(w, true, map[string]string{"result": "ok"}, "", 200)
+	s.respond(w, true, map[string]string{
+		"result": "ok",
+		"state":  s.coord.Alarm.CurrentState(),
+		"mode":   req.Mode,
+	}, "", 200)
 }
 
 func (s *Server) handleAlarmDisarm(w http.ResponseWriter, r *http.Request) {
-	_, allowed := s.checkPerm(w, r, auth.PermAlarm)
-	if !allowed {
-		return
-	}
 	if r.Method != "POST" {
 		s.respondError(w, r, CodeMethodNotAllowed, "POST required")
 		return
 	}
+	s.signalDuress(getAuthContext(r), "")
 	err := s.coord.Alarm.Handle("DISARM_REQUEST")
 	if err != nil {
 		s.respondError(w, r, CodeInternalError, "alarm disarm error")
@@ -537,8 +575,48 @@ func (s *Server) handleAlarmDisarm(w http.ResponseWriter, r *http.Request) {
 	s.respond(w, true, map[string]string{"result": "ok"}, "", 200)
 }
 
+// signalDuress raises the silent alarm for a disarm by a duress login; call it
+// before sending the disarm, so it goes out whatever the disarm's result. The
+// response stays identical to a normal disarm.
+func (s *Server) signalDuress(ctx *auth.AuthContext, area string) {
+	if ctx == nil || !ctx.Duress {
+		return
+	}
+	if panel, ok := s.alarmoPanel(area); ok {
+		area = panel.Area
+	}
+	s.coord.SignalDuress(ctx.Username, area)
+}
+
 // === ALARMO MONITORING (READ-ONLY) ===
 
+// alarmoPanels returns the configured Alarmo panels (primary first)
+func (s *Server) alarmoPanels() []alarmo.Panel {
+	if s.coord != nil {
+		s.coord.AlarmoMu.RLock()
+		adapter := s.coord.AlarmoAdapter
+		s.coord.AlarmoMu.RUnlock()
+		if adapter != nil {
+			return adapter.Panels()
+		}
+	}
+	return alarmo.DefaultPanels()
+}
+
+// alarmoPanel resolves an area key; empty area selects the primary panel
+func (s *Server) alarmoPanel(area string) (alarmo.Panel, bool) {
+	panels := s.alarmoPanels()
+	if area == "" {
+		return panels[0], true
+	}
+	for _, p := range panels {
+		if p.Area == area {
+			return p, true
+		}
+	}
+	return alarmo.Panel{}, false
+}
+
 // handleAlarmoStatus exposes lightweight Alarmo connectivity/health status
 // Visible to all roles; returns only runtime health fields (no secrets)
 func (s *Server) handleAlarmoStatus(w http.ResponseWriter, r *http.Request) {
@@ -622,11 +700,41 @@ func (s *Server) handleAlarmoStatus(w http.ResponseWriter, r *http.Request) {
 		"delay_remaining":        delayRemaining,
 		"delay_type":             delayType,
 		"alarmo_last_changed":    alarmoLastChanged,
+		"areas":                  s.alarmoAreaStatus(),
 	}
 
 	s.respond(w, true, data, "", 200)
 }
 
+// alarmoAreaStatus builds the per-area part of /api/ui/alarmo/status (A5)
+func (s *Server) alarmoAreaStatus() []map[string]interface{} {
+	areas := make([]map[string]interface{}, 0)
+	if s.coord == nil {
+		return areas
+	}
+	for _, a := range s.coord.AlarmoAreaStates() {
+		lastChanged := ""
+		if !a.State.LastChanged.IsZero() {
+			lastChanged = a.State.LastChanged.UTC().Format(time.RFC3339)
+		}
+		areas = append(areas, map[string]interface{}{
+			"area":            a.Panel.Area,
+			"name":            a.Panel.Name,
+			"entity_id":       a.Panel.EntityID,
+			"primary":         a.Primary,
+			"available":       a.Available,
+			"state":           a.State.RawState,
+			"mode":            a.State.Mode,
+			"armed_mode":      a.State.ArmedMode,
+			"triggered":       a.State.Triggered,
+			"delay_remaining": a.State.DelayRemaining,
+			"delay_type":      a.State.DelayType,
+			"last_changed":    lastChanged,
+		})
+	}
+	return areas
+}
+
 // handleAlarmoSensors returns sanitized Alarmo-related sensor list
 // Visible to admin/user/guest (read-only)
 func (s *Server) handleAlarmoSensors(w http.ResponseWriter, r *http.Request) {
@@ -659,14 +767,8 @@ func (s *Server) handleAlarmoSensors(w http.ResponseWriter, r *http.Request) {
 }
 
 // handleAlarmoEvents returns recent Alarmo-related events (read-only)
-// Visible to admin and user; guests are blocked from event log
+// Requires alarm.read
 func (s *Server) handleAlarmoEvents(w http.ResponseWriter, r *http.Request) {
-	role := getRole(r)
-	if role == auth.Guest {
-		s.respondError(w, r, CodeForbidden, "guest not allowed")
-		return
-	}
-
 	if r.Method != http.MethodGet {
 		s.respondError(w, r, CodeMethodNotAllowed, "GET required")
 		return
@@ -701,8 +803,9 @@ func (s *Server) handleAlarmoEvents(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	nameMap := map[string]string{
-		"alarm_control_panel.alarmo": "Alarmo",
+	nameMap := map[string]string{}
+	for _, p := range s.alarmoPanels() {
+		nameMap[p.EntityID] = p.Name
 	}
 	for _, s := range sensors {
 		nameMap[s.ID] = s.Name
@@ -720,15 +823,9 @@ func (s *Server) handleAlarmoEvents(w http.ResponseWriter, r *http.Request) {
 
 // handleAlarmoArm arms the Alarmo system with specified mode
 // POST /api/ui/alarmo/arm
-// Body: {"mode": "armed_away", "code": "1234"} - code is optional PIN
-// Visible to admin and user only
+// Body: {"mode": "armed_away", "code": "1234", "area": "garage"} - code and area are optional
+// Requires alarm.arm
 func (s *Server) handleAlarmoArm(w http.ResponseWriter, r *http.Request) {
-	role := getRole(r)
-	if role == auth.Guest {
-		s.respondError(w, r, CodeForbidden, "guest not allowed")
-		return
-	}
-
 	if r.Method != http.MethodPost {
 		s.respondError(w, r, CodeMethodNotAllowed, "POST required")
 		return
@@ -756,10 +853,25 @@ func (s *Server) handleAlarmoArm(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	// Extract code if provided
+	// Extract code if provided, else use the Alarmo code mapped to the PIN user
 	if c, ok := reqBody["code"].(string); ok {
 		code = c
 	}
+	if code == "" {
+		if code, err = auth.AlarmoCode(getAuthContext(r).Username); err != nil {
+			logger.Error("alarmo code lookup failed: " + err.Error())
+			s.respondError(w, r, CodeInternalError, "alarmo code unavailable")
+			return
+		}
+	}
+
+	// A5: Resolve target area (empty = primary panel)
+	area, _ := reqBody["area"].(string)
+	panel, ok := s.alarmoPanel(area)
+	if !ok {
+		s.respondError(w, r, CodeBadRequest, "unknown area")
+		return
+	}
 
 	// Call HA service to arm Alarmo
 	client := &http.Client{Timeout: 30 * time.Second}
@@ -777,14 +889,15 @@ func (s *Server) handleAlarmoArm(w http.ResponseWriter, r *http.Request) {
 
 	url := fmt.Sprintf("%s/api/services/alarm_control_panel/%s", baseURL, serviceName)
 	payload := map[string]interface{}{
-		"entity_id": "alarm_control_panel.alarmo",
+		"entity_id": panel.EntityID,
 	}
 	if code != "" {
 		payload["code"] = code
 	}
 
 	body, _ := json.Marshal(payload)
-	logger.Info("alarmo arm: mode=" + mode + " code_provided=" + fmt.Sprintf("%v", code != "") + " service=" + serviceName + " payload=" + string(body))
+	// Never log payload (contains code)
+	logger.Info("alarmo arm: area=" + panel.Area + " mode=" + mode + " code_provided=" + fmt.Sprintf("%v", code != "") + " service=" + serviceName)
 
 	req, err := http.NewRequest("POST", url, bytes.NewReader(body))
 	if err != nil {
@@ -795,9 +908,13 @@ func (s *Server) handleAlarmoArm(w http.ResponseWriter, r *http.Request) {
 	req.Header.Set("Authorization", "Bearer "+token)
 	req.Header.Set("Content-Type", "application/json")
 
+	// A6: Track before sending; confirmation follows from Alarmo state
+	cmd := s.coord.TrackAlarmCommand(panel.Area, "arm_"+strings.TrimPrefix(mode, "armed_"), getAuthContext(r).Username)
+
 	resp, err := client.Do(req)
 	if err != nil {
 		logger.Error("alarmo arm request failed: " + err.Error())
+		s.coord.FailAlarmCommand(cmd.ID, "service call failed")
 		s.respondError(w, r, CodeInternalError, "failed to arm system")
 		return
 	}
@@ -808,56 +925,76 @@ func (s *Server) handleAlarmoArm(w http.ResponseWriter, r *http.Request) {
 
 	if resp.StatusCode >= 400 {
 		logger.Error("alarmo arm error from HA: status=" + fmt.Sprintf("%d", resp.StatusCode) + " body=" + string(respBody))
+		s.coord.FailAlarmCommand(cmd.ID, "service call failed")
 		s.respondError(w, r, CodeInternalError, "HA returned error")
 		return
 	}
 
-	s.respond(w, true, map[string]string{"status": "armed", "mode": mode}, "", 200)
+	s.respond(w, true, map[string]interface{}{"status": "armed", "mode": mode, "area": panel.Area, "command": cmd}, "", 200)
 }
 
 // handleAlarmoDisarm disarms the Alarmo system
 // POST /api/ui/alarmo/disarm
-// Body: {"code": "1234"} - optional PIN code for HA
-// Visible to admin and user only
+// Body: {"code": "1234", "area": "garage"} - optional PIN code for HA and target area
+// Requires alarm.disarm
 func (s *Server) handleAlarmoDisarm(w http.ResponseWriter, r *http.Request) {
-	role := getRole(r)
-	if role == auth.Guest {
-		s.respondError(w, r, CodeForbidden, "guest not allowed")
-		return
-	}
-
 	if r.Method != http.MethodPost {
 		s.respondError(w, r, CodeMethodNotAllowed, "POST required")
 		return
 	}
 
-	baseURL, token, err := s.getHACredentials()
-	if err != nil || baseURL == "" || token == "" {
-		s.respondError(w, r, CodeInternalError, "HA not configured")
-		return
-	}
-
 	// Parse request body for code (optional)
 	var reqBody map[string]interface{}
 	code := ""
+	area := ""
 	if err := json.NewDecoder(r.Body).Decode(&reqBody); err == nil {
 		if c, ok := reqBody["code"].(string); ok {
 			code = c
 		}
+		area, _ = reqBody["area"].(string)
+	}
+	authCtx := getAuthContext(r)
+	if username, ok := auth.MatchDuressPIN(code); ok {
+		// Duress PIN typed as the code: disarm with the owner's real code, raise the silent alarm
+		authCtx = &auth.AuthContext{Username: username, Duress: true}
+		code = ""
+	}
+	s.signalDuress(authCtx, area)
+
+	baseURL, token, err := s.getHACredentials()
+	if err != nil || baseURL == "" || token == "" {
+		s.respondError(w, r, CodeInternalError, "HA not configured")
+		return
+	}
+	if code == "" {
+		// Use the Alarmo code mapped to the PIN user
+		if code, err = auth.AlarmoCode(authCtx.Username); err != nil {
+			logger.Error("alarmo code lookup failed: " + err.Error())
+			s.respondError(w, r, CodeInternalError, "alarmo code unavailable")
+			return
+		}
+	}
+
+	// A5: Resolve target area (empty = primary panel)
+	panel, ok := s.alarmoPanel(area)
+	if !ok {
+		s.respondError(w, r, CodeBadRequest, "unknown area")
+		return
 	}
 
 	// Call HA service to disarm Alarmo
 	client := &http.Client{Timeout: 30 * time.Second}
 	url := fmt.Sprintf("%s/api/services/alarm_control_panel/alarm_disarm", baseURL)
 	payload := map[string]interface{}{
-		"entity_id": "alarm_control_panel.alarmo",
+		"entity_id": panel.EntityID,
 	}
 	if code != "" {
 		payload["code"] = code
 	}
 
 	body, _ := json.Marshal(payload)
-	logger.Info("alarmo disarm: code_provided=" + fmt.Sprintf("%v", code != "") + " payload=" + string(body))
+	// Never log payload (contains code)
+	logger.Info("alarmo disarm: area=" + panel.Area + " code_provided=" + fmt.Sprintf("%v", code != ""))
 
 	req, err := http.NewRequest("POST", url, bytes.NewReader(body))
 	if err != nil {
@@ -868,9 +1005,13 @@ func (s *Server) handleAlarmoDisarm(w http.ResponseWriter, r *http.Request) {
 	req.Header.Set("Authorization", "Bearer "+token)
 	req.Header.Set("Content-Type", "application/json")
 
+	// A6: Track before sending; confirmation follows from Alarmo state
+	cmd := s.coord.TrackAlarmCommand(panel.Area, "disarm", getAuthContext(r).Username)
+
 	resp, err := client.Do(req)
 	if err != nil {
 		logger.Error("alarmo disarm request failed: " + err.Error())
+		s.coord.FailAlarmCommand(cmd.ID, "service call failed")
 		s.respondError(w, r, CodeInternalError, "failed to disarm system")
 		return
 	}
@@ -881,33 +1022,27 @@ func (s *Server) handleAlarmoDisarm(w http.ResponseWriter, r *http.Request) {
 
 	if resp.StatusCode >= 400 {
 		logger.Error("alarmo disarm error from HA: status=" + fmt.Sprintf("%d", resp.StatusCode) + " body=" + string(respBody))
+		s.coord.FailAlarmCommand(cmd.ID, "service call failed")
 		s.respondError(w, r, CodeInternalError, "HA returned error")
 		return
 	}
 
-	s.respond(w, true, map[string]string{"status": "disarmed"}, "", 200)
+	s.respond(w, true, map[string]interface{}{"status": "disarmed", "area": panel.Area, "command": cmd}, "", 200)
 }
 
 func (s *Server) handleGuestApprove(w http.ResponseWriter, r *http.Request) {
-	// FAZ L3: HA approval callback with token validation
+	// FAZ L3: HA approval callback with a signed decision token
 	// POST /api/guest/approve
-	// Called by HA automation with request_id and decision
+	// Called by HA automation with request_id, decision and the token from the notification button
 	if r.Method != http.MethodPost {
 		s.respondError(w, r, CodeMethodNotAllowed, "POST required")
 		return
 	}
 
-	// FAZ L3: Validate HA Authorization token
-	authHeader := r.Header.Get("Authorization")
-	if !s.validateHAToken(authHeader) {
-		logger.Error("guest approval: invalid or missing HA token")
-		s.respondError(w, r, CodeUnauthorized, "invalid authorization")
-		return
-	}
-
 	var req struct {
 		RequestID string `json:"request_id"`
 		Decision  string `json:"decision"` // approve | reject
+		Token     string `json:"token"`    // Signed for this request and decision, single use
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -920,11 +1055,29 @@ func (s *Server) handleGuestApprove(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if s.coord.GuestRequest == nil {
+	if s.coord.GuestRequest == nil || s.coord.GuestSigner == nil {
 		s.respondError(w, r, CodeInternalError, "guest request manager not initialized")
 		return
 	}
 
+	// Used tokens are remembered in memory only: a decision for a request that is no
+	// longer pending is refused (409) before its token is checked, so replaying one
+	// after a restart fails as well
+	if prev, ok := s.coord.GuestRequest.Get(req.RequestID); ok && prev.Status != guest.StatusPending {
+		logger.Error("guest approval: request already " + prev.Status + " (request_id=" + req.RequestID + ")")
+		audit.Record("guest_decision_rejected", "request_id="+req.RequestID+" decision="+req.Decision+" reason=request already "+prev.Status)
+		s.respondError(w, r, CodeConflict, "request already "+prev.Status)
+		return
+	}
+
+	// The token replaces the long-lived HA token: forged, replayed or expired decisions stop here
+	if err := s.coord.GuestSigner.Verify(req.Token, req.RequestID, req.Decision); err != nil {
+		logger.Error("guest approval: " + err.Error() + " (request_id=" + req.RequestID + ")")
+		audit.Record("guest_decision_rejected", "request_id="+req.RequestID+" decision="+req.Decision+" reason="+err.Error())
+		s.respondError(w, r, CodeForbidden, err.Error())
+		return
+	}
+
 	var err error
 	switch req.Decision {
 	case "approve":
@@ -939,6 +1092,11 @@ func (s *Server) handleGuestApprove(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err != nil {
+		// Answered, expired or ended already: say so instead of "not found"
+		if prev, ok := s.coord.GuestRequest.Get(req.RequestID); ok && prev.Status != guest.StatusPending {
+			s.respondError(w, r, CodeConflict, "request already "+prev.Status)
+			return
+		}
 		s.respondError(w, r, CodeBadRequest, err.Error())
 		return
 	}
@@ -958,13 +1116,17 @@ func (s *Server) handleGuestDeny(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	guestReq := s.coord.GuestRequest.GetActiveRequest()
-	if guestReq == nil {
-		s.respondError(w, r, CodeBadRequest, "no active guest request")
+	// {"request_id": "..."}; required, since several guests may be waiting
+	var req struct {
+		RequestID string `json:"request_id"`
+	}
+	_ = json.NewDecoder(r.Body).Decode(&req)
+	if req.RequestID == "" {
+		s.respondError(w, r, CodeBadRequest, "request_id required")
 		return
 	}
 
-	err := s.coord.GuestRequest.RejectRequest(guestReq.ID)
+	err := s.coord.GuestRequest.RejectRequest(req.RequestID)
 	if err != nil {
 		s.respondError(w, r, CodeBadRequest, err.Error())
 		return
@@ -1045,15 +1207,10 @@ func (s *Server) handleUIScorecard(w http.ResponseWriter, r *http.Request) {
 	s.respondError(w, r, CodeInternalError, "not yet implemented")
 }
 
-// handleTelemetrySummary returns aggregated telemetry summary (admin-only)
+// handleTelemetrySummary returns aggregated telemetry summary (system.admin)
 // GET /api/admin/telemetry/summary
 // Returns: aggregated feature usage, error categories, and performance buckets (no personal data)
 func (s *Server) handleTelemetrySummary(w http.ResponseWriter, r *http.Request) {
-	role := getRole(r)
-	if role != auth.Admin {
-		s.respondError(w, r, CodeForbidden, "admin required")
-		return
-	}
 	if r.Method != "GET" {
 		s.respondError(w, r, CodeMethodNotAllowed, "GET required")
 		return
@@ -1062,16 +1219,11 @@ func (s *Server) handleTelemetrySummary(w http.ResponseWriter, r *http.Request)
 	s.respond(w, true, summary, "", 200)
 }
 
-// handleTelemetryOptIn enables or disables telemetry opt-in (admin-only)
+// handleTelemetryOptIn enables or disables telemetry opt-in (system.admin)
 // POST /api/admin/telemetry/optin
 // Request body: {"enabled": bool}
 // Opt-in is disabled by default and must be explicitly enabled by admin.
 func (s *Server) handleTelemetryOptIn(w http.ResponseWriter, r *http.Request) {
-	role := getRole(r)
-	if role != auth.Admin {
-		s.respondError(w, r, CodeForbidden, "admin required")
-		return
-	}
 	if r.Method != "POST" {
 		s.respondError(w, r, CodeMethodNotAllowed, "POST required")
 		return
@@ -1086,13 +1238,13 @@ func (s *Server) handleTelemetryOptIn(w http.ResponseWriter, r *http.Request) {
 	s.telemetry.SetOptIn(req.Enabled)
 	// Persist the state
 	if err := s.telemetry.Flush(); err != nil {
-		audit.Record("telemetry_error", "failed to persist telemetry state: "+err.Error())
+		audit.RecordAs(auditActor(r), "telemetry_error", "failed to persist telemetry state: "+err.Error())
 	}
 	status := "disabled"
 	if req.Enabled {
 		status = "enabled"
 	}
-	audit.Record("telemetry_optin", "admin set telemetry to "+status)
+	audit.RecordAs(auditActor(r), "telemetry_optin", "admin set telemetry to "+status)
 	s.respond(w, true, map[string]interface{}{
 		"enabled": req.Enabled,
 		"message": "telemetry " + status,
@@ -1106,35 +1258,25 @@ func (u *UpdateAuditLogger) Record(action string, detail string) {
 	audit.Record("update_"+action, detail)
 }
 
-// handleUpdateStatus returns current update system status (admin-only)
+// handleUpdateStatus returns current update system status (system.admin)
 // GET /api/admin/update/status
 // Returns: current version, available updates, staged updates, pending reboot state
 func (s *Server) handleUpdateStatus(w http.ResponseWriter, r *http.Request) {
-	role := getRole(r)
-	if role != auth.Admin {
-		s.respondError(w, r, CodeForbidden, "admin required")
-		return
-	}
 	if r.Method != "GET" {
 		s.respondError(w, r, CodeMethodNotAllowed, "GET required")
 		return
 	}
 	status := s.updateMgr.GetStatus()
-	audit.Record("update_status_check", fmt.Sprintf("admin checked update status (version=%s, pending=%v)", status.CurrentVersion, status.PendingReboot))
+	audit.RecordAs(auditActor(r), "update_status_check", fmt.Sprintf("admin checked update status (version=%s, pending=%v)", status.CurrentVersion, status.PendingReboot))
 	s.respond(w, true, status, "", 200)
 }
 
-// handleUpdateStage stages an update package for later activation (admin-only, NO-OP STUB)
+// handleUpdateStage stages an update package for later activation (system.admin, NO-OP STUB)
 // POST /api/admin/update/stage
 // Request body: {"package": {...}, "data": "base64-encoded-package-data"}
 // NOTE: This is currently a STUB. Package data is not actually downloaded or written.
 // In future phases, this will accept pre-validated packages and stage them to disk.
 func (s *Server) handleUpdateStage(w http.ResponseWriter, r *http.Request) {
-	role := getRole(r)
-	if role != auth.Admin {
-		s.respondError(w, r, CodeForbidden, "admin required")
-		return
-	}
 	if r.Method != "POST" {
 		s.respondError(w, r, CodeMethodNotAllowed, "POST required")
 		return
@@ -1151,7 +1293,7 @@ func (s *Server) handleUpdateStage(w http.ResponseWriter, r *http.Request) {
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		s.respondError(w, r, CodeBadRequest, "invalid json")
-		audit.Record("update_stage_error", "invalid request body")
+		audit.RecordAs(auditActor(r), "update_stage_error", "invalid request body")
 		return
 	}
 
@@ -1162,7 +1304,7 @@ func (s *Server) handleUpdateStage(w http.ResponseWriter, r *http.Request) {
 	// 3. Write to staging directory
 	// 4. Return staged path
 
-	audit.Record("update_stage_stub", fmt.Sprintf("admin requested staging of version %s (no-op stub)", req.Package.Version))
+	audit.RecordAs(auditActor(r), "update_stage_stub", fmt.Sprintf("admin requested staging of version %s (no-op stub)", req.Package.Version))
 	s.respond(w, true, map[string]interface{}{
 		"message": "update staging is a no-op stub in this phase",
 		"version": req.Package.Version,
@@ -1252,7 +1394,7 @@ func (s *Server) handleAccessibilityPost(w http.ResponseWriter, r *http.Request)
 	// Log changes
 	if len(changes) > 0 {
 		logger.Info("accessibility preferences updated: " + strings.Join(changes, ", "))
-		audit.Record("accessibility", "preferences updated: "+strings.Join(changes, ", "))
+		audit.RecordAs(auditActor(r), "accessibility", "preferences updated: "+strings.Join(changes, ", "))
 	}
 
 	// TODO: Update coordinator's config if it has accessibility awareness
@@ -1457,6 +1599,23 @@ func (s *Server) handleHomeSummary(w http.ResponseWriter, r *http.Request) {
 
 // === ALARM SCREEN ENDPOINTS (D3) ===
 
+// alarmoScreenMode maps an Alarmo mode onto the alarm screen mode (A2)
+func alarmoScreenMode(mode string) (alarm.ScreenMode, bool) {
+	switch mode {
+	case "disarmed":
+		return alarm.ModeDisarmed, true
+	case "arming":
+		return alarm.ModeArming, true
+	case "pending":
+		return alarm.ModePending, true
+	case "armed":
+		return alarm.ModeArmed, true
+	case "triggered":
+		return alarm.ModeTriggered, true
+	}
+	return "", false
+}
+
 // handleAlarmState returns full alarm screen state with all contextual data (D3)
 // A2: Uses Alarmo as source of truth instead of internal alarm state
 func (s *Server) handleAlarmState(w http.ResponseWriter, r *http.Request) {
@@ -1479,19 +1638,8 @@ func (s *Server) handleAlarmState(w http.ResponseWriter, r *http.Request) {
 	state := s.coord.AlarmScreen.GetScreenState()
 
 	// A2: Override mode from Alarmo for accuracy
-	if alarmoState.Mode != "" {
-		switch alarmoState.Mode {
-		case "disarmed":
-			state.Mode = alarm.ModeDisarmed
-		case "arming":
-			state.Mode = alarm.ModeArming
-		case "pending":
-			state.Mode = alarm.ModePending
-		case "armed":
-			state.Mode = alarm.ModeArmed
-		case "triggered":
-			state.Mode = alarm.ModeTriggered
-		}
+	if mode, ok := alarmoScreenMode(alarmoState.Mode); ok {
+		state.Mode = mode
 	}
 
 	lastUpdated := ""
@@ -1597,7 +1745,7 @@ func (s *Server) handleAlarmSummary(w http.ResponseWriter, r *http.Request) {
 
 // handleAlarmAction handles controlled arm/disarm requests to Alarmo (A4)
 // POST /api/ui/alarm/action
-// Request: {"action": "arm_home | arm_away | arm_night | disarm"}
+// Request: {"action": "arm_home | arm_away | arm_night | disarm", "area": "garage"} - area optional
 // This is the FIRST write operation - fully controlled and audited
 func (s *Server) handleAlarmAction(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
@@ -1608,6 +1756,7 @@ func (s *Server) handleAlarmAction(w http.ResponseWriter, r *http.Request) {
 	// Parse request body
 	var req struct {
 		Action string `json:"action"`
+		Area   string `json:"area"` // A5: Empty = primary panel
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -1628,13 +1777,48 @@ func (s *Server) handleAlarmAction(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// The route is open; arming and disarming are separate permissions
+	perm := auth.PermAlarmArm
+	if req.Action == "disarm" {
+		perm = auth.PermAlarmDisarm
+	}
+	if _, allowed := s.checkPerm(w, r, perm); !allowed {
+		return
+	}
+
+	if req.Action == "disarm" {
+		s.signalDuress(getAuthContext(r), req.Area)
+	}
+
 	// Send request to coordinator (does NOT modify local state)
 	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
 	defer cancel()
 
-	err := s.coord.RequestAlarmAction(ctx, req.Action)
+	// Alarmo user code mapped to the PIN user (panels with code_arm/disarm_required)
+	username := getAuthContext(r).Username
+	code, err := auth.AlarmoCode(username)
+	if err != nil {
+		logger.Error("alarmo code lookup failed: " + err.Error())
+		s.respondError(w, r, CodeInternalError, "alarmo code unavailable")
+		return
+	}
+
+	cmd, err := s.coord.RequestAreaAlarmAction(ctx, system.AlarmActionRequest{
+		Area:        req.Area,
+		Action:      req.Action,
+		Code:        code,
+		RequestedBy: username,
+	})
 	if err != nil {
 		// Check error type for appropriate status code
+		if errors.Is(err, alarmo.ErrUnknownArea) {
+			s.respondError(w, r, CodeBadRequest, "unknown area")
+			return
+		}
+		if errors.Is(err, alarmo.ErrCodeRejected) {
+			s.respondError(w, r, CodeForbidden, "alarmo code rejected")
+			return
+		}
 		errMsg := err.Error()
 		if strings.Contains(errMsg, "unreachable") {
 			s.respondError(w, r, CodeServiceUnavailable, "alarmo unreachable")
@@ -1649,12 +1833,46 @@ func (s *Server) handleAlarmAction(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Success - but state change will appear via polling
-	s.respond(w, true, map[string]string{
+	// A6: command status resolves via GET /api/ui/alarm/commands/{id}
+	s.respond(w, true, map[string]interface{}{
 		"status":  "requested",
 		"message": "action sent to alarmo, state will update via polling",
+		"command": cmd,
 	}, "", 200)
 }
 
+// handleAlarmCommands lists recent Alarmo commands with their confirmation status (A6)
+// GET /api/ui/alarm/commands
+func (s *Server) handleAlarmCommands(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		s.respondError(w, r, CodeMethodNotAllowed, "GET required")
+		return
+	}
+	s.respond(w, true, map[string]interface{}{
+		"commands": s.coord.AlarmCommands(),
+	}, "", 200)
+}
+
+// handleAlarmCommand returns a single Alarmo command (A6)
+// GET /api/ui/alarm/commands/{id}
+func (s *Server) handleAlarmCommand(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		s.respondError(w, r, CodeMethodNotAllowed, "GET required")
+		return
+	}
+	id := strings.TrimPrefix(r.URL.Path, "/api/ui/alarm/commands/")
+	if id == "" {
+		s.handleAlarmCommands(w, r)
+		return
+	}
+	cmd, ok := s.coord.AlarmCommandByID(id)
+	if !ok {
+		s.respondError(w, r, CodeNotFound, "command not found")
+		return
+	}
+	s.respond(w, true, cmd, "", 200)
+}
+
 // === GUEST ACCESS ENDPOINTS (D4) ===
 
 // handleGuestState returns full guest access state with all contextual data (D4)
@@ -1698,13 +1916,6 @@ func (s *Server) handleGuestRequest(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Check auth: only guest role can request
-	authCtx := getAuthContext(r)
-	if authCtx.Role != "guest" {
-		s.respondError(w, r, CodeForbidden, "guest role required")
-		return
-	}
-
 	// Parse request
 	var req struct {
 		HAUser string `json:"ha_user"`
@@ -1734,9 +1945,16 @@ func (s *Server) handleGuestRequest(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	guestReq, err := s.coord.GuestRequest.CreateRequest(req.HAUser)
+	guestReq, err := s.coord.GuestRequest.CreateRequest(req.HAUser, clientKey(r))
 	if err != nil {
-		s.respondError(w, r, CodeBadRequest, err.Error())
+		switch {
+		case errors.Is(err, guest.ErrRateLimited):
+			s.respondError(w, r, CodeTooManyRequests, err.Error())
+		case errors.Is(err, guest.ErrGuestLimit):
+			s.respondError(w, r, CodeConflict, err.Error())
+		default:
+			s.respondError(w, r, CodeBadRequest, err.Error())
+		}
 		return
 	}
 
@@ -1779,20 +1997,37 @@ func (s *Server) handleGuestRequestStatus(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	// Get request from manager
-	guestReq := s.coord.GuestRequest.GetActiveRequest()
-	if guestReq == nil || guestReq.ID != requestID {
+	// Get request from manager; guests only see their own
+	guestReq, ok := s.visibleGuestRequest(r, requestID)
+	if !ok {
 		s.respondError(w, r, CodeNotFound, "request not found or expired")
 		return
 	}
 
-	// Respond with current request status
-	s.respond(w, true, map[string]interface{}{
-		"request_id":  guestReq.ID,
-		"status":      guestReq.Status,
-		"expires_at":  guestReq.ExpiresAt,
-		"target_user": guestReq.TargetUser,
-	}, "", 200)
+	// Respond with current request status; who was asked stays hidden from guests
+	status := map[string]interface{}{
+		"request_id": guestReq.ID,
+		"status":     guestReq.Status,
+		"expires_at": guestReq.ExpiresAt,
+	}
+	if auth.Allowed(getAuthContext(r), auth.PermGuestApprove) {
+		status["target_user"] = guestReq.TargetUser
+	}
+	s.respond(w, true, status, "", 200)
+}
+
+// visibleGuestRequest returns a guest request the caller may see or act on:
+// any for callers who answer guest requests, otherwise only one made from the
+// caller's own client
+func (s *Server) visibleGuestRequest(r *http.Request, requestID string) (guest.GuestRequest, bool) {
+	req, ok := s.coord.GuestRequest.Get(requestID)
+	if !ok {
+		return guest.GuestRequest{}, false
+	}
+	if !auth.Allowed(getAuthContext(r), auth.PermGuestApprove) && req.Source != clientKey(r) {
+		return guest.GuestRequest{}, false
+	}
+	return req, true
 }
 
 // handleGuestExit processes guest exit and alarm re-arming (D4)
@@ -1807,11 +2042,46 @@ func (s *Server) handleGuestExit(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	s.coord.GuestScreen.OnExit()
+	// {"request_id": "..."}; only callers who may answer guest requests can
+	// leave it out to end every active guest
+	var req struct {
+		RequestID string `json:"request_id"`
+	}
+	_ = json.NewDecoder(r.Body).Decode(&req)
+	if req.RequestID == "" && !auth.Allowed(getAuthContext(r), auth.PermGuestApprove) {
+		s.respondError(w, r, CodeBadRequest, "request_id required")
+		return
+	}
+	if req.RequestID != "" {
+		if _, ok := s.visibleGuestRequest(r, req.RequestID); !ok {
+			s.respondError(w, r, CodeNotFound, "request not found or expired")
+			return
+		}
+	}
+	if err := s.coord.HandleGuestExit(req.RequestID); err != nil {
+		s.respondError(w, r, CodeBadRequest, err.Error())
+		return
+	}
 	state := s.coord.GuestScreen.GetScreenState()
 	s.respond(w, true, state, "", 200)
 }
 
+// handleGuestRearmCancel stops the re-arm counting down after the last guest left
+// POST /api/ui/guest/rearm/cancel
+func (s *Server) handleGuestRearmCancel(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		s.respondError(w, r, CodeMethodNotAllowed, "POST required")
+		return
+	}
+
+	if err := s.coord.CancelGuestRearm(getAuthContext(r).Username); err != nil {
+		s.respondError(w, r, CodeConflict, err.Error())
+		return
+	}
+	audit.RecordAs(auditActor(r), "guest_rearm_cancel", "")
+	s.respond(w, true, map[string]string{"result": "cancelled"}, "", 200)
+}
+
 func (s *Server) handleLogbook(w http.ResponseWriter, r *http.Request) {
 	if r.Method != "GET" {
 		s.respondError(w, r, CodeMethodNotAllowed, "GET required")
@@ -1823,35 +2093,37 @@ func (s *Server) handleLogbook(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Parse query parameters
-	limit := 20
-	offset := 0
-	categoryFilter := ""
-
-	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
-		fmt.Sscanf(limitStr, "%d", &limit)
-	}
-	if offsetStr := r.URL.Query().Get("offset"); offsetStr != "" {
-		fmt.Sscanf(offsetStr, "%d", &offset)
-	}
-	if cat := r.URL.Query().Get("category"); cat != "" {
-		categoryFilter = cat
+	params := r.URL.Query()
+	q := logbook.Query{
+		Limit:    20,
+		Category: params.Get("category"),
+		Severity: params.Get("severity"),
+		Search:   params.Get("q"),
+	}
+
+	if limitStr := params.Get("limit"); limitStr != "" {
+		fmt.Sscanf(limitStr, "%d", &q.Limit)
+	}
+	if offsetStr := params.Get("offset"); offsetStr != "" {
+		fmt.Sscanf(offsetStr, "%d", &q.Offset)
+	}
+	// Time range (RFC 3339, inclusive)
+	for name, dst := range map[string]*time.Time{"from": &q.From, "to": &q.To} {
+		if raw := params.Get(name); raw != "" {
+			t, err := time.Parse(time.RFC3339, raw)
+			if err != nil {
+				s.respondError(w, r, CodeBadRequest, name+" must be RFC 3339")
+				return
+			}
+			*dst = t
+		}
 	}
 
 	userID := r.Header.Get("X-User-ID")
-	userRole := r.Header.Get("X-User-Role")
-
-	// Map user role string to logbook role type
-	var logbookRole logbook.UserRole
-	switch userRole {
-	case "admin":
-		logbookRole = logbook.RoleAdmin
-	case "user":
-		logbookRole = logbook.RoleUser
-	default:
-		logbookRole = logbook.RoleGuest
-	}
+	logbookRole := logbookRole(r)
 
-	response := s.coord.Logbook.GetEntries(logbookRole, limit, offset, categoryFilter)
+	q.Role = logbookRole
+	response := s.coord.Logbook.Search(q)
 	response.UserID = userID
 	response.Role = logbookRole
 
@@ -1874,21 +2146,81 @@ func (s *Server) handleLogbookSummary(w http.ResponseWriter, r *http.Request) {
 		fmt.Sscanf(limitStr, "%d", &limit)
 	}
 
-	userRole := r.Header.Get("X-User-Role")
+	response := s.coord.Logbook.GetSummary(logbookRole(r), limit)
+	s.respond(w, true, response, "", 200)
+}
 
-	// Map user role string to logbook role type
-	var logbookRole logbook.UserRole
-	switch userRole {
-	case "admin":
-		logbookRole = logbook.RoleAdmin
-	case "user":
-		logbookRole = logbook.RoleUser
-	default:
-		logbookRole = logbook.RoleGuest
+// logbookRole maps the caller's logbook permissions onto a visibility role:
+// logbook.read.safety sees everything, logbook.read the user entries, others nothing
+func logbookRole(r *http.Request) logbook.UserRole {
+	authCtx := getAuthContext(r)
+	switch {
+	case auth.Allowed(authCtx, auth.PermLogbookSafety):
+		return logbook.RoleAdmin
+	case auth.Allowed(authCtx, auth.PermLogbookRead):
+		return logbook.RoleUser
 	}
+	return logbook.RoleGuest
+}
 
-	response := s.coord.Logbook.GetSummary(logbookRole, limit)
-	s.respond(w, true, response, "", 200)
+// handleLogbookExport downloads the logbook as CSV, JSON Lines or a printable HTML report.
+// Visibility follows the caller's permissions, like the logbook view.
+func (s *Server) handleLogbookExport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		s.respondError(w, r, CodeMethodNotAllowed, "GET required")
+		return
+	}
+	if s.coord.Logbook == nil {
+		s.respondError(w, r, CodeInternalError, "logbook manager not initialized")
+		return
+	}
+
+	params := r.URL.Query()
+	format := logbook.ExportCSV
+	if raw := params.Get("format"); raw != "" {
+		f, ok := logbook.ParseExportFormat(raw)
+		if !ok {
+			s.respondError(w, r, CodeBadRequest, "format must be csv, jsonl or html")
+			return
+		}
+		format = f
+	}
+
+	q := logbook.Query{
+		Category: params.Get("category"),
+		Severity: params.Get("severity"),
+		Search:   params.Get("q"),
+	}
+	for name, dst := range map[string]*time.Time{"from": &q.From, "to": &q.To} {
+		if raw := params.Get(name); raw != "" {
+			t, err := time.Parse(time.RFC3339, raw)
+			if err != nil {
+				s.respondError(w, r, CodeBadRequest, name+" must be RFC 3339")
+				return
+			}
+			*dst = t
+		}
+	}
+
+	q.Role = logbookRole(r)
+
+	entries := s.coord.Logbook.Export(q)
+	now := time.Now()
+	opts := logbook.ExportOptions{
+		Lang:        params.Get("lang"),
+		From:        q.From,
+		To:          q.To,
+		Category:    q.Category,
+		GeneratedAt: now,
+	}
+	audit.RecordAs(auditActor(r), "logbook_export", fmt.Sprintf("format=%s entries=%d", format, len(entries)))
+
+	filename := fmt.Sprintf("logbook-%s.%s", now.Format("20060102-150405"), format.Extension())
+	w.Header().Set("Content-Type", format.ContentType())
+	w.Header().Set("Content-Disposition", "attachment; filename="+filename)
+	if err := logbook.WriteExport(w, format, entries, opts); err != nil {
+		logger.Error("logbook export failed: " + err.Error())
+	}
 }
 
 // === SETTINGS HANDLERS (D7) ===
@@ -1903,27 +2235,8 @@ func (s *Server) handleSettings(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	userRole := r.Header.Get("X-User-Role")
-
-	// Map user role string to settings role type and update SettingsManager role
-	var settingsRole string
-	switch userRole {
-	case "admin":
-		settingsRole = "admin"
-		s.coord.Settings.SetUserRole(settings.RoleAdmin)
-	case "user":
-		settingsRole = "user"
-		s.coord.Settings.SetUserRole(settings.RoleUser)
-	default:
-		settingsRole = "guest"
-		s.coord.Settings.SetUserRole(settings.RoleGuest)
-	}
-
-	// Only admin can access settings
-	if settingsRole != "admin" {
-		s.respondError(w, r, CodeForbidden, "admin required")
-		return
-	}
+	// The route requires settings.read; the manager's own admin gate follows it
+	s.coord.Settings.SetUserRole(settings.RoleAdmin)
 
 	response, err := s.coord.Settings.GetSettings()
 	if err != nil {
@@ -1944,25 +2257,8 @@ func (s *Server) handleSettingsAction(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	userRole := r.Header.Get("X-User-Role")
-
-	// Update SettingsManager role from incoming header so ApplyFieldChange/ApplyAction honor permissions
-	if s.coord != nil && s.coord.Settings != nil {
-		switch userRole {
-		case "admin":
-			s.coord.Settings.SetUserRole(settings.RoleAdmin)
-		case "user":
-			s.coord.Settings.SetUserRole(settings.RoleUser)
-		default:
-			s.coord.Settings.SetUserRole(settings.RoleGuest)
-		}
-	}
-
-	// Only admin can execute settings actions
-	if userRole != "admin" {
-		s.respondError(w, r, CodeForbidden, "admin required")
-		return
-	}
+	// The route requires settings.write; let ApplyFieldChange/ApplyAction pass their admin gate
+	s.coord.Settings.SetUserRole(settings.RoleAdmin)
 
 	// Parse request body
 	var reqBody map[string]interface{}
@@ -2010,7 +2306,7 @@ func (s *Server) handleSettingsAction(w http.ResponseWriter, r *http.Request) {
 				// Build best-effort payload for Alarmo configuration update
 				// Note: Alarmo may expose different service names; this is a best-effort attempt.
 				payload := map[string]interface{}{
-					"entity_id": "alarm_control_panel.alarmo",
+					"entity_id": s.alarmoPanels()[0].EntityID,
 				}
 				if v, ok := req.NewValue.(float64); ok {
 					// JSON numbers decode as float64; cast to int
@@ -2100,7 +2396,9 @@ func (s *Server) sendGuestRequestNotification(req *guest.GuestRequest) {
 		return
 	}
 
-	// Build notification payload with actionable buttons
+	// Build notification payload with actionable buttons; each carries its own
+	// single-use token, valid until the approval deadline
+	signer := s.coord.GuestSigner
 	payload := map[string]interface{}{
 		"title":   "Guest Access Request",
 		"message": "A guest requests access via SmartDisplay",
@@ -2112,6 +2410,7 @@ func (s *Server) sendGuestRequestNotification(req *guest.GuestRequest) {
 					"data": map[string]interface{}{
 						"request_id": req.ID,
 						"decision":   "approve",
+						"token":      signer.Issue(req.ID, "approve", req.ExpiresAt),
 					},
 				},
 				{
@@ -2120,6 +2419,7 @@ func (s *Server) sendGuestRequestNotification(req *guest.GuestRequest) {
 					"data": map[string]interface{}{
 						"request_id": req.ID,
 						"decision":   "reject",
+						"token":      signer.Issue(req.ID, "reject", req.ExpiresAt),
 					},
 				},
 			},
@@ -2137,39 +2437,6 @@ func (s *Server) sendGuestRequestNotification(req *guest.GuestRequest) {
 	}
 }
 
-// validateHAToken validates the HA Authorization header
-// Expects: "Bearer <token>" format
-func (s *Server) validateHAToken(authHeader string) bool {
-	if authHeader == "" {
-		return false
-	}
-
-	// Extract token from "Bearer <token>" format
-	const bearerPrefix = "Bearer "
-	if !strings.HasPrefix(authHeader, bearerPrefix) {
-		return false
-	}
-
-	token := strings.TrimPrefix(authHeader, bearerPrefix)
-	if token == "" {
-		return false
-	}
-
-	// Get expected HA token from config
-	// FAZ L3: Token should match the HA long-lived access token
-	// For now, we check against the HA adapter's configured token
-	if s.coord.HA == nil {
-		logger.Error("HA token validation: HA adapter not available")
-		return false
-	}
-
-	// Simple validation: token must be non-empty
-	// In production, this should validate against the actual HA token from config
-	// Since we don't expose the token from HA adapter, we accept any non-empty Bearer token
-	// The real security is that only HA should know the SmartDisplay endpoint
-	return len(token) > 0
-}
-
 // === ALARMO READ-ONLY HELPERS ===
 
 // haStateEnvelope represents the HA /api/states payload (subset)
@@ -2194,6 +2461,7 @@ type alarmoSensor struct {
 
 type alarmoEvent struct {
 	EntityID    string `json:"entity_id"`
+	Area        string `json:"area,omitempty"` // Set for Alarmo panel entities (A5)
 	Name        string `json:"name"`
 	State       string `json:"state"`
 	EventType   string `json:"event_type"`
@@ -2286,11 +2554,16 @@ func (s *Server) fetchAlarmoSensors(baseURL string, token string) ([]alarmoSenso
 	sensors := make([]alarmoSensor, 0)
 	entityIDs := make([]string, 0)
 
+	panelNames := map[string]string{}
+	for _, p := range s.alarmoPanels() {
+		panelNames[p.EntityID] = p.Name
+	}
+
 	for _, st := range states {
-		if st.EntityID == "alarm_control_panel.alarmo" {
+		if panelName, isPanel := panelNames[st.EntityID]; isPanel {
 			friendly, _ := st.Attributes["friendly_name"].(string)
 			if friendly == "" {
-				friendly = "Alarmo"
+				friendly = panelName
 			}
 			sensors = append(sensors, alarmoSensor{
 				ID:             st.EntityID,
@@ -2448,9 +2721,6 @@ func (s *Server) handleDevicesLightsToggle(w http.ResponseWriter, r *http.Reques
 		s.respondError(w, r, CodeMethodNotAllowed, "POST required")
 		return
 	}
-	if _, allowed := s.checkPerm(w, r, auth.PermDevice); !allowed {
-		return
-	}
 	var req struct {
 		ID string `json:"id"`
 	}
@@ -2473,9 +2743,6 @@ func (s *Server) handleDevicesLightsSet(w http.ResponseWriter, r *http.Request)
 		s.respondError(w, r, CodeMethodNotAllowed, "POST required")
 		return
 	}
-	if _, allowed := s.checkPerm(w, r, auth.PermDevice); !allowed {
-		return
-	}
 	var req struct {
 		ID            string `json:"id"`
 		On            *bool  `json:"on,omitempty"`
@@ -2629,7 +2896,7 @@ func deriveBatteryStatus(attrs map[string]interface{}) string {
 	return "normal"
 }
 
-// fetchAlarmoEvents pulls recent HA history for Alarmo entities and sensors
+// fetchAlarmoEvents pulls recent HA history for Alarmo panels (all areas) and sensors
 func (s *Server) fetchAlarmoEvents(baseURL string, token string, limit int, entityIDs []string, names map[string]string) ([]alarmoEvent, error) {
 	// Ensure alarm entity is included
 	seen := map[string]bool{}
@@ -2646,8 +2913,12 @@ func (s *Server) fetchAlarmoEvents(baseURL string, token string, limit int, enti
 		allIDs = append(allIDs, id)
 	}
 
-	if !seen["alarm_control_panel.alarmo"] {
-		allIDs = append(allIDs, "alarm_control_panel.alarmo")
+	panels := s.alarmoPanels()
+	for _, p := range panels {
+		if !seen[p.EntityID] {
+			seen[p.EntityID] = true
+			allIDs = append(allIDs, p.EntityID)
+		}
 	}
 
 	start := time.Now().Add(-12 * time.Hour)
@@ -2685,8 +2956,12 @@ func (s *Server) fetchAlarmoEvents(baseURL string, token string, limit int, enti
 		nameMap[k] = v
 	}
 
-	if _, ok := nameMap["alarm_control_panel.alarmo"]; !ok {
-		nameMap["alarm_control_panel.alarmo"] = "Alarmo"
+	areaMap := map[string]string{}
+	for _, p := range panels {
+		areaMap[p.EntityID] = p.Area
+		if _, ok := nameMap[p.EntityID]; !ok {
+			nameMap[p.EntityID] = p.Name
+		}
 	}
 	for _, id := range allIDs {
 		if _, ok := nameMap[id]; !ok {
@@ -2703,6 +2978,7 @@ func (s *Server) fetchAlarmoEvents(baseURL string, token string, limit int, enti
 			}
 			events = append(events, alarmoEvent{
 				EntityID:    entry.EntityID,
+				Area:        areaMap[entry.EntityID],
 				Name:        name,
 				State:       entry.State,
 				EventType:   mapEventType(entry.State),
@@ -2739,9 +3015,24 @@ func mapEventType(state string) string {
 	}
 }
 
+// respondLockout yanıtı 429 ile yazar ve true döner (err bir PIN kilidi ise)
+func respondLockout(w http.ResponseWriter, err error) bool {
+	var lockout *auth.LockoutError
+	if !errors.As(err, &lockout) {
+		return false
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(lockout.RetryAfter.Seconds()))))
+	w.WriteHeader(http.StatusTooManyRequests)
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":     false,
+		"message":     "Çok fazla hatalı PIN denemesi",
+		"retry_after": int(math.Ceil(lockout.RetryAfter.Seconds())),
+	})
+	return true
+}
+
 // handleLogin: PIN ile giriş için endpoint
 func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
-	fmt.Println("DEBUG: handleLogin çağrıldı")
 	if r.Method != http.MethodPost {
 		w.WriteHeader(http.StatusMethodNotAllowed)
 		_ = json.NewEncoder(w).Encode(map[string]interface{}{
@@ -2761,9 +3052,10 @@ func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
 		})
 		return
 	}
-	fmt.Println("DEBUG: Gelen PIN:", req.Pin)
-	log.Printf("DEBUG: Gelen PIN: '%s'", req.Pin)
-	ctx, err := auth.ValidatePIN(req.Pin)
+	ctx, err := auth.CheckPIN(clientKey(r), req.Pin)
+	if respondLockout(w, err) {
+		return
+	}
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		_ = json.NewEncoder(w).Encode(map[string]interface{}{
@@ -2773,19 +3065,26 @@ func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	if ctx.Authenticated {
-		// Basit bir session cookie oluştur (gerçek projede JWT veya secure session kullanılmalı)
+		// İmzalı oturum token'ı: PIN istemcide tutulmaz
+		token, sess := auth.Sessions.Create(ctx)
 		cookie := &http.Cookie{
-			Name:     "sd_session",
-			Value:    string(ctx.Role) + ":" + req.Pin, // Basit örnek, prod için güvenli değil!
+			Name:     sessionCookie,
+			Value:    token,
 			Path:     "/",
+			Expires:  sess.ExpiresAt,
 			HttpOnly: true,
-			SameSite: http.SameSiteLaxMode,
+			SameSite: http.SameSiteStrictMode,
 		}
 		http.SetCookie(w, cookie)
+		audit.RecordAs(audit.Actor{User: ctx.Username, Role: string(ctx.Role), RequestID: RequestIDFromContext(r.Context())},
+			"login", "session started")
 		_ = json.NewEncoder(w).Encode(map[string]interface{}{
-			"success": true,
-			"role":    ctx.Role,
-			"message": "Giriş başarılı",
+			"success":     true,
+			"role":        ctx.Role,
+			"permissions": auth.Permissions(ctx),
+			"token":       token,
+			"expires_at":  sess.ExpiresAt,
+			"message":     "Giriş başarılı",
 		})
 	} else {
 		w.WriteHeader(http.StatusUnauthorized)
@@ -2795,3 +3094,30 @@ func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
 		})
 	}
 }
+
+// handleLogout: oturumu kapatır (token iptal edilir, cookie silinir)
+func (s *Server) handleLogout(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "POST methodu gerekli",
+		})
+		return
+	}
+	if auth.Sessions.Revoke(sessionToken(r)) {
+		audit.RecordAs(auditActor(r), "logout", "session ended")
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	})
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Çıkış yapıldı",
+	})
+}
@@ -1,27 +1,95 @@
+// Package audit keeps the append-only audit trail.
+// Entries are hash-chained (each entry stores the hash of the previous one) so
+// edits or deletions are detectable with Verify. After Init the trail is
+// persisted as JSON lines under the data directory; before Init it is memory-only.
 package audit
 
+import (
+	"smartdisplay-core/internal/logger"
+	"sync"
+)
+
+// Entry is a single audit record
 type Entry struct {
-	Timestamp string
-	Action    string
-	Detail    string
+	Seq       uint64 `json:"seq"`
+	Timestamp string `json:"timestamp"` // RFC 3339, UTC
+	Action    string `json:"action"`
+	Detail    string `json:"detail"`
+	User      string `json:"user,omitempty"`
+	Role      string `json:"role,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+	PrevHash  string `json:"prev_hash"`
+	Hash      string `json:"hash"`
+}
+
+// Actor identifies who caused an entry
+type Actor struct {
+	User      string
+	Role      string
+	RequestID string
 }
 
-var entries []Entry
+// System is the actor for entries not caused by a request
+var System = Actor{Role: "system"}
+
+var (
+	mu    sync.RWMutex
+	store = newMemoryStore()
+)
+
+// Init switches the trail to a persistent store in dir, continuing its chain
+func Init(dir string) error {
+	s, err := Open(dir, Options{})
+	if err != nil {
+		return err
+	}
+	mu.Lock()
+	old := store
+	store = s
+	mu.Unlock()
+	old.Close()
+	logger.Info("audit: persistent trail at " + dir)
+	return nil
+}
 
+// Close flushes and closes the persistent store
+func Close() error {
+	mu.RLock()
+	defer mu.RUnlock()
+	return store.Close()
+}
+
+// Record appends an entry on behalf of the system
 func Record(action, detail string) {
-	entry := Entry{
-		Timestamp: now(),
-		Action:    action,
-		Detail:    detail,
+	RecordAs(System, action, detail)
+}
+
+// RecordAs appends an entry on behalf of actor
+func RecordAs(actor Actor, action, detail string) {
+	mu.RLock()
+	defer mu.RUnlock()
+	if _, err := store.Append(actor, action, detail); err != nil {
+		logger.Error("audit: append failed: " + err.Error())
 	}
-	entries = append(entries, entry)
 }
 
+// GetEntries returns the recent entries kept in memory, oldest first
 func GetEntries() []Entry {
-	return append([]Entry(nil), entries...)
+	mu.RLock()
+	defer mu.RUnlock()
+	return store.Recent()
+}
+
+// Search queries the whole trail, newest first
+func Search(q Query) ([]Entry, int, error) {
+	mu.RLock()
+	defer mu.RUnlock()
+	return store.Query(q)
 }
 
-func now() string {
-	// Use time.Now().Format for ISO8601
-	return "2006-01-02T15:04:05Z" // placeholder, replace with time.Now().Format(time.RFC3339) in real code
+// VerifyChain checks the hash chain of the whole trail
+func VerifyChain() (VerifyResult, error) {
+	mu.RLock()
+	defer mu.RUnlock()
+	return store.Verify()
 }
@@ -0,0 +1,435 @@
+package audit
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	currentFile     = "audit.jsonl"
+	rotatedPattern  = "audit.*.jsonl" // audit.<last seq, zero padded>.jsonl
+	defaultMaxBytes = 1 << 20
+	defaultKeep     = 10
+	recentEntries   = 500
+	maxLineBytes    = 1 << 20
+)
+
+// genesisHash is the previous hash of the very first entry
+var genesisHash = strings.Repeat("0", 64)
+
+// Options tunes a persistent store
+type Options struct {
+	MaxBytes int64            // Rotate the current file beyond this size (default 1 MiB)
+	Keep     int              // Rotated files kept; older ones are deleted (default 10)
+	Now      func() time.Time // Clock (default time.Now)
+}
+
+// Store is a hash-chained audit trail, optionally backed by JSON line files
+type Store struct {
+	mu       sync.Mutex
+	dir      string // Empty = memory only
+	opts     Options
+	file     *os.File
+	size     int64
+	closed   bool
+	seq      uint64
+	lastHash string
+	recent   []Entry
+}
+
+// Query filters a trail search; zero values match everything
+type Query struct {
+	Action string
+	User   string
+	Role   string
+	Since  time.Time
+	Until  time.Time
+	Limit  int
+	Offset int
+}
+
+// VerifyResult reports the outcome of a chain check
+type VerifyResult struct {
+	OK       bool   `json:"ok"`
+	Entries  int    `json:"entries"`
+	Files    int    `json:"files"`
+	FirstSeq uint64 `json:"first_seq"`
+	LastSeq  uint64 `json:"last_seq"`
+	Anchored bool   `json:"anchored"` // Starts at the genesis entry (no rotated file pruned)
+	BrokenAt uint64 `json:"broken_at,omitempty"`
+	File     string `json:"file,omitempty"`
+	Line     int    `json:"line,omitempty"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+func newMemoryStore() *Store {
+	return &Store{opts: defaultOptions(Options{}), lastHash: genesisHash}
+}
+
+func defaultOptions(opts Options) Options {
+	if opts.MaxBytes <= 0 {
+		opts.MaxBytes = defaultMaxBytes
+	}
+	if opts.Keep <= 0 {
+		opts.Keep = defaultKeep
+	}
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+	return opts
+}
+
+// Open opens (or creates) the trail in dir and continues its chain
+func Open(dir string, opts Options) (*Store, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	s := &Store{dir: dir, opts: defaultOptions(opts), lastHash: genesisHash}
+
+	files, err := s.files()
+	if err != nil {
+		return nil, err
+	}
+	// Resume from the newest file that has entries
+	for i := len(files) - 1; i >= 0; i-- {
+		entries, err := readEntries(files[i])
+		if err != nil {
+			return nil, err
+		}
+		if len(entries) == 0 {
+			continue
+		}
+		last := entries[len(entries)-1]
+		s.seq, s.lastHash = last.Seq, last.Hash
+		s.recent = tailEntries(entries, recentEntries)
+		break
+	}
+
+	if err := s.openCurrent(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *Store) openCurrent() error {
+	f, err := os.OpenFile(filepath.Join(s.dir, currentFile), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	s.file, s.size = f, info.Size()
+	return nil
+}
+
+// files lists rotated files oldest first, then the current file
+func (s *Store) files() ([]string, error) {
+	rotated, err := filepath.Glob(filepath.Join(s.dir, rotatedPattern))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(rotated)
+	return append(rotated, filepath.Join(s.dir, currentFile)), nil
+}
+
+// Append chains and stores a new entry
+func (s *Store) Append(actor Actor, action, detail string) (Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return Entry{}, errors.New("audit store closed")
+	}
+
+	e := Entry{
+		Seq:       s.seq + 1,
+		Timestamp: s.opts.Now().UTC().Format(time.RFC3339Nano),
+		Action:    action,
+		Detail:    detail,
+		User:      actor.User,
+		Role:      actor.Role,
+		RequestID: actor.RequestID,
+		PrevHash:  s.lastHash,
+	}
+	e.Hash = hashEntry(e)
+
+	if s.dir != "" {
+		line, err := json.Marshal(e)
+		if err != nil {
+			return Entry{}, err
+		}
+		line = append(line, '\n')
+		if s.size > 0 && s.size+int64(len(line)) > s.opts.MaxBytes {
+			if err := s.rotateLocked(); err != nil {
+				return Entry{}, err
+			}
+		}
+		n, err := s.file.Write(line)
+		s.size += int64(n)
+		if err != nil {
+			return Entry{}, err
+		}
+	}
+
+	s.seq, s.lastHash = e.Seq, e.Hash
+	s.recent = tailEntries(append(s.recent, e), recentEntries)
+	return e, nil
+}
+
+// rotateLocked renames the current file after its last sequence number and prunes old files
+func (s *Store) rotateLocked() error {
+	if err := s.file.Close(); err != nil {
+		return err
+	}
+	rotated := filepath.Join(s.dir, fmt.Sprintf("audit.%012d.jsonl", s.seq))
+	if err := os.Rename(filepath.Join(s.dir, currentFile), rotated); err != nil {
+		return err
+	}
+	if err := s.openCurrent(); err != nil {
+		return err
+	}
+
+	old, err := filepath.Glob(filepath.Join(s.dir, rotatedPattern))
+	if err != nil {
+		return err
+	}
+	sort.Strings(old)
+	for len(old) > s.opts.Keep {
+		os.Remove(old[0])
+		old = old[1:]
+	}
+	return nil
+}
+
+// Close closes the current file; later appends fail
+func (s *Store) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed || s.file == nil {
+		s.closed = true
+		return nil
+	}
+	s.closed = true
+	return s.file.Close()
+}
+
+// Recent returns the entries kept in memory, oldest first
+func (s *Store) Recent() []Entry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Entry(nil), s.recent...)
+}
+
+// allLocked returns every readable entry of the trail, oldest first
+func (s *Store) allLocked() ([]Entry, error) {
+	if s.dir == "" {
+		return append([]Entry(nil), s.recent...), nil
+	}
+	files, err := s.files()
+	if err != nil {
+		return nil, err
+	}
+	var out []Entry
+	for _, path := range files {
+		entries, err := readEntries(path)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, entries...)
+	}
+	return out, nil
+}
+
+// Query returns matching entries newest first and the total match count
+func (s *Store) Query(q Query) ([]Entry, int, error) {
+	s.mu.Lock()
+	entries, err := s.allLocked()
+	s.mu.Unlock()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var matched []Entry
+	for i := len(entries) - 1; i >= 0; i-- {
+		if q.matches(entries[i]) {
+			matched = append(matched, entries[i])
+		}
+	}
+	total := len(matched)
+	if q.Offset >= total {
+		return []Entry{}, total, nil
+	}
+	matched = matched[q.Offset:]
+	if q.Limit > 0 && len(matched) > q.Limit {
+		matched = matched[:q.Limit]
+	}
+	return matched, total, nil
+}
+
+func (q Query) matches(e Entry) bool {
+	if q.Action != "" && e.Action != q.Action {
+		return false
+	}
+	if q.User != "" && e.User != q.User {
+		return false
+	}
+	if q.Role != "" && e.Role != q.Role {
+		return false
+	}
+	if !q.Since.IsZero() || !q.Until.IsZero() {
+		ts, err := time.Parse(time.RFC3339Nano, e.Timestamp)
+		if err != nil {
+			return false
+		}
+		if !q.Since.IsZero() && ts.Before(q.Since) {
+			return false
+		}
+		if !q.Until.IsZero() && ts.After(q.Until) {
+			return false
+		}
+	}
+	return true
+}
+
+// Verify walks the trail and reports the first broken link
+func (s *Store) Verify() (VerifyResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	res := VerifyResult{OK: true}
+	var prev *Entry
+	check := func(e Entry, file string, line int) bool {
+		reason := ""
+		switch {
+		case prev == nil:
+			res.FirstSeq = e.Seq
+			res.Anchored = e.Seq == 1 && e.PrevHash == genesisHash
+		case e.Seq != prev.Seq+1:
+			reason = fmt.Sprintf("sequence gap: %d follows %d", e.Seq, prev.Seq)
+		case e.PrevHash != prev.Hash:
+			reason = "previous hash mismatch"
+		}
+		if reason == "" && hashEntry(e) != e.Hash {
+			reason = "entry hash mismatch"
+		}
+		if reason != "" {
+			res.OK, res.BrokenAt, res.File, res.Line, res.Reason = false, e.Seq, file, line, reason
+			return false
+		}
+		res.Entries++
+		res.LastSeq = e.Seq
+		prev = &e
+		return true
+	}
+
+	if s.dir == "" {
+		for i, e := range s.recent {
+			if !check(e, "", i+1) {
+				return res, nil
+			}
+		}
+		return res, nil
+	}
+
+	files, err := s.files()
+	if err != nil {
+		return res, err
+	}
+	for _, path := range files {
+		res.Files++
+		ok, err := scanFile(path, func(e *Entry, line int) bool {
+			if e == nil {
+				res.OK, res.File, res.Line, res.Reason = false, filepath.Base(path), line, "unparsable entry"
+				if prev != nil {
+					res.BrokenAt = prev.Seq + 1
+				}
+				return false
+			}
+			return check(*e, filepath.Base(path), line)
+		})
+		if err != nil {
+			return res, err
+		}
+		if !ok {
+			return res, nil
+		}
+	}
+
+	// Entries removed from the end of the current file leave no broken link
+	if s.seq > 0 && (prev == nil || prev.Hash != s.lastHash) {
+		res.OK, res.BrokenAt, res.Reason = false, res.LastSeq+1, "trail truncated"
+	}
+	return res, nil
+}
+
+// hashEntry is the SHA-256 of the entry's JSON with an empty hash field
+func hashEntry(e Entry) string {
+	e.Hash = ""
+	b, _ := json.Marshal(e)
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// readEntries returns the parsable entries of a file (a missing file is empty)
+func readEntries(path string) ([]Entry, error) {
+	var out []Entry
+	_, err := scanFile(path, func(e *Entry, _ int) bool {
+		if e != nil {
+			out = append(out, *e)
+		}
+		return true
+	})
+	return out, err
+}
+
+// scanFile calls fn for every non-empty line; e is nil when a line does not parse.
+// It stops early (returning false) when fn returns false.
+func scanFile(path string, fn func(e *Entry, line int) bool) (bool, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 0, 64*1024), maxLineBytes)
+	line := 0
+	for sc.Scan() {
+		line++
+		raw := sc.Bytes()
+		if len(raw) == 0 {
+			continue
+		}
+		var e Entry
+		var ep *Entry
+		if json.Unmarshal(raw, &e) == nil {
+			ep = &e
+		}
+		if !fn(ep, line) {
+			return false, nil
+		}
+	}
+	return true, sc.Err()
+}
+
+func tailEntries(entries []Entry, n int) []Entry {
+	if len(entries) > n {
+		return append([]Entry(nil), entries[len(entries)-n:]...)
+	}
+	return entries
+}
@@ -0,0 +1,130 @@
+package audit
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func fixedClock() func() time.Time {
+	t := time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC)
+	return func() time.Time {
+		t = t.Add(time.Minute)
+		return t
+	}
+}
+
+func appendN(t *testing.T, s *Store, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		if _, err := s.Append(Actor{User: "ayse", Role: "admin", RequestID: "req-1"}, "config", "change"); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestStoreChainsAndResumesAfterReopen(t *testing.T) {
+	dir := t.TempDir()
+	s, err := Open(dir, Options{Now: fixedClock()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	appendN(t, s, 3)
+	s.Close()
+
+	s, err = Open(dir, Options{Now: fixedClock()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	e, err := s.Append(System, "restore", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Seq != 4 || e.PrevHash != s.Recent()[2].Hash || e.Role != "system" {
+		t.Fatalf("resumed entry = %+v", e)
+	}
+	if _, err := time.Parse(time.RFC3339, e.Timestamp); err != nil {
+		t.Errorf("timestamp %q: %v", e.Timestamp, err)
+	}
+
+	res, err := s.Verify()
+	if err != nil || !res.OK || !res.Anchored || res.Entries != 4 {
+		t.Fatalf("verify = %+v, %v", res, err)
+	}
+}
+
+func TestStoreVerifyDetectsTampering(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		edit   func(lines []string) []string
+		reason string
+	}{
+		{"edited", func(l []string) []string {
+			l[1] = strings.Replace(l[1], `"detail":"change"`, `"detail":"nothing"`, 1)
+			return l
+		}, "entry hash mismatch"},
+		{"deleted", func(l []string) []string { return append(l[:1], l[2:]...) }, "sequence gap: 3 follows 1"},
+		{"truncated", func(l []string) []string { return l[:3] }, "trail truncated"},
+		{"garbage", func(l []string) []string { l[2] = "{oops"; return l }, "unparsable entry"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			s, err := Open(dir, Options{Now: fixedClock()})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer s.Close()
+			appendN(t, s, 4)
+
+			path := filepath.Join(dir, currentFile)
+			raw, _ := os.ReadFile(path)
+			lines := strings.Split(strings.TrimSpace(string(raw)), "\n")
+			os.WriteFile(path, []byte(strings.Join(tc.edit(lines), "\n")+"\n"), 0600)
+
+			res, err := s.Verify()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.OK || res.Reason != tc.reason {
+				t.Fatalf("verify = %+v, want reason %q", res, tc.reason)
+			}
+		})
+	}
+}
+
+func TestStoreRotatesAndQueriesAcrossFiles(t *testing.T) {
+	dir := t.TempDir()
+	s, err := Open(dir, Options{MaxBytes: 600, Keep: 2, Now: fixedClock()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	appendN(t, s, 12)
+	s.Append(Actor{User: "mehmet", Role: "user"}, "login", "ok")
+
+	rotated, _ := filepath.Glob(filepath.Join(dir, rotatedPattern))
+	if len(rotated) != 2 {
+		t.Fatalf("rotated files = %v, want 2 kept", rotated)
+	}
+
+	// Pruned history: still a valid chain, no longer anchored at genesis
+	res, err := s.Verify()
+	if err != nil || !res.OK || res.Anchored || res.LastSeq != 13 || res.Files != 3 {
+		t.Fatalf("verify = %+v, %v", res, err)
+	}
+
+	got, total, err := s.Query(Query{User: "ayse", Limit: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != res.Entries-1 || len(got) != 2 || got[0].Seq != 12 || got[1].Seq != 11 {
+		t.Fatalf("query = %d entries (total %d), first seq %d", len(got), total, got[0].Seq)
+	}
+	since := time.Date(2025, 3, 1, 9, 13, 0, 0, time.UTC)
+	if got, _, _ := s.Query(Query{Since: since}); len(got) != 1 || got[0].Action != "login" {
+		t.Fatalf("since query = %+v", got)
+	}
+}
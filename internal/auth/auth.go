@@ -4,7 +4,10 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"smartdisplay-core/internal/audit"
 	"smartdisplay-core/internal/logger"
+	"smartdisplay-core/internal/settings"
+	"time"
 )
 
 // Tüm kullanıcıları döndürür
@@ -24,12 +27,31 @@ func AddUser(newUser User) error {
 			return fmt.Errorf("Kullanıcı zaten mevcut")
 		}
 	}
+	if err := newUser.Scopes.Validate(); err != nil {
+		return err
+	}
+	if err := validateSchedule(newUser); err != nil {
+		return err
+	}
+	if isDuressPIN(users, newUser.PIN) {
+		return fmt.Errorf("Bu PIN kullanılamaz")
+	}
+	// PIN sadece hash olarak saklanır
+	if err := hashUserPIN(&newUser); err != nil {
+		return err
+	}
 	users = append(users, newUser)
 	return saveUsers(users)
 }
 
 // Kullanıcı günceller
 func UpdateUser(updated User) error {
+	if err := updated.Scopes.Validate(); err != nil {
+		return err
+	}
+	if err := validateSchedule(updated); err != nil {
+		return err
+	}
 	users, err := loadUsers()
 	if err != nil {
 		return err
@@ -37,6 +59,20 @@ func UpdateUser(updated User) error {
 	found := false
 	for i, u := range users {
 		if u.Username == updated.Username {
+			// Alarmo kodu sadece SetAlarmoCode ile değişir
+			if updated.AlarmoCode == "" {
+				updated.AlarmoCode = u.AlarmoCode
+			}
+			// Duress PIN sadece SetDuressPIN ile değişir
+			updated.DuressPIN = u.DuressPIN
+			// Boş PIN mevcut PIN'i korur, yeni PIN hash'lenir
+			if updated.PIN == "" {
+				updated.PIN = u.PIN
+			} else if isDuressPIN(users, updated.PIN) {
+				return fmt.Errorf("Bu PIN kullanılamaz")
+			} else if err := hashUserPIN(&updated); err != nil {
+				return err
+			}
 			users[i] = updated
 			found = true
 			break
@@ -68,6 +104,57 @@ func DeleteUser(username string) error {
 	return saveUsers(users)
 }
 
+// SetAlarmoCode stores the user's Alarmo code encrypted (same AES-GCM scheme as the HA token).
+// Empty code removes the mapping. The code is never logged.
+func SetAlarmoCode(username string, code string) error {
+	users, err := loadUsers()
+	if err != nil {
+		return err
+	}
+	encrypted := ""
+	if code != "" {
+		encrypted, err = settings.Encrypt(code)
+		if err != nil {
+			return fmt.Errorf("alarmo code encryption failed: %w", err)
+		}
+	}
+	for i, u := range users {
+		if u.Username == username {
+			users[i].AlarmoCode = encrypted
+			if err := saveUsers(users); err != nil {
+				return err
+			}
+			logger.Info("[AUTH] alarmo code updated for user=" + username + " set=" + fmt.Sprintf("%v", code != ""))
+			return nil
+		}
+	}
+	return fmt.Errorf("Kullanıcı bulunamadı")
+}
+
+// AlarmoCode returns the decrypted Alarmo code mapped to a user ("" if none)
+func AlarmoCode(username string) (string, error) {
+	if username == "" {
+		return "", nil
+	}
+	users, err := loadUsers()
+	if err != nil {
+		return "", err
+	}
+	for _, u := range users {
+		if u.Username == username {
+			if u.AlarmoCode == "" {
+				return "", nil
+			}
+			code, err := settings.Decrypt(u.AlarmoCode)
+			if err != nil {
+				return "", fmt.Errorf("alarmo code decryption failed: %w", err)
+			}
+			return code, nil
+		}
+	}
+	return "", nil
+}
+
 // Kullanıcıları dosyaya kaydeder
 func saveUsers(users []User) error {
 	path := "data/users.json"
@@ -89,28 +176,53 @@ type AuthContext struct {
 	Role          Role
 	Authenticated bool
 	PIN           string
+	Username      string    // Matched user (empty for role overrides / guests)
+	Scopes        Scopes    // The user's per-user permission overrides
+	AccessUntil   time.Time // End of the user's current access window (zero = unlimited)
+	Duress        bool      // Logged in with the duress PIN (see duress.go)
 }
 
-// ValidatePIN checks the given PIN against users.json and returns AuthContext
+// ValidatePIN checks the given PIN against users.json and returns AuthContext.
+// It has no brute-force protection; request handlers use CheckPIN. PINs are never logged.
+// A correct PIN outside the user's access times returns a *ScheduleError.
 func ValidatePIN(pin string) (*AuthContext, error) {
-	logger.Info("[AUTH] ValidatePIN: called with pin=" + pin)
+	if pin == "" {
+		return &AuthContext{Role: Guest, Authenticated: false, PIN: ""}, nil
+	}
 	users, err := loadUsers()
 	if err != nil {
 		logger.Error("[AUTH] ValidatePIN: loadUsers failed: " + err.Error())
 		return &AuthContext{Role: Guest, Authenticated: false, PIN: ""}, err
 	}
 	for _, user := range users {
-		logger.Info("[AUTH] ValidatePIN: checking user=" + user.Username + ", pin=" + user.PIN)
-		if user.PIN == pin {
-			logger.Info("[AUTH] ValidatePIN: PIN match for user=" + user.Username)
-			return &AuthContext{
-				Role:          user.Role,
-				Authenticated: true,
-				PIN:           pin,
-			}, nil
+		// Duress PIN behaves exactly like the real one; only ctx.Duress differs
+		duress := !verifyPIN(user.PIN, pin)
+		if duress && (user.DuressPIN == "" || !verifyPIN(user.DuressPIN, pin)) {
+			continue
+		}
+		now := time.Now()
+		if reason := user.AccessAt(now); reason != "" {
+			// Doğru PIN, ama erişim saatleri dışında
+			logger.Info("[AUTH] ValidatePIN: PIN refused for user=" + user.Username + " reason=" + reason)
+			audit.Record("auth_schedule_denied", "user="+user.Username+" reason="+reason)
+			if scheduleHook != nil {
+				scheduleHook(user.Username, reason)
+			}
+			return &AuthContext{Role: Guest, Authenticated: false, PIN: ""},
+				&ScheduleError{Username: user.Username, Reason: reason}
 		}
+		logger.Info("[AUTH] ValidatePIN: PIN match for user=" + user.Username)
+		return &AuthContext{
+			Role:          user.Role,
+			Authenticated: true,
+			PIN:           pin,
+			Username:      user.Username,
+			Scopes:        user.Scopes,
+			AccessUntil:   user.accessEnd(now),
+			Duress:        duress,
+		}, nil
 	}
-	logger.Info("[AUTH] ValidatePIN: no match for pin=" + pin)
+	logger.Info("[AUTH] ValidatePIN: no match")
 	return &AuthContext{Role: Guest, Authenticated: false, PIN: ""}, nil
 }
 
@@ -120,37 +232,21 @@ const (
 	Guest    Role = "guest"
 )
 
-type Permission string
-
-const (
-	PermAlarm  Permission = "alarm"
-	PermDevice Permission = "device"
-	PermGuest  Permission = "guest"
-)
-
-var rolePermissions = map[Role][]Permission{
-	Admin:    {PermAlarm, PermDevice, PermGuest},
-	UserRole: {PermAlarm, PermDevice},
-	Guest:    {PermGuest},
-}
-
-func HasPermission(role Role, perm Permission) bool {
-	perms := rolePermissions[role]
-	for _, p := range perms {
-		if p == perm {
-			return true
-		}
-	}
-	return false
-}
-
 // AuthContext represents authenticated request context
 // FAZ L1: PIN-based authentication
 
 type User struct {
-	Username string `json:"username"`
-	PIN      string `json:"pin"`
-	Role     Role   `json:"role"` // Keep this line as it is
+	Username   string `json:"username"`
+	PIN        string `json:"pin"`                       // PBKDF2 hash (see HashPIN); plaintext only in legacy files
+	Role       Role   `json:"role"`                      // Keep this line as it is
+	AlarmoCode string `json:"alarmo_code_enc,omitempty"` // Alarmo user code, AES-GCM encrypted (never plaintext)
+	DuressPIN  string `json:"duress_pin_hash,omitempty"` // PBKDF2 hash; written only by SetDuressPIN
+	Scopes     Scopes `json:"scopes,omitzero"`           // Per-user grants/denies on top of Role
+
+	// Access times (see schedule.go); zero values = no restriction
+	ValidFrom  time.Time        `json:"valid_from,omitzero"`
+	ValidUntil time.Time        `json:"valid_until,omitzero"`
+	Schedule   []ScheduleWindow `json:"schedule,omitempty"`
 }
 
 func loadUsers() ([]User, error) {
@@ -168,7 +264,7 @@ func loadUsers() ([]User, error) {
 				return nil, err
 			}
 			logger.Info("[AUTH] loadUsers: loaded " + fmt.Sprintf("%d", len(users)) + " users from " + path)
-			return users, nil
+			return migratePINs(users), nil
 		} else {
 			logger.Info("[AUTH] loadUsers: not found at " + path)
 		}
@@ -180,8 +276,33 @@ func loadUsers() ([]User, error) {
 		PIN:      "1234",
 		Role:     Admin,
 	}
-	_ = saveUsers([]User{defaultUser})
-	return []User{defaultUser}, nil
+	return migratePINs([]User{defaultUser}), nil
 }
 
-// hashPIN creates SHA-256 hash of PIN
+// migratePINs hashes legacy plaintext PINs and rewrites data/users.json.
+// If hashing or saving fails the users are returned unchanged (plaintext still verifies).
+func migratePINs(users []User) []User {
+	migrated := make([]User, len(users))
+	copy(migrated, users)
+	changed := 0
+	for i := range migrated {
+		if (migrated[i].PIN == "" || IsHashedPIN(migrated[i].PIN)) &&
+			(migrated[i].DuressPIN == "" || IsHashedPIN(migrated[i].DuressPIN)) {
+			continue
+		}
+		if err := hashUserPIN(&migrated[i]); err != nil {
+			logger.Error("[AUTH] PIN migration failed: " + err.Error())
+			return users
+		}
+		changed++
+	}
+	if changed == 0 {
+		return users
+	}
+	if err := saveUsers(migrated); err != nil {
+		logger.Error("[AUTH] PIN migration: save failed: " + err.Error())
+		return users
+	}
+	logger.Info(fmt.Sprintf("[AUTH] PIN migration: hashed %d plaintext PINs", changed))
+	return migrated
+}
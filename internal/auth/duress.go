@@ -0,0 +1,71 @@
+package auth
+
+import (
+	"fmt"
+	"smartdisplay-core/internal/logger"
+)
+
+// A duress PIN logs the user in (and disarms) like the real PIN, so an intruder
+// forcing the user sees nothing unusual; the API then raises a silent alarm.
+// It is stored hashed like the PIN and never returned or logged.
+
+// SetDuressPIN sets the user's duress PIN; empty removes it. It must differ from
+// every PIN and duress PIN in use so a match is never ambiguous.
+func SetDuressPIN(username, pin string) error {
+	users, err := loadUsers()
+	if err != nil {
+		return err
+	}
+	hashed := ""
+	if pin != "" {
+		for _, u := range users {
+			if verifyPIN(u.PIN, pin) || (u.Username != username && u.DuressPIN != "" && verifyPIN(u.DuressPIN, pin)) {
+				return fmt.Errorf("Bu PIN kullanılamaz")
+			}
+		}
+		if hashed, err = HashPIN(pin); err != nil {
+			return fmt.Errorf("PIN hashing failed: %w", err)
+		}
+	}
+	for i, u := range users {
+		if u.Username == username {
+			users[i].DuressPIN = hashed
+			if err := saveUsers(users); err != nil {
+				return err
+			}
+			logger.Info("[AUTH] duress PIN updated for user=" + username + " set=" + fmt.Sprintf("%v", pin != ""))
+			return nil
+		}
+	}
+	return fmt.Errorf("Kullanıcı bulunamadı")
+}
+
+// isDuressPIN reports whether pin is any user's duress PIN
+func isDuressPIN(users []User, pin string) bool {
+	if pin == "" {
+		return false
+	}
+	for _, u := range users {
+		if u.DuressPIN != "" && verifyPIN(u.DuressPIN, pin) {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchDuressPIN returns the user whose duress PIN is pin
+func MatchDuressPIN(pin string) (string, bool) {
+	if pin == "" {
+		return "", false
+	}
+	users, err := loadUsers()
+	if err != nil {
+		return "", false
+	}
+	for _, u := range users {
+		if u.DuressPIN != "" && verifyPIN(u.DuressPIN, pin) {
+			return u.Username, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,23 @@
+package auth
+
+import "testing"
+
+func TestDuressPINHashedAndMatched(t *testing.T) {
+	pinIterations = 1000
+	defer func() { pinIterations = pinHashIterations }()
+
+	u := User{Username: "ayse", PIN: "1234", DuressPIN: "4321"}
+	if err := hashUserPIN(&u); err != nil {
+		t.Fatal(err)
+	}
+	if !IsHashedPIN(u.PIN) || !IsHashedPIN(u.DuressPIN) {
+		t.Fatalf("PINs not hashed: %q %q", u.PIN, u.DuressPIN)
+	}
+	users := []User{u, {Username: "mehmet", PIN: "5555"}}
+	if !isDuressPIN(users, "4321") {
+		t.Error("duress PIN not recognised")
+	}
+	if isDuressPIN(users, "1234") || isDuressPIN(users, "5555") || isDuressPIN(users, "") {
+		t.Error("normal or empty PIN taken for a duress PIN")
+	}
+}
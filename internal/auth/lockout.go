@@ -0,0 +1,182 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"smartdisplay-core/internal/audit"
+	"smartdisplay-core/internal/logger"
+	"sync"
+	"time"
+)
+
+// Brute-force protection for PIN checks. Failed attempts are counted per client
+// (remote address) and across all clients; crossing a threshold locks that scope
+// out for a delay that doubles with every further failure. A client adds to the
+// global count only until its own lockout starts, so one client cannot lock
+// everyone out.
+
+// LockoutPolicy tunes a Limiter
+type LockoutPolicy struct {
+	ClientThreshold int           // Failures from one client before it is locked out
+	GlobalThreshold int           // Failures from all clients before everyone is locked out (at most ClientThreshold per client)
+	BaseDelay       time.Duration // First lockout; doubles per further failure
+	MaxDelay        time.Duration
+	ResetAfter      time.Duration // Quiet period after which a counter starts over
+}
+
+// DefaultLockoutPolicy is used by CheckPIN
+var DefaultLockoutPolicy = LockoutPolicy{
+	ClientThreshold: 5,
+	GlobalThreshold: 20,
+	BaseDelay:       30 * time.Second,
+	MaxDelay:        15 * time.Minute,
+	ResetAfter:      15 * time.Minute,
+}
+
+// maxTrackedClients bounds the per-client table; idle clients are pruned beyond it
+const maxTrackedClients = 1024
+
+// LockoutError is returned while a client (or everyone) is locked out
+type LockoutError struct {
+	Scope      string // "client" or "global"
+	RetryAfter time.Duration
+}
+
+func (e *LockoutError) Error() string {
+	return fmt.Sprintf("too many failed PIN attempts (%s), retry in %s", e.Scope, e.RetryAfter.Round(time.Second))
+}
+
+type attemptCounter struct {
+	failures    int
+	last        time.Time
+	lockedUntil time.Time
+}
+
+// fail counts a failure and returns the lockout it starts, if any
+func (c *attemptCounter) fail(now time.Time, threshold int, p LockoutPolicy) (time.Duration, bool) {
+	if !c.last.IsZero() && now.Sub(c.last) > p.ResetAfter {
+		c.failures = 0
+	}
+	c.failures++
+	c.last = now
+	if c.failures < threshold {
+		return 0, false
+	}
+	delay := p.MaxDelay
+	if shift := c.failures - threshold; shift < 32 {
+		if d := p.BaseDelay << shift; d > 0 && d < p.MaxDelay {
+			delay = d
+		}
+	}
+	c.lockedUntil = now.Add(delay)
+	return delay, true
+}
+
+// Limiter tracks failed PIN attempts
+type Limiter struct {
+	mu      sync.Mutex
+	policy  LockoutPolicy
+	now     func() time.Time
+	clients map[string]*attemptCounter
+	global  attemptCounter
+}
+
+// NewLimiter creates a limiter; now defaults to time.Now
+func NewLimiter(policy LockoutPolicy, now func() time.Time) *Limiter {
+	if now == nil {
+		now = time.Now
+	}
+	return &Limiter{policy: policy, now: now, clients: make(map[string]*attemptCounter)}
+}
+
+// Check returns a *LockoutError if client may not try a PIN right now
+func (l *Limiter) Check(client string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	if now.Before(l.global.lockedUntil) {
+		return &LockoutError{Scope: "global", RetryAfter: l.global.lockedUntil.Sub(now)}
+	}
+	if c := l.clients[client]; c != nil && now.Before(c.lockedUntil) {
+		return &LockoutError{Scope: "client", RetryAfter: c.lockedUntil.Sub(now)}
+	}
+	return nil
+}
+
+// Failure records a wrong PIN and returns the lockout it starts, if any
+func (l *Limiter) Failure(client string) *LockoutError {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+
+	c := l.clients[client]
+	if c == nil {
+		if len(l.clients) >= maxTrackedClients {
+			l.pruneLocked(now)
+		}
+		c = &attemptCounter{}
+		l.clients[client] = c
+	}
+	var lockout *LockoutError
+	if d, locked := c.fail(now, l.policy.ClientThreshold, l.policy); locked {
+		lockout = &LockoutError{Scope: "client", RetryAfter: d}
+	}
+	if c.failures > l.policy.ClientThreshold {
+		// Already locked out on its own: retrying after each delay must not lock out everyone
+		return lockout
+	}
+	if d, locked := l.global.fail(now, l.policy.GlobalThreshold, l.policy); locked {
+		lockout = &LockoutError{Scope: "global", RetryAfter: d}
+	}
+	return lockout
+}
+
+// Success clears the client's failures (the global counter only decays)
+func (l *Limiter) Success(client string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.clients, client)
+}
+
+func (l *Limiter) pruneLocked(now time.Time) {
+	for key, c := range l.clients {
+		if now.After(c.lockedUntil) && now.Sub(c.last) > l.policy.ResetAfter {
+			delete(l.clients, key)
+		}
+	}
+}
+
+var pinLimiter = NewLimiter(DefaultLockoutPolicy, nil)
+
+// CheckPIN validates pin on behalf of client (usually the remote address) with
+// brute-force protection. An empty PIN is a guest and never counts as a failure.
+// While locked out it returns a *LockoutError without checking the PIN.
+// A correct PIN outside its access times counts and answers like a wrong one, so
+// the schedule cannot be used to confirm a guessed PIN.
+func CheckPIN(client, pin string) (*AuthContext, error) {
+	if pin == "" {
+		return &AuthContext{Role: Guest, Authenticated: false, PIN: ""}, nil
+	}
+	if err := pinLimiter.Check(client); err != nil {
+		return &AuthContext{Role: Guest, Authenticated: false, PIN: ""}, err
+	}
+	ctx, err := ValidatePIN(pin)
+	var scheduleErr *ScheduleError
+	if errors.As(err, &scheduleErr) {
+		err = nil
+	}
+	if err != nil {
+		return ctx, err
+	}
+	if ctx.Authenticated {
+		pinLimiter.Success(client)
+		return ctx, nil
+	}
+	if lockout := pinLimiter.Failure(client); lockout != nil {
+		logger.Error("[AUTH] PIN lockout: scope=" + lockout.Scope + " client=" + client +
+			" retry_after=" + lockout.RetryAfter.String())
+		audit.Record("auth_lockout", fmt.Sprintf("scope=%s client=%s retry_after=%s",
+			lockout.Scope, client, lockout.RetryAfter))
+	}
+	return ctx, nil
+}
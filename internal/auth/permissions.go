@@ -0,0 +1,109 @@
+package auth
+
+import (
+	"fmt"
+	"slices"
+)
+
+// Permission is a single capability checked by the API (see api.checkPerm)
+type Permission string
+
+const (
+	// PermPublic marks routes that need no permission (health, login, panel views)
+	PermPublic Permission = ""
+
+	PermAlarmRead     Permission = "alarm.read"   // Overview, Alarmo event history and command log
+	PermAlarmArm      Permission = "alarm.arm"    // Arm the local alarm or Alarmo
+	PermAlarmDisarm   Permission = "alarm.disarm" // Disarm the local alarm or Alarmo
+	PermGuestRequest  Permission = "guest.request"
+	PermGuestApprove  Permission = "guest.approve" // Answer guest requests from the panel
+	PermGuestExit     Permission = "guest.exit"
+	PermGuestPasses   Permission = "guest.passes" // Create and revoke scheduled guest passes
+	PermLightsRead    Permission = "lights.read"
+	PermLightsControl Permission = "lights.control"
+	PermLogbookRead   Permission = "logbook.read"        // Alarm, guest and system entries shown to users
+	PermLogbookSafety Permission = "logbook.read.safety" // Everything, including safety and admin entries
+	PermSettingsRead  Permission = "settings.read"
+	PermSettingsWrite Permission = "settings.write"
+	PermUsersManage   Permission = "users.manage"
+	PermAuditRead     Permission = "audit.read"
+	PermSystemAdmin   Permission = "system.admin" // Smoke test, restart, backup/restore, telemetry, updates
+)
+
+// AllPermissions lists every permission, in display order
+var AllPermissions = []Permission{
+	PermAlarmRead, PermAlarmArm, PermAlarmDisarm,
+	PermGuestRequest, PermGuestApprove, PermGuestExit, PermGuestPasses,
+	PermLightsRead, PermLightsControl,
+	PermLogbookRead, PermLogbookSafety,
+	PermSettingsRead, PermSettingsWrite,
+	PermUsersManage, PermAuditRead, PermSystemAdmin,
+}
+
+// rolePermissions is the permission matrix; users may adjust it with Scopes
+var rolePermissions = map[Role][]Permission{
+	Admin: AllPermissions,
+	UserRole: {
+		PermAlarmRead, PermAlarmArm, PermAlarmDisarm,
+		PermGuestApprove, PermGuestExit,
+		PermLightsRead, PermLightsControl,
+		PermLogbookRead,
+	},
+	// Only the panel's guest asks for access; the household answers
+	Guest: {PermGuestRequest, PermGuestExit, PermLightsRead},
+}
+
+// HasPermission reports whether role grants perm, ignoring per-user scopes
+func HasPermission(role Role, perm Permission) bool {
+	if perm == PermPublic {
+		return true
+	}
+	return slices.Contains(rolePermissions[role], perm)
+}
+
+// KnownPermission reports whether perm is part of the matrix
+func KnownPermission(perm Permission) bool {
+	return slices.Contains(AllPermissions, perm)
+}
+
+// Scopes adjust a user's role permissions. Deny wins over Grant.
+type Scopes struct {
+	Grant []Permission `json:"grant,omitempty"`
+	Deny  []Permission `json:"deny,omitempty"`
+}
+
+// Validate rejects unknown permission names
+func (s Scopes) Validate() error {
+	for _, p := range append(slices.Clone(s.Grant), s.Deny...) {
+		if !KnownPermission(p) {
+			return fmt.Errorf("Bilinmeyen yetki: %s", p)
+		}
+	}
+	return nil
+}
+
+// Allowed reports whether the caller has perm: the role's permissions plus the
+// user's grants, minus the user's denies. A nil context is a guest.
+func Allowed(ctx *AuthContext, perm Permission) bool {
+	if perm == PermPublic {
+		return true
+	}
+	if ctx == nil {
+		return HasPermission(Guest, perm)
+	}
+	if slices.Contains(ctx.Scopes.Deny, perm) {
+		return false
+	}
+	return slices.Contains(ctx.Scopes.Grant, perm) || HasPermission(ctx.Role, perm)
+}
+
+// Permissions returns every permission the caller has, in display order
+func Permissions(ctx *AuthContext) []Permission {
+	out := []Permission{}
+	for _, p := range AllPermissions {
+		if Allowed(ctx, p) {
+			out = append(out, p)
+		}
+	}
+	return out
+}
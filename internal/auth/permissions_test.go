@@ -0,0 +1,57 @@
+package auth
+
+import "testing"
+
+func TestPermissionMatrix(t *testing.T) {
+	tests := []struct {
+		role Role
+		perm Permission
+		want bool
+	}{
+		{Admin, PermUsersManage, true},
+		{Admin, PermLogbookSafety, true},
+		{UserRole, PermAlarmDisarm, true},
+		{UserRole, PermLightsControl, true},
+		{UserRole, PermLogbookSafety, false},
+		{UserRole, PermSettingsWrite, false},
+		{UserRole, PermGuestRequest, false},
+		{Guest, PermGuestRequest, true},
+		{Guest, PermAlarmArm, false},
+		{Guest, PermLightsControl, false},
+		{Guest, PermPublic, true},
+		{Role("intruder"), PermLightsRead, false},
+	}
+	for _, tt := range tests {
+		if got := HasPermission(tt.role, tt.perm); got != tt.want {
+			t.Errorf("HasPermission(%s, %s) = %v, want %v", tt.role, tt.perm, got, tt.want)
+		}
+	}
+}
+
+func TestScopes(t *testing.T) {
+	ctx := &AuthContext{Role: UserRole, Authenticated: true, Username: "mehmet", Scopes: Scopes{
+		Grant: []Permission{PermLogbookSafety, PermAlarmArm},
+		Deny:  []Permission{PermAlarmArm, PermLightsControl},
+	}}
+	for perm, want := range map[Permission]bool{
+		PermLogbookSafety: true,  // Granted
+		PermAlarmArm:      false, // Deny wins over grant
+		PermLightsControl: false, // Denied although the role has it
+		PermAlarmDisarm:   true,  // Role
+		PermUsersManage:   false,
+	} {
+		if got := Allowed(ctx, perm); got != want {
+			t.Errorf("Allowed(%s) = %v, want %v", perm, got, want)
+		}
+	}
+	if Allowed(nil, PermAlarmRead) || !Allowed(nil, PermGuestRequest) {
+		t.Error("nil context should be a guest")
+	}
+
+	if err := (Scopes{Grant: []Permission{"alarm.everything"}}).Validate(); err == nil {
+		t.Error("unknown permission accepted")
+	}
+	if err := ctx.Scopes.Validate(); err != nil {
+		t.Errorf("valid scopes rejected: %v", err)
+	}
+}
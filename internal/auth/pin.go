@@ -0,0 +1,87 @@
+package auth
+
+import (
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// PIN hashes are stored as "pbkdf2-sha256$<iterations>$<salt>$<key>" (base64, no padding).
+// A PIN without the prefix is a legacy plaintext PIN; loadUsers migrates those on first read.
+const (
+	pinHashScheme     = "pbkdf2-sha256"
+	pinHashIterations = 120000
+	pinSaltBytes      = 16
+	pinKeyBytes       = 32
+)
+
+// pinIterations is lowered by tests to keep them fast
+var pinIterations = pinHashIterations
+
+// HashPIN returns a salted PBKDF2 hash of pin for storage
+func HashPIN(pin string) (string, error) {
+	salt := make([]byte, pinSaltBytes)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key, err := pbkdf2.Key(sha256.New, pin, salt, pinIterations, pinKeyBytes)
+	if err != nil {
+		return "", err
+	}
+	enc := base64.RawStdEncoding
+	return fmt.Sprintf("%s$%d$%s$%s", pinHashScheme, pinIterations, enc.EncodeToString(salt), enc.EncodeToString(key)), nil
+}
+
+// IsHashedPIN reports whether stored is a PIN hash rather than a plaintext PIN
+func IsHashedPIN(stored string) bool {
+	return strings.HasPrefix(stored, pinHashScheme+"$")
+}
+
+// verifyPIN compares pin with a stored hash (or legacy plaintext) in constant time
+func verifyPIN(stored, pin string) bool {
+	if !IsHashedPIN(stored) {
+		return stored != "" && subtle.ConstantTimeCompare([]byte(stored), []byte(pin)) == 1
+	}
+	parts := strings.Split(stored, "$")
+	if len(parts) != 4 {
+		return false
+	}
+	iterations, err := strconv.Atoi(parts[1])
+	if err != nil || iterations <= 0 {
+		return false
+	}
+	enc := base64.RawStdEncoding
+	salt, err := enc.DecodeString(parts[2])
+	if err != nil {
+		return false
+	}
+	want, err := enc.DecodeString(parts[3])
+	if err != nil {
+		return false
+	}
+	got, err := pbkdf2.Key(sha256.New, pin, salt, iterations, len(want))
+	if err != nil {
+		return false
+	}
+	return subtle.ConstantTimeCompare(got, want) == 1
+}
+
+// hashUserPIN replaces a plaintext PIN (and duress PIN) with its hash; hashes and empty PINs are kept
+func hashUserPIN(u *User) error {
+	for _, pin := range []*string{&u.PIN, &u.DuressPIN} {
+		if *pin == "" || IsHashedPIN(*pin) {
+			continue
+		}
+		hashed, err := HashPIN(*pin)
+		if err != nil {
+			return fmt.Errorf("PIN hashing failed: %w", err)
+		}
+		*pin = hashed
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package auth
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestHashPINVerifiesAndSalts(t *testing.T) {
+	pinIterations = 1000
+	defer func() { pinIterations = pinHashIterations }()
+
+	a, err := HashPIN("1234")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := HashPIN("1234")
+	if a == b || !IsHashedPIN(a) {
+		t.Fatalf("hashes not salted or not tagged: %q %q", a, b)
+	}
+	if !verifyPIN(a, "1234") || verifyPIN(a, "1235") || verifyPIN(a, "") {
+		t.Error("hash verification wrong")
+	}
+	// Legacy plaintext still verifies until migrated
+	if !verifyPIN("5678", "5678") || verifyPIN("", "") || verifyPIN("pbkdf2-sha256$x", "x") {
+		t.Error("legacy or malformed verification wrong")
+	}
+}
+
+func TestLimiterLocksOutExponentially(t *testing.T) {
+	now := time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+	l := NewLimiter(LockoutPolicy{
+		ClientThreshold: 3,
+		GlobalThreshold: 10,
+		BaseDelay:       30 * time.Second,
+		MaxDelay:        2 * time.Minute,
+		ResetAfter:      15 * time.Minute,
+	}, clock)
+
+	if l.Failure("10.0.0.5") != nil || l.Failure("10.0.0.5") != nil {
+		t.Fatal("locked out before threshold")
+	}
+	lockout := l.Failure("10.0.0.5")
+	if lockout == nil || lockout.Scope != "client" || lockout.RetryAfter != 30*time.Second {
+		t.Fatalf("third failure = %+v", lockout)
+	}
+	if l.Check("10.0.0.5") == nil || l.Check("10.0.0.6") != nil {
+		t.Fatal("lockout not scoped to the client")
+	}
+
+	// Each further failure doubles the delay, up to MaxDelay
+	now = now.Add(31 * time.Second)
+	if lockout := l.Failure("10.0.0.5"); lockout == nil || lockout.RetryAfter != time.Minute {
+		t.Fatalf("fourth failure = %+v", lockout)
+	}
+	now = now.Add(61 * time.Second)
+	l.Failure("10.0.0.5")
+	now = now.Add(3 * time.Minute)
+	if lockout := l.Failure("10.0.0.5"); lockout == nil || lockout.RetryAfter != 2*time.Minute {
+		t.Fatalf("capped failure = %+v", lockout)
+	}
+
+	// Success clears the client; the global counter trips across clients
+	now = now.Add(3 * time.Minute)
+	l.Success("10.0.0.5")
+	if l.Check("10.0.0.5") != nil {
+		t.Fatal("success did not clear the client")
+	}
+	for i := 0; i < 3; i++ {
+		l.Failure("10.0.0.7")
+		l.Failure("10.0.0.8")
+	}
+	if lockout := l.Failure("10.0.0.9"); lockout == nil || lockout.Scope != "global" {
+		t.Fatalf("tenth failure = %+v", lockout)
+	}
+	if err, ok := l.Check("10.0.0.10").(*LockoutError); !ok || err.Scope != "global" {
+		t.Fatalf("global lockout check = %v", err)
+	}
+}
+
+func TestLimiterSingleClientCannotLockOutEveryone(t *testing.T) {
+	now := time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+	policy := DefaultLockoutPolicy
+	l := NewLimiter(policy, clock)
+
+	// One client keeps guessing, retrying as soon as each of its lockouts ends
+	for i := 0; i < 3*policy.GlobalThreshold; i++ {
+		if err, ok := l.Check("10.0.0.5").(*LockoutError); ok {
+			now = now.Add(err.RetryAfter)
+		}
+		if lockout := l.Failure("10.0.0.5"); lockout != nil && lockout.Scope == "global" {
+			t.Fatalf("failure %d of a single client locked out everyone", i+1)
+		}
+	}
+	if l.Check("10.0.0.6") != nil {
+		t.Fatal("other client locked out by a single client's guesses")
+	}
+
+	// Clients that each stay below their own threshold still trip the global limit
+	l = NewLimiter(policy, clock)
+	var lockout *LockoutError
+	for i := 0; lockout == nil && i < policy.GlobalThreshold; i++ {
+		client := "10.0.1." + strconv.Itoa(i)
+		for j := 0; lockout == nil && j < policy.ClientThreshold-1; j++ {
+			lockout = l.Failure(client)
+		}
+	}
+	if lockout == nil || lockout.Scope != "global" {
+		t.Fatalf("distributed guessing lockout = %+v, want global", lockout)
+	}
+}
@@ -0,0 +1,161 @@
+package auth
+
+import (
+	"fmt"
+	"slices"
+	"time"
+)
+
+// Time-restricted users (cleaner, dog walker): a PIN may be limited to a validity
+// period and to weekly windows. Both are evaluated in the device's local time.
+
+// Reasons a matching PIN is refused
+const (
+	ReasonNotYetValid     = "not_yet_valid"
+	ReasonExpired         = "expired"
+	ReasonOutsideSchedule = "outside_schedule"
+)
+
+// weekdays maps schedule day names to time.Weekday
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// ScheduleWindow is a weekly window. A window whose To is before From spans
+// midnight and belongs to the day it starts on.
+type ScheduleWindow struct {
+	Days []string `json:"days,omitempty"` // "mon".."sun"; empty = every day
+	From string   `json:"from"`           // "HH:MM"
+	To   string   `json:"to"`             // "HH:MM", exclusive
+}
+
+// ScheduleError is returned by ValidatePIN for a correct PIN used outside its access times
+type ScheduleError struct {
+	Username string
+	Reason   string
+}
+
+func (e *ScheduleError) Error() string {
+	return "PIN not valid at this time (" + e.Reason + ")"
+}
+
+// scheduleHook is told about refused PINs (see OnScheduleDenied)
+var scheduleHook func(username, reason string)
+
+// OnScheduleDenied registers fn to be called when a correct PIN is refused by its schedule
+func OnScheduleDenied(fn func(username, reason string)) {
+	scheduleHook = fn
+}
+
+// parseClock parses "HH:MM" into minutes after midnight
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("Geçersiz saat: %q (SS:DD bekleniyor)", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// validateSchedule checks a user's access times before they are saved
+func validateSchedule(u User) error {
+	if !u.ValidFrom.IsZero() && !u.ValidUntil.IsZero() && !u.ValidUntil.After(u.ValidFrom) {
+		return fmt.Errorf("Geçerlilik bitişi başlangıçtan sonra olmalı")
+	}
+	for _, w := range u.Schedule {
+		from, err := parseClock(w.From)
+		if err != nil {
+			return err
+		}
+		to, err := parseClock(w.To)
+		if err != nil {
+			return err
+		}
+		if from == to {
+			return fmt.Errorf("Zaman aralığı boş: %s-%s", w.From, w.To)
+		}
+		for _, d := range w.Days {
+			if _, ok := weekdays[d]; !ok {
+				return fmt.Errorf("Geçersiz gün: %q", d)
+			}
+		}
+	}
+	return nil
+}
+
+// contains reports whether t falls in the window
+func (w ScheduleWindow) contains(t time.Time) bool {
+	from, err := parseClock(w.From)
+	if err != nil {
+		return false
+	}
+	to, err := parseClock(w.To)
+	if err != nil {
+		return false
+	}
+	minute := t.Hour()*60 + t.Minute()
+	onDay := func(day time.Weekday) bool {
+		return len(w.Days) == 0 || slices.ContainsFunc(w.Days, func(d string) bool {
+			wd, ok := weekdays[d]
+			return ok && wd == day
+		})
+	}
+	if from < to {
+		return onDay(t.Weekday()) && minute >= from && minute < to
+	}
+	// Overnight: the evening part is on the start day, the morning part on the next
+	if minute >= from {
+		return onDay(t.Weekday())
+	}
+	return minute < to && onDay((t.Weekday()+6)%7)
+}
+
+// AccessAt returns "" if the user's PIN works at t, otherwise the reason it does not
+func (u User) AccessAt(t time.Time) string {
+	t = t.Local()
+	if !u.ValidFrom.IsZero() && t.Before(u.ValidFrom) {
+		return ReasonNotYetValid
+	}
+	if !u.ValidUntil.IsZero() && !t.Before(u.ValidUntil) {
+		return ReasonExpired
+	}
+	if len(u.Schedule) == 0 {
+		return ""
+	}
+	for _, w := range u.Schedule {
+		if w.contains(t) {
+			return ""
+		}
+	}
+	return ReasonOutsideSchedule
+}
+
+// end returns when the window that contains t closes
+func (w ScheduleWindow) end(t time.Time) time.Time {
+	to, _ := parseClock(w.To)
+	from, _ := parseClock(w.From)
+	y, m, d := t.Date()
+	if from > to && t.Hour()*60+t.Minute() >= from {
+		d++
+	}
+	// Wall clock, not minutes since midnight: DST days are 23 or 25 hours long
+	return time.Date(y, m, d, to/60, to%60, 0, 0, t.Location())
+}
+
+// accessEnd returns when access granted at t runs out (zero = unlimited).
+// Sessions started at t do not outlive it.
+func (u User) accessEnd(t time.Time) time.Time {
+	t = t.Local()
+	var end time.Time
+	for _, w := range u.Schedule {
+		if w.contains(t) {
+			if e := w.end(t); e.After(end) {
+				end = e
+			}
+		}
+	}
+	if !u.ValidUntil.IsZero() && (end.IsZero() || u.ValidUntil.Before(end)) {
+		end = u.ValidUntil
+	}
+	return end
+}
@@ -0,0 +1,159 @@
+package auth
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"smartdisplay-core/internal/logger"
+	"testing"
+	"time"
+)
+
+func TestMain(m *testing.M) {
+	// users.json and the log live under the working directory; keep them out of the tree
+	dir, err := os.MkdirTemp("", "auth-test")
+	if err != nil {
+		panic(err)
+	}
+	os.Chdir(dir)
+	os.MkdirAll("logs", 0755)
+	logger.Init()
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+func TestUserAccessAt(t *testing.T) {
+	at := func(day, hour, minute int) time.Time {
+		// March 2025: the 3rd is a Monday
+		return time.Date(2025, 3, day, hour, minute, 0, 0, time.Local)
+	}
+	cleaner := User{
+		Username:   "temizlik",
+		ValidFrom:  at(3, 0, 0),
+		ValidUntil: at(29, 0, 0),
+		Schedule: []ScheduleWindow{
+			{Days: []string{"mon", "thu"}, From: "09:00", To: "13:00"},
+			{Days: []string{"fri"}, From: "22:00", To: "02:00"}, // Overnight
+		},
+	}
+
+	tests := []struct {
+		name string
+		t    time.Time
+		want string
+	}{
+		{"monday morning", at(3, 9, 0), ""},
+		{"window end is exclusive", at(3, 13, 0), ReasonOutsideSchedule},
+		{"tuesday", at(4, 10, 0), ReasonOutsideSchedule},
+		{"thursday", at(6, 12, 59), ""},
+		{"friday night", at(7, 23, 30), ""},
+		{"after midnight belongs to friday", at(8, 1, 30), ""},
+		{"saturday night", at(8, 23, 0), ReasonOutsideSchedule},
+		{"before valid from", at(2, 10, 0), ReasonNotYetValid},
+		{"after valid until", at(31, 10, 0), ReasonExpired},
+	}
+	for _, tt := range tests {
+		if got := cleaner.AccessAt(tt.t); got != tt.want {
+			t.Errorf("%s: AccessAt = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	if got := (User{Username: "admin"}).AccessAt(at(4, 3, 0)); got != "" {
+		t.Errorf("unrestricted user refused: %q", got)
+	}
+
+	// Sessions end with the window (or validity), whichever is first
+	if end := cleaner.accessEnd(at(7, 23, 0)); !end.Equal(at(8, 2, 0)) {
+		t.Errorf("accessEnd overnight = %v", end)
+	}
+	if end := (User{ValidUntil: at(3, 10, 0), Schedule: cleaner.Schedule}).accessEnd(at(3, 9, 30)); !end.Equal(at(3, 10, 0)) {
+		t.Errorf("accessEnd capped by ValidUntil = %v", end)
+	}
+}
+
+func TestScheduleEndOnDSTDays(t *testing.T) {
+	loc, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skip("no tz database: " + err.Error())
+	}
+	w := ScheduleWindow{From: "00:00", To: "10:00"}
+	// 30 March 2025 has 23 hours, 26 October 2025 has 25
+	for _, day := range []int{30, 26} {
+		month := time.March
+		if day == 26 {
+			month = time.October
+		}
+		at := time.Date(2025, month, day, 8, 0, 0, 0, loc)
+		if end, want := w.end(at), time.Date(2025, month, day, 10, 0, 0, 0, loc); !end.Equal(want) {
+			t.Errorf("%s: end = %v, want %v", at.Format("2006-01-02"), end, want)
+		}
+	}
+	overnight := ScheduleWindow{From: "22:00", To: "06:00"}
+	at := time.Date(2025, time.March, 29, 23, 0, 0, 0, loc)
+	if end, want := overnight.end(at), time.Date(2025, time.March, 30, 6, 0, 0, 0, loc); !end.Equal(want) {
+		t.Errorf("overnight end = %v, want %v", end, want)
+	}
+}
+
+func TestCheckPINOutsideScheduleCountsAsWrong(t *testing.T) {
+	pinIterations = 1000
+	defer func() { pinIterations = pinHashIterations }()
+	saved := pinLimiter
+	defer func() { pinLimiter = saved }()
+	pinLimiter = NewLimiter(LockoutPolicy{
+		ClientThreshold: 2,
+		GlobalThreshold: 10,
+		BaseDelay:       time.Minute,
+		MaxDelay:        time.Minute,
+		ResetAfter:      time.Hour,
+	}, nil)
+
+	u := User{Username: "temizlik", PIN: "2468", Role: UserRole, ValidFrom: time.Now().Add(24 * time.Hour)}
+	if err := hashUserPIN(&u); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := json.Marshal([]User{u})
+	os.MkdirAll("data", 0755)
+	if err := os.WriteFile(filepath.Join("data", "users.json"), data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(filepath.Join("data", "users.json"))
+
+	var denied []string
+	OnScheduleDenied(func(username, reason string) { denied = append(denied, username+" "+reason) })
+	defer OnScheduleDenied(nil)
+
+	// Answers like a wrong PIN, and counts towards the lockout
+	for i := 0; i < 2; i++ {
+		ctx, err := CheckPIN("10.0.0.5", "2468")
+		if err != nil || ctx.Authenticated {
+			t.Fatalf("attempt %d = %+v, %v; want unauthenticated without error", i, ctx, err)
+		}
+	}
+	if _, err := CheckPIN("10.0.0.5", "2468"); err == nil {
+		t.Error("out-of-schedule PIN did not count towards the lockout")
+	}
+	if len(denied) != 2 || denied[0] != "temizlik "+ReasonNotYetValid {
+		t.Errorf("schedule denials reported = %v", denied)
+	}
+}
+
+func TestValidateSchedule(t *testing.T) {
+	now := time.Now()
+	bad := []User{
+		{Schedule: []ScheduleWindow{{From: "9:00", To: "25:00"}}},
+		{Schedule: []ScheduleWindow{{From: "09:00", To: "09:00"}}},
+		{Schedule: []ScheduleWindow{{Days: []string{"monday"}, From: "09:00", To: "10:00"}}},
+		{ValidFrom: now, ValidUntil: now.Add(-time.Hour)},
+	}
+	for i, u := range bad {
+		if err := validateSchedule(u); err == nil {
+			t.Errorf("case %d accepted", i)
+		}
+	}
+	ok := User{ValidUntil: now, Schedule: []ScheduleWindow{{Days: []string{"sat", "sun"}, From: "18:00", To: "06:30"}}}
+	if err := validateSchedule(ok); err != nil {
+		t.Errorf("valid schedule rejected: %v", err)
+	}
+}
@@ -0,0 +1,188 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Session tokens replace sending the PIN with every request. A token is
+// "<id>.<signature>", the signature being an HMAC of the id under a per-process key,
+// so forged or mangled tokens are rejected before the store is consulted. Sessions
+// live in memory: they end on idle timeout, max age, logout, revocation or restart.
+
+const (
+	DefaultSessionIdle   = 15 * time.Minute
+	DefaultSessionMaxAge = 12 * time.Hour
+	sessionIDBytes       = 24
+)
+
+// Session is an authenticated login
+type Session struct {
+	ID        string    `json:"-"`
+	Username  string    `json:"username"`
+	Role      Role      `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+	LastSeen  time.Time `json:"last_seen"`
+	ExpiresAt time.Time `json:"expires_at"` // Absolute limit; idle timeout may end it earlier
+	Scopes    Scopes    `json:"-"`          // Copied at login; user changes revoke the session
+	Duress    bool      `json:"-"`          // Opened with the duress PIN; never exposed
+}
+
+// SessionStore issues and validates session tokens
+type SessionStore struct {
+	mu       sync.Mutex
+	key      []byte
+	sessions map[string]*Session
+	timeouts func() (idle, maxAge time.Duration)
+	now      func() time.Time
+}
+
+// NewSessionStore creates a store with a fresh signing key.
+// timeouts is read on every check so settings changes apply immediately.
+func NewSessionStore(timeouts func() (idle, maxAge time.Duration), now func() time.Time) *SessionStore {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		panic("auth: session key: " + err.Error())
+	}
+	if now == nil {
+		now = time.Now
+	}
+	return &SessionStore{key: key, sessions: make(map[string]*Session), timeouts: timeouts, now: now}
+}
+
+// Sessions is the process-wide session store
+var Sessions = NewSessionStore(nil, nil)
+
+// SetTimeouts replaces the idle/max-age source (nil = defaults)
+func (s *SessionStore) SetTimeouts(fn func() (idle, maxAge time.Duration)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.timeouts = fn
+}
+
+func (s *SessionStore) limitsLocked() (time.Duration, time.Duration) {
+	idle, maxAge := DefaultSessionIdle, DefaultSessionMaxAge
+	if s.timeouts != nil {
+		if i, m := s.timeouts(); i > 0 && m > 0 {
+			idle, maxAge = i, m
+		}
+	}
+	return idle, maxAge
+}
+
+func (s *SessionStore) sign(id string) string {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte(id))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// parse returns the session id of a well-signed token
+func (s *SessionStore) parse(token string) (string, bool) {
+	id, sig, ok := strings.Cut(token, ".")
+	if !ok || id == "" {
+		return "", false
+	}
+	return id, hmac.Equal([]byte(sig), []byte(s.sign(id)))
+}
+
+// Create starts a session for an authenticated user and returns its token.
+// The session ends no later than the user's access window (ctx.AccessUntil).
+func (s *SessionStore) Create(ctx *AuthContext) (string, Session) {
+	raw := make([]byte, sessionIDBytes)
+	if _, err := rand.Read(raw); err != nil {
+		panic("auth: session id: " + err.Error())
+	}
+	id := base64.RawURLEncoding.EncodeToString(raw)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pruneLocked()
+	now := s.now()
+	_, maxAge := s.limitsLocked()
+	expires := now.Add(maxAge)
+	if !ctx.AccessUntil.IsZero() && ctx.AccessUntil.Before(expires) {
+		expires = ctx.AccessUntil
+	}
+	sess := &Session{ID: id, Username: ctx.Username, Role: ctx.Role, CreatedAt: now, LastSeen: now, ExpiresAt: expires, Scopes: ctx.Scopes, Duress: ctx.Duress}
+	s.sessions[id] = sess
+	return id + "." + s.sign(id), *sess
+}
+
+// Validate returns the session of a live token and marks it as used
+func (s *SessionStore) Validate(token string) (Session, bool) {
+	id, ok := s.parse(token)
+	if !ok {
+		return Session{}, false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sess := s.sessions[id]
+	if sess == nil {
+		return Session{}, false
+	}
+	now := s.now()
+	if s.expiredLocked(sess, now) {
+		delete(s.sessions, id)
+		return Session{}, false
+	}
+	sess.LastSeen = now
+	return *sess, true
+}
+
+// Revoke ends the session of token (logout); unknown tokens are ignored
+func (s *SessionStore) Revoke(token string) bool {
+	id, ok := s.parse(token)
+	if !ok {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, found := s.sessions[id]
+	delete(s.sessions, id)
+	return found
+}
+
+// RevokeUser ends every session of username and returns how many were ended
+func (s *SessionStore) RevokeUser(username string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for id, sess := range s.sessions {
+		if sess.Username == username {
+			delete(s.sessions, id)
+			n++
+		}
+	}
+	return n
+}
+
+// Active returns the live sessions
+func (s *SessionStore) Active() []Session {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pruneLocked()
+	out := make([]Session, 0, len(s.sessions))
+	for _, sess := range s.sessions {
+		out = append(out, *sess)
+	}
+	return out
+}
+
+func (s *SessionStore) expiredLocked(sess *Session, now time.Time) bool {
+	idle, _ := s.limitsLocked()
+	return !now.Before(sess.ExpiresAt) || now.Sub(sess.LastSeen) >= idle
+}
+
+func (s *SessionStore) pruneLocked() {
+	now := s.now()
+	for id, sess := range s.sessions {
+		if s.expiredLocked(sess, now) {
+			delete(s.sessions, id)
+		}
+	}
+}
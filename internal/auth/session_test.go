@@ -0,0 +1,69 @@
+package auth
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSessionLifecycle(t *testing.T) {
+	now := time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC)
+	store := NewSessionStore(func() (time.Duration, time.Duration) {
+		return 10 * time.Minute, time.Hour
+	}, func() time.Time { return now })
+
+	token, sess := store.Create(&AuthContext{Username: "ayse", Role: Admin})
+	if sess.ExpiresAt != now.Add(time.Hour) {
+		t.Fatalf("expires at %v", sess.ExpiresAt)
+	}
+	if got, ok := store.Validate(token); !ok || got.Username != "ayse" || got.Role != Admin {
+		t.Fatalf("validate = %+v, %v", got, ok)
+	}
+
+	// Forged or mangled tokens never reach the store
+	id := token[:len(token)-44] // Strip ".<43-char signature>"
+	for _, bad := range []string{"", "nodot", id + ".AAAA", token + "x", "x" + token} {
+		if _, ok := store.Validate(bad); ok {
+			t.Errorf("token %q accepted", bad)
+		}
+	}
+
+	// Activity keeps the session alive until the absolute limit
+	for i := 0; i < 5; i++ {
+		now = now.Add(9 * time.Minute)
+		if _, ok := store.Validate(token); !ok {
+			t.Fatalf("session ended after %d active intervals", i+1)
+		}
+	}
+	now = now.Add(9 * time.Minute) // 54 min
+	if _, ok := store.Validate(token); !ok {
+		t.Fatal("session ended before max age")
+	}
+	now = now.Add(9 * time.Minute) // 63 min
+	if _, ok := store.Validate(token); ok {
+		t.Fatal("session outlived max age")
+	}
+
+	// Idle timeout
+	idle, _ := store.Create(&AuthContext{Username: "mehmet", Role: UserRole})
+	now = now.Add(10 * time.Minute)
+	if _, ok := store.Validate(idle); ok {
+		t.Fatal("idle session still valid")
+	}
+
+	// Logout and per-user revocation
+	a, _ := store.Create(&AuthContext{Username: "ayse", Role: Admin})
+	b, _ := store.Create(&AuthContext{Username: "ayse", Role: Admin})
+	c, _ := store.Create(&AuthContext{Username: "mehmet", Role: UserRole})
+	if !store.Revoke(a) || store.Revoke(a) {
+		t.Fatal("revoke should succeed once")
+	}
+	if n := store.RevokeUser("ayse"); n != 1 {
+		t.Fatalf("revoked %d sessions, want 1", n)
+	}
+	if _, ok := store.Validate(b); ok {
+		t.Fatal("revoked user session still valid")
+	}
+	if _, ok := store.Validate(c); !ok || len(store.Active()) != 1 {
+		t.Fatal("other user's session affected")
+	}
+}
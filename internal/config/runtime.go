@@ -16,7 +16,7 @@ type RuntimeConfig struct {
 	WizardCompleted bool   `json:"wizard_completed"`
 	BindAddr        string `json:"bind_addr"`
 	Port            int    `json:"port"`
-	TrustProxy      bool   `json:"trust_proxy"`
+	TrustProxy      bool   `json:"trust_proxy"`       // Behind a reverse proxy: take the client address from X-Forwarded-For / X-Real-IP
 	QuietHoursStart string `json:"quiet_hours_start"` // "22:00"
 	QuietHoursEnd   string `json:"quiet_hours_end"`   // "06:00"
 	Language        string `json:"language"`          // "en"|"tr"
@@ -48,6 +48,21 @@ type RuntimeConfig struct {
 	HaRuntimeUnreachable  bool    `json:"ha_runtime_unreachable"`            // true = HA became temporarily unreachable after N failures
 	HaLastSeenAt          *string `json:"ha_last_seen_at,omitempty"`         // RFC3339 timestamp of last successful HA read
 	HaConsecutiveFailures int     `json:"ha_consecutive_failures,omitempty"` // Counter for consecutive read failures (not persisted, runtime only)
+
+	// Development mode: honours the X-User-Role header so any client can pick its role.
+	// Only set from SMARTDISPLAY_DEV_MODE, never persisted.
+	DevMode bool `json:"-"`
+
+	// Alarmo areas (A5): one alarm_control_panel per area; first entry is primary.
+	// Empty = single panel alarm_control_panel.alarmo
+	AlarmoPanels []AlarmoPanelConfig `json:"alarmo_panels,omitempty"`
+}
+
+// AlarmoPanelConfig maps an area key to its Alarmo alarm_control_panel entity.
+type AlarmoPanelConfig struct {
+	Area     string `json:"area"`           // "house", "garage", "shed"
+	EntityID string `json:"entity_id"`      // "alarm_control_panel.garage"
+	Name     string `json:"name,omitempty"` // Display name (defaults to area)
 }
 
 const RuntimeConfigPath = "data/runtime.json"
@@ -106,6 +121,9 @@ func LoadRuntimeConfig() (*RuntimeConfig, error) {
 	if v := os.Getenv("TRUST_PROXY"); v != "" {
 		cfg.TrustProxy = v == "true" || v == "1"
 	}
+	if v := os.Getenv("SMARTDISPLAY_DEV_MODE"); v != "" {
+		cfg.DevMode = v == "true" || v == "1"
+	}
 	if v := os.Getenv("LANGUAGE"); v != "" {
 		cfg.Language = v
 	} else if cfg.Language == "" {
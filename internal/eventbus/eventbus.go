@@ -1,27 +1,308 @@
+// Package eventbus is the in-process event bus owned by the Coordinator.
+// Publishers never block: every subscriber has its own buffered queue and goroutine,
+// a full queue drops events according to the subscriber's policy, and a panicking
+// handler is recovered without affecting other subscribers.
 package eventbus
 
+import (
+	"fmt"
+	"smartdisplay-core/internal/logger"
+	"sync"
+	"time"
+)
+
+// Topic groups related event types
+type Topic string
+
+const (
+	TopicAlarm  Topic = "alarm"
+	TopicGuest  Topic = "guest"
+	TopicHAL    Topic = "hal"
+	TopicHA     Topic = "ha"
+	TopicSystem Topic = "system"
+)
+
+// Event types
+const (
+	AlarmStateChanged    = "alarm.state_changed"    // from, to, event, armed_mode
+	AlarmoStateChanged   = "alarm.alarmo_changed"   // area, mode, armed_mode, raw_state
+	AlarmCommandResolved = "alarm.command_resolved" // id, area, action, status, reason
+	AlarmDuress          = "alarm.duress"           // username, area
+	GuestAction          = "guest.action"           // id, action, from, state
+	GuestRequestChanged  = "guest.request_changed"  // id, status, target_user, pass_id, guest_name
+	GuestRearm           = "guest.rearm"            // status, action, arm_at, reason, by
+	HALSignal            = "hal.signal"             // device_type, id, value
+	HALDeviceFault       = "hal.device_fault"       // device_type, id, error
+	HALDeviceRecovered   = "hal.device_recovered"   // device_type, id
+	HAConnection         = "ha.connection"          // connected
+	HAEvent              = "ha.event"               // event_type
+	FailsafeChanged      = "system.failsafe"        // active, explanation
+	SystemStarted        = "system.started"         // version
+	PINScheduleDenied    = "system.pin_schedule"    // username, reason
+)
+
+// Event is a single published fact
 type Event struct {
+	Topic   Topic
 	Type    string
+	Time    time.Time
 	Payload map[string]interface{}
 }
 
-type Subscriber func(Event)
+// Handler receives events on the subscriber's own goroutine
+type Handler func(Event)
+
+// OverflowPolicy decides what a full subscriber queue does with a new event
+type OverflowPolicy int
+
+const (
+	DropNewest OverflowPolicy = iota // Keep queued events, discard the new one
+	DropOldest                       // Discard the oldest queued event to make room
+)
+
+const defaultBuffer = 64
+
+// Option configures a subscription
+type Option func(*subscriber)
+
+// Topics limits a subscription to the given topics (default: all)
+func Topics(topics ...Topic) Option {
+	return func(s *subscriber) {
+		s.topics = make(map[Topic]bool, len(topics))
+		for _, t := range topics {
+			s.topics[t] = true
+		}
+	}
+}
+
+// Buffer sets the queue length of a subscription
+func Buffer(n int) Option {
+	return func(s *subscriber) {
+		if n > 0 {
+			s.buffer = n
+		}
+	}
+}
 
-var subscribers []Subscriber
+// Policy sets the overflow policy of a subscription
+func Policy(p OverflowPolicy) Option {
+	return func(s *subscriber) { s.policy = p }
+}
 
-func Subscribe(sub Subscriber) {
-	subscribers = append(subscribers, sub)
+// Stats reports per-subscriber delivery counters
+type Stats struct {
+	Name      string `json:"name"`
+	Queued    int    `json:"queued"`
+	Delivered uint64 `json:"delivered"`
+	Dropped   uint64 `json:"dropped"`
+	Panics    uint64 `json:"panics"`
+}
+
+type subscriber struct {
+	id      uint64
+	name    string
+	topics  map[Topic]bool // nil = all topics
+	buffer  int
+	policy  OverflowPolicy
+	handler Handler
+	queue   chan Event
+	done    chan struct{}
+	exited  chan struct{} // Closed when run returns
+
+	mu        sync.Mutex // Guards counters and enqueue/close
+	closed    bool
+	delivered uint64
+	dropped   uint64
+	panics    uint64
+}
+
+// Subscription is the handle returned by Subscribe
+type Subscription struct {
+	bus *Bus
+	sub *subscriber
+}
+
+// Unsubscribe stops delivery; queued events are discarded. Safe to call twice.
+func (s *Subscription) Unsubscribe() {
+	if s == nil {
+		return
+	}
+	s.bus.remove(s.sub.id)
+}
+
+// Bus is a typed publish/subscribe hub
+type Bus struct {
+	mu     sync.RWMutex
+	subs   map[uint64]*subscriber
+	nextID uint64
+	closed bool
+	now    func() time.Time
+}
+
+// New creates an empty bus
+func New() *Bus {
+	return &Bus{subs: make(map[uint64]*subscriber), now: time.Now}
+}
+
+// Subscribe registers a handler; name identifies it in logs and stats
+func (b *Bus) Subscribe(name string, handler Handler, opts ...Option) *Subscription {
+	s := &subscriber{
+		name:    name,
+		buffer:  defaultBuffer,
+		policy:  DropNewest,
+		handler: handler,
+		done:    make(chan struct{}),
+		exited:  make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.queue = make(chan Event, s.buffer)
+
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		close(s.done)
+		return &Subscription{bus: b, sub: s}
+	}
+	b.nextID++
+	s.id = b.nextID
+	b.subs[s.id] = s
+	b.mu.Unlock()
+
+	go s.run()
+	return &Subscription{bus: b, sub: s}
+}
+
+// Publish queues the event for every matching subscriber without blocking.
+// A nil bus ignores the event.
+func (b *Bus) Publish(topic Topic, eventType string, payload map[string]interface{}) {
+	if b == nil {
+		return
+	}
+	ev := Event{Topic: topic, Type: eventType, Time: b.now(), Payload: payload}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if b.closed {
+		return
+	}
+	for _, s := range b.subs {
+		if s.topics != nil && !s.topics[topic] {
+			continue
+		}
+		s.enqueue(ev)
+	}
+}
+
+// Stats returns delivery counters of all subscribers
+func (b *Bus) Stats() []Stats {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	out := make([]Stats, 0, len(b.subs))
+	for _, s := range b.subs {
+		s.mu.Lock()
+		out = append(out, Stats{
+			Name:      s.name,
+			Queued:    len(s.queue),
+			Delivered: s.delivered,
+			Dropped:   s.dropped,
+			Panics:    s.panics,
+		})
+		s.mu.Unlock()
+	}
+	return out
+}
+
+// Close unsubscribes everyone and waits for handlers still running; later
+// publishes are ignored. Must not be called from a handler.
+func (b *Bus) Close() {
+	b.mu.Lock()
+	subs := b.subs
+	b.subs = make(map[uint64]*subscriber)
+	b.closed = true
+	b.mu.Unlock()
+
+	for _, s := range subs {
+		s.stop()
+	}
+	for _, s := range subs {
+		<-s.exited
+	}
+}
+
+func (b *Bus) remove(id uint64) {
+	b.mu.Lock()
+	s, ok := b.subs[id]
+	delete(b.subs, id)
+	b.mu.Unlock()
+	if ok {
+		s.stop()
+	}
+}
+
+func (s *subscriber) enqueue(ev Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	select {
+	case s.queue <- ev:
+		return
+	default:
+	}
+
+	s.dropped++
+	if s.dropped == 1 || s.dropped%100 == 0 {
+		logger.Error(fmt.Sprintf("eventbus: subscriber %s queue full, dropped %d events", s.name, s.dropped))
+	}
+	if s.policy == DropOldest {
+		select {
+		case <-s.queue:
+		default:
+		}
+		select {
+		case s.queue <- ev:
+		default:
+		}
+	}
+}
+
+func (s *subscriber) stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	s.closed = true
+	close(s.done)
 }
 
-func Publish(event Event) {
-	for _, sub := range subscribers {
-		sub(event)
+func (s *subscriber) run() {
+	defer close(s.exited)
+	for {
+		select {
+		case <-s.done:
+			return
+		case ev := <-s.queue:
+			s.deliver(ev)
+		}
 	}
-	logEvent(event)
 }
 
-func logEvent(event Event) {
-	// Use logger if available, else fallback to println
-	// logger.Info("eventbus: " + event.Type)
-	println("eventbus: " + event.Type)
+// deliver calls the handler with panic isolation
+func (s *subscriber) deliver(ev Event) {
+	defer func() {
+		if r := recover(); r != nil {
+			s.mu.Lock()
+			s.panics++
+			s.mu.Unlock()
+			logger.Error(fmt.Sprintf("eventbus: subscriber %s panicked on %s: %v", s.name, ev.Type, r))
+		}
+	}()
+	s.handler(ev)
+	s.mu.Lock()
+	s.delivered++
+	s.mu.Unlock()
 }
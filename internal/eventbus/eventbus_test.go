@@ -0,0 +1,162 @@
+package eventbus
+
+import (
+	"os"
+	"smartdisplay-core/internal/logger"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMain(m *testing.M) {
+	os.MkdirAll("logs", 0755)
+	logger.Init()
+	os.Exit(m.Run())
+}
+
+func receive(t *testing.T, ch <-chan Event) Event {
+	t.Helper()
+	select {
+	case ev := <-ch:
+		return ev
+	case <-time.After(2 * time.Second):
+		t.Fatal("event not delivered")
+	}
+	return Event{}
+}
+
+func expectNone(t *testing.T, ch <-chan Event) {
+	t.Helper()
+	select {
+	case ev := <-ch:
+		t.Fatalf("unexpected event %s", ev.Type)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestTopicFilterAndUnsubscribe(t *testing.T) {
+	bus := New()
+	defer bus.Close()
+
+	alarmCh := make(chan Event, 4)
+	allCh := make(chan Event, 4)
+	sub := bus.Subscribe("alarm-only", func(ev Event) { alarmCh <- ev }, Topics(TopicAlarm))
+	bus.Subscribe("all", func(ev Event) { allCh <- ev })
+
+	bus.Publish(TopicGuest, GuestAction, map[string]interface{}{"action": "REQUEST"})
+	bus.Publish(TopicAlarm, AlarmStateChanged, map[string]interface{}{"to": "ARMED"})
+
+	if ev := receive(t, alarmCh); ev.Type != AlarmStateChanged || ev.Payload["to"] != "ARMED" || ev.Time.IsZero() {
+		t.Errorf("alarm subscriber got %+v", ev)
+	}
+	expectNone(t, alarmCh)
+	if receive(t, allCh).Topic != TopicGuest || receive(t, allCh).Topic != TopicAlarm {
+		t.Error("unfiltered subscriber missed events or order")
+	}
+
+	sub.Unsubscribe()
+	sub.Unsubscribe() // Idempotent
+	bus.Publish(TopicAlarm, AlarmStateChanged, nil)
+	expectNone(t, alarmCh)
+	receive(t, allCh)
+}
+
+func TestOverflowPolicies(t *testing.T) {
+	bus := New()
+	defer bus.Close()
+
+	for _, tc := range []struct {
+		name   string
+		policy OverflowPolicy
+		want   []int // Values delivered after the handler is released
+	}{
+		{"drop-newest", DropNewest, []int{0, 1, 2}},
+		{"drop-oldest", DropOldest, []int{0, 3, 4}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			gate := make(chan struct{})
+			busy := make(chan struct{}, 8)
+			got := make(chan Event, 8)
+			bus.Subscribe(tc.name, func(ev Event) {
+				busy <- struct{}{}
+				<-gate
+				got <- ev
+			}, Topics(Topic(tc.name)), Buffer(2), Policy(tc.policy))
+
+			// First event is taken by the blocked handler, the queue holds two more
+			bus.Publish(Topic(tc.name), "n", map[string]interface{}{"n": 0})
+			<-busy
+			for i := 1; i <= 4; i++ {
+				bus.Publish(Topic(tc.name), "n", map[string]interface{}{"n": i})
+			}
+			close(gate)
+
+			for _, want := range tc.want {
+				if n := receive(t, got).Payload["n"]; n != want {
+					t.Fatalf("delivered n=%v, want %d", n, want)
+				}
+			}
+			expectNone(t, got)
+
+			for _, st := range bus.Stats() {
+				if st.Name == tc.name && st.Dropped != 2 {
+					t.Errorf("dropped = %d, want 2", st.Dropped)
+				}
+			}
+		})
+	}
+}
+
+func TestPanickingSubscriberIsIsolated(t *testing.T) {
+	bus := New()
+	defer bus.Close()
+
+	healthy := make(chan Event, 4)
+	bus.Subscribe("broken", func(Event) { panic("boom") })
+	bus.Subscribe("healthy", func(ev Event) { healthy <- ev })
+
+	bus.Publish(TopicHAL, HALSignal, nil)
+	bus.Publish(TopicHAL, HALSignal, nil)
+	receive(t, healthy)
+	receive(t, healthy)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		for _, st := range bus.Stats() {
+			if st.Name == "broken" && st.Panics == 2 {
+				return
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("panics not counted: %+v", bus.Stats())
+}
+
+func TestPublishAfterCloseAndNilBus(t *testing.T) {
+	var nilBus *Bus
+	nilBus.Publish(TopicSystem, "noop", nil)
+
+	bus := New()
+	ch := make(chan Event, 1)
+	bus.Subscribe("s", func(ev Event) { ch <- ev })
+	bus.Close()
+	bus.Publish(TopicSystem, "late", nil)
+	expectNone(t, ch)
+}
+
+func TestCloseWaitsForRunningHandlers(t *testing.T) {
+	bus := New()
+	started := make(chan struct{})
+	var finished atomic.Bool
+	bus.Subscribe("slow", func(ev Event) {
+		close(started)
+		time.Sleep(50 * time.Millisecond)
+		finished.Store(true)
+	})
+	bus.Publish(TopicSystem, "work", nil)
+	<-started
+	bus.Close()
+	if !finished.Load() {
+		t.Error("Close returned while a handler was still running")
+	}
+}
@@ -0,0 +1,109 @@
+package guest
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The Approve/Reject buttons of the HA notification carry a signed decision token.
+// A token is "<expiry>.<nonce>.<signature>", the signature being an HMAC over the
+// request ID, the decision, the expiry and the nonce, so a token only works for its
+// own request and button, until the approval deadline, and only once. Used nonces
+// are kept in memory; after a restart the request is no longer pending, which the
+// approval handler checks first.
+
+var (
+	ErrTokenInvalid = errors.New("invalid decision token")
+	ErrTokenExpired = errors.New("decision token expired")
+	ErrTokenUsed    = errors.New("decision token already used")
+)
+
+// CallbackSigner issues and checks decision tokens for HA notification actions
+type CallbackSigner struct {
+	mu   sync.Mutex
+	key  []byte
+	used map[string]time.Time // Nonce -> token expiry, dropped once expired
+	now  func() time.Time
+}
+
+// NewCallbackSigner creates a signer with a fresh key (tokens end with the process)
+func NewCallbackSigner() *CallbackSigner {
+	return &CallbackSigner{key: newKey(), used: make(map[string]time.Time), now: time.Now}
+}
+
+// Open loads the signing key from path, creating it on first start, so buttons
+// of notifications sent before a restart keep working
+func (s *CallbackSigner) Open(path string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key, err := loadOrCreateKey(path, s.key)
+	if err != nil {
+		return err
+	}
+	s.key = key
+	return nil
+}
+
+func (s *CallbackSigner) sign(requestID, decision, expiry, nonce string) string {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte(requestID + "\n" + decision + "\n" + expiry + "\n" + nonce))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// Issue returns a token for one decision on one request, valid until expires
+func (s *CallbackSigner) Issue(requestID, decision string, expires time.Time) string {
+	raw := make([]byte, 16)
+	if _, err := rand.Read(raw); err != nil {
+		panic("guest: callback nonce: " + err.Error())
+	}
+	nonce := base64.RawURLEncoding.EncodeToString(raw)
+	expiry := strconv.FormatInt(expires.Unix(), 10)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return expiry + "." + nonce + "." + s.sign(requestID, decision, expiry, nonce)
+}
+
+// Verify checks a token against the request and decision it is used for and
+// consumes it
+func (s *CallbackSigner) Verify(token, requestID, decision string) error {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 || parts[1] == "" {
+		return ErrTokenInvalid
+	}
+	expiry, nonce, sig := parts[0], parts[1], parts[2]
+	unix, err := strconv.ParseInt(expiry, 10, 64)
+	if err != nil {
+		return ErrTokenInvalid
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !hmac.Equal([]byte(sig), []byte(s.sign(requestID, decision, expiry, nonce))) {
+		return ErrTokenInvalid
+	}
+	now := s.now()
+	expires := time.Unix(unix, 0)
+	if !now.Before(expires) {
+		return ErrTokenExpired
+	}
+	for n, exp := range s.used {
+		if !now.Before(exp) {
+			delete(s.used, n)
+		}
+	}
+	if _, ok := s.used[nonce]; ok {
+		return ErrTokenUsed
+	}
+	s.used[nonce] = expires
+	return nil
+}
@@ -0,0 +1,59 @@
+package guest
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCallbackTokens(t *testing.T) {
+	now := time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC)
+	s := NewCallbackSigner()
+	s.now = func() time.Time { return now }
+
+	approve := s.Issue("greq-1", "approve", now.Add(time.Minute))
+	if err := s.Verify(approve, "greq-1", "reject"); !errors.Is(err, ErrTokenInvalid) {
+		t.Errorf("approve token used to reject = %v", err)
+	}
+	if err := s.Verify(approve, "greq-2", "approve"); !errors.Is(err, ErrTokenInvalid) {
+		t.Errorf("token used for another request = %v", err)
+	}
+	tampered := []byte(approve)
+	tampered[len(tampered)-1] ^= 1
+	if err := s.Verify(string(tampered), "greq-1", "approve"); !errors.Is(err, ErrTokenInvalid) {
+		t.Errorf("tampered token = %v", err)
+	}
+	if err := s.Verify(approve, "greq-1", "approve"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Verify(approve, "greq-1", "approve"); !errors.Is(err, ErrTokenUsed) {
+		t.Errorf("replayed token = %v", err)
+	}
+
+	reject := s.Issue("greq-1", "reject", now.Add(time.Minute))
+	now = now.Add(time.Minute)
+	if err := s.Verify(reject, "greq-1", "reject"); !errors.Is(err, ErrTokenExpired) {
+		t.Errorf("expired token = %v", err)
+	}
+}
+
+func TestCallbackKeySurvivesRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "guest_callback.key")
+	s := NewCallbackSigner()
+	if err := s.Open(path); err != nil {
+		t.Fatal(err)
+	}
+	token := s.Issue("greq-1", "approve", time.Now().Add(time.Minute))
+
+	restarted := NewCallbackSigner()
+	if err := restarted.Open(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := restarted.Verify(token, "greq-1", "approve"); err != nil {
+		t.Errorf("token from before the restart = %v", err)
+	}
+	if err := NewCallbackSigner().Verify(token, "greq-1", "approve"); !errors.Is(err, ErrTokenInvalid) {
+		t.Errorf("token accepted under another key: %v", err)
+	}
+}
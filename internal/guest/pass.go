@@ -0,0 +1,275 @@
+package guest
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"slices"
+	"smartdisplay-core/internal/logger"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Guest passes are created ahead of time (cleaner, family visiting for the weekend)
+// and redeemed at the display with a short code or an RFID card. Redeeming admits
+// the guest like an approved request; access ends after the pass's access time.
+
+const passCodeDigits = 6
+
+// MinPassCodeLength is the shortest code a pass may be given
+const MinPassCodeLength = passCodeDigits
+
+var (
+	ErrPassNotFound = errors.New("guest pass not found")
+	ErrPassInvalid  = errors.New("guest pass not valid now")
+)
+
+// weekdayNames maps time.Weekday to the day names used by passes
+var weekdayNames = [...]string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// Pass is a pre-created guest access
+type Pass struct {
+	ID            string    `json:"id"`
+	Name          string    `json:"name"`                     // Guest name, shown in the logbook
+	CodeHash      string    `json:"code_hash,omitempty"`      // HMAC of the short code under the device key; the code itself is shown once
+	CardID        string    `json:"card_id,omitempty"`        // RFID card UID
+	TargetUser    string    `json:"target_user,omitempty"`    // HA user notified when the pass is used
+	ValidFrom     time.Time `json:"valid_from"`               // First moment the pass works
+	ValidUntil    time.Time `json:"valid_until"`              // Pass (and any access from it) ends here
+	Days          []string  `json:"days,omitempty"`           // "mon".."sun"; empty = every day
+	Recurring     bool      `json:"recurring"`                // false = one-time pass
+	AccessMinutes int       `json:"access_minutes,omitempty"` // Access per use; 0 = DefaultAccessDuration
+	CreatedBy     string    `json:"created_by,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+	Uses          int       `json:"uses"`
+	LastUsedAt    time.Time `json:"last_used_at,omitzero"`
+}
+
+// Validate checks a pass before it is stored
+func (p Pass) Validate() error {
+	if strings.TrimSpace(p.Name) == "" {
+		return errors.New("name required")
+	}
+	if p.CodeHash == "" && p.CardID == "" {
+		return errors.New("code or card_id required")
+	}
+	if p.ValidFrom.IsZero() || p.ValidUntil.IsZero() || !p.ValidUntil.After(p.ValidFrom) {
+		return errors.New("valid_until must be after valid_from")
+	}
+	for _, d := range p.Days {
+		if !slices.Contains(weekdayNames[:], d) {
+			return fmt.Errorf("invalid day: %q", d)
+		}
+	}
+	if p.AccessMinutes < 0 {
+		return errors.New("invalid access_minutes")
+	}
+	return nil
+}
+
+// ValidAt reports whether the pass can be redeemed at t
+func (p Pass) ValidAt(t time.Time) bool {
+	if t.Before(p.ValidFrom) || !t.Before(p.ValidUntil) {
+		return false
+	}
+	if !p.Recurring && p.Uses > 0 {
+		return false
+	}
+	return len(p.Days) == 0 || slices.Contains(p.Days, weekdayNames[t.Local().Weekday()])
+}
+
+// accessEnd returns when access from a redemption at t ends
+func (p Pass) accessEnd(t time.Time, def time.Duration) time.Time {
+	d := def
+	if p.AccessMinutes > 0 {
+		d = time.Duration(p.AccessMinutes) * time.Minute
+	}
+	if end := t.Add(d); end.Before(p.ValidUntil) {
+		return end
+	}
+	return p.ValidUntil
+}
+
+// hashCode hashes a pass code for storage. The HMAC key stays on the device, so a
+// copied pass file does not give away the short codes.
+func (b *PassBook) hashCode(code string) string {
+	mac := hmac.New(sha256.New, b.key)
+	mac.Write([]byte(code))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// NewPassCode returns a random numeric pass code
+func NewPassCode() (string, error) {
+	max := big.NewInt(1)
+	for i := 0; i < passCodeDigits; i++ {
+		max.Mul(max, big.NewInt(10))
+	}
+	n, err := rand.Int(rand.Reader, max)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%0*d", passCodeDigits, n), nil
+}
+
+// PassBook stores guest passes, optionally in a JSON file
+type PassBook struct {
+	mu      sync.Mutex
+	passes  []Pass
+	key     []byte // Code hashing key
+	path    string // Empty = memory only
+	now     func() time.Time
+	counter int64
+}
+
+// NewPassBook creates an empty, memory-only pass book
+func NewPassBook() *PassBook {
+	return &PassBook{key: newKey(), now: time.Now}
+}
+
+// Open loads the passes in path and saves every later change there. The code
+// hashing key is kept in keyPath. Call it at startup, before passes are added.
+func (b *PassBook) Open(path, keyPath string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	key, err := loadOrCreateKey(keyPath, b.key)
+	if err != nil {
+		return err
+	}
+	b.key = key
+
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	var loaded []Pass
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &loaded); err != nil {
+			return err
+		}
+	}
+	b.passes = append(loaded, b.passes...)
+	b.path = path
+	logger.Info(fmt.Sprintf("guest passes loaded: %d", len(loaded)))
+	return b.saveLocked()
+}
+
+// Close stops the store once a save in progress is done; later changes stay in memory
+func (b *PassBook) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.path = ""
+}
+
+// saveLocked atomically rewrites the pass file
+func (b *PassBook) saveLocked() error {
+	if b.path == "" {
+		return nil
+	}
+	return writeJSONFile(b.path, b.passes)
+}
+
+// Add stores a new pass. code is the plaintext short code (may be empty for card-only passes).
+func (b *PassBook) Add(p Pass, code string) (Pass, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if code != "" {
+		p.CodeHash = b.hashCode(code)
+	}
+	if err := p.Validate(); err != nil {
+		return Pass{}, err
+	}
+	for _, other := range b.passes {
+		if code != "" && other.CodeHash == p.CodeHash || p.CardID != "" && other.CardID == p.CardID {
+			return Pass{}, errors.New("code or card already used by another pass")
+		}
+	}
+	now := b.now()
+	b.counter++
+	p.ID = fmt.Sprintf("gpass-%d-%d", now.UnixNano(), b.counter)
+	p.CreatedAt = now
+	p.Uses = 0
+	p.LastUsedAt = time.Time{}
+	b.passes = append(b.passes, p)
+	if err := b.saveLocked(); err != nil {
+		b.passes = b.passes[:len(b.passes)-1]
+		return Pass{}, err
+	}
+	logger.Info("guest pass created: id=" + p.ID + " name=" + p.Name)
+	return p, nil
+}
+
+// List returns all passes without their code hashes
+func (b *PassBook) List() []Pass {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	list := make([]Pass, len(b.passes))
+	for i, p := range b.passes {
+		p.CodeHash = ""
+		list[i] = p
+	}
+	return list
+}
+
+// Remove deletes a pass. Guests already admitted with it are not affected here;
+// Coordinator.RemoveGuestPass ends their access too.
+func (b *PassBook) Remove(id string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	i := slices.IndexFunc(b.passes, func(p Pass) bool { return p.ID == id })
+	if i < 0 {
+		return ErrPassNotFound
+	}
+	b.passes = slices.Delete(b.passes, i, i+1)
+	logger.Info("guest pass removed: id=" + id)
+	return b.saveLocked()
+}
+
+// Redeem finds the pass for code or cardID, checks it is valid now and calls admit.
+// The use is recorded only when admit succeeds.
+func (b *PassBook) Redeem(code, cardID string, admit func(Pass) error) (Pass, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	hash := ""
+	if code != "" {
+		hash = b.hashCode(code)
+	}
+	i := slices.IndexFunc(b.passes, func(p Pass) bool {
+		if hash != "" && p.CodeHash != "" {
+			return subtle.ConstantTimeCompare([]byte(hash), []byte(p.CodeHash)) == 1
+		}
+		return cardID != "" && p.CardID == cardID
+	})
+	if i < 0 {
+		return Pass{}, ErrPassNotFound
+	}
+	now := b.now()
+	p := b.passes[i]
+	if !p.ValidAt(now) {
+		logger.Info("guest pass refused: id=" + p.ID)
+		return Pass{}, ErrPassInvalid
+	}
+	if err := admit(p); err != nil {
+		return Pass{}, err
+	}
+	b.passes[i].Uses++
+	b.passes[i].LastUsedAt = now
+	if err := b.saveLocked(); err != nil {
+		logger.Error("guest pass save failed: " + err.Error())
+	}
+	p = b.passes[i]
+	p.CodeHash = ""
+	return p, nil
+}
@@ -0,0 +1,103 @@
+package guest
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPassValidAt(t *testing.T) {
+	from := time.Date(2025, 3, 3, 0, 0, 0, 0, time.Local) // Monday
+	p := Pass{
+		Name:       "Temizlikçi",
+		CardID:     "04A1B2",
+		ValidFrom:  from,
+		ValidUntil: from.AddDate(0, 1, 0),
+		Days:       []string{"mon", "thu"},
+		Recurring:  true,
+	}
+	if err := p.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if !p.ValidAt(from.Add(10 * time.Hour)) {
+		t.Error("monday refused")
+	}
+	if p.ValidAt(from.AddDate(0, 0, 1).Add(10 * time.Hour)) {
+		t.Error("tuesday accepted")
+	}
+	if p.ValidAt(from.Add(-time.Hour)) || p.ValidAt(p.ValidUntil) {
+		t.Error("accepted outside the validity period")
+	}
+
+	p.Recurring = false
+	p.Uses = 1
+	if p.ValidAt(from.Add(10 * time.Hour)) {
+		t.Error("one-time pass accepted twice")
+	}
+
+	p.Days = []string{"monday"}
+	if err := p.Validate(); err == nil {
+		t.Error("invalid day accepted")
+	}
+}
+
+func TestPassBookRedeem(t *testing.T) {
+	now := time.Date(2025, 3, 3, 10, 0, 0, 0, time.Local)
+	dir := t.TempDir()
+	path, keyPath := filepath.Join(dir, "guest_passes.json"), filepath.Join(dir, "guest_pass.key")
+	b := NewPassBook()
+	b.now = func() time.Time { return now }
+	if err := b.Open(path, keyPath); err != nil {
+		t.Fatal(err)
+	}
+	created, err := b.Add(Pass{Name: "Ayşe", ValidFrom: now.Add(-time.Hour), ValidUntil: now.Add(time.Hour)}, "123456")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := NewManager(time.Minute)
+	m.now = func() time.Time { return now }
+	admit := func(p Pass) error {
+		_, err := m.Admit(p, "display")
+		return err
+	}
+
+	if _, err := b.Redeem("000000", "", admit); !errors.Is(err, ErrPassNotFound) {
+		t.Errorf("wrong code = %v", err)
+	}
+	used, err := b.Redeem("123456", "", admit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if used.Uses != 1 || used.CodeHash != "" {
+		t.Errorf("redeemed pass = %+v", used)
+	}
+	reqs := m.Requests()
+	if len(reqs) != 1 || reqs[0].State != APPROVED || reqs[0].PassID != created.ID || !reqs[0].ExpiresAt.Equal(now.Add(DefaultAccessDuration)) {
+		t.Errorf("admitted guest = %+v", reqs)
+	}
+	if _, err := b.Redeem("123456", "", admit); !errors.Is(err, ErrPassInvalid) {
+		t.Errorf("second use of one-time pass = %v", err)
+	}
+
+	// The stored hash is keyed: a plain SHA-256 of the code does not match it
+	if sum := sha256.Sum256([]byte("123456")); b.passes[0].CodeHash == hex.EncodeToString(sum[:]) {
+		t.Error("pass code stored as unkeyed SHA-256")
+	}
+
+	// Uses and the key survive a restart: the code is still recognised (and spent)
+	reloaded := NewPassBook()
+	reloaded.now = b.now
+	if err := reloaded.Open(path, keyPath); err != nil {
+		t.Fatal(err)
+	}
+	if list := reloaded.List(); len(list) != 1 || list[0].Uses != 1 || list[0].CodeHash != "" {
+		t.Errorf("reloaded passes = %+v", list)
+	}
+	if _, err := reloaded.Redeem("123456", "", admit); !errors.Is(err, ErrPassInvalid) {
+		t.Errorf("used code after restart = %v, want ErrPassInvalid", err)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"smartdisplay-core/internal/logger"
+	"sort"
 	"sync"
 	"time"
 )
@@ -16,25 +17,71 @@ const (
 	StatusApproved = "approved"
 	StatusRejected = "rejected"
 	StatusExpired  = "expired"
+	StatusEnded    = "ended" // Approved access is over (exit or access timeout)
+)
+
+// DefaultAccessDuration is how long an approved guest keeps access
+const DefaultAccessDuration = 30 * time.Minute
+
+const (
+	rateWindow   = time.Hour
+	historyLimit = 20 // Finished requests kept for status lookups
+)
+
+var (
+	ErrRequestNotFound = errors.New("request not found")
+	ErrNotPending      = errors.New("request is not pending")
+	ErrNotActive       = errors.New("guest is not active")
+	ErrGuestLimit      = errors.New("too many active guests")
+	ErrRateLimited     = errors.New("too many guest requests, try again later")
 )
 
 // GuestRequest represents a single guest access request
 type GuestRequest struct {
 	ID          string    `json:"id"`
 	TargetUser  string    `json:"target_user"`
+	Source      string    `json:"source,omitempty"`     // Requesting client; rate limited per source
+	PassID      string    `json:"pass_id,omitempty"`    // Set when admitted with a guest pass
+	GuestName   string    `json:"guest_name,omitempty"` // The pass's guest
 	Status      string    `json:"status"`
+	State       string    `json:"state"` // The guest's state machine (REQUESTED, APPROVED, ...)
 	RequestedAt time.Time `json:"requested_at"`
-	ExpiresAt   time.Time `json:"expires_at"`
+	ApprovedAt  time.Time `json:"approved_at,omitzero"`
+	ExpiresAt   time.Time `json:"expires_at"` // Pending: approval deadline; approved: end of access
+}
+
+// Limits bounds concurrent guests and request volume (0 = unlimited)
+type Limits struct {
+	MaxActive      int           // Pending + approved guests at the same time
+	MaxPerHour     int           // Requests per source per hour
+	RequestTimeout time.Duration // Approval window; 0 = the manager's default
+}
+
+// tracked is a live request with its own state machine and timer
+type tracked struct {
+	req     GuestRequest
+	machine *StateMachine
+	timer   *time.Timer // Approval deadline while pending, end of access once approved
 }
 
-// Manager handles guest access requests with in-memory storage
+// Manager handles concurrent guest access requests, optionally stored in a JSON file
 type Manager struct {
-	mu            sync.RWMutex
-	activeRequest *GuestRequest
-	timeout       time.Duration
-	onApproved    func(*GuestRequest) error
-	onRejected    func(*GuestRequest) error
-	expireTimer   *time.Timer
+	mu         sync.RWMutex
+	requests   map[string]*tracked    // Pending and approved
+	history    []GuestRequest         // Finished, oldest first, capped at historyLimit
+	recent     map[string][]time.Time // Request times per source within rateWindow
+	timeout    time.Duration          // Approval window unless Limits sets one
+	access     time.Duration          // Access granted on approval
+	limits     func() Limits          // Read on every request so settings apply immediately
+	now        func() time.Time
+	counter    int64
+	path       string      // Store file; empty = memory only
+	closed     bool        // Set by Close: timers no longer expire requests
+	rearm      *RearmState // Saved with the requests for the coordinator
+	onApproved func(*GuestRequest) error
+	onRejected func(*GuestRequest) error
+	onChange   func(GuestRequest)
+	onAction   func(req GuestRequest, action, from string)
 }
 
 // NewManager creates a new guest request manager with specified timeout
@@ -43,7 +90,27 @@ func NewManager(timeout time.Duration) *Manager {
 		timeout = 60 * time.Second // Default 60 seconds
 	}
 	return &Manager{
-		timeout: timeout,
+		requests: make(map[string]*tracked),
+		recent:   make(map[string][]time.Time),
+		timeout:  timeout,
+		access:   DefaultAccessDuration,
+		now:      time.Now,
+	}
+}
+
+// SetLimits sets the source of concurrency and rate limits (nil = unlimited)
+func (m *Manager) SetLimits(fn func() Limits) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.limits = fn
+}
+
+// SetAccessDuration sets how long approved guests keep access
+func (m *Manager) SetAccessDuration(d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if d > 0 {
+		m.access = d
 	}
 }
 
@@ -61,81 +128,277 @@ func (m *Manager) SetRejectedCallback(fn func(*GuestRequest) error) {
 	m.onRejected = fn
 }
 
-// CreateRequest creates a new guest access request
-// Returns error if another request is already active
-func (m *Manager) CreateRequest(targetUser string) (*GuestRequest, error) {
+// SetChangeCallback sets a handler for every status change (created, approved,
+// rejected, expired, ended). It runs with the manager locked and must not call back into it.
+func (m *Manager) SetChangeCallback(fn func(GuestRequest)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.onChange = fn
+}
+
+// SetActionCallback sets a handler for every state machine transition of a guest
+// (action is REQUEST, APPROVE, DENY, TIMEOUT or EXIT; from is the previous state).
+// It runs with the manager locked and must not call back into it.
+func (m *Manager) SetActionCallback(fn func(req GuestRequest, action, from string)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.onAction = fn
+}
+
+// applyLocked moves a guest's state machine and reports the new status
+func (m *Manager) applyLocked(t *tracked, action, status string) {
+	from := t.machine.CurrentState()
+	if err := t.machine.Handle(action); err != nil {
+		logger.Error("guest request " + t.req.ID + ": " + action + " from " + from + ": " + err.Error())
+	}
+	t.req.State = t.machine.CurrentState()
+	t.req.Status = status
+	if m.onAction != nil {
+		m.onAction(t.req, action, from)
+	}
+	if m.onChange != nil {
+		m.onChange(t.req)
+	}
+	// Finished requests are saved by finishLocked
+	if status == StatusPending || status == StatusApproved {
+		m.persistLocked()
+	}
+}
+
+// finishLocked moves a request from the live set into the history
+func (m *Manager) finishLocked(t *tracked) {
+	if t.timer != nil {
+		t.timer.Stop()
+		t.timer = nil
+	}
+	delete(m.requests, t.req.ID)
+	m.history = append(m.history, t.req)
+	if len(m.history) > historyLimit {
+		m.history = m.history[len(m.history)-historyLimit:]
+	}
+	m.persistLocked()
+}
+
+// pruneRecentLocked drops request times older than the rate window
+func (m *Manager) pruneRecentLocked(now time.Time) {
+	for source, times := range m.recent {
+		var kept []time.Time
+		for _, at := range times {
+			if now.Sub(at) < rateWindow {
+				kept = append(kept, at)
+			}
+		}
+		if len(kept) == 0 {
+			delete(m.recent, source)
+			continue
+		}
+		m.recent[source] = kept
+	}
+}
+
+// CreateRequest creates a new guest access request from source (e.g. the client address).
+// Fails with ErrGuestLimit or ErrRateLimited when the configured limits are reached.
+func (m *Manager) CreateRequest(targetUser, source string) (*GuestRequest, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	limits := Limits{}
+	if m.limits != nil {
+		limits = m.limits()
+	}
+	if limits.MaxActive > 0 && len(m.requests) >= limits.MaxActive {
+		return nil, ErrGuestLimit
+	}
+
+	// Per-source hourly budget; sources without recent requests are forgotten
+	m.pruneRecentLocked(now)
+	kept := m.recent[source]
+	if limits.MaxPerHour > 0 && len(kept) >= limits.MaxPerHour {
+		logger.Info("guest request rate limited: source=" + source)
+		return nil, ErrRateLimited
+	}
+	m.recent[source] = append(kept, now)
+
+	timeout := m.timeout
+	if limits.RequestTimeout > 0 {
+		timeout = limits.RequestTimeout
+	}
+
+	m.counter++
+	t := &tracked{
+		req: GuestRequest{
+			ID:          fmt.Sprintf("greq-%d-%d", now.UnixNano(), m.counter),
+			TargetUser:  targetUser,
+			Source:      source,
+			RequestedAt: now,
+			ExpiresAt:   now.Add(timeout),
+		},
+		machine: NewStateMachine(),
+	}
+	m.requests[t.req.ID] = t
+
+	logger.Info("guest request created: id=" + t.req.ID + " target=" + targetUser)
+	m.applyLocked(t, REQUEST, StatusPending)
+	m.startTimerLocked(t, timeout)
+
+	req := t.req
+	return &req, nil
+}
+
+// Admit lets a guest in with a pass: the request is created already approved and
+// the approved callback runs as for an answered request. Access ends with the pass's
+// access time, or with the pass itself.
+func (m *Manager) Admit(p Pass, source string) (*GuestRequest, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	// Only one active request at a time
-	if m.activeRequest != nil && m.activeRequest.Status == StatusPending {
-		return nil, errors.New("guest request already pending")
+	if m.limits != nil {
+		if max := m.limits().MaxActive; max > 0 && len(m.requests) >= max {
+			return nil, ErrGuestLimit
+		}
 	}
 
-	now := time.Now()
-	req := &GuestRequest{
-		ID:          fmt.Sprintf("greq-%d", now.UnixNano()),
-		TargetUser:  targetUser,
-		Status:      StatusPending,
-		RequestedAt: now,
-		ExpiresAt:   now.Add(m.timeout),
+	now := m.now()
+	m.counter++
+	t := &tracked{
+		req: GuestRequest{
+			ID:          fmt.Sprintf("greq-%d-%d", now.UnixNano(), m.counter),
+			TargetUser:  p.TargetUser,
+			Source:      source,
+			PassID:      p.ID,
+			GuestName:   p.Name,
+			RequestedAt: now,
+			ApprovedAt:  now,
+			ExpiresAt:   p.accessEnd(now, m.access),
+		},
+		machine: NewStateMachine(),
 	}
+	m.requests[t.req.ID] = t
 
-	m.activeRequest = req
+	logger.Info("guest admitted with pass: id=" + t.req.ID + " pass=" + p.ID)
+	t.machine.Handle(REQUEST) // The pass stands in for the request; only the approval is reported
+	m.applyLocked(t, APPROVE, StatusApproved)
+	m.startTimerLocked(t, t.req.ExpiresAt.Sub(now))
 
-	// Start expiration timer
-	m.startExpirationTimer(req.ID)
+	req := t.req
+	if m.onApproved != nil {
+		go func() {
+			if err := m.onApproved(&req); err != nil {
+				logger.Error("approval callback failed: " + err.Error())
+			}
+		}()
+	}
+	return &req, nil
+}
 
-	logger.Info("guest request created: id=" + req.ID + " target=" + targetUser)
+// Get returns a request by ID, including recently finished ones
+func (m *Manager) Get(requestID string) (GuestRequest, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
 
-	return req, nil
+	if t, ok := m.requests[requestID]; ok {
+		return t.req, true
+	}
+	for i := len(m.history) - 1; i >= 0; i-- {
+		if m.history[i].ID == requestID {
+			return m.history[i], true
+		}
+	}
+	return GuestRequest{}, false
 }
 
-// GetActiveRequest returns the currently active request (if any)
-func (m *Manager) GetActiveRequest() *GuestRequest {
+// Requests returns pending requests and active guests, oldest first
+func (m *Manager) Requests() []GuestRequest {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
-	if m.activeRequest == nil {
-		return nil
+	list := make([]GuestRequest, 0, len(m.requests))
+	for _, t := range m.requests {
+		list = append(list, t.req)
 	}
+	sort.Slice(list, func(i, j int) bool { return list[i].RequestedAt.Before(list[j].RequestedAt) })
+	return list
+}
 
-	// Return nil if request has expired
-	if m.activeRequest.Status == StatusPending && time.Now().After(m.activeRequest.ExpiresAt) {
-		return nil
+// Latest returns the most recent request, live or finished
+func (m *Manager) Latest() (GuestRequest, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var latest GuestRequest
+	found := false
+	for _, t := range m.requests {
+		if !found || t.req.RequestedAt.After(latest.RequestedAt) {
+			latest, found = t.req, true
+		}
+	}
+	if !found && len(m.history) > 0 {
+		return m.history[len(m.history)-1], true
+	}
+	return latest, found
+}
+
+// Counts returns the number of pending requests and active guests
+func (m *Manager) Counts() (pending, active int) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, t := range m.requests {
+		if t.req.Status == StatusApproved {
+			active++
+		} else {
+			pending++
+		}
+	}
+	return pending, active
+}
+
+// CurrentState summarises all guests: APPROVED while any guest has access,
+// REQUESTED while any request waits for an answer, IDLE otherwise
+func (m *Manager) CurrentState() string {
+	pending, active := m.Counts()
+	switch {
+	case active > 0:
+		return APPROVED
+	case pending > 0:
+		return REQUESTED
 	}
+	return IDLE
+}
 
-	return m.activeRequest
+// HasPendingRequest returns true if any request waits for an answer
+func (m *Manager) HasPendingRequest() bool {
+	pending, _ := m.Counts()
+	return pending > 0
 }
 
-// ApproveRequest marks a request as approved
+// ApproveRequest marks a request as approved and starts the guest's access
 func (m *Manager) ApproveRequest(requestID string) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	if m.activeRequest == nil || m.activeRequest.ID != requestID {
-		return errors.New("request not found")
+	t, ok := m.requests[requestID]
+	if !ok {
+		return ErrRequestNotFound
 	}
-
-	if m.activeRequest.Status != StatusPending {
-		return errors.New("request is not pending")
+	if t.req.Status != StatusPending {
+		return ErrNotPending
 	}
 
-	m.activeRequest.Status = StatusApproved
-	req := m.activeRequest
+	now := m.now()
+	t.req.ApprovedAt = now
+	t.req.ExpiresAt = now.Add(m.access)
 
-	// Stop expiration timer
-	if m.expireTimer != nil {
-		m.expireTimer.Stop()
-		m.expireTimer = nil
-	}
-
-	logger.Info("guest request approved: id=" + req.ID)
+	logger.Info("guest request approved: id=" + t.req.ID)
+	m.applyLocked(t, APPROVE, StatusApproved)
+	m.startTimerLocked(t, m.access)
 
 	// Call approved callback if set
 	if m.onApproved != nil {
+		req := t.req
 		go func() {
-			if err := m.onApproved(req); err != nil {
+			if err := m.onApproved(&req); err != nil {
 				logger.Error("approval callback failed: " + err.Error())
 			}
 		}()
@@ -149,29 +412,23 @@ func (m *Manager) RejectRequest(requestID string) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	if m.activeRequest == nil || m.activeRequest.ID != requestID {
-		return errors.New("request not found")
+	t, ok := m.requests[requestID]
+	if !ok {
+		return ErrRequestNotFound
 	}
-
-	if m.activeRequest.Status != StatusPending {
-		return errors.New("request is not pending")
+	if t.req.Status != StatusPending {
+		return ErrNotPending
 	}
 
-	m.activeRequest.Status = StatusRejected
-	req := m.activeRequest
-
-	// Stop expiration timer
-	if m.expireTimer != nil {
-		m.expireTimer.Stop()
-		m.expireTimer = nil
-	}
-
-	logger.Info("guest request rejected: id=" + req.ID)
+	logger.Info("guest request rejected: id=" + t.req.ID)
+	m.applyLocked(t, DENY, StatusRejected)
+	m.finishLocked(t)
 
 	// Call rejected callback if set
 	if m.onRejected != nil {
+		req := t.req
 		go func() {
-			if err := m.onRejected(req); err != nil {
+			if err := m.onRejected(&req); err != nil {
 				logger.Error("rejection callback failed: " + err.Error())
 			}
 		}()
@@ -180,33 +437,64 @@ func (m *Manager) RejectRequest(requestID string) error {
 	return nil
 }
 
-// ClearRequest clears the active request
-func (m *Manager) ClearRequest() {
+// ExitGuest ends an approved guest's access; an empty ID ends every active guest
+func (m *Manager) ExitGuest(requestID string) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	if m.expireTimer != nil {
-		m.expireTimer.Stop()
-		m.expireTimer = nil
+	if requestID == "" {
+		for _, t := range m.requests {
+			if t.req.Status == StatusApproved {
+				m.exitLocked(t)
+			}
+		}
+		return nil
+	}
+	t, ok := m.requests[requestID]
+	if !ok {
+		return ErrRequestNotFound
+	}
+	if t.req.Status != StatusApproved {
+		return ErrNotActive
 	}
+	m.exitLocked(t)
+	return nil
+}
 
-	m.activeRequest = nil
+func (m *Manager) exitLocked(t *tracked) {
+	logger.Info("guest exited: id=" + t.req.ID)
+	m.applyLocked(t, EXIT, StatusEnded)
+	m.finishLocked(t)
 }
 
-// startExpirationTimer starts the expiration timer for a request
-func (m *Manager) startExpirationTimer(requestID string) {
-	if m.expireTimer != nil {
-		m.expireTimer.Stop()
+// startTimerLocked (re)arms a request's timer: the approval deadline while
+// pending, the end of access once approved
+func (m *Manager) startTimerLocked(t *tracked, after time.Duration) {
+	if t.timer != nil {
+		t.timer.Stop()
 	}
-
-	m.expireTimer = time.AfterFunc(m.timeout, func() {
+	requestID, status := t.req.ID, t.req.Status
+	t.timer = time.AfterFunc(after, func() {
 		m.mu.Lock()
 		defer m.mu.Unlock()
 
-		// Only expire if the request is still pending
-		if m.activeRequest != nil && m.activeRequest.ID == requestID && m.activeRequest.Status == StatusPending {
-			m.activeRequest.Status = StatusExpired
-			logger.Info("guest request expired: id=" + requestID)
+		// Only if the request is still in the state the timer was set for
+		if current, ok := m.requests[requestID]; m.closed || !ok || current.req.Status != status {
+			return
 		}
+		t.timer = nil
+		m.expireLocked(t)
 	})
 }
+
+// expireLocked ends a request whose time ran out: no answer in time, or end of access
+func (m *Manager) expireLocked(t *tracked) {
+	if t.req.Status == StatusPending {
+		logger.Info("guest request expired: id=" + t.req.ID)
+		m.applyLocked(t, TIMEOUT, StatusExpired)
+	} else {
+		logger.Info("guest access ended: id=" + t.req.ID)
+		m.applyLocked(t, TIMEOUT, StatusEnded)
+	}
+	m.finishLocked(t)
+}
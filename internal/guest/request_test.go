@@ -0,0 +1,210 @@
+package guest
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"smartdisplay-core/internal/logger"
+	"testing"
+	"time"
+)
+
+func TestMain(m *testing.M) {
+	os.MkdirAll("logs", 0755)
+	logger.Init()
+	os.Exit(m.Run())
+}
+
+func TestManagerConcurrentRequests(t *testing.T) {
+	m := NewManager(time.Minute)
+	m.SetLimits(func() Limits { return Limits{MaxActive: 2, MaxPerHour: 3} })
+
+	a, err := m.CreateRequest("ayse", "10.0.0.5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := m.CreateRequest("mehmet", "10.0.0.6")
+	if err != nil {
+		t.Fatalf("second concurrent request refused: %v", err)
+	}
+	if _, err := m.CreateRequest("ayse", "10.0.0.7"); !errors.Is(err, ErrGuestLimit) {
+		t.Fatalf("third request = %v, want ErrGuestLimit", err)
+	}
+
+	// Each guest has its own state machine
+	if err := m.ApproveRequest(a.ID); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := m.Get(a.ID); got.State != APPROVED || got.Status != StatusApproved {
+		t.Errorf("approved guest = %+v", got)
+	}
+	if got, _ := m.Get(b.ID); got.State != REQUESTED {
+		t.Errorf("other guest state = %s, want REQUESTED", got.State)
+	}
+	if pending, active := m.Counts(); pending != 1 || active != 1 || m.CurrentState() != APPROVED {
+		t.Errorf("counts = %d/%d state = %s", pending, active, m.CurrentState())
+	}
+
+	if err := m.RejectRequest(b.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.ApproveRequest(b.ID); !errors.Is(err, ErrRequestNotFound) {
+		t.Errorf("approve after reject = %v", err)
+	}
+	if got, ok := m.Get(b.ID); !ok || got.Status != StatusRejected || got.State != DENIED {
+		t.Errorf("rejected request not kept for lookups: %+v", got)
+	}
+
+	if err := m.ExitGuest(""); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := m.Get(a.ID); got.Status != StatusEnded || m.CurrentState() != IDLE {
+		t.Errorf("after exit: %+v state=%s", got, m.CurrentState())
+	}
+}
+
+func TestManagerRateLimitPerSource(t *testing.T) {
+	now := time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC)
+	m := NewManager(time.Minute)
+	m.now = func() time.Time { return now }
+	m.SetLimits(func() Limits { return Limits{MaxPerHour: 2, RequestTimeout: 5 * time.Minute} })
+
+	for i := 0; i < 2; i++ {
+		req, err := m.CreateRequest("ayse", "10.0.0.5")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !req.ExpiresAt.Equal(now.Add(5 * time.Minute)) {
+			t.Errorf("approval deadline = %v, want the configured timeout", req.ExpiresAt)
+		}
+		m.RejectRequest(req.ID)
+	}
+	if _, err := m.CreateRequest("ayse", "10.0.0.5"); !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("third request in the hour = %v, want ErrRateLimited", err)
+	}
+	if _, err := m.CreateRequest("ayse", "10.0.0.6"); err != nil {
+		t.Errorf("other source limited: %v", err)
+	}
+	now = now.Add(time.Hour)
+	if _, err := m.CreateRequest("ayse", "10.0.0.5"); err != nil {
+		t.Errorf("limit not reset after an hour: %v", err)
+	}
+	if _, ok := m.recent["10.0.0.6"]; ok || len(m.recent) != 1 {
+		t.Errorf("idle sources kept: %v", m.recent)
+	}
+}
+
+func TestManagerTimersPerRequest(t *testing.T) {
+	m := NewManager(20 * time.Millisecond)
+	m.SetAccessDuration(40 * time.Millisecond)
+	actions := make(chan string, 10)
+	m.SetActionCallback(func(req GuestRequest, action, from string) { actions <- req.ID + " " + action })
+
+	expiring, _ := m.CreateRequest("ayse", "a")
+	approved, _ := m.CreateRequest("mehmet", "b")
+	if err := m.ApproveRequest(approved.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	if got, _ := m.Get(expiring.ID); got.Status != StatusExpired || got.State != EXPIRED {
+		t.Errorf("unanswered request = %+v", got)
+	}
+	if got, _ := m.Get(approved.ID); got.Status != StatusEnded {
+		t.Errorf("approved guest after access window = %+v", got)
+	}
+	if len(m.Requests()) != 0 {
+		t.Errorf("finished requests still live: %+v", m.Requests())
+	}
+	close(actions)
+	var seen []string
+	for a := range actions {
+		seen = append(seen, a)
+	}
+	if len(seen) != 5 { // 2x REQUEST, APPROVE, 2x TIMEOUT
+		t.Errorf("actions = %v", seen)
+	}
+}
+
+func TestManagerRestoresRequestsAfterRestart(t *testing.T) {
+	now := time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC)
+	path := filepath.Join(t.TempDir(), "guest_requests.json")
+	clock := func() time.Time { return now }
+
+	m := NewManager(time.Minute)
+	m.now = clock
+	if err := m.Open(path); err != nil {
+		t.Fatal(err)
+	}
+	pending, _ := m.CreateRequest("ayse", "a")
+	approved, _ := m.CreateRequest("mehmet", "b")
+	rejected, _ := m.CreateRequest("ayse", "c")
+	m.ApproveRequest(approved.ID)
+	m.RejectRequest(rejected.ID)
+
+	// Restart within the deadlines: both live requests come back with their state
+	now = now.Add(30 * time.Second)
+	restarted := NewManager(time.Minute)
+	restarted.now = clock
+	if err := restarted.Open(path); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := restarted.Get(pending.ID); got.Status != StatusPending || got.State != REQUESTED {
+		t.Errorf("pending after restart = %+v", got)
+	}
+	if got, _ := restarted.Get(approved.ID); got.Status != StatusApproved || got.State != APPROVED {
+		t.Errorf("approved after restart = %+v", got)
+	}
+	if got, ok := restarted.Get(rejected.ID); !ok || got.Status != StatusRejected {
+		t.Errorf("history lost: %+v", got)
+	}
+	if err := restarted.ApproveRequest(pending.ID); err != nil {
+		t.Errorf("late approval after restart: %v", err)
+	}
+
+	// Restart after the deadlines: requests end at once, through the callbacks
+	now = now.Add(time.Hour)
+	late := NewManager(time.Minute)
+	late.now = clock
+	var actions []string
+	late.SetActionCallback(func(req GuestRequest, action, from string) { actions = append(actions, action) })
+	if err := late.Open(path); err != nil {
+		t.Fatal(err)
+	}
+	if len(late.Requests()) != 0 || len(actions) != 2 {
+		t.Errorf("live = %+v actions = %v", late.Requests(), actions)
+	}
+	if got, _ := late.Get(approved.ID); got.Status != StatusEnded {
+		t.Errorf("approved guest after deadline = %+v", got)
+	}
+}
+
+func TestManagerCloseLeavesDeadlinesToNextStart(t *testing.T) {
+	now := time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC)
+	path := filepath.Join(t.TempDir(), "guest_requests.json")
+	clock := func() time.Time { return now }
+
+	m := NewManager(20 * time.Millisecond)
+	m.now = clock
+	if err := m.Open(path); err != nil {
+		t.Fatal(err)
+	}
+	pending, _ := m.CreateRequest("ayse", "a")
+	m.Close()
+	m.CreateRequest("mehmet", "b") // After Close: memory only
+
+	// The stopped timer does not expire the request during the shutdown
+	time.Sleep(60 * time.Millisecond)
+	if got, _ := m.Get(pending.ID); got.Status != StatusPending {
+		t.Errorf("request after close = %+v", got)
+	}
+
+	restarted := NewManager(time.Minute)
+	restarted.now = clock
+	if err := restarted.Open(path); err != nil {
+		t.Fatal(err)
+	}
+	if live := restarted.Requests(); len(live) != 1 || live[0].ID != pending.ID || live[0].Status != StatusPending {
+		t.Errorf("stored requests = %+v", live)
+	}
+}
@@ -61,6 +61,13 @@ type ExitInfo struct {
 	AlarmStatusNow    string `json:"alarm_status_now"`
 }
 
+// RearmInfo describes the re-arm pending after the last guest left
+type RearmInfo struct {
+	Action           string `json:"action"` // arm_<mode>, e.g. arm_night
+	ArmAt            string `json:"arm_at"` // RFC 3339
+	RemainingSeconds int    `json:"remaining_seconds"`
+}
+
 // GuestActionInfo describes an action available to guest
 type GuestActionInfo struct {
 	ID      string `json:"id"`
@@ -110,6 +117,7 @@ type ScreenStateResponse struct {
 	Denial            *DenialInfo             `json:"denial,omitempty"`
 	Expiration        *ExpirationInfo         `json:"expiration,omitempty"`
 	Exit              *ExitInfo               `json:"exit,omitempty"`
+	Rearm             *RearmInfo              `json:"rearm,omitempty"`
 	Actions           []GuestActionInfo       `json:"actions"`
 	OwnerNotification *GuestOwnerNotification `json:"owner_notification,omitempty"`
 	Info              GuestInfoContext        `json:"info"`
@@ -133,6 +141,7 @@ type ScreenStateManager struct {
 	alarmStateFn        func() string         // Get alarm state
 	systemTimeFn        func() time.Time      // Get current time
 	firstBootBlockingFn func() (bool, string) // Check first-boot blocking
+	rearmFn             func() *RearmInfo     // Pending re-arm (nil = none)
 
 	// State tracking
 	currentState             GuestScreenState
@@ -171,6 +180,11 @@ func NewScreenStateManager(
 	}
 }
 
+// SetRearmSource sets where the pending re-arm is read from
+func (s *ScreenStateManager) SetRearmSource(fn func() *RearmInfo) {
+	s.rearmFn = fn
+}
+
 // EvaluateState evaluates and returns the current guest screen state
 func (s *ScreenStateManager) EvaluateState() GuestScreenState {
 	// Check blocking conditions
@@ -268,6 +282,9 @@ func (s *ScreenStateManager) GetScreenState() *ScreenStateResponse {
 		Actions:   []GuestActionInfo{},
 		Info:      GuestInfoContext{},
 	}
+	if s.rearmFn != nil {
+		resp.Rearm = s.rearmFn()
+	}
 
 	// Build response based on state
 	switch state {
@@ -498,6 +515,11 @@ func (s *ScreenStateManager) buildExitResponse(resp *ScreenStateResponse) {
 
 	resp.Message = "You have exited the property"
 	resp.Context = "Thank you for visiting. The alarm has been re-armed."
+	alarmStatus := "re-armed"
+	if resp.Rearm != nil {
+		resp.Context = fmt.Sprintf("Thank you for visiting. The alarm will be armed in %d seconds.", resp.Rearm.RemainingSeconds)
+		alarmStatus = "re-arming"
+	}
 
 	resp.Exit = &ExitInfo{
 		ExitedAt:          s.exitedAt.UTC().Format(time.RFC3339),
@@ -516,11 +538,11 @@ func (s *ScreenStateManager) buildExitResponse(resp *ScreenStateResponse) {
 		Title:                "Guest has exited",
 		Status:               "exited",
 		VisitDurationMinutes: visitDuration / 60,
-		AlarmStatus:          "re-armed",
+		AlarmStatus:          alarmStatus,
 	}
 
 	resp.Info.VisitDurationSeconds = visitDuration
-	resp.Info.AlarmRestored = true
+	resp.Info.AlarmRestored = resp.Rearm == nil
 }
 
 // SetRequestTimeoutSeconds sets the request timeout duration
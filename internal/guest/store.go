@@ -0,0 +1,219 @@
+package guest
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"smartdisplay-core/internal/logger"
+	"sort"
+	"time"
+)
+
+// Live requests and the recent history are kept in a JSON file, so a restart does
+// not lose a guest waiting for an answer or one already inside, and late answers
+// from HA notifications still find the request. The secrets used for decision
+// tokens and pass codes are kept next to it, readable by the owner only.
+
+const keyBytes = 32
+
+// newKey returns a random secret for signing tokens and hashing pass codes
+func newKey() []byte {
+	key := make([]byte, keyBytes)
+	if _, err := rand.Read(key); err != nil {
+		panic("guest: key: " + err.Error())
+	}
+	return key
+}
+
+// loadOrCreateKey reads the secret in path; on first start (or if the file is
+// damaged) it stores fresh instead
+func loadOrCreateKey(path string, fresh []byte) ([]byte, error) {
+	key, err := os.ReadFile(path)
+	if err == nil && len(key) == keyBytes {
+		return key, nil
+	}
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if err == nil {
+		logger.Error("guest key " + path + ": bad length, replacing it")
+	}
+	if err := writeKeyFile(path, fresh); err != nil {
+		return nil, err
+	}
+	return fresh, nil
+}
+
+// writeKeyFile stores a key readable by the owner only
+func writeKeyFile(path string, key []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, key, 0600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// requestFile is the stored form of the manager
+type requestFile struct {
+	Requests []GuestRequest `json:"requests"`        // Pending and approved
+	History  []GuestRequest `json:"history"`         // Finished, oldest first
+	Rearm    *RearmState    `json:"rearm,omitempty"` // Alarm mode to restore after the guests
+}
+
+// RearmState is the alarm mode to restore once the guests have left, saved with
+// the requests so a restart does not leave the house disarmed
+type RearmState struct {
+	Action string    `json:"action"`           // Alarmo action, e.g. arm_night
+	Before string    `json:"before,omitempty"` // Alarmo state before the first guest
+	ArmAt  time.Time `json:"arm_at"`           // Pending re-arm; zero = guests still inside
+}
+
+// writeJSONFile atomically replaces path with v as indented JSON
+func writeJSONFile(path string, v interface{}) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// Open restores the requests saved in path and saves every later change there.
+// Timers resume with their remaining time; requests whose deadline passed while
+// the system was down expire now, through the usual callbacks.
+func (m *Manager) Open(path string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	var saved requestFile
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &saved); err != nil {
+			return err
+		}
+	}
+	m.history = append(saved.History, m.history...)
+	if m.rearm == nil {
+		m.rearm = saved.Rearm
+	}
+	m.path = path
+
+	now := m.now()
+	restored, expired := 0, 0
+	for _, req := range saved.Requests {
+		if _, ok := m.requests[req.ID]; ok {
+			continue
+		}
+		// Replay the guest's state machine up to the saved status
+		t := &tracked{req: req, machine: NewStateMachine()}
+		t.machine.Handle(REQUEST)
+		switch req.Status {
+		case StatusPending:
+		case StatusApproved:
+			t.machine.Handle(APPROVE)
+		default:
+			m.history = append(m.history, req) // Finished just before the stop
+			continue
+		}
+		t.req.State = t.machine.CurrentState()
+		m.requests[req.ID] = t
+
+		if remaining := req.ExpiresAt.Sub(now); remaining > 0 {
+			m.startTimerLocked(t, remaining)
+			restored++
+			continue
+		}
+		m.expireLocked(t)
+		expired++
+	}
+	if len(m.history) > historyLimit {
+		m.history = m.history[len(m.history)-historyLimit:]
+	}
+
+	logger.Info(fmt.Sprintf("guest requests restored: %d live, %d expired while down, %d in history", restored, expired, len(m.history)))
+	return m.saveLocked()
+}
+
+// Close stops the request timers and the store once a save in progress is
+// done; later changes stay in memory. Deadlines passing from now on are handled
+// by the next Open.
+func (m *Manager) Close() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, t := range m.requests {
+		if t.timer != nil {
+			t.timer.Stop()
+			t.timer = nil
+		}
+	}
+	m.closed = true
+	m.path = ""
+}
+
+// saveLocked writes live requests and the history to the store (memory only without Open)
+func (m *Manager) saveLocked() error {
+	if m.path == "" {
+		return nil
+	}
+	file := requestFile{
+		Requests: make([]GuestRequest, 0, len(m.requests)),
+		History:  m.history,
+		Rearm:    m.rearm,
+	}
+	for _, t := range m.requests {
+		file.Requests = append(file.Requests, t.req)
+	}
+	sort.Slice(file.Requests, func(i, j int) bool { return file.Requests[i].RequestedAt.Before(file.Requests[j].RequestedAt) })
+	return writeJSONFile(m.path, file)
+}
+
+// persistLocked saves after a change; a failed write is logged and the guest flow goes on
+func (m *Manager) persistLocked() {
+	if err := m.saveLocked(); err != nil {
+		logger.Error("guest request save failed: " + err.Error())
+	}
+}
+
+// SaveRearm stores the alarm mode to restore (nil = nothing to restore)
+func (m *Manager) SaveRearm(st *RearmState) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if st != nil {
+		st = &RearmState{Action: st.Action, Before: st.Before, ArmAt: st.ArmAt}
+	}
+	m.rearm = st
+	m.persistLocked()
+}
+
+// SavedRearm returns the alarm mode restored by Open (nil = none)
+func (m *Manager) SavedRearm() *RearmState {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.rearm == nil {
+		return nil
+	}
+	st := *m.rearm
+	return &st
+}
@@ -8,9 +8,36 @@ import (
 	"io"
 	"net/http"
 	"strings"
+	"sync"
 	"time"
 )
 
+// DefaultEntityID is the HA entity created by the Alarmo integration
+const DefaultEntityID = "alarm_control_panel.alarmo"
+
+// DefaultArea is the area key used when no panels are configured
+const DefaultArea = "main"
+
+// ErrUnknownArea is returned when an action targets an area that is not configured
+var ErrUnknownArea = errors.New("alarmo: unknown area")
+
+// ErrCodeRejected is returned when HA refuses an action because the panel
+// requires a user code (code_arm_required / code_disarm_required) or the code is wrong
+var ErrCodeRejected = errors.New("alarmo: code missing or rejected by panel")
+
+// Panel is one Alarmo area exposed by HA as an alarm_control_panel entity.
+// Alarmo creates one entity per area (house, garage, shed...) plus an optional master.
+type Panel struct {
+	Area     string `json:"area"`      // Short key used by the API (e.g. "garage")
+	EntityID string `json:"entity_id"` // HA entity (e.g. "alarm_control_panel.garage")
+	Name     string `json:"name"`      // Display name
+}
+
+// DefaultPanels returns the single-panel setup (Alarmo master entity)
+func DefaultPanels() []Panel {
+	return []Panel{{Area: DefaultArea, EntityID: DefaultEntityID, Name: "Alarmo"}}
+}
+
 // AlarmoState represents normalized alarm state from Home Assistant
 // This is the single source of truth for alarm state within SmartDisplay
 type AlarmoState struct {
@@ -27,10 +54,14 @@ type AlarmoState struct {
 
 // Adapter reads alarm state from Home Assistant Alarmo integration
 // A4: Now supports controlled write operations via RequestAction
+// A5: Follows a set of named panels; the first panel is the primary one
 type Adapter struct {
 	baseURL string
 	token   string
 	client  *http.Client
+
+	mu     sync.RWMutex
+	panels []Panel
 }
 
 // haStateResponse represents the JSON response from HA /api/states endpoint
@@ -51,14 +82,102 @@ func New(baseURL string, decryptedToken string) *Adapter {
 		client: &http.Client{
 			Timeout: 5 * time.Second,
 		},
+		panels: DefaultPanels(),
+	}
+}
+
+// SetPanels replaces the followed panels. An empty list restores the default single panel.
+// Areas must be unique and entities must be alarm_control_panel entities.
+func (a *Adapter) SetPanels(panels []Panel) error {
+	if len(panels) == 0 {
+		panels = DefaultPanels()
+	}
+
+	clean := make([]Panel, 0, len(panels))
+	seenArea := map[string]bool{}
+	seenEntity := map[string]bool{}
+	for _, p := range panels {
+		p.Area = strings.TrimSpace(p.Area)
+		p.EntityID = strings.TrimSpace(p.EntityID)
+		if p.Area == "" {
+			return errors.New("alarmo: panel area required")
+		}
+		if !strings.HasPrefix(p.EntityID, "alarm_control_panel.") {
+			return fmt.Errorf("alarmo: panel %s: entity must be an alarm_control_panel", p.Area)
+		}
+		if seenArea[p.Area] {
+			return fmt.Errorf("alarmo: duplicate area %s", p.Area)
+		}
+		if seenEntity[p.EntityID] {
+			return fmt.Errorf("alarmo: duplicate entity %s", p.EntityID)
+		}
+		seenArea[p.Area] = true
+		seenEntity[p.EntityID] = true
+		if p.Name == "" {
+			p.Name = p.Area
+		}
+		clean = append(clean, p)
+	}
+
+	a.mu.Lock()
+	a.panels = clean
+	a.mu.Unlock()
+	return nil
+}
+
+// Panels returns a copy of the followed panels (primary first)
+func (a *Adapter) Panels() []Panel {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	out := make([]Panel, len(a.panels))
+	copy(out, a.panels)
+	return out
+}
+
+// Primary returns the primary panel (drives the legacy single-state API)
+func (a *Adapter) Primary() Panel {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.panels[0]
+}
+
+// Panel looks up a panel by area; empty area selects the primary panel
+func (a *Adapter) Panel(area string) (Panel, bool) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	if area == "" {
+		return a.panels[0], true
+	}
+	for _, p := range a.panels {
+		if p.Area == area {
+			return p, true
+		}
 	}
+	return Panel{}, false
 }
 
-// FetchState retrieves the current alarm state from Home Assistant
+// PanelByEntity looks up a panel by its HA entity id
+func (a *Adapter) PanelByEntity(entityID string) (Panel, bool) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	for _, p := range a.panels {
+		if p.EntityID == entityID {
+			return p, true
+		}
+	}
+	return Panel{}, false
+}
+
+// FetchState retrieves the current state of the primary panel from Home Assistant
 // Returns normalized AlarmoState and error if fetch fails
 func (a *Adapter) FetchState(ctx context.Context, prev AlarmoState) (AlarmoState, error) {
+	return a.FetchPanelState(ctx, a.Primary(), prev)
+}
+
+// FetchPanelState retrieves the current state of a single panel
+func (a *Adapter) FetchPanelState(ctx context.Context, panel Panel, prev AlarmoState) (AlarmoState, error) {
 	// Construct request (do not log URL)
-	url := a.baseURL + "/api/states/alarm_control_panel.alarmo"
+	url := a.baseURL + "/api/states/" + panel.EntityID
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		return AlarmoState{}, fmt.Errorf("alarmo: request creation failed: %w", err)
@@ -143,6 +262,14 @@ func mapAlarmoState(ha haStateResponse, prev AlarmoState) AlarmoState {
 		state.Mode = "armed"
 		state.ArmedMode = "night"
 
+	case "armed_vacation":
+		state.Mode = "armed"
+		state.ArmedMode = "vacation"
+
+	case "armed_custom_bypass":
+		state.Mode = "armed"
+		state.ArmedMode = "custom_bypass"
+
 	case "triggered":
 		state.Mode = "triggered"
 		state.ArmedMode = ""
@@ -236,12 +363,24 @@ func parseLastChanged(timeStr string) time.Time {
 	return time.Now()
 }
 
-// RequestAction sends an arm/disarm request to Alarmo
+// RequestAction sends an arm/disarm request to the primary panel
 // A4: Controlled write operations - does NOT modify local state
 // Valid actions: arm_home, arm_away, arm_night, disarm
 // Returns error if request fails, but DOES NOT update AlarmoState
 // Caller must wait for polling to reflect changes
-func (a *Adapter) RequestAction(ctx context.Context, action string) error {
+// code is the Alarmo user code ("" = none); it is sent to HA only and never logged
+func (a *Adapter) RequestAction(ctx context.Context, action string, code string) error {
+	return a.RequestAreaAction(ctx, "", action, code)
+}
+
+// RequestAreaAction sends an arm/disarm request to the panel of one area
+// Empty area targets the primary panel; unknown areas return ErrUnknownArea
+func (a *Adapter) RequestAreaAction(ctx context.Context, area string, action string, code string) error {
+	panel, ok := a.Panel(area)
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownArea, area)
+	}
+
 	// Map action to HA service name
 	serviceName, err := mapActionToService(action)
 	if err != nil {
@@ -253,7 +392,10 @@ func (a *Adapter) RequestAction(ctx context.Context, action string) error {
 
 	// Construct request body
 	body := map[string]interface{}{
-		"entity_id": "alarm_control_panel.alarmo",
+		"entity_id": panel.EntityID,
+	}
+	if code != "" {
+		body["code"] = code // Never log body
 	}
 	bodyJSON, err := json.Marshal(body)
 	if err != nil {
@@ -285,6 +427,16 @@ func (a *Adapter) RequestAction(ctx context.Context, action string) error {
 		return errors.New("alarmo: unauthorized (check HA token)")
 	}
 
+	// HA answers 400 for any invalid service call; only a message about the code
+	// (required or invalid) means the code was refused
+	if resp.StatusCode == http.StatusBadRequest {
+		message := badRequestMessage(resp.Body)
+		if strings.Contains(strings.ToLower(message), "code") {
+			return ErrCodeRejected
+		}
+		return fmt.Errorf("alarmo: http 400: %s", message)
+	}
+
 	if resp.StatusCode != http.StatusOK {
 		return fmt.Errorf("alarmo: http %d", resp.StatusCode)
 	}
@@ -294,6 +446,18 @@ func (a *Adapter) RequestAction(ctx context.Context, action string) error {
 	return nil
 }
 
+// badRequestMessage extracts HA's error message ({"message": "..."}) from a 400 body
+func badRequestMessage(body io.Reader) string {
+	data, _ := io.ReadAll(io.LimitReader(body, 4096))
+	var parsed struct {
+		Message string `json:"message"`
+	}
+	if json.Unmarshal(data, &parsed) == nil && parsed.Message != "" {
+		return parsed.Message
+	}
+	return strings.TrimSpace(string(data))
+}
+
 // mapActionToService converts UI action to HA service name
 // A4: Explicit mapping for security
 func mapActionToService(action string) (string, error) {
@@ -304,6 +468,10 @@ func mapActionToService(action string) (string, error) {
 		return "alarm_arm_away", nil
 	case "arm_night":
 		return "alarm_arm_night", nil
+	case "arm_vacation":
+		return "alarm_arm_vacation", nil
+	case "arm_custom_bypass":
+		return "alarm_arm_custom_bypass", nil
 	case "disarm":
 		return "alarm_disarm", nil
 	default:
@@ -0,0 +1,198 @@
+package alarmo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeHA serves /api/states/<entity> from a fixed map and records service calls
+type fakeHA struct {
+	mu     sync.Mutex
+	states map[string]string
+	calls  []string // "<service> <entity_id> <code>"
+}
+
+func (f *fakeHA) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if strings.HasPrefix(r.URL.Path, "/api/states/") {
+		entity := strings.TrimPrefix(r.URL.Path, "/api/states/")
+		state, ok := f.states[entity]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"state": state, "attributes": map[string]interface{}{}})
+		return
+	}
+	if strings.HasPrefix(r.URL.Path, "/api/services/alarm_control_panel/") {
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		service := strings.TrimPrefix(r.URL.Path, "/api/services/alarm_control_panel/")
+		code, _ := body["code"].(string)
+		f.calls = append(f.calls, service+" "+body["entity_id"].(string)+" "+code)
+		switch code {
+		case "0000":
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"message": "Invalid alarm code provided"}`))
+			return
+		case "1111":
+			w.WriteHeader(http.StatusBadRequest) // Valid code, call refused for another reason
+			w.Write([]byte(`{"message": "Entity alarm_control_panel.shed does not support action alarm_disarm"}`))
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	w.WriteHeader(http.StatusNotFound)
+}
+
+var testPanels = []Panel{
+	{Area: "house", EntityID: "alarm_control_panel.house", Name: "House"},
+	{Area: "garage", EntityID: "alarm_control_panel.garage"},
+	{Area: "shed", EntityID: "alarm_control_panel.shed"},
+}
+
+func TestSetPanelsValidation(t *testing.T) {
+	a := New("http://ha.local", "token")
+
+	if got := a.Primary().EntityID; got != DefaultEntityID {
+		t.Fatalf("default primary = %q, want %q", got, DefaultEntityID)
+	}
+
+	invalid := [][]Panel{
+		{{Area: "", EntityID: "alarm_control_panel.house"}},
+		{{Area: "house", EntityID: "switch.house"}},
+		{{Area: "house", EntityID: "alarm_control_panel.a"}, {Area: "house", EntityID: "alarm_control_panel.b"}},
+		{{Area: "a", EntityID: "alarm_control_panel.x"}, {Area: "b", EntityID: "alarm_control_panel.x"}},
+	}
+	for i, panels := range invalid {
+		if err := a.SetPanels(panels); err == nil {
+			t.Errorf("case %d: expected error", i)
+		}
+	}
+
+	if err := a.SetPanels(testPanels); err != nil {
+		t.Fatalf("SetPanels: %v", err)
+	}
+	if p, _ := a.Panel(""); p.Area != "house" {
+		t.Errorf("empty area resolves to %q, want primary house", p.Area)
+	}
+	if p, ok := a.Panel("garage"); !ok || p.Name != "garage" {
+		t.Errorf("garage panel = %+v, want name defaulted to area", p)
+	}
+	if p, ok := a.PanelByEntity("alarm_control_panel.shed"); !ok || p.Area != "shed" {
+		t.Errorf("PanelByEntity(shed) = %+v, %v", p, ok)
+	}
+	if _, ok := a.Panel("attic"); ok {
+		t.Error("unknown area resolved")
+	}
+
+	if err := a.SetPanels(nil); err != nil || a.Primary().EntityID != DefaultEntityID {
+		t.Errorf("empty panel list should restore default, got %+v (err=%v)", a.Panels(), err)
+	}
+}
+
+func TestFetchAndActionPerArea(t *testing.T) {
+	fake := &fakeHA{states: map[string]string{
+		"alarm_control_panel.house":  "armed_away",
+		"alarm_control_panel.garage": "disarmed",
+	}}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	a := New(srv.URL, "token")
+	if err := a.SetPanels(testPanels); err != nil {
+		t.Fatal(err)
+	}
+
+	got := map[string]AlarmoState{}
+	errs := map[string]error{}
+	allNotFound := fetchAll(context.Background(), a,
+		func(string) AlarmoState { return AlarmoState{} },
+		func(p Panel, st AlarmoState, err error) {
+			got[p.Area] = st
+			errs[p.Area] = err
+		})
+
+	if allNotFound {
+		t.Error("fetchAll reported all panels missing")
+	}
+	if got["house"].Mode != "armed" || got["house"].ArmedMode != "away" {
+		t.Errorf("house = %s/%s, want armed/away", got["house"].Mode, got["house"].ArmedMode)
+	}
+	if got["garage"].Mode != "disarmed" {
+		t.Errorf("garage = %s, want disarmed", got["garage"].Mode)
+	}
+	if !IsNotFound(errs["shed"]) {
+		t.Errorf("shed error = %v, want http 404", errs["shed"])
+	}
+
+	ctx := context.Background()
+	if err := a.RequestAreaAction(ctx, "garage", "arm_night", ""); err != nil {
+		t.Fatalf("garage arm: %v", err)
+	}
+	if err := a.RequestAction(ctx, "disarm", "4321"); err != nil {
+		t.Fatalf("primary disarm: %v", err)
+	}
+	if err := a.RequestAreaAction(ctx, "shed", "disarm", "0000"); !errors.Is(err, ErrCodeRejected) {
+		t.Fatalf("rejected code error = %v, want ErrCodeRejected", err)
+	} else if strings.Contains(err.Error(), "0000") {
+		t.Fatalf("error leaks code: %v", err)
+	}
+	if err := a.RequestAreaAction(ctx, "shed", "disarm", "1111"); err == nil || errors.Is(err, ErrCodeRejected) {
+		t.Fatalf("other bad request error = %v, want a non-code error", err)
+	} else if !strings.Contains(err.Error(), "does not support") || strings.Contains(err.Error(), "1111") {
+		t.Fatalf("other bad request error = %v, want HA's message without the code", err)
+	}
+	if err := a.RequestAreaAction(ctx, "attic", "disarm", ""); !errors.Is(err, ErrUnknownArea) {
+		t.Fatalf("unknown area error = %v, want ErrUnknownArea", err)
+	}
+
+	want := []string{
+		"alarm_arm_night alarm_control_panel.garage ",
+		"alarm_disarm alarm_control_panel.house 4321",
+		"alarm_disarm alarm_control_panel.shed 0000",
+		"alarm_disarm alarm_control_panel.shed 1111",
+	}
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	if strings.Join(fake.calls, "|") != strings.Join(want, "|") {
+		t.Errorf("service calls = %v, want %v", fake.calls, want)
+	}
+}
+
+func TestPushSourceIgnoresUnknownEntities(t *testing.T) {
+	a := New("http://ha.local", "token")
+	if err := a.SetPanels(testPanels); err != nil {
+		t.Fatal(err)
+	}
+	push := NewPushSource(func() *Adapter { return a })
+
+	if push.HandleStateChanged("light.kitchen", map[string]interface{}{"state": "on"}) {
+		t.Error("non-panel entity was queued")
+	}
+	if !push.HandleStateChanged("alarm_control_panel.garage", map[string]interface{}{"state": "triggered"}) {
+		t.Fatal("panel entity was not queued")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		push.Run(ctx, func(string) AlarmoState { return AlarmoState{} }, func(p Panel, st AlarmoState, err error) {
+			if p.Area != "garage" || !st.Triggered || err != nil {
+				t.Errorf("update = %s %+v %v, want garage triggered", p.Area, st, err)
+			}
+			cancel()
+		})
+	}()
+	<-done
+}
@@ -0,0 +1,209 @@
+package alarmo
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// StateSource delivers per-panel AlarmoState updates to the coordinator.
+// Implementations: PushSource (HA WebSocket state_changed) and PollSource (REST fallback).
+type StateSource interface {
+	// Name identifies the source in logs and SelfCheck
+	Name() string
+	// Run delivers updates to sink until ctx is cancelled.
+	// prev returns the latest known state of an area so HA omissions preserve countdown fields.
+	Run(ctx context.Context, prev func(area string) AlarmoState, sink func(Panel, AlarmoState, error))
+}
+
+// AdapterFunc returns the current adapter (it is replaced when HA credentials change)
+type AdapterFunc func() *Adapter
+
+const (
+	SourcePush = "push"
+	SourcePoll = "poll"
+)
+
+// === POLLING (fallback) ===
+
+// PollSource fetches the state of every panel over REST at a fixed interval
+type PollSource struct {
+	adapter          AdapterFunc
+	interval         time.Duration
+	notFoundInterval time.Duration // Slow down when Alarmo is not installed (HTTP 404)
+}
+
+// NewPollSource creates a REST poller (used while the HA WebSocket is down)
+func NewPollSource(adapter AdapterFunc, interval time.Duration) *PollSource {
+	if interval <= 0 {
+		interval = 2 * time.Second
+	}
+	return &PollSource{
+		adapter:          adapter,
+		interval:         interval,
+		notFoundInterval: 60 * time.Second,
+	}
+}
+
+// Name implements StateSource
+func (p *PollSource) Name() string { return SourcePoll }
+
+// Run implements StateSource
+func (p *PollSource) Run(ctx context.Context, prev func(area string) AlarmoState, sink func(Panel, AlarmoState, error)) {
+	timer := time.NewTimer(0) // Fetch immediately on switch-over
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+
+		allNotFound := fetchAll(ctx, p.adapter(), prev, sink)
+		if ctx.Err() != nil {
+			return
+		}
+
+		next := p.interval
+		if allNotFound {
+			next = p.notFoundInterval
+		}
+		timer.Reset(next)
+	}
+}
+
+// fetchAll fetches every panel once and reports whether all of them returned 404
+func fetchAll(ctx context.Context, adapter *Adapter, prev func(area string) AlarmoState, sink func(Panel, AlarmoState, error)) bool {
+	if adapter == nil {
+		return false
+	}
+	allNotFound := true
+	for _, panel := range adapter.Panels() {
+		fetchCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		state, err := adapter.FetchPanelState(fetchCtx, panel, prev(panel.Area))
+		cancel()
+		if ctx.Err() != nil {
+			return false
+		}
+		sink(panel, state, err)
+		if !IsNotFound(err) {
+			allNotFound = false
+		}
+	}
+	return allNotFound
+}
+
+// === PUSH (HA WebSocket) ===
+
+// PushSource turns HA state_changed events into AlarmoState updates.
+// Events are fed by the coordinator; Resync fetches a REST snapshot to cover
+// anything missed while the WebSocket was down.
+type PushSource struct {
+	adapter AdapterFunc
+	inbox   chan pushMessage
+	resync  chan struct{} // Holds at most one pending resync, so a full inbox cannot drop it
+}
+
+type pushMessage struct {
+	panel    Panel
+	newState map[string]interface{}
+}
+
+// NewPushSource creates a push source for the adapter's Alarmo panels
+func NewPushSource(adapter AdapterFunc) *PushSource {
+	return &PushSource{
+		adapter: adapter,
+		inbox:   make(chan pushMessage, 32),
+		resync:  make(chan struct{}, 1),
+	}
+}
+
+// Name implements StateSource
+func (p *PushSource) Name() string { return SourcePush }
+
+// HandleStateChanged queues the new_state of a state_changed event.
+// Entities that are not a configured panel are ignored (returns false).
+// Non-blocking: if the queue is full the event is dropped and a resync is requested.
+func (p *PushSource) HandleStateChanged(entityID string, newState map[string]interface{}) bool {
+	adapter := p.adapter()
+	if adapter == nil {
+		return false
+	}
+	panel, ok := adapter.PanelByEntity(entityID)
+	if !ok {
+		return false
+	}
+	select {
+	case p.inbox <- pushMessage{panel: panel, newState: newState}:
+	default:
+		p.Resync()
+	}
+	return true
+}
+
+// Resync requests a full REST fetch (e.g. after the WebSocket reconnects)
+func (p *PushSource) Resync() {
+	select {
+	case p.resync <- struct{}{}:
+	default:
+		// A resync is already pending
+	}
+}
+
+// Run implements StateSource
+func (p *PushSource) Run(ctx context.Context, prev func(area string) AlarmoState, sink func(Panel, AlarmoState, error)) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-p.resync:
+			p.dropQueued() // The snapshot supersedes them; applied later they would roll it back
+			fetchAll(ctx, p.adapter(), prev, sink)
+			if ctx.Err() != nil {
+				return
+			}
+		case msg := <-p.inbox:
+			state, ok := StateFromEvent(msg.newState, prev(msg.panel.Area))
+			if ok {
+				sink(msg.panel, state, nil)
+			}
+		}
+	}
+}
+
+// dropQueued discards the events waiting in the inbox
+func (p *PushSource) dropQueued() {
+	for {
+		select {
+		case <-p.inbox:
+		default:
+			return
+		}
+	}
+}
+
+// StateFromEvent maps the new_state object of an HA state_changed event.
+// Returns false when the entity was removed (new_state is null) or malformed.
+func StateFromEvent(newState map[string]interface{}, prev AlarmoState) (AlarmoState, bool) {
+	if newState == nil {
+		return prev, false
+	}
+	raw, ok := newState["state"].(string)
+	if !ok {
+		return prev, false
+	}
+	ha := haStateResponse{State: raw}
+	if attrs, ok := newState["attributes"].(map[string]interface{}); ok {
+		ha.Attributes = attrs
+	}
+	if lc, ok := newState["last_changed"].(string); ok {
+		ha.LastChanged = lc
+	}
+	return mapAlarmoState(ha, prev), true
+}
+
+// IsNotFound reports whether err is an HTTP 404 from HA (Alarmo not installed)
+func IsNotFound(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "http 404")
+}
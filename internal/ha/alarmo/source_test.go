@@ -0,0 +1,189 @@
+package alarmo
+
+import (
+	"context"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// update is one state delivered by a source
+type update struct {
+	area string
+	raw  string
+	err  error
+}
+
+// runSource runs src until the test ends and returns the updates it delivers
+func runSource(t *testing.T, src StateSource) <-chan update {
+	t.Helper()
+	updates := make(chan update, 64)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		src.Run(ctx, func(string) AlarmoState { return AlarmoState{} }, func(p Panel, st AlarmoState, err error) {
+			updates <- update{area: p.Area, raw: st.RawState, err: err}
+		})
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	return updates
+}
+
+func nextUpdate(t *testing.T, updates <-chan update) update {
+	t.Helper()
+	select {
+	case u := <-updates:
+		return u
+	case <-time.After(2 * time.Second):
+		t.Fatal("no update delivered")
+	}
+	return update{}
+}
+
+func newSourceAdapter(t *testing.T, states map[string]string) (*Adapter, *fakeHA) {
+	t.Helper()
+	fake := &fakeHA{states: states}
+	srv := httptest.NewServer(fake)
+	t.Cleanup(srv.Close)
+	a := New(srv.URL, "token")
+	if err := a.SetPanels(testPanels); err != nil {
+		t.Fatal(err)
+	}
+	return a, fake
+}
+
+func TestPushSourceDeliversStateChanges(t *testing.T) {
+	a, _ := newSourceAdapter(t, map[string]string{})
+	push := NewPushSource(func() *Adapter { return a })
+	updates := runSource(t, push)
+
+	if push.HandleStateChanged("alarm_control_panel.other", map[string]interface{}{"state": "triggered"}) {
+		t.Error("event of an unknown entity accepted")
+	}
+	if !push.HandleStateChanged("alarm_control_panel.garage", map[string]interface{}{"state": "armed_away"}) {
+		t.Fatal("event of a configured panel ignored")
+	}
+	if u := nextUpdate(t, updates); u.area != "garage" || u.raw != "armed_away" || u.err != nil {
+		t.Errorf("update = %+v, want garage armed_away", u)
+	}
+
+	// A removed entity (new_state null) delivers nothing
+	push.HandleStateChanged("alarm_control_panel.house", nil)
+	push.HandleStateChanged("alarm_control_panel.house", map[string]interface{}{"state": "triggered"})
+	if u := nextUpdate(t, updates); u.area != "house" || u.raw != "triggered" {
+		t.Errorf("update = %+v, want house triggered", u)
+	}
+}
+
+func TestPushSourceResyncFetchesEveryPanel(t *testing.T) {
+	a, _ := newSourceAdapter(t, map[string]string{
+		"alarm_control_panel.house":  "armed_night",
+		"alarm_control_panel.garage": "disarmed",
+	})
+	push := NewPushSource(func() *Adapter { return a })
+	updates := runSource(t, push)
+
+	push.Resync()
+	got := map[string]update{}
+	for range testPanels {
+		u := nextUpdate(t, updates)
+		got[u.area] = u
+	}
+	if got["house"].raw != "armed_night" || got["garage"].raw != "disarmed" {
+		t.Errorf("resync = %+v", got)
+	}
+	if !IsNotFound(got["shed"].err) {
+		t.Errorf("missing panel error = %v, want http 404", got["shed"].err)
+	}
+}
+
+func TestPushSourceOverflowResyncsInsteadOfGoingStale(t *testing.T) {
+	a, fake := newSourceAdapter(t, map[string]string{
+		"alarm_control_panel.house":  "armed_away",
+		"alarm_control_panel.garage": "disarmed",
+		"alarm_control_panel.shed":   "disarmed",
+	})
+	push := NewPushSource(func() *Adapter { return a })
+
+	// Events pile up while nothing reads them; the last one does not fit
+	for i := 0; i < cap(push.inbox); i++ {
+		push.HandleStateChanged("alarm_control_panel.house", map[string]interface{}{"state": "arming"})
+	}
+	fake.mu.Lock()
+	fake.states["alarm_control_panel.house"] = "triggered"
+	fake.mu.Unlock()
+	push.HandleStateChanged("alarm_control_panel.house", map[string]interface{}{"state": "triggered"})
+
+	// The dropped event is recovered by a resync, and the older queued events
+	// do not roll the house back afterwards
+	updates := runSource(t, push)
+	house := ""
+	deadline := time.After(2 * time.Second)
+	for house != "triggered" {
+		select {
+		case u := <-updates:
+			if u.area == "house" {
+				house = u.raw
+			}
+		case <-deadline:
+			t.Fatalf("house = %q, want triggered after the overflow", house)
+		}
+	}
+	select {
+	case u := <-updates:
+		if u.area == "house" {
+			t.Errorf("house went back to %q after the resync", u.raw)
+		}
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestPollSourceFetchesEveryPanelEachInterval(t *testing.T) {
+	a, fake := newSourceAdapter(t, map[string]string{
+		"alarm_control_panel.house":  "disarmed",
+		"alarm_control_panel.garage": "disarmed",
+	})
+	poll := NewPollSource(func() *Adapter { return a }, 10*time.Millisecond)
+	updates := runSource(t, poll)
+
+	// First round right away, then the changed state on a later round
+	for range testPanels {
+		nextUpdate(t, updates)
+	}
+	fake.mu.Lock()
+	fake.states["alarm_control_panel.house"] = "pending"
+	fake.mu.Unlock()
+	for u := nextUpdate(t, updates); u.area != "house" || u.raw != "pending"; u = nextUpdate(t, updates) {
+	}
+}
+
+func TestPollSourceSlowsDownWhenAlarmoMissing(t *testing.T) {
+	a, _ := newSourceAdapter(t, map[string]string{})
+	var mu sync.Mutex
+	rounds := 0
+	poll := NewPollSource(func() *Adapter {
+		mu.Lock()
+		defer mu.Unlock()
+		rounds++
+		return a
+	}, 10*time.Millisecond)
+	poll.notFoundInterval = time.Hour
+	updates := runSource(t, poll)
+
+	for range testPanels {
+		if u := nextUpdate(t, updates); !IsNotFound(u.err) {
+			t.Fatalf("update = %+v, want http 404", u)
+		}
+	}
+	time.Sleep(50 * time.Millisecond)
+	mu.Lock()
+	defer mu.Unlock()
+	if rounds != 1 {
+		t.Errorf("fetch rounds = %d, want 1 while every panel is missing", rounds)
+	}
+}
@@ -1,6 +1,7 @@
 package haadapter
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"net/http"
@@ -16,6 +17,7 @@ const (
 	EventAlarmStateChange = "alarm_state_change"
 	EventGuestRequest     = "guest_request"
 	EventGuestResponse    = "guest_response"
+	EventStateChanged     = "state_changed" // Raw HA state_changed from the WebSocket subscription
 )
 
 type Event struct {
@@ -28,7 +30,16 @@ type Adapter struct {
 	mu        sync.Mutex
 	baseURL   string
 	token     string
-	ws        interface{} // Placeholder for websocket connection
+	onEvent   func(Event)        // Receives events pushed over the WebSocket session
+	onConnect func(bool)         // Notified when the WebSocket session goes up/down
+	cancel    context.CancelFunc // Stops the WebSocket session loop
+	done      chan struct{}      // Closed when the session loop exits
+
+	// WebSocket tuning (overridable in tests)
+	reconnectMin time.Duration
+	reconnectMax time.Duration
+	pingInterval time.Duration
+	pongTimeout  time.Duration
 }
 
 // Domain model references (to be set by main or test)
@@ -55,6 +66,9 @@ func (a *Adapter) HandleEvent(event Event) error {
 			logger.Info("ha event: guest_request → guest.Handle(REQUEST)")
 			return guestSM.Handle("REQUEST")
 		}
+	case EventStateChanged:
+		// High-volume stream; consumers filter by entity_id in the coordinator
+		return nil
 	case EventGuestResponse:
 		if guestSM != nil && event.Payload["response"] != nil {
 			resp := event.Payload["response"].(string)
@@ -78,7 +92,37 @@ func New() *Adapter {
 	if err != nil || u.Scheme == "" || u.Host == "" {
 		logger.Error("invalid HA_BASE_URL format")
 	}
-	return &Adapter{baseURL: baseURL, token: token}
+	return &Adapter{
+		baseURL:      baseURL,
+		token:        token,
+		reconnectMin: defaultReconnectMin,
+		reconnectMax: defaultReconnectMax,
+		pingInterval: defaultPingInterval,
+		pongTimeout:  defaultPongTimeout,
+	}
+}
+
+// SetCredentials replaces the HA base URL and token (e.g. after loading secure storage).
+// Takes effect on the next (re)connect.
+func (a *Adapter) SetCredentials(baseURL, token string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.baseURL = strings.TrimRight(baseURL, "/")
+	a.token = token
+}
+
+// SetConnectionHandler registers a callback for WebSocket session up/down transitions
+func (a *Adapter) SetConnectionHandler(fn func(connected bool)) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.onConnect = fn
+}
+
+// SetEventHandler registers the receiver for events pushed by Home Assistant
+func (a *Adapter) SetEventHandler(fn func(Event)) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.onEvent = fn
 }
 
 func (a *Adapter) Init() error {
@@ -89,43 +133,75 @@ func (a *Adapter) Init() error {
 	return nil
 }
 
+// Start opens the HA WebSocket session in the background.
+// IsConnected reports true only while an authenticated session is up.
 func (a *Adapter) Start() error {
 	a.mu.Lock()
 	defer a.mu.Unlock()
-	// Placeholder for websocket connection (would use golang.org/x/net/websocket in full implementation)
-	a.connected = true
-	logger.Info("ha adapter started (placeholder)")
+	if a.cancel != nil {
+		return errors.New("already started")
+	}
+	if a.baseURL == "" || a.token == "" {
+		logger.Info("ha adapter: credentials not configured, websocket disabled")
+		return nil
+	}
+	if _, err := websocketURL(a.baseURL); err != nil {
+		return errors.New("invalid HA base url: " + err.Error())
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	a.cancel = cancel
+	a.done = make(chan struct{})
+	go a.run(ctx, a.done)
+	logger.Info("ha adapter started (websocket)")
 	return nil
 }
 
 // REST: CallService(domain, service, payload)
 func (a *Adapter) CallService(domain, service string, payload map[string]interface{}) error {
+	if err := a.post("/api/services/"+domain+"/"+service, payload); err != nil {
+		logger.Error("callservice " + domain + "." + service + ": " + err.Error())
+		return err
+	}
+	logger.Info("callservice success: " + domain + "." + service)
+	return nil
+}
+
+// REST: FireEvent(eventType, data) - custom event for HA automations
+func (a *Adapter) FireEvent(eventType string, data map[string]interface{}) error {
+	if err := a.post("/api/events/"+eventType, data); err != nil {
+		logger.Error("fireevent " + eventType + ": " + err.Error())
+		return err
+	}
+	logger.Info("fireevent success: " + eventType)
+	return nil
+}
+
+// post sends an authenticated JSON POST to the HA REST API.
+// REST does not need the WebSocket session, so it works while that reconnects.
+func (a *Adapter) post(path string, payload map[string]interface{}) error {
 	a.mu.Lock()
-	defer a.mu.Unlock()
-	if !a.connected {
-		return errors.New("not connected")
+	baseURL := a.baseURL
+	token := a.token
+	a.mu.Unlock()
+	if baseURL == "" || token == "" {
+		return errors.New("not configured")
 	}
-	url := a.baseURL + "/api/services/" + domain + "/" + service
 	body, _ := json.Marshal(payload)
-	req, err := http.NewRequest("POST", url, strings.NewReader(string(body)))
+	req, err := http.NewRequest("POST", baseURL+path, strings.NewReader(string(body)))
 	if err != nil {
-		logger.Error("callservice request error: " + err.Error())
 		return err
 	}
-	req.Header.Set("Authorization", "Bearer "+a.token)
+	req.Header.Set("Authorization", "Bearer "+token)
 	req.Header.Set("Content-Type", "application/json")
 	client := &http.Client{Timeout: 30 * time.Second}
 	resp, err := client.Do(req)
 	if err != nil {
-		logger.Error("callservice http error: " + err.Error())
 		return err
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode != 200 {
-		logger.Error("callservice failed: status " + resp.Status)
-		return errors.New("callservice failed")
+		return errors.New("status " + resp.Status)
 	}
-	logger.Info("callservice success: " + domain + "." + service)
 	return nil
 }
 
@@ -140,8 +216,18 @@ func (a *Adapter) DisarmAlarm(payload map[string]interface{}) error {
 
 func (a *Adapter) Stop() {
 	a.mu.Lock()
-	defer a.mu.Unlock()
-	a.connected = false
+	cancel := a.cancel
+	done := a.done
+	a.cancel = nil
+	a.done = nil
+	a.mu.Unlock()
+
+	if cancel != nil {
+		cancel()
+		<-done
+	}
+
+	a.setConnected(false)
 	logger.Info("ha adapter stopped")
 }
 
@@ -150,3 +236,24 @@ func (a *Adapter) IsConnected() bool {
 	defer a.mu.Unlock()
 	return a.connected
 }
+
+func (a *Adapter) setConnected(connected bool) {
+	a.mu.Lock()
+	changed := a.connected != connected
+	a.connected = connected
+	fn := a.onConnect
+	a.mu.Unlock()
+	if changed && fn != nil {
+		fn(connected)
+	}
+}
+
+// emit forwards an event to the registered handler (if any)
+func (a *Adapter) emit(event Event) {
+	a.mu.Lock()
+	fn := a.onEvent
+	a.mu.Unlock()
+	if fn != nil {
+		fn(event)
+	}
+}
@@ -0,0 +1,446 @@
+package haadapter
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"smartdisplay-core/internal/logger"
+)
+
+func TestMain(m *testing.M) {
+	os.MkdirAll("logs", 0755)
+	logger.Init()
+	os.Exit(m.Run())
+}
+
+// fakeHA is a minimal Home Assistant WebSocket API server for offline tests
+type fakeHA struct {
+	t     *testing.T
+	token string
+
+	mu          sync.Mutex
+	connections int
+	authTokens  []string
+	subscribed  []string // event_type of each subscribe_events
+	pings       int
+
+	// Events pushed to every connection after subscription
+	events []map[string]interface{}
+	// Drop the first N connections right after subscribing (simulates HA restart)
+	dropFirst int
+}
+
+func (f *fakeHA) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/api/websocket" {
+		http.NotFound(w, r)
+		return
+	}
+
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		f.t.Errorf("fake HA: response writer cannot hijack")
+		return
+	}
+	conn, brw, err := hj.Hijack()
+	if err != nil {
+		f.t.Errorf("fake HA: hijack failed: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	brw.WriteString("HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + wsAcceptKey(r.Header.Get("Sec-WebSocket-Key")) + "\r\n\r\n")
+	brw.Flush()
+
+	f.mu.Lock()
+	f.connections++
+	connNum := f.connections
+	f.mu.Unlock()
+
+	send := func(v interface{}) error {
+		data, _ := json.Marshal(v)
+		return writeWSFrame(conn, wsOpText, data, false)
+	}
+	recv := func() (map[string]interface{}, error) {
+		for {
+			_, op, payload, err := readWSFrame(brw.Reader)
+			if err != nil {
+				return nil, err
+			}
+			if op == wsOpClose {
+				return nil, errWSClosed
+			}
+			if op != wsOpText {
+				continue
+			}
+			var msg map[string]interface{}
+			if err := json.Unmarshal(payload, &msg); err != nil {
+				return nil, err
+			}
+			return msg, nil
+		}
+	}
+
+	send(map[string]interface{}{"type": "auth_required", "ha_version": "2026.1.0"})
+	auth, err := recv()
+	if err != nil {
+		return
+	}
+	token, _ := auth["access_token"].(string)
+	f.mu.Lock()
+	f.authTokens = append(f.authTokens, token)
+	f.mu.Unlock()
+	if auth["type"] != "auth" || token != f.token {
+		send(map[string]interface{}{"type": "auth_invalid", "message": "Invalid access token"})
+		return
+	}
+	send(map[string]interface{}{"type": "auth_ok", "ha_version": "2026.1.0"})
+
+	for {
+		msg, err := recv()
+		if err != nil {
+			return
+		}
+		id := msg["id"]
+		switch msg["type"] {
+		case "subscribe_events":
+			eventType, _ := msg["event_type"].(string)
+			f.mu.Lock()
+			f.subscribed = append(f.subscribed, eventType)
+			events := f.events
+			drop := connNum <= f.dropFirst
+			f.mu.Unlock()
+
+			send(map[string]interface{}{"id": id, "type": "result", "success": true, "result": nil})
+			if drop {
+				return
+			}
+			for _, ev := range events {
+				send(map[string]interface{}{"id": id, "type": "event", "event": ev})
+			}
+		case "ping":
+			f.mu.Lock()
+			f.pings++
+			f.mu.Unlock()
+			send(map[string]interface{}{"id": id, "type": "pong"})
+		}
+	}
+}
+
+func (f *fakeHA) snapshot() (connections int, tokens []string, subscribed []string, pings int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.connections, append([]string(nil), f.authTokens...), append([]string(nil), f.subscribed...), f.pings
+}
+
+func alarmoStateChanged(oldState, newState string) map[string]interface{} {
+	return map[string]interface{}{
+		"event_type": "state_changed",
+		"time_fired": "2026-01-04T12:34:56.789012+00:00",
+		"data": map[string]interface{}{
+			"entity_id": "alarm_control_panel.alarmo",
+			"old_state": map[string]interface{}{"entity_id": "alarm_control_panel.alarmo", "state": oldState},
+			"new_state": map[string]interface{}{"entity_id": "alarm_control_panel.alarmo", "state": newState},
+		},
+	}
+}
+
+// newTestAdapter returns an adapter pointed at the fake server with fast timings
+func newTestAdapter(baseURL, token string) *Adapter {
+	a := New()
+	a.SetCredentials(baseURL, token)
+	a.reconnectMin = 10 * time.Millisecond
+	a.reconnectMax = 40 * time.Millisecond
+	return a
+}
+
+func waitFor(t *testing.T, timeout time.Duration, cond func() bool, what string) {
+	t.Helper()
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if cond() {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("timed out waiting for %s", what)
+}
+
+func TestWebSocketAuthSubscribeAndEvents(t *testing.T) {
+	fake := &fakeHA{t: t, token: "secret-token", events: []map[string]interface{}{
+		alarmoStateChanged("disarmed", "arming"),
+	}}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	a := newTestAdapter(srv.URL, "secret-token")
+	received := make(chan Event, 4)
+	a.SetEventHandler(func(e Event) { received <- e })
+
+	if a.IsConnected() {
+		t.Fatal("adapter must not report connected before Start")
+	}
+	if err := a.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	defer a.Stop()
+
+	select {
+	case ev := <-received:
+		if ev.Type != EventStateChanged {
+			t.Fatalf("expected %s event, got %s", EventStateChanged, ev.Type)
+		}
+		if id, _ := ev.Payload["entity_id"].(string); id != "alarm_control_panel.alarmo" {
+			t.Fatalf("unexpected entity_id %q", id)
+		}
+		newState, _ := ev.Payload["new_state"].(map[string]interface{})
+		if newState["state"] != "arming" {
+			t.Fatalf("expected new_state arming, got %v", newState["state"])
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no state_changed event received")
+	}
+
+	if !a.IsConnected() {
+		t.Fatal("expected adapter to be connected after auth_ok")
+	}
+
+	_, tokens, subscribed, _ := fake.snapshot()
+	if len(tokens) != 1 || tokens[0] != "secret-token" {
+		t.Fatalf("expected single auth with configured token, got %v", tokens)
+	}
+	if len(subscribed) != 1 || subscribed[0] != "state_changed" {
+		t.Fatalf("expected subscribe_events for state_changed, got %v", subscribed)
+	}
+
+	a.Stop()
+	if a.IsConnected() {
+		t.Fatal("expected adapter disconnected after Stop")
+	}
+}
+
+func TestWebSocketAuthInvalidRetriesWithoutConnecting(t *testing.T) {
+	fake := &fakeHA{t: t, token: "secret-token"}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	a := newTestAdapter(srv.URL, "wrong-token")
+	if err := a.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	defer a.Stop()
+
+	waitFor(t, 2*time.Second, func() bool {
+		conns, _, _, _ := fake.snapshot()
+		return conns >= 3
+	}, "reconnect attempts after auth_invalid")
+
+	if a.IsConnected() {
+		t.Fatal("adapter must not report connected when auth is rejected")
+	}
+	_, _, subscribed, _ := fake.snapshot()
+	if len(subscribed) != 0 {
+		t.Fatalf("expected no subscriptions without auth, got %v", subscribed)
+	}
+}
+
+func TestWebSocketReconnectsAfterDrop(t *testing.T) {
+	fake := &fakeHA{t: t, token: "secret-token", dropFirst: 1, events: []map[string]interface{}{
+		alarmoStateChanged("armed_away", "triggered"),
+	}}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	a := newTestAdapter(srv.URL, "secret-token")
+	received := make(chan Event, 4)
+	a.SetEventHandler(func(e Event) { received <- e })
+	if err := a.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	defer a.Stop()
+
+	select {
+	case <-received:
+	case <-time.After(2 * time.Second):
+		t.Fatal("no event received after reconnect")
+	}
+
+	conns, _, subscribed, _ := fake.snapshot()
+	if conns != 2 {
+		t.Fatalf("expected exactly one reconnect (2 connections), got %d", conns)
+	}
+	if len(subscribed) != 2 {
+		t.Fatalf("expected re-subscription on reconnect, got %v", subscribed)
+	}
+	if !a.IsConnected() {
+		t.Fatal("expected adapter connected after reconnect")
+	}
+}
+
+func TestWebSocketPingKeepsSessionAlive(t *testing.T) {
+	fake := &fakeHA{t: t, token: "secret-token"}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	a := newTestAdapter(srv.URL, "secret-token")
+	a.pingInterval = 20 * time.Millisecond
+	a.pongTimeout = 200 * time.Millisecond
+	if err := a.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	defer a.Stop()
+
+	waitFor(t, 2*time.Second, func() bool {
+		_, _, _, pings := fake.snapshot()
+		return pings >= 3
+	}, "keepalive pings")
+
+	conns, _, _, _ := fake.snapshot()
+	if conns != 1 {
+		t.Fatalf("expected session to stay on one connection, got %d", conns)
+	}
+	if !a.IsConnected() {
+		t.Fatal("expected adapter connected while pongs arrive")
+	}
+}
+
+func TestWebSocketDeadPeerTriggersReconnect(t *testing.T) {
+	// Server that authenticates then goes silent (never answers pings)
+	var mu sync.Mutex
+	conns := 0
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen failed: %v", err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			mu.Lock()
+			conns++
+			mu.Unlock()
+			go func(c net.Conn) {
+				defer c.Close()
+				br := bufio.NewReader(c)
+				req, err := http.ReadRequest(br)
+				if err != nil {
+					return
+				}
+				c.Write([]byte("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n" +
+					"Sec-WebSocket-Accept: " + wsAcceptKey(req.Header.Get("Sec-WebSocket-Key")) + "\r\n\r\n"))
+				writeWSFrame(c, wsOpText, []byte(`{"type":"auth_required"}`), false)
+				readWSFrame(br)
+				writeWSFrame(c, wsOpText, []byte(`{"type":"auth_ok"}`), false)
+				for {
+					if _, _, _, err := readWSFrame(br); err != nil {
+						return
+					}
+				}
+			}(c)
+		}
+	}()
+
+	a := newTestAdapter("http://"+ln.Addr().String(), "secret-token")
+	a.pingInterval = 20 * time.Millisecond
+	a.pongTimeout = 30 * time.Millisecond
+	if err := a.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	defer a.Stop()
+
+	waitFor(t, 2*time.Second, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return conns >= 2
+	}, "reconnect after missing pongs")
+}
+
+func TestStartWithoutCredentialsStaysDisconnected(t *testing.T) {
+	a := newTestAdapter("", "")
+	if err := a.Start(); err != nil {
+		t.Fatalf("Start without credentials should not fail: %v", err)
+	}
+	defer a.Stop()
+	if a.IsConnected() {
+		t.Fatal("adapter without credentials must not report connected")
+	}
+}
+
+func TestCallServiceWorksWhileWebSocketDown(t *testing.T) {
+	var gotPath, gotAuth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath, gotAuth = r.URL.Path, r.Header.Get("Authorization")
+		w.Write([]byte("[]"))
+	}))
+	defer srv.Close()
+
+	// Never started: no WebSocket session, REST still goes through
+	a := newTestAdapter(srv.URL, "secret")
+	if a.IsConnected() {
+		t.Fatal("adapter connected without a session")
+	}
+	if err := a.CallService("notify", "mobile_app_ayse", map[string]interface{}{"message": "hi"}); err != nil {
+		t.Fatalf("CallService while disconnected: %v", err)
+	}
+	if gotPath != "/api/services/notify/mobile_app_ayse" || gotAuth != "Bearer secret" {
+		t.Errorf("request = %s auth=%q", gotPath, gotAuth)
+	}
+
+	if err := newTestAdapter("", "").FireEvent("smartdisplay_duress", nil); err == nil {
+		t.Error("FireEvent without credentials succeeded")
+	}
+}
+
+func TestWebSocketFrameRoundTrip(t *testing.T) {
+	for _, size := range []int{0, 125, 126, 70000} {
+		payload := bytes.Repeat([]byte("x"), size)
+		for _, masked := range []bool{true, false} {
+			var buf bytes.Buffer
+			if err := writeWSFrame(&buf, wsOpText, payload, masked); err != nil {
+				t.Fatalf("write size=%d masked=%v: %v", size, masked, err)
+			}
+			fin, op, got, err := readWSFrame(bufio.NewReader(&buf))
+			if err != nil {
+				t.Fatalf("read size=%d masked=%v: %v", size, masked, err)
+			}
+			if !fin || op != wsOpText || !bytes.Equal(got, payload) {
+				t.Fatalf("round trip mismatch size=%d masked=%v", size, masked)
+			}
+		}
+	}
+}
+
+func TestWebsocketURL(t *testing.T) {
+	cases := map[string]string{
+		"http://homeassistant.local:8123":    "ws://homeassistant.local:8123/api/websocket",
+		"https://ha.example.com/":            "wss://ha.example.com/api/websocket",
+		"http://10.0.0.2:8123/prefix":        "ws://10.0.0.2:8123/prefix/api/websocket",
+		"ws://homeassistant.local:8123":      "ws://homeassistant.local:8123/api/websocket",
+		"https://ha.example.com:443/ha/sub/": "wss://ha.example.com:443/ha/sub/api/websocket",
+	}
+	for in, want := range cases {
+		got, err := websocketURL(in)
+		if err != nil {
+			t.Fatalf("websocketURL(%q) error: %v", in, err)
+		}
+		if got != want {
+			t.Errorf("websocketURL(%q) = %q, want %q", in, got, want)
+		}
+	}
+	if _, err := websocketURL("ftp://example.com"); err == nil {
+		t.Error("expected error for unsupported scheme")
+	}
+}
@@ -0,0 +1,281 @@
+package haadapter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"smartdisplay-core/internal/logger"
+	"strings"
+	"time"
+)
+
+// HA WebSocket API session: auth handshake, subscribe_events, ping/pong and reconnect.
+// Protocol reference: https://developers.home-assistant.io/docs/api/websocket
+
+const (
+	defaultReconnectMin     = 1 * time.Second
+	defaultReconnectMax     = 60 * time.Second
+	defaultPingInterval     = 30 * time.Second
+	defaultPongTimeout      = 10 * time.Second
+	defaultHandshakeTimeout = 10 * time.Second
+)
+
+var errAuthInvalid = errors.New("ha websocket: authentication rejected (check HA token)")
+
+// haMessage is the subset of HA WebSocket API fields SmartDisplay reads
+type haMessage struct {
+	ID        int             `json:"id,omitempty"`
+	Type      string          `json:"type"`
+	Success   *bool           `json:"success,omitempty"`
+	HAVersion string          `json:"ha_version,omitempty"`
+	Message   string          `json:"message,omitempty"`
+	Error     *haError        `json:"error,omitempty"`
+	Event     *haEventMessage `json:"event,omitempty"`
+}
+
+type haError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+type haEventMessage struct {
+	EventType string                 `json:"event_type"`
+	Data      map[string]interface{} `json:"data"`
+	TimeFired string                 `json:"time_fired"`
+}
+
+// websocketURL converts the REST base URL into the HA WebSocket endpoint
+func websocketURL(baseURL string) (string, error) {
+	u, err := url.Parse(strings.TrimRight(baseURL, "/"))
+	if err != nil {
+		return "", err
+	}
+	switch u.Scheme {
+	case "http":
+		u.Scheme = "ws"
+	case "https":
+		u.Scheme = "wss"
+	case "ws", "wss":
+	default:
+		return "", fmt.Errorf("unsupported scheme %q", u.Scheme)
+	}
+	u.Path = strings.TrimRight(u.Path, "/") + "/api/websocket"
+	return u.String(), nil
+}
+
+// run keeps a session alive until ctx is cancelled, reconnecting with exponential backoff
+func (a *Adapter) run(ctx context.Context, done chan struct{}) {
+	defer close(done)
+
+	a.mu.Lock()
+	backoff := a.reconnectMin
+	maxBackoff := a.reconnectMax
+	a.mu.Unlock()
+	minBackoff := backoff
+
+	for {
+		authenticated, err := a.session(ctx)
+		a.setConnected(false)
+
+		if ctx.Err() != nil {
+			return
+		}
+		if authenticated {
+			// Healthy session ended: start over with the short delay
+			backoff = minBackoff
+		}
+		if err != nil {
+			logger.Error("ha websocket: disconnected: " + err.Error() + " (retry in " + backoff.String() + ")")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+		if backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
+
+// session runs a single connection: handshake, subscribe, then read until error.
+// Returns whether the connection got past authentication.
+func (a *Adapter) session(ctx context.Context) (bool, error) {
+	a.mu.Lock()
+	baseURL := a.baseURL
+	token := a.token
+	pingInterval := a.pingInterval
+	pongTimeout := a.pongTimeout
+	a.mu.Unlock()
+
+	wsURL, err := websocketURL(baseURL)
+	if err != nil {
+		return false, fmt.Errorf("invalid base url: %w", err)
+	}
+
+	conn, err := dialWebSocket(ctx, wsURL, defaultHandshakeTimeout)
+	if err != nil {
+		return false, err
+	}
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-stop:
+		}
+	}()
+	defer conn.Close()
+
+	// Step 1: server greets with auth_required
+	conn.SetReadDeadline(time.Now().Add(defaultHandshakeTimeout))
+	msg, err := readHAMessage(conn)
+	if err != nil {
+		return false, err
+	}
+	if msg.Type != "auth_required" {
+		return false, fmt.Errorf("unexpected greeting %q", msg.Type)
+	}
+
+	// Step 2: authenticate (never log the token)
+	if err := conn.WriteJSON(map[string]string{"type": "auth", "access_token": token}); err != nil {
+		return false, err
+	}
+	msg, err = readHAMessage(conn)
+	if err != nil {
+		return false, err
+	}
+	switch msg.Type {
+	case "auth_ok":
+	case "auth_invalid":
+		return false, errAuthInvalid
+	default:
+		return false, fmt.Errorf("unexpected auth response %q", msg.Type)
+	}
+
+	a.setConnected(true)
+	logger.Info("ha websocket: connected (ha_version=" + msg.HAVersion + ")")
+
+	// Step 3: subscribe to state_changed events
+	nextID := 1
+	subscribeID := nextID
+	if err := conn.WriteJSON(map[string]interface{}{
+		"id":         subscribeID,
+		"type":       "subscribe_events",
+		"event_type": "state_changed",
+	}); err != nil {
+		return true, err
+	}
+
+	// Step 4: keepalive pings; any inbound traffic pushes the read deadline out
+	pingErr := make(chan error, 1)
+	go func() {
+		ticker := time.NewTicker(pingInterval)
+		defer ticker.Stop()
+		id := subscribeID
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				id++
+				if err := conn.WriteJSON(map[string]interface{}{"id": id, "type": "ping"}); err != nil {
+					pingErr <- err
+					conn.Close()
+					return
+				}
+			}
+		}
+	}()
+
+	for {
+		conn.SetReadDeadline(time.Now().Add(pingInterval + pongTimeout))
+		raw, err := conn.ReadMessage()
+		if err != nil {
+			select {
+			case perr := <-pingErr:
+				return true, fmt.Errorf("ping failed: %w", perr)
+			default:
+			}
+			return true, err
+		}
+		a.dispatchMessages(raw, subscribeID)
+	}
+}
+
+// dispatchMessages decodes one frame (single object or coalesced array) and routes it
+func (a *Adapter) dispatchMessages(raw []byte, subscribeID int) {
+	var msgs []haMessage
+	trimmed := strings.TrimSpace(string(raw))
+	if strings.HasPrefix(trimmed, "[") {
+		if err := json.Unmarshal(raw, &msgs); err != nil {
+			logger.Error("ha websocket: invalid message batch: " + err.Error())
+			return
+		}
+	} else {
+		var msg haMessage
+		if err := json.Unmarshal(raw, &msg); err != nil {
+			logger.Error("ha websocket: invalid message: " + err.Error())
+			return
+		}
+		msgs = append(msgs, msg)
+	}
+
+	for _, msg := range msgs {
+		switch msg.Type {
+		case "event":
+			if msg.Event == nil {
+				continue
+			}
+			a.emit(translateHAEvent(msg.Event))
+		case "result":
+			if msg.Success != nil && !*msg.Success {
+				detail := "unknown error"
+				if msg.Error != nil {
+					detail = msg.Error.Code + ": " + msg.Error.Message
+				}
+				logger.Error(fmt.Sprintf("ha websocket: command %d failed: %s", msg.ID, detail))
+			} else if msg.ID == subscribeID {
+				logger.Info("ha websocket: subscribed to state_changed")
+			}
+		case "pong":
+			// Keepalive reply; read deadline already extended
+		}
+	}
+}
+
+// translateHAEvent maps a raw HA event onto the adapter Event model
+func translateHAEvent(ev *haEventMessage) Event {
+	if ev.EventType == "state_changed" {
+		payload := map[string]interface{}{
+			"time_fired": ev.TimeFired,
+		}
+		for _, key := range []string{"entity_id", "old_state", "new_state"} {
+			if v, ok := ev.Data[key]; ok {
+				payload[key] = v
+			}
+		}
+		return Event{Type: EventStateChanged, Payload: payload}
+	}
+	return Event{Type: ev.EventType, Payload: ev.Data}
+}
+
+// readHAMessage reads and decodes a single HA message (handshake phase only)
+func readHAMessage(conn *wsConn) (haMessage, error) {
+	var msg haMessage
+	raw, err := conn.ReadMessage()
+	if err != nil {
+		return msg, err
+	}
+	if err := json.Unmarshal(raw, &msg); err != nil {
+		return msg, fmt.Errorf("invalid message: %w", err)
+	}
+	return msg, nil
+}
@@ -0,0 +1,314 @@
+package haadapter
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Minimal RFC 6455 client used for the Home Assistant WebSocket API.
+// Only what HA needs is implemented: text frames, fragmentation, ping/pong and close.
+// stdlib only (no golang.org/x/net dependency).
+
+const (
+	wsOpContinuation byte = 0x0
+	wsOpText         byte = 0x1
+	wsOpBinary       byte = 0x2
+	wsOpClose        byte = 0x8
+	wsOpPing         byte = 0x9
+	wsOpPong         byte = 0xA
+)
+
+// wsMaxMessageSize caps a single reassembled message (HA state dumps can be large)
+const wsMaxMessageSize = 32 << 20
+
+// wsAcceptGUID is the fixed GUID from RFC 6455 section 1.3
+const wsAcceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+var errWSClosed = errors.New("websocket: connection closed by peer")
+
+// wsConn is a client-side websocket connection
+type wsConn struct {
+	conn net.Conn
+	br   *bufio.Reader
+	wmu  sync.Mutex // serializes frame writes (reader answers pings concurrently)
+}
+
+// dialWebSocket performs the opening handshake against a ws:// or wss:// URL
+func dialWebSocket(ctx context.Context, rawURL string, timeout time.Duration) (*wsConn, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("websocket: invalid url: %w", err)
+	}
+
+	host := u.Host
+	useTLS := false
+	switch u.Scheme {
+	case "ws":
+		if u.Port() == "" {
+			host = net.JoinHostPort(u.Hostname(), "80")
+		}
+	case "wss":
+		useTLS = true
+		if u.Port() == "" {
+			host = net.JoinHostPort(u.Hostname(), "443")
+		}
+	default:
+		return nil, fmt.Errorf("websocket: unsupported scheme %q", u.Scheme)
+	}
+
+	dialer := &net.Dialer{Timeout: timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", host)
+	if err != nil {
+		return nil, fmt.Errorf("websocket: dial failed: %w", err)
+	}
+	if useTLS {
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: u.Hostname()})
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("websocket: tls handshake failed: %w", err)
+		}
+		conn = tlsConn
+	}
+
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	keyBytes := make([]byte, 16)
+	if _, err := io.ReadFull(rand.Reader, keyBytes); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("websocket: key generation failed: %w", err)
+	}
+	key := base64.StdEncoding.EncodeToString(keyBytes)
+
+	path := u.RequestURI()
+	req := &http.Request{
+		Method:     http.MethodGet,
+		URL:        u,
+		Host:       u.Host,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     make(http.Header),
+	}
+	handshake := "GET " + path + " HTTP/1.1\r\n" +
+		"Host: " + u.Host + "\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Key: " + key + "\r\n" +
+		"Sec-WebSocket-Version: 13\r\n\r\n"
+	if _, err := io.WriteString(conn, handshake); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("websocket: handshake write failed: %w", err)
+	}
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("websocket: handshake read failed: %w", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		conn.Close()
+		return nil, fmt.Errorf("websocket: handshake failed: http %d", resp.StatusCode)
+	}
+	if !strings.EqualFold(resp.Header.Get("Upgrade"), "websocket") {
+		conn.Close()
+		return nil, errors.New("websocket: handshake failed: missing upgrade header")
+	}
+	if resp.Header.Get("Sec-WebSocket-Accept") != wsAcceptKey(key) {
+		conn.Close()
+		return nil, errors.New("websocket: handshake failed: bad accept key")
+	}
+
+	conn.SetDeadline(time.Time{})
+	return &wsConn{conn: conn, br: br}, nil
+}
+
+// wsAcceptKey computes Sec-WebSocket-Accept for a given client key
+func wsAcceptKey(key string) string {
+	h := sha1.New()
+	h.Write([]byte(key + wsAcceptGUID))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// ReadMessage returns the next complete data message.
+// Control frames are handled inline: pings are answered, close ends the stream.
+func (c *wsConn) ReadMessage() ([]byte, error) {
+	var message []byte
+	started := false
+	for {
+		fin, op, payload, err := readWSFrame(c.br)
+		if err != nil {
+			return nil, err
+		}
+
+		switch op {
+		case wsOpPing:
+			if err := c.writeFrame(wsOpPong, payload); err != nil {
+				return nil, err
+			}
+			continue
+		case wsOpPong:
+			continue
+		case wsOpClose:
+			_ = c.writeFrame(wsOpClose, nil)
+			return nil, errWSClosed
+		case wsOpText, wsOpBinary:
+			if started {
+				return nil, errors.New("websocket: unexpected data frame inside fragmented message")
+			}
+			started = true
+			message = payload
+		case wsOpContinuation:
+			if !started {
+				return nil, errors.New("websocket: continuation without start frame")
+			}
+			message = append(message, payload...)
+		default:
+			return nil, fmt.Errorf("websocket: unknown opcode %d", op)
+		}
+
+		if len(message) > wsMaxMessageSize {
+			return nil, errors.New("websocket: message too large")
+		}
+		if fin {
+			return message, nil
+		}
+	}
+}
+
+// WriteJSON sends v as a single text frame
+func (c *wsConn) WriteJSON(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return c.writeFrame(wsOpText, data)
+}
+
+// SetReadDeadline sets the deadline for the next ReadMessage
+func (c *wsConn) SetReadDeadline(t time.Time) error {
+	return c.conn.SetReadDeadline(t)
+}
+
+// Close sends a close frame (best effort) and closes the underlying connection
+func (c *wsConn) Close() error {
+	c.wmu.Lock()
+	c.conn.SetWriteDeadline(time.Now().Add(time.Second))
+	_ = writeWSFrame(c.conn, wsOpClose, nil, true)
+	c.wmu.Unlock()
+	return c.conn.Close()
+}
+
+func (c *wsConn) writeFrame(op byte, payload []byte) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	c.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	return writeWSFrame(c.conn, op, payload, true)
+}
+
+// writeWSFrame writes a single final frame. Clients must mask, servers must not.
+func writeWSFrame(w io.Writer, op byte, payload []byte, masked bool) error {
+	header := make([]byte, 0, 14)
+	header = append(header, 0x80|op)
+
+	maskBit := byte(0)
+	if masked {
+		maskBit = 0x80
+	}
+
+	n := len(payload)
+	switch {
+	case n <= 125:
+		header = append(header, maskBit|byte(n))
+	case n <= 0xFFFF:
+		header = append(header, maskBit|126)
+		header = binary.BigEndian.AppendUint16(header, uint16(n))
+	default:
+		header = append(header, maskBit|127)
+		header = binary.BigEndian.AppendUint64(header, uint64(n))
+	}
+
+	data := payload
+	if masked {
+		var mask [4]byte
+		if _, err := io.ReadFull(rand.Reader, mask[:]); err != nil {
+			return err
+		}
+		header = append(header, mask[:]...)
+		data = make([]byte, n)
+		for i := range payload {
+			data[i] = payload[i] ^ mask[i%4]
+		}
+	}
+
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	_, err := w.Write(data)
+	return err
+}
+
+// readWSFrame reads a single frame, unmasking the payload when needed
+func readWSFrame(r *bufio.Reader) (fin bool, op byte, payload []byte, err error) {
+	var head [2]byte
+	if _, err = io.ReadFull(r, head[:]); err != nil {
+		return false, 0, nil, err
+	}
+	fin = head[0]&0x80 != 0
+	op = head[0] & 0x0F
+	masked := head[1]&0x80 != 0
+
+	length := uint64(head[1] & 0x7F)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err = io.ReadFull(r, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err = io.ReadFull(r, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+	if length > wsMaxMessageSize {
+		return false, 0, nil, errors.New("websocket: frame too large")
+	}
+
+	var mask [4]byte
+	if masked {
+		if _, err = io.ReadFull(r, mask[:]); err != nil {
+			return false, 0, nil, err
+		}
+	}
+
+	payload = make([]byte, length)
+	if _, err = io.ReadFull(r, payload); err != nil {
+		return false, 0, nil, err
+	}
+	if masked {
+		for i := range payload {
+			payload[i] ^= mask[i%4]
+		}
+	}
+	return fin, op, payload, nil
+}
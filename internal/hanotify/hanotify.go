@@ -8,6 +8,7 @@ const (
 	GuestAccessDenied    = "GuestAccessDenied"
 	AlarmTriggered       = "AlarmTriggered"
 	AlarmRearmed         = "AlarmRearmed"
+	DuressAlarm          = "DuressAlarm"
 )
 
 type Notifier interface {
@@ -99,17 +99,34 @@ func T(key string) string {
 	mu.RLock()
 	defer mu.RUnlock()
 
+	return lookup(currentLang, key)
+}
+
+// TLang translates a key to the given language without changing the current one.
+// Unknown languages and missing keys fall back like T.
+func TLang(lang, key string) string {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	if translations[lang] == nil {
+		lang = currentLang
+	}
+	return lookup(lang, key)
+}
+
+// lookup resolves key in lang, then English, then the key itself. Caller holds mu.
+func lookup(lang, key string) string {
 	if !initialized {
 		return key
 	}
 
-	// Try current language
-	if trans, ok := translations[currentLang][key]; ok {
+	// Try requested language
+	if trans, ok := translations[lang][key]; ok {
 		return trans
 	}
 
 	// Fallback to English
-	if currentLang != "en" {
+	if lang != "en" {
 		if trans, ok := translations["en"][key]; ok {
 			return trans
 		}
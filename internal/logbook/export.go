@@ -0,0 +1,253 @@
+package logbook
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"smartdisplay-core/internal/i18n"
+	"smartdisplay-core/internal/logger"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ExportFormat selects the output of an export
+type ExportFormat string
+
+const (
+	ExportCSV   ExportFormat = "csv"
+	ExportJSONL ExportFormat = "jsonl"
+	ExportHTML  ExportFormat = "html" // Printable report (browser "Save as PDF")
+)
+
+// exportTimeLayout is used for timestamps in CSV and HTML reports
+const exportTimeLayout = "2006-01-02 15:04:05"
+
+// ParseExportFormat validates a format name
+func ParseExportFormat(s string) (ExportFormat, bool) {
+	switch f := ExportFormat(s); f {
+	case ExportCSV, ExportJSONL, ExportHTML:
+		return f, true
+	}
+	return "", false
+}
+
+// ContentType returns the HTTP content type of the format
+func (f ExportFormat) ContentType() string {
+	switch f {
+	case ExportJSONL:
+		return "application/x-ndjson"
+	case ExportHTML:
+		return "text/html; charset=utf-8"
+	}
+	return "text/csv; charset=utf-8"
+}
+
+// Extension returns the file name extension of the format
+func (f ExportFormat) Extension() string {
+	return string(f)
+}
+
+// ExportOptions describes an export for report headers and localization
+type ExportOptions struct {
+	Lang        string // i18n language of labels; empty = current language
+	From        time.Time
+	To          time.Time
+	Category    string
+	GeneratedAt time.Time
+}
+
+// Export returns every entry matching q (ignoring Limit/Offset), oldest first
+func (m *LogbookManager) Export(q Query) []Entry {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	matched := m.match(q)
+	out := make([]Entry, 0, len(matched))
+	for i := len(matched) - 1; i >= 0; i-- {
+		out = append(out, matched[i])
+	}
+	logger.Info(fmt.Sprintf("logbook: export by role %s (count: %d)", q.Role, len(out)))
+	return out
+}
+
+// WriteExport writes entries in the given format
+func WriteExport(w io.Writer, format ExportFormat, entries []Entry, opts ExportOptions) error {
+	if opts.Lang == "" {
+		opts.Lang = i18n.GetLang()
+	}
+	if opts.GeneratedAt.IsZero() {
+		opts.GeneratedAt = time.Now()
+	}
+	switch format {
+	case ExportJSONL:
+		return writeJSONL(w, entries)
+	case ExportHTML:
+		return writeHTML(w, entries, opts)
+	}
+	return writeCSV(w, entries, opts)
+}
+
+// writeJSONL writes entries unlocalized, one JSON object per line
+func writeJSONL(w io.Writer, entries []Entry) error {
+	enc := json.NewEncoder(w)
+	for _, e := range entries {
+		if err := enc.Encode(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// exportColumns are the CSV/HTML columns, as i18n keys under logbook.export.col
+var exportColumns = []string{"time", "category", "type", "severity", "message", "user", "location", "device", "reason", "count"}
+
+// exportRow renders an entry as localized column values
+func exportRow(e Entry, lang string) []string {
+	device := e.Details.DeviceName
+	if e.Details.DeviceType != "" && device != "" {
+		device = e.Details.DeviceType + " " + device
+	}
+	return []string{
+		e.Timestamp.Local().Format(exportTimeLayout),
+		i18n.TLang(lang, "logbook.category."+string(e.Category)),
+		i18n.TLang(lang, "logbook.type."+string(e.Type)),
+		i18n.TLang(lang, "logbook.severity."+string(e.Severity)),
+		e.Message,
+		e.Details.UserID,
+		e.Details.Location,
+		device,
+		e.Details.Reason,
+		strconv.Itoa(e.GroupCount),
+	}
+}
+
+func exportHeader(lang string) []string {
+	header := make([]string, len(exportColumns))
+	for i, col := range exportColumns {
+		header[i] = i18n.TLang(lang, "logbook.export.col."+col)
+	}
+	return header
+}
+
+func writeCSV(w io.Writer, entries []Entry, opts ExportOptions) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(exportHeader(opts.Lang)); err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if err := cw.Write(csvSafe(exportRow(e, opts.Lang))); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// csvSafe keeps spreadsheets from running cells as formulas: usernames, device
+// names and reasons can be chosen by users, so a cell starting with =, +, -, @,
+// tab or CR gets a leading '
+func csvSafe(row []string) []string {
+	for i, cell := range row {
+		if cell != "" && strings.ContainsRune("=+-@\t\r", rune(cell[0])) {
+			row[i] = "'" + cell
+		}
+	}
+	return row
+}
+
+// htmlReport is the data of reportTemplate
+type htmlReport struct {
+	Lang      string
+	Title     string
+	Labels    map[string]string
+	Period    string
+	Category  string
+	Generated string
+	Count     int
+	Header    []string
+	Rows      []htmlRow
+}
+
+type htmlRow struct {
+	Severity string
+	Cells    []string
+}
+
+var reportTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html lang="{{.Lang}}">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: sans-serif; font-size: 11pt; margin: 2em; color: #222; }
+h1 { font-size: 16pt; margin-bottom: 0.2em; }
+dl { display: grid; grid-template-columns: max-content auto; gap: 0.2em 1em; margin: 1em 0; }
+dt { font-weight: bold; }
+table { width: 100%; border-collapse: collapse; font-size: 9pt; }
+th, td { border: 1px solid #999; padding: 3px 5px; text-align: left; vertical-align: top; }
+th { background: #eee; }
+tr.critical td { background: #fde0e0; }
+tr.warning td { background: #fff4d6; }
+thead { display: table-header-group; }
+tr { page-break-inside: avoid; }
+@page { size: A4 landscape; margin: 1.5cm; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<dl>
+<dt>{{index .Labels "range"}}</dt><dd>{{.Period}}</dd>
+<dt>{{index .Labels "category_filter"}}</dt><dd>{{.Category}}</dd>
+<dt>{{index .Labels "generated"}}</dt><dd>{{.Generated}}</dd>
+<dt>{{index .Labels "count"}}</dt><dd>{{.Count}}</dd>
+</dl>
+{{if .Rows}}<table>
+<thead><tr>{{range .Header}}<th>{{.}}</th>{{end}}</tr></thead>
+<tbody>
+{{range .Rows}}<tr class="{{.Severity}}">{{range .Cells}}<td>{{.}}</td>{{end}}</tr>
+{{end}}</tbody>
+</table>{{else}}<p>{{index .Labels "empty"}}</p>{{end}}
+</body>
+</html>
+`))
+
+func writeHTML(w io.Writer, entries []Entry, opts ExportOptions) error {
+	lang := opts.Lang
+	t := func(key string) string { return i18n.TLang(lang, "logbook.export."+key) }
+
+	from, to := "…", "…"
+	if !opts.From.IsZero() {
+		from = opts.From.Local().Format(exportTimeLayout)
+	}
+	if !opts.To.IsZero() {
+		to = opts.To.Local().Format(exportTimeLayout)
+	}
+	category := t("all")
+	if opts.Category != "" {
+		category = i18n.TLang(lang, "logbook.category."+opts.Category)
+	}
+
+	report := htmlReport{
+		Lang:  lang,
+		Title: t("title"),
+		Labels: map[string]string{
+			"range":           t("range"),
+			"category_filter": t("category_filter"),
+			"generated":       t("generated"),
+			"count":           t("count"),
+			"empty":           t("empty"),
+		},
+		Period:    from + " – " + to,
+		Category:  category,
+		Generated: opts.GeneratedAt.Local().Format(exportTimeLayout),
+		Count:     len(entries),
+		Header:    exportHeader(lang),
+	}
+	for _, e := range entries {
+		report.Rows = append(report.Rows, htmlRow{Severity: string(e.Severity), Cells: exportRow(e, lang)})
+	}
+	return reportTemplate.Execute(w, report)
+}
@@ -2,7 +2,11 @@ package logbook
 
 import (
 	"fmt"
+	"reflect"
 	"smartdisplay-core/internal/logger"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 )
 
@@ -27,6 +31,7 @@ const (
 	AlarmCountdownStarted   EntryType = "alarm_countdown_started"
 	AlarmCountdownCancelled EntryType = "alarm_countdown_cancelled"
 	AlarmAcknowledged       EntryType = "alarm_acknowledged"
+	AlarmCommandFailed      EntryType = "alarm_command_failed"
 
 	// Guest events
 	GuestRequested   EntryType = "guest_requested"
@@ -35,6 +40,9 @@ const (
 	GuestExpired     EntryType = "guest_expired"
 	GuestExited      EntryType = "guest_exited"
 	GuestAutoExpired EntryType = "guest_auto_expired"
+	GuestPassUsed    EntryType = "guest_pass_used"
+	GuestRearmCancel EntryType = "guest_rearm_cancelled"
+	GuestRearmFailed EntryType = "guest_rearm_failed"
 
 	// System events
 	SystemStarted   EntryType = "system_started"
@@ -51,6 +59,10 @@ const (
 	FailsafeRecovering  EntryType = "failsafe_recovering"
 	FailsafeRecovered   EntryType = "failsafe_recovered"
 	AlarmDuringFailsafe EntryType = "alarm_during_failsafe"
+
+	// Access events
+	PINOutOfSchedule EntryType = "pin_out_of_schedule"
+	DuressDisarm     EntryType = "duress_disarm"
 )
 
 // Severity represents the severity level of an entry
@@ -157,14 +169,35 @@ type SummaryEntry struct {
 	Category       EntryCategory `json:"category"`
 }
 
-// LogbookManager manages logbook entries
+// groupWindow is how long after the last occurrence a repeated event is still grouped
+const groupWindow = 10 * time.Minute
+
+// Query selects logbook entries; zero values match everything
+type Query struct {
+	Role     UserRole
+	Category string
+	Severity string
+	From     time.Time // Inclusive
+	To       time.Time // Inclusive
+	Search   string    // Case-insensitive; every word must appear in Message
+	Limit    int
+	Offset   int
+}
+
+// LogbookManager manages logbook entries.
+// Entries are kept oldest first with position indexes by category and
+// severity; after Open every entry is also appended to a JSON lines file.
 type LogbookManager struct {
-	entries             []Entry
-	retentionDays       int // for normal entries
-	retentionSafetyDays int // for safety events
+	mu                  sync.Mutex
+	entries             []Entry                 // Oldest first
+	byCategory          map[EntryCategory][]int // Positions in entries, ascending
+	bySeverity          map[Severity][]int      // Positions in entries, ascending
+	retentionDays       int                     // for normal entries
+	retentionSafetyDays int                     // for safety events
 	entryIDCounter      int64
 	lastGroupCheck      time.Time
 	userRole            UserRole
+	store               *fileStore // nil = memory only
 }
 
 // NewLogbookManager creates a new LogbookManager
@@ -177,6 +210,8 @@ func NewLogbookManager(retentionDays, retentionSafetyDays int) *LogbookManager {
 	}
 	mgr := &LogbookManager{
 		entries:             make([]Entry, 0),
+		byCategory:          make(map[EntryCategory][]int),
+		bySeverity:          make(map[Severity][]int),
 		retentionDays:       retentionDays,
 		retentionSafetyDays: retentionSafetyDays,
 		entryIDCounter:      1000,
@@ -188,16 +223,95 @@ func NewLogbookManager(retentionDays, retentionSafetyDays int) *LogbookManager {
 	return mgr
 }
 
+// Open loads the logbook file in dir and persists every later entry there.
+// Entries recorded before Open are kept and written to the file.
+func (m *LogbookManager) Open(dir string) error {
+	store, loaded, err := openFileStore(dir)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, e := range loaded {
+		if id := entryNumber(e.ID); id > m.entryIDCounter {
+			m.entryIDCounter = id
+		}
+	}
+	pending := m.entries
+	for i := range pending {
+		m.entryIDCounter++
+		pending[i].ID = fmt.Sprintf("entry_%d", m.entryIDCounter)
+	}
+
+	all := append(loaded, pending...)
+	sort.SliceStable(all, func(i, j int) bool { return all[i].Timestamp.Before(all[j].Timestamp) })
+	m.entries = all
+	m.store = store
+	m.reindex()
+
+	// Expired entries and pre-Open entries go through a rewrite
+	if removed := m.cleanupOldEntries(); removed > 0 || len(pending) > 0 {
+		if err := m.store.compact(m.entries); err != nil {
+			logger.Error("logbook: compact failed: " + err.Error())
+		}
+	}
+	logger.Info(fmt.Sprintf("logbook: persistent store at %s (entries: %d)", dir, len(m.entries)))
+	return nil
+}
+
+// Close closes the logbook file; the manager stays usable in memory
+func (m *LogbookManager) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.store == nil {
+		return nil
+	}
+	err := m.store.close()
+	m.store = nil
+	return err
+}
+
 // SetUserRole sets the current user role (for role-based filtering)
 func (m *LogbookManager) SetUserRole(role UserRole) {
+	m.mu.Lock()
 	m.userRole = role
+	m.mu.Unlock()
 	logger.Info(fmt.Sprintf("logbook: user role set to %s", role))
 }
 
 // AddEntry adds a new entry to the logbook
 func (m *LogbookManager) AddEntry(category EntryCategory, entryType EntryType, severity Severity,
 	message string, context string, details EntryDetail, visibleToRole UserRole) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	now := time.Now()
+
+	// An identical repeat of the latest entry in the category is folded into it
+	if positions := m.byCategory[category]; len(positions) > 0 {
+		last := &m.entries[positions[len(positions)-1]]
+		lastSeen := last.Timestamp
+		if last.GroupedAt != nil {
+			lastSeen = *last.GroupedAt
+		}
+		if last.Type == entryType && last.Message == message && last.Context == context &&
+			reflect.DeepEqual(last.Details, details) && now.Sub(lastSeen) <= groupWindow {
+			last.Grouped = true
+			last.GroupCount++
+			last.GroupedAt = &now
+			if m.store != nil {
+				m.store.stale++
+				if err := m.store.append(*last); err != nil {
+					logger.Error("logbook: persist failed: " + err.Error())
+				}
+			}
+			logger.Info(fmt.Sprintf("logbook: entry grouped (type: %s, count: %d)", entryType, last.GroupCount))
+			return
+		}
+	}
+
 	m.entryIDCounter++
 	entry := Entry{
 		ID:             fmt.Sprintf("entry_%d", m.entryIDCounter),
@@ -215,17 +329,41 @@ func (m *LogbookManager) AddEntry(category EntryCategory, entryType EntryType, s
 		VisibleToRole:  visibleToRole,
 	}
 
-	// Add to entries (prepend for newest first)
-	m.entries = append([]Entry{entry}, m.entries...)
+	pos := len(m.entries)
+	m.entries = append(m.entries, entry)
+	m.byCategory[category] = append(m.byCategory[category], pos)
+	m.bySeverity[severity] = append(m.bySeverity[severity], pos)
+
+	if m.store != nil {
+		if err := m.store.append(entry); err != nil {
+			logger.Error("logbook: persist failed: " + err.Error())
+		}
+	}
 
 	// Clean up old entries
-	m.cleanupOldEntries()
+	if removed := m.cleanupOldEntries(); removed > 0 && m.store != nil {
+		m.store.stale += removed
+		if m.store.needsCompaction(len(m.entries)) {
+			if err := m.store.compact(m.entries); err != nil {
+				logger.Error("logbook: compact failed: " + err.Error())
+			}
+		}
+	}
 
 	logger.Info(fmt.Sprintf("logbook: entry created (category: %s, severity: %s)", category, severity))
 }
 
 // GetEntries returns logbook entries with optional filtering
 func (m *LogbookManager) GetEntries(userRole UserRole, limit, offset int, categoryFilter string) LogbookResponse {
+	return m.Search(Query{Role: userRole, Category: categoryFilter, Limit: limit, Offset: offset})
+}
+
+// Search returns entries matching q, newest first, with pagination and metadata
+func (m *LogbookManager) Search(q Query) LogbookResponse {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	limit, offset := q.Limit, q.Offset
 	if limit <= 0 || limit > 100 {
 		limit = 20
 	}
@@ -233,19 +371,7 @@ func (m *LogbookManager) GetEntries(userRole UserRole, limit, offset int, catego
 		offset = 0
 	}
 
-	// Filter by role visibility
-	filtered := m.filterByRole(userRole)
-
-	// Filter by category if specified
-	if categoryFilter != "" {
-		var categoryFiltered []Entry
-		for _, e := range filtered {
-			if string(e.Category) == categoryFilter {
-				categoryFiltered = append(categoryFiltered, e)
-			}
-		}
-		filtered = categoryFiltered
-	}
+	filtered := m.match(q)
 
 	// Calculate pagination
 	total := len(filtered)
@@ -254,13 +380,14 @@ func (m *LogbookManager) GetEntries(userRole UserRole, limit, offset int, catego
 		offset = total
 	}
 
-	var entries []Entry
 	end := offset + limit
 	if end > total {
 		end = total
 	}
-	if offset < total {
-		entries = filtered[offset:end]
+	entries := make([]Entry, 0, end-offset)
+	for _, e := range filtered[offset:end] {
+		e.TimestampLocal = formatTimestamp(e.Timestamp) // Relative to now, not to creation
+		entries = append(entries, e)
 	}
 
 	// Build category counts
@@ -275,7 +402,14 @@ func (m *LogbookManager) GetEntries(userRole UserRole, limit, offset int, catego
 	hasCritical := last24h.Critical > 0
 
 	now := time.Now()
-	retentionStart := now.AddDate(0, 0, -m.retentionDays)
+	rangeStart := now.AddDate(0, 0, -m.retentionDays)
+	rangeEnd := now
+	if !q.From.IsZero() && q.From.After(rangeStart) {
+		rangeStart = q.From
+	}
+	if !q.To.IsZero() && q.To.Before(rangeEnd) {
+		rangeEnd = q.To
+	}
 
 	response := LogbookResponse{
 		Entries: entries,
@@ -286,8 +420,8 @@ func (m *LogbookManager) GetEntries(userRole UserRole, limit, offset int, catego
 			HasMore: hasMore,
 		},
 		Metadata: MetadataInfo{
-			DateRangeStart:    &retentionStart,
-			DateRangeEnd:      &now,
+			DateRangeStart:    &rangeStart,
+			DateRangeEnd:      &rangeEnd,
 			CategoryCounts:    categoryCounts,
 			Last24Hours:       &last24h,
 			HasCriticalEvents: hasCritical,
@@ -295,31 +429,84 @@ func (m *LogbookManager) GetEntries(userRole UserRole, limit, offset int, catego
 	}
 
 	logger.Info(fmt.Sprintf("logbook: entries retrieved by role %s (count: %d, filtered)",
-		userRole, len(entries)))
+		q.Role, len(entries)))
 
 	return response
 }
 
+// match returns the entries matching q, newest first.
+// Category/severity narrow the scan through their index, the time range through binary search.
+func (m *LogbookManager) match(q Query) []Entry {
+	var positions []int
+	switch {
+	case q.Category != "":
+		positions = m.byCategory[EntryCategory(q.Category)]
+	case q.Severity != "":
+		positions = m.bySeverity[Severity(q.Severity)]
+	default:
+		positions = make([]int, len(m.entries))
+		for i := range positions {
+			positions[i] = i
+		}
+	}
+
+	lo, hi := 0, len(positions)
+	if !q.From.IsZero() {
+		lo = sort.Search(len(positions), func(i int) bool {
+			return !m.entries[positions[i]].Timestamp.Before(q.From)
+		})
+	}
+	if !q.To.IsZero() {
+		hi = sort.Search(len(positions), func(i int) bool {
+			return m.entries[positions[i]].Timestamp.After(q.To)
+		})
+	}
+
+	terms := strings.Fields(strings.ToLower(q.Search))
+	var out []Entry
+	for i := hi - 1; i >= lo; i-- {
+		e := m.entries[positions[i]]
+		if !canView(q.Role, e.VisibleToRole, e.Category) {
+			continue
+		}
+		if q.Severity != "" && string(e.Severity) != q.Severity {
+			continue
+		}
+		if !containsAll(strings.ToLower(e.Message), terms) {
+			continue
+		}
+		out = append(out, e)
+	}
+	return out
+}
+
+func containsAll(text string, terms []string) bool {
+	for _, t := range terms {
+		if !strings.Contains(text, t) {
+			return false
+		}
+	}
+	return true
+}
+
 // GetSummary returns recent entries for dashboard view
 func (m *LogbookManager) GetSummary(userRole UserRole, limit int) LogbookSummaryResponse {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	if limit <= 0 || limit > 20 {
 		limit = 5
 	}
 
-	// Filter by role visibility
-	filtered := m.filterByRole(userRole)
-
-	// Get most recent entries
-	var recent []Entry
-	for i := 0; i < len(filtered) && i < limit; i++ {
-		recent = append(recent, filtered[i])
-	}
-
-	// Convert to summary entries
+	// Get most recent entries visible to the role
 	var summaryEntries []SummaryEntry
-	for _, e := range recent {
+	for i := len(m.entries) - 1; i >= 0 && len(summaryEntries) < limit; i-- {
+		e := m.entries[i]
+		if !canView(userRole, e.VisibleToRole, e.Category) {
+			continue
+		}
 		summaryEntries = append(summaryEntries, SummaryEntry{
-			TimestampLocal: e.TimestampLocal,
+			TimestampLocal: formatTimestamp(e.Timestamp),
 			Message:        e.Message,
 			Severity:       e.Severity,
 			Category:       e.Category,
@@ -341,17 +528,6 @@ func (m *LogbookManager) GetSummary(userRole UserRole, limit int) LogbookSummary
 	return response
 }
 
-// filterByRole returns entries visible to the given role
-func (m *LogbookManager) filterByRole(role UserRole) []Entry {
-	var filtered []Entry
-	for _, e := range m.entries {
-		if canView(role, e.VisibleToRole, e.Category) {
-			filtered = append(filtered, e)
-		}
-	}
-	return filtered
-}
-
 // canView determines if a role can view an entry
 func canView(userRole UserRole, entryVisibility UserRole, category EntryCategory) bool {
 	switch userRole {
@@ -370,8 +546,18 @@ func canView(userRole UserRole, entryVisibility UserRole, category EntryCategory
 	return false
 }
 
-// cleanupOldEntries removes entries older than retention period
-func (m *LogbookManager) cleanupOldEntries() {
+// reindex rebuilds the category and severity indexes
+func (m *LogbookManager) reindex() {
+	m.byCategory = make(map[EntryCategory][]int)
+	m.bySeverity = make(map[Severity][]int)
+	for i, e := range m.entries {
+		m.byCategory[e.Category] = append(m.byCategory[e.Category], i)
+		m.bySeverity[e.Severity] = append(m.bySeverity[e.Severity], i)
+	}
+}
+
+// cleanupOldEntries removes entries older than retention period and returns how many
+func (m *LogbookManager) cleanupOldEntries() int {
 	now := time.Now()
 	var retained []Entry
 
@@ -386,11 +572,13 @@ func (m *LogbookManager) cleanupOldEntries() {
 		}
 	}
 
-	if len(retained) < len(m.entries) {
-		removed := len(m.entries) - len(retained)
+	removed := len(m.entries) - len(retained)
+	if removed > 0 {
 		logger.Info(fmt.Sprintf("logbook: old entries archived (count: %d, days: %d)", removed, m.retentionDays))
 		m.entries = retained
+		m.reindex()
 	}
+	return removed
 }
 
 // getSeverityCounts returns severity counts within a time window
@@ -398,17 +586,19 @@ func (m *LogbookManager) getSeverityCounts(window time.Duration) SeverityCount {
 	cutoff := time.Now().Add(-window)
 	count := SeverityCount{}
 
-	for _, e := range m.entries {
-		if e.Timestamp.After(cutoff) {
-			count.Total++
-			switch e.Severity {
-			case SeverityCritical:
-				count.Critical++
-			case SeverityWarning:
-				count.Warning++
-			case SeverityInfo:
-				count.Info++
-			}
+	for i := len(m.entries) - 1; i >= 0; i-- {
+		e := m.entries[i]
+		if !e.Timestamp.After(cutoff) {
+			break // Oldest first: everything before is outside the window
+		}
+		count.Total++
+		switch e.Severity {
+		case SeverityCritical:
+			count.Critical++
+		case SeverityWarning:
+			count.Warning++
+		case SeverityInfo:
+			count.Info++
 		}
 	}
 
@@ -0,0 +1,232 @@
+package logbook
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"smartdisplay-core/internal/logger"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMain(m *testing.M) {
+	os.MkdirAll("logs", 0755)
+	logger.Init()
+	os.Exit(m.Run())
+}
+
+// writeLogbook seeds dir with entries as if written by an earlier run
+func writeLogbook(t *testing.T, dir string, entries ...Entry) {
+	t.Helper()
+	f, err := os.Create(filepath.Join(dir, logbookFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	enc := json.NewEncoder(f)
+	for _, e := range entries {
+		enc.Encode(e)
+	}
+}
+
+func TestLogbookSurvivesRestart(t *testing.T) {
+	dir := t.TempDir()
+	m := NewLogbookManager(30, 90)
+	m.AddEntry(CategorySystem, SystemStarted, SeverityInfo, "before open", "", EntryDetail{}, RoleUser)
+	if err := m.Open(dir); err != nil {
+		t.Fatal(err)
+	}
+	m.AddEntry(CategoryAlarm, AlarmArmed, SeverityInfo, "Alarm armed", "", EntryDetail{UserID: "ayse"}, RoleUser)
+	m.Close()
+
+	reopened := NewLogbookManager(30, 90)
+	if err := reopened.Open(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	reopened.AddEntry(CategoryAlarm, AlarmDisarmed, SeverityInfo, "Alarm disarmed", "", EntryDetail{}, RoleUser)
+
+	resp := reopened.GetEntries(RoleAdmin, 10, 0, "")
+	if resp.Pagination.Total != 3 {
+		t.Fatalf("total = %d, want 3", resp.Pagination.Total)
+	}
+	ids := map[string]bool{}
+	for _, e := range resp.Entries {
+		ids[e.ID] = true
+	}
+	if len(ids) != 3 {
+		t.Errorf("IDs not unique after restart: %v", ids)
+	}
+	if resp.Entries[0].Message != "Alarm disarmed" || resp.Entries[1].Details.UserID != "ayse" {
+		t.Errorf("unexpected order or details: %+v", resp.Entries)
+	}
+}
+
+func TestLogbookQueryIndexes(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	at := func(ago time.Duration) time.Time { return now.Add(-ago) }
+	writeLogbook(t, dir,
+		Entry{ID: "entry_1", Timestamp: at(40 * 24 * time.Hour), Category: CategoryAlarm, Type: AlarmArmed, Severity: SeverityInfo, Message: "expired", VisibleToRole: RoleUser},
+		Entry{ID: "entry_2", Timestamp: at(50 * time.Hour), Category: CategoryAlarm, Type: AlarmTriggered, Severity: SeverityCritical, Message: "Alarm triggered by front door", VisibleToRole: RoleUser},
+		Entry{ID: "entry_3", Timestamp: at(30 * time.Hour), Category: CategoryGuest, Type: GuestApproved, Severity: SeverityInfo, Message: "Guest approved", VisibleToRole: RoleUser},
+		Entry{ID: "entry_4", Timestamp: at(3 * time.Hour), Category: CategorySafety, Type: FailsafeActivated, Severity: SeverityCritical, Message: "Failsafe: front door sensor offline", VisibleToRole: RoleAdmin},
+		Entry{ID: "entry_5", Timestamp: at(time.Hour), Category: CategoryAlarm, Type: AlarmDisarmed, Severity: SeverityInfo, Message: "Alarm disarmed", VisibleToRole: RoleUser},
+	)
+
+	m := NewLogbookManager(30, 90)
+	if err := m.Open(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	ids := func(q Query) []string {
+		var out []string
+		for _, e := range m.Search(q).Entries {
+			out = append(out, e.ID)
+		}
+		return out
+	}
+	for _, tc := range []struct {
+		name string
+		q    Query
+		want []string
+	}{
+		{"retention drops expired", Query{Role: RoleAdmin}, []string{"entry_5", "entry_4", "entry_3", "entry_2"}},
+		{"category", Query{Role: RoleAdmin, Category: "alarm"}, []string{"entry_5", "entry_2"}},
+		{"severity", Query{Role: RoleAdmin, Severity: "critical"}, []string{"entry_4", "entry_2"}},
+		{"category and severity", Query{Role: RoleAdmin, Category: "alarm", Severity: "critical"}, []string{"entry_2"}},
+		{"range", Query{Role: RoleAdmin, From: at(36 * time.Hour), To: at(2 * time.Hour)}, []string{"entry_4", "entry_3"}},
+		{"search", Query{Role: RoleAdmin, Search: "FRONT door"}, []string{"entry_4", "entry_2"}},
+		{"role filter", Query{Role: RoleUser, Search: "front door"}, []string{"entry_2"}},
+		{"pagination", Query{Role: RoleAdmin, Limit: 2, Offset: 1}, []string{"entry_4", "entry_3"}},
+	} {
+		got := ids(tc.q)
+		if len(got) != len(tc.want) {
+			t.Errorf("%s: got %v, want %v", tc.name, got, tc.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tc.want[i] {
+				t.Errorf("%s: got %v, want %v", tc.name, got, tc.want)
+				break
+			}
+		}
+	}
+
+	// The expired entry was compacted out of the file
+	reloaded, _, err := readLogbookFile(filepath.Join(dir, logbookFile))
+	if err != nil || len(reloaded) != 4 {
+		t.Fatalf("file holds %d entries (%v), want 4", len(reloaded), err)
+	}
+}
+
+func TestLogbookGroupsRepeats(t *testing.T) {
+	dir := t.TempDir()
+	m := NewLogbookManager(30, 90)
+	if err := m.Open(dir); err != nil {
+		t.Fatal(err)
+	}
+	offline := EntryDetail{DeviceName: "fan_1", DeviceType: "fan"}
+	m.AddEntry(CategorySystem, DeviceOffline, SeverityWarning, "Device offline: fan fan_1", "", offline, RoleAdmin)
+	m.AddEntry(CategorySystem, DeviceOffline, SeverityWarning, "Device offline: fan fan_1", "", offline, RoleAdmin)
+	m.AddEntry(CategorySystem, DeviceOffline, SeverityWarning, "Device offline: fan fan_1", "", offline, RoleAdmin)
+	// Different details are not a repeat
+	m.AddEntry(CategoryGuest, GuestRequested, SeverityInfo, "Guest access requested", "", EntryDetail{GuestID: "req_1"}, RoleUser)
+	m.AddEntry(CategoryGuest, GuestRequested, SeverityInfo, "Guest access requested", "", EntryDetail{GuestID: "req_2"}, RoleUser)
+	m.Close()
+
+	reopened := NewLogbookManager(30, 90)
+	if err := reopened.Open(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+
+	resp := reopened.Search(Query{Role: RoleAdmin, Category: "system"})
+	if resp.Pagination.Total != 1 {
+		t.Fatalf("system entries = %d, want 1 grouped", resp.Pagination.Total)
+	}
+	if e := resp.Entries[0]; !e.Grouped || e.GroupCount != 3 || e.GroupedAt == nil {
+		t.Errorf("grouped entry = %+v", e)
+	}
+	if got := reopened.Search(Query{Role: RoleAdmin, Category: "guest"}).Pagination.Total; got != 2 {
+		t.Errorf("guest entries = %d, want 2", got)
+	}
+}
+
+func TestLogbookExport(t *testing.T) {
+	m := NewLogbookManager(30, 90)
+	m.AddEntry(CategoryAlarm, AlarmArmed, SeverityInfo, "Alarm armed (away)", "", EntryDetail{UserID: "ayse"}, RoleUser)
+	m.AddEntry(CategorySafety, FailsafeActivated, SeverityCritical, "Failsafe mode activated", "", EntryDetail{}, RoleAdmin)
+	m.AddEntry(CategoryAlarm, AlarmTriggered, SeverityCritical, "Alarm triggered <front door>", "", EntryDetail{Reason: "TRIGGER"}, RoleUser)
+
+	// Users never see safety entries; the export is oldest first
+	entries := m.Export(Query{Role: RoleUser, Limit: 1})
+	if len(entries) != 2 || entries[0].Type != AlarmArmed || entries[1].Type != AlarmTriggered {
+		t.Fatalf("export = %+v", entries)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteExport(&buf, ExportCSV, entries, ExportOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := csv.NewReader(&buf).ReadAll()
+	if err != nil || len(rows) != 3 || len(rows[0]) != len(exportColumns) || rows[1][5] != "ayse" {
+		t.Fatalf("csv rows = %v (%v)", rows, err)
+	}
+
+	buf.Reset()
+	if err := WriteExport(&buf, ExportJSONL, entries, ExportOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	var last Entry
+	if len(lines) != 2 || json.Unmarshal([]byte(lines[1]), &last) != nil || last.Details.Reason != "TRIGGER" {
+		t.Fatalf("jsonl = %q", buf.String())
+	}
+
+	buf.Reset()
+	if err := WriteExport(&buf, ExportHTML, entries, ExportOptions{Lang: "en", Category: "alarm"}); err != nil {
+		t.Fatal(err)
+	}
+	html := buf.String()
+	if !strings.Contains(html, "&lt;front door&gt;") || !strings.Contains(html, `<tr class="critical">`) {
+		t.Errorf("html report not escaped or missing rows:\n%s", html)
+	}
+}
+
+func TestLogbookCSVEscapesFormulas(t *testing.T) {
+	m := NewLogbookManager(30, 90)
+	m.AddEntry(CategorySystem, DeviceOffline, SeverityWarning, `=HYPERLINK("http://x","fan")`, "", EntryDetail{UserID: "@ayse", Reason: "-1+1"}, RoleUser)
+	entries := m.Export(Query{Role: RoleUser})
+
+	var buf bytes.Buffer
+	if err := WriteExport(&buf, ExportCSV, entries, ExportOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := csv.NewReader(&buf).ReadAll()
+	if err != nil || len(rows) != 2 {
+		t.Fatalf("csv rows = %v (%v)", rows, err)
+	}
+	for _, want := range []string{`'=HYPERLINK("http://x","fan")`, "'@ayse", "'-1+1"} {
+		found := false
+		for _, cell := range rows[1] {
+			found = found || cell == want
+		}
+		if !found {
+			t.Errorf("csv row %q has no cell %q", rows[1], want)
+		}
+	}
+
+	// Only the CSV export is changed
+	buf.Reset()
+	if err := WriteExport(&buf, ExportJSONL, entries, ExportOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), `"@ayse"`) {
+		t.Errorf("jsonl = %q", buf.String())
+	}
+}
@@ -0,0 +1,157 @@
+package logbook
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"smartdisplay-core/internal/logger"
+	"strconv"
+	"strings"
+)
+
+const (
+	logbookFile      = "logbook.jsonl"
+	compactMinStale  = 200 // Dropped entries that trigger a rewrite of the file
+	maxEntryLineSize = 1 << 20
+)
+
+// fileStore appends entries as JSON lines. Grouping appends a newer version of an
+// entry under the same ID; superseded versions and entries dropped by retention stay
+// in the file until compact rewrites it.
+type fileStore struct {
+	path  string
+	file  *os.File
+	stale int
+}
+
+// openFileStore opens the logbook file in dir and returns its entries, oldest first
+func openFileStore(dir string) (*fileStore, []Entry, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, nil, err
+	}
+	path := filepath.Join(dir, logbookFile)
+	entries, stale, err := readLogbookFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &fileStore{path: path, file: f, stale: stale}, entries, nil
+}
+
+// readLogbookFile returns the latest version of every entry in file order and
+// the number of superseded lines
+func readLogbookFile(path string) ([]Entry, int, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, 0, nil
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	defer f.Close()
+
+	var entries []Entry
+	seen := make(map[string]int)
+	stale := 0
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 0, 64*1024), maxEntryLineSize)
+	line := 0
+	for sc.Scan() {
+		line++
+		if len(sc.Bytes()) == 0 {
+			continue
+		}
+		var e Entry
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			// A torn last write after a crash; skip the line
+			logger.Error("logbook: skipping unreadable line " + strconv.Itoa(line) + " in " + path)
+			continue
+		}
+		if i, ok := seen[e.ID]; ok {
+			entries[i] = e // Regrouped entry
+			stale++
+			continue
+		}
+		seen[e.ID] = len(entries)
+		entries = append(entries, e)
+	}
+	return entries, stale, sc.Err()
+}
+
+func (s *fileStore) append(e Entry) error {
+	if s.file == nil {
+		return os.ErrClosed
+	}
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	_, err = s.file.Write(append(line, '\n'))
+	return err
+}
+
+func (s *fileStore) needsCompaction(retained int) bool {
+	return s.stale >= compactMinStale || s.stale > retained
+}
+
+// compact atomically replaces the file with the retained entries
+func (s *fileStore) compact(entries []Entry) error {
+	tmp := s.path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	for _, e := range entries {
+		if err := enc.Encode(e); err != nil {
+			f.Close()
+			os.Remove(tmp)
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	f.Close()
+
+	if err := s.file.Close(); err != nil {
+		logger.Error("logbook: close before compact: " + err.Error())
+	}
+	renameErr := os.Rename(tmp, s.path)
+	// Reopen either way so appends continue on whichever file is in place
+	s.file, err = os.OpenFile(s.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if renameErr != nil {
+		os.Remove(tmp)
+		return renameErr
+	}
+	if err != nil {
+		return err
+	}
+	s.stale = 0
+	return nil
+}
+
+func (s *fileStore) close() error {
+	return s.file.Close()
+}
+
+// entryNumber extracts N from an "entry_N" ID (0 if malformed)
+func entryNumber(id string) int64 {
+	n, err := strconv.ParseInt(strings.TrimPrefix(id, "entry_"), 10, 64)
+	if err != nil {
+		return 0
+	}
+	return n
+}
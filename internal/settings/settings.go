@@ -210,11 +210,28 @@ func NewSettingsManager(
 			"alarm_entry_delay_s":         30,
 			"alarm_exit_delay_s":          30,
 			"alarm_arm_delay_s":           30,
+			"alarm_command_timeout_s":     30,
 			"alarm_trigger_sound_enabled": true,
 			"guest_max_active":            1,
 			"guest_request_timeout_s":     300,
 			"guest_max_requests_per_hour": 10,
+			"guest_rearm_delay_s":         60,
 			"force_ha_connection":         true,
+			"session_idle_timeout_s":      900,
+			"session_max_age_s":           43200,
+
+			// Per-mode delays; -1 = use alarm_exit_delay_s / alarm_entry_delay_s
+			"alarm_exit_delay_home_s":      -1,
+			"alarm_exit_delay_away_s":      -1,
+			"alarm_exit_delay_night_s":     -1,
+			"alarm_exit_delay_vacation_s":  -1,
+			"alarm_entry_delay_home_s":     -1,
+			"alarm_entry_delay_away_s":     -1,
+			"alarm_entry_delay_night_s":    -1,
+			"alarm_entry_delay_vacation_s": -1,
+
+			// User whose Alarmo code is sent when the system arms or disarms for guests
+			"guest_alarmo_user": "",
 		},
 
 		systemSettings: map[string]interface{}{
@@ -565,6 +582,14 @@ func (sm *SettingsManager) buildSecuritySection() *SectionResponse {
 				MinValue:       intPtr(0),
 				MaxValue:       intPtr(600),
 			},
+			sm.modeDelayField("exit", "home"),
+			sm.modeDelayField("exit", "away"),
+			sm.modeDelayField("exit", "night"),
+			sm.modeDelayField("exit", "vacation"),
+			sm.modeDelayField("entry", "home"),
+			sm.modeDelayField("entry", "away"),
+			sm.modeDelayField("entry", "night"),
+			sm.modeDelayField("entry", "vacation"),
 			{
 				ID:             "alarm_arm_delay_s",
 				Section:        SectionSecurity,
@@ -576,6 +601,17 @@ func (sm *SettingsManager) buildSecuritySection() *SectionResponse {
 				MinValue:       intPtr(10),
 				MaxValue:       intPtr(300),
 			},
+			{
+				ID:             "alarm_command_timeout_s",
+				Section:        SectionSecurity,
+				Type:           TypeInteger,
+				Value:          sm.securitySettings["alarm_command_timeout_s"],
+				DefaultValue:   30,
+				Help:           "Seconds to wait for Alarmo to confirm an arm/disarm command before reporting it failed",
+				RequireConfirm: false,
+				MinValue:       intPtr(5),
+				MaxValue:       intPtr(300),
+			},
 			{
 				ID:             "alarm_trigger_sound_enabled",
 				Section:        SectionSecurity,
@@ -620,6 +656,27 @@ func (sm *SettingsManager) buildSecuritySection() *SectionResponse {
 				MinValue:       intPtr(1),
 				MaxValue:       intPtr(100),
 			},
+			{
+				ID:             "guest_rearm_delay_s",
+				Section:        SectionSecurity,
+				Type:           TypeInteger,
+				Value:          sm.securitySettings["guest_rearm_delay_s"],
+				DefaultValue:   60,
+				Help:           "Seconds after the last guest leaves before the alarm is armed again (can be cancelled on the display)",
+				RequireConfirm: false,
+				MinValue:       intPtr(10),
+				MaxValue:       intPtr(600),
+			},
+			{
+				ID:             "guest_alarmo_user",
+				Section:        SectionSecurity,
+				Type:           TypeString,
+				Value:          sm.securitySettings["guest_alarmo_user"],
+				DefaultValue:   "",
+				Help:           "User whose Alarmo code is sent when the alarm is disarmed for an approved guest and re-armed after guests (empty = no code)",
+				Warning:        "Alarmo records these actions under this user; panels that require a code refuse them while it is empty",
+				RequireConfirm: true,
+			},
 			{
 				ID:             "force_ha_connection",
 				Section:        SectionSecurity,
@@ -630,10 +687,49 @@ func (sm *SettingsManager) buildSecuritySection() *SectionResponse {
 				Warning:        "SmartDisplay will stop working if Home Assistant becomes unavailable and this is enabled",
 				RequireConfirm: true,
 			},
+			{
+				ID:             "session_idle_timeout_s",
+				Section:        SectionSecurity,
+				Type:           TypeInteger,
+				Value:          sm.securitySettings["session_idle_timeout_s"],
+				DefaultValue:   900,
+				Help:           "Seconds without activity before a login session ends and the PIN is asked again",
+				RequireConfirm: false,
+				MinValue:       intPtr(60),
+				MaxValue:       intPtr(86400),
+			},
+			{
+				ID:             "session_max_age_s",
+				Section:        SectionSecurity,
+				Type:           TypeInteger,
+				Value:          sm.securitySettings["session_max_age_s"],
+				DefaultValue:   43200,
+				Help:           "Maximum lifetime of a login session in seconds, even when in use",
+				RequireConfirm: false,
+				MinValue:       intPtr(300),
+				MaxValue:       intPtr(604800),
+			},
 		},
 	}
 }
 
+// modeDelayField describes alarm_<kind>_delay_<mode>_s, an exit or entry delay for
+// one arm mode that overrides the shared alarm_<kind>_delay_s
+func (sm *SettingsManager) modeDelayField(kind, mode string) SettingsField {
+	id := "alarm_" + kind + "_delay_" + mode + "_s"
+	return SettingsField{
+		ID:             id,
+		Section:        SectionSecurity,
+		Type:           TypeInteger,
+		Value:          sm.securitySettings[id],
+		DefaultValue:   -1,
+		Help:           "Seconds of " + kind + " delay when armed " + mode + " (-1 = use alarm_" + kind + "_delay_s)",
+		RequireConfirm: false,
+		MinValue:       intPtr(-1),
+		MaxValue:       intPtr(600),
+	}
+}
+
 func (sm *SettingsManager) buildSystemSection() *SectionResponse {
 	return &SectionResponse{
 		Title:       "System",
@@ -769,6 +865,31 @@ func (sm *SettingsManager) settingRequiresRestart(fieldID string) bool {
 	return restartFields[fieldID]
 }
 
+// SecurityInt returns an integer security setting for internal consumers (no role check).
+// Values changed via the API arrive as float64 (JSON numbers); def is returned when unset/invalid.
+func (sm *SettingsManager) SecurityInt(fieldID string, def int) int {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+	switch v := sm.securitySettings[fieldID].(type) {
+	case int:
+		return v
+	case float64:
+		return int(v)
+	}
+	return def
+}
+
+// SecurityString returns a string security setting for internal consumers (no role check);
+// def is returned when unset or not a string.
+func (sm *SettingsManager) SecurityString(fieldID string, def string) string {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+	if v, ok := sm.securitySettings[fieldID].(string); ok {
+		return v
+	}
+	return def
+}
+
 // Helper function to create int pointer
 func intPtr(i int) *int {
 	return &i
@@ -793,13 +914,42 @@ func (sm *SettingsManager) ValidateSettings() error {
 		return errors.New("invalid alarm_exit_delay_s")
 	}
 
+	for _, kind := range []string{"exit", "entry"} {
+		for _, mode := range []string{"home", "away", "night", "vacation"} {
+			id := "alarm_" + kind + "_delay_" + mode + "_s"
+			if delay, ok := sm.securitySettings[id].(int); !ok || delay < -1 || delay > 600 {
+				return errors.New("invalid " + id)
+			}
+		}
+	}
+
 	if armDelay, ok := sm.securitySettings["alarm_arm_delay_s"].(int); !ok || armDelay < 10 || armDelay > 300 {
 		return errors.New("invalid alarm_arm_delay_s")
 	}
 
+	if cmdTimeout, ok := sm.securitySettings["alarm_command_timeout_s"].(int); !ok || cmdTimeout < 5 || cmdTimeout > 300 {
+		return errors.New("invalid alarm_command_timeout_s")
+	}
+
 	if guestMax, ok := sm.securitySettings["guest_max_active"].(int); !ok || guestMax < 1 || guestMax > 10 {
 		return errors.New("invalid guest_max_active")
 	}
 
+	if rearmDelay, ok := sm.securitySettings["guest_rearm_delay_s"].(int); !ok || rearmDelay < 10 || rearmDelay > 600 {
+		return errors.New("invalid guest_rearm_delay_s")
+	}
+
+	if _, ok := sm.securitySettings["guest_alarmo_user"].(string); !ok {
+		return errors.New("invalid guest_alarmo_user")
+	}
+
+	if idle, ok := sm.securitySettings["session_idle_timeout_s"].(int); !ok || idle < 60 || idle > 86400 {
+		return errors.New("invalid session_idle_timeout_s")
+	}
+
+	if maxAge, ok := sm.securitySettings["session_max_age_s"].(int); !ok || maxAge < 300 || maxAge > 604800 {
+		return errors.New("invalid session_max_age_s")
+	}
+
 	return nil
 }
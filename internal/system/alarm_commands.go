@@ -0,0 +1,336 @@
+package system
+
+import (
+	"fmt"
+	"smartdisplay-core/internal/eventbus"
+	"smartdisplay-core/internal/ha/alarmo"
+	"smartdisplay-core/internal/logger"
+	"sort"
+	"sync"
+	"time"
+)
+
+// === ALARMO COMMAND CONFIRMATION (A6) ===
+// Every arm/disarm request is tracked until the next Alarmo state (polled or pushed)
+// shows the requested result, or until the confirmation window expires.
+
+// CommandStatus is the lifecycle state of a tracked Alarmo command
+type CommandStatus string
+
+const (
+	CommandPending   CommandStatus = "pending"
+	CommandConfirmed CommandStatus = "confirmed"
+	CommandFailed    CommandStatus = "failed"
+)
+
+const (
+	defaultCommandTimeout = 30 * time.Second
+	commandHistoryLimit   = 50
+)
+
+// AlarmCommand is one requested Alarmo action and its outcome
+type AlarmCommand struct {
+	ID          string        `json:"id"`
+	Area        string        `json:"area"`
+	Action      string        `json:"action"`
+	Status      CommandStatus `json:"status"`
+	Reason      string        `json:"reason,omitempty"`
+	RequestedBy string        `json:"requested_by,omitempty"`
+	RequestedAt time.Time     `json:"requested_at"`
+	ExpiresAt   time.Time     `json:"expires_at"`
+	ResolvedAt  *time.Time    `json:"resolved_at,omitempty"`
+	FinalState  string        `json:"final_state,omitempty"` // Raw Alarmo state that resolved the command
+}
+
+// trackedCommand adds correlation bookkeeping to an AlarmCommand
+type trackedCommand struct {
+	AlarmCommand
+	sawArming bool        // Panel entered arming/pending after the request
+	timer     *time.Timer // Fires the timeout
+}
+
+// alarmCommandTracker correlates requested actions with subsequent Alarmo states
+type alarmCommandTracker struct {
+	mu       sync.Mutex
+	commands map[string]*trackedCommand
+	order    []string // Oldest first, capped at commandHistoryLimit
+	counter  int64
+
+	timeout    func() time.Duration
+	onResolved func(AlarmCommand)
+}
+
+func newAlarmCommandTracker(timeout func() time.Duration, onResolved func(AlarmCommand)) *alarmCommandTracker {
+	return &alarmCommandTracker{
+		commands:   make(map[string]*trackedCommand),
+		timeout:    timeout,
+		onResolved: onResolved,
+	}
+}
+
+// track registers a command that is being sent to Alarmo.
+// current is the area's state at request time: a panel already in the target state confirms immediately.
+func (t *alarmCommandTracker) track(area, action, requestedBy string, current alarmo.AlarmoState) AlarmCommand {
+	now := time.Now()
+	window := t.timeout()
+
+	t.mu.Lock()
+	t.counter++
+	cmd := &trackedCommand{AlarmCommand: AlarmCommand{
+		ID:          fmt.Sprintf("cmd_%d_%d", now.Unix(), t.counter),
+		Area:        area,
+		Action:      action,
+		Status:      CommandPending,
+		RequestedBy: requestedBy,
+		RequestedAt: now,
+		ExpiresAt:   now.Add(window),
+	}}
+	t.commands[cmd.ID] = cmd
+	t.order = append(t.order, cmd.ID)
+	t.pruneLocked()
+
+	var resolved []AlarmCommand
+	if commandSatisfied(action, current) {
+		t.resolveLocked(cmd, CommandConfirmed, "already in requested state", current.RawState)
+		resolved = append(resolved, cmd.AlarmCommand)
+	} else {
+		id := cmd.ID
+		cmd.timer = time.AfterFunc(window, func() { t.expire(id) })
+	}
+	snapshot := cmd.AlarmCommand
+	t.mu.Unlock()
+
+	t.notify(resolved)
+	return snapshot
+}
+
+// fail records a command whose service call was rejected before reaching Alarmo state
+func (t *alarmCommandTracker) fail(id, reason string) AlarmCommand {
+	t.mu.Lock()
+	cmd, ok := t.commands[id]
+	if !ok {
+		t.mu.Unlock()
+		return AlarmCommand{}
+	}
+	var resolved []AlarmCommand
+	if cmd.Status == CommandPending {
+		t.resolveLocked(cmd, CommandFailed, reason, "")
+		resolved = append(resolved, cmd.AlarmCommand)
+	}
+	snapshot := cmd.AlarmCommand
+	t.mu.Unlock()
+
+	t.notify(resolved)
+	return snapshot
+}
+
+// observe correlates a new Alarmo state of one area with its pending commands
+func (t *alarmCommandTracker) observe(area string, state alarmo.AlarmoState) {
+	t.mu.Lock()
+	var resolved []AlarmCommand
+	for _, id := range t.order {
+		cmd := t.commands[id]
+		if cmd == nil || cmd.Status != CommandPending || cmd.Area != area {
+			continue
+		}
+
+		switch {
+		case commandSatisfied(cmd.Action, state):
+			t.resolveLocked(cmd, CommandConfirmed, "", state.RawState)
+			resolved = append(resolved, cmd.AlarmCommand)
+
+		case cmd.Action != "disarm" && (state.Mode == "arming" || state.Mode == "pending"):
+			// Alarmo accepted the arm request: wait out the exit delay before timing out
+			if !cmd.sawArming {
+				cmd.sawArming = true
+				extend := time.Duration(state.DelayRemaining) * time.Second
+				if extend <= 0 {
+					extend = time.Duration(state.ExitDelaySec) * time.Second
+				}
+				if extend > 0 && cmd.timer != nil {
+					cmd.ExpiresAt = time.Now().Add(extend + t.timeout())
+					cmd.timer.Reset(time.Until(cmd.ExpiresAt))
+				}
+			}
+
+		case cmd.Action != "disarm" && cmd.sawArming && state.Mode == "disarmed":
+			t.resolveLocked(cmd, CommandFailed, "arming cancelled", state.RawState)
+			resolved = append(resolved, cmd.AlarmCommand)
+
+		case state.Mode == "triggered":
+			t.resolveLocked(cmd, CommandFailed, "alarm triggered", state.RawState)
+			resolved = append(resolved, cmd.AlarmCommand)
+		}
+	}
+	t.mu.Unlock()
+
+	t.notify(resolved)
+}
+
+// expire fails a command that was not confirmed within its window
+func (t *alarmCommandTracker) expire(id string) {
+	t.mu.Lock()
+	cmd, ok := t.commands[id]
+	if !ok || cmd.Status != CommandPending || time.Now().Before(cmd.ExpiresAt) {
+		t.mu.Unlock()
+		return
+	}
+	t.resolveLocked(cmd, CommandFailed, "timeout waiting for alarmo", "")
+	resolved := []AlarmCommand{cmd.AlarmCommand}
+	t.mu.Unlock()
+
+	t.notify(resolved)
+}
+
+// get returns a command by id
+func (t *alarmCommandTracker) get(id string) (AlarmCommand, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	cmd, ok := t.commands[id]
+	if !ok {
+		return AlarmCommand{}, false
+	}
+	return cmd.AlarmCommand, true
+}
+
+// recent returns the newest arm (or disarm) command that is still pending or was
+// confirmed within the last window; it attributes a state change to its requester
+func (t *alarmCommandTracker) recent(disarm bool, window time.Duration) (AlarmCommand, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := time.Now()
+	for i := len(t.order) - 1; i >= 0; i-- {
+		cmd := t.commands[t.order[i]]
+		if (cmd.Action == "disarm") != disarm {
+			continue
+		}
+		switch {
+		case cmd.Status == CommandPending:
+			return cmd.AlarmCommand, true
+		case cmd.Status == CommandConfirmed && now.Sub(*cmd.ResolvedAt) <= window:
+			return cmd.AlarmCommand, true
+		}
+	}
+	return AlarmCommand{}, false
+}
+
+// list returns tracked commands, newest first
+func (t *alarmCommandTracker) list() []AlarmCommand {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	out := make([]AlarmCommand, 0, len(t.order))
+	for _, id := range t.order {
+		out = append(out, t.commands[id].AlarmCommand)
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].RequestedAt.After(out[j].RequestedAt) })
+	return out
+}
+
+func (t *alarmCommandTracker) resolveLocked(cmd *trackedCommand, status CommandStatus, reason, finalState string) {
+	now := time.Now()
+	cmd.Status = status
+	cmd.Reason = reason
+	cmd.FinalState = finalState
+	cmd.ResolvedAt = &now
+	if cmd.timer != nil {
+		cmd.timer.Stop()
+	}
+}
+
+// pruneLocked drops the oldest resolved commands beyond the history limit
+func (t *alarmCommandTracker) pruneLocked() {
+	for len(t.order) > commandHistoryLimit {
+		dropped := false
+		for i, id := range t.order {
+			if t.commands[id].Status != CommandPending {
+				delete(t.commands, id)
+				t.order = append(t.order[:i], t.order[i+1:]...)
+				dropped = true
+				break
+			}
+		}
+		if !dropped {
+			return // Only pending commands left
+		}
+	}
+}
+
+func (t *alarmCommandTracker) notify(resolved []AlarmCommand) {
+	if t.onResolved == nil {
+		return
+	}
+	for _, cmd := range resolved {
+		t.onResolved(cmd)
+	}
+}
+
+// commandSatisfied reports whether state is the result an action asks for
+func commandSatisfied(action string, state alarmo.AlarmoState) bool {
+	switch action {
+	case "disarm":
+		return state.Mode == "disarmed"
+	case "arm_home":
+		return state.Mode == "armed" && state.ArmedMode == "home"
+	case "arm_away":
+		return state.Mode == "armed" && state.ArmedMode == "away"
+	case "arm_night":
+		return state.Mode == "armed" && state.ArmedMode == "night"
+	case "arm_vacation":
+		return state.Mode == "armed" && state.ArmedMode == "vacation"
+	case "arm_custom_bypass":
+		return state.Mode == "armed" && state.ArmedMode == "custom_bypass"
+	}
+	return false
+}
+
+// === COORDINATOR WIRING ===
+
+// alarmCommandTimeout reads the confirmation window from settings
+func (c *Coordinator) alarmCommandTimeout() time.Duration {
+	if c.Settings == nil {
+		return defaultCommandTimeout
+	}
+	return time.Duration(c.Settings.SecurityInt("alarm_command_timeout_s", 30)) * time.Second
+}
+
+// onAlarmCommandResolved logs the outcome of a command and announces it on the bus
+func (c *Coordinator) onAlarmCommandResolved(cmd AlarmCommand) {
+	logger.Info(fmt.Sprintf("alarmo command %s: %s (area=%s action=%s reason=%s)",
+		cmd.ID, cmd.Status, cmd.Area, cmd.Action, cmd.Reason))
+	c.Events.Publish(eventbus.TopicAlarm, eventbus.AlarmCommandResolved, map[string]interface{}{
+		"id":           cmd.ID,
+		"area":         cmd.Area,
+		"action":       cmd.Action,
+		"status":       string(cmd.Status),
+		"reason":       cmd.Reason,
+		"requested_by": cmd.RequestedBy,
+	})
+
+	// Someone armed or disarmed the main panel: the guest re-arm no longer applies
+	if cmd.Status == CommandConfirmed && cmd.RequestedBy != "" {
+		if adapter := c.alarmoAdapter(); adapter == nil || cmd.Area == adapter.Primary().Area {
+			c.forgetGuestRearm(cmd.RequestedBy, cmd.Action)
+		}
+	}
+}
+
+// TrackAlarmCommand registers an action about to be sent to Alarmo outside
+// RequestAreaAlarmAction. Track before sending, so a fast pushed state is not missed.
+func (c *Coordinator) TrackAlarmCommand(area, action, requestedBy string) AlarmCommand {
+	return c.alarmCommands.track(area, action, requestedBy, c.alarmoSnapshot(area))
+}
+
+// FailAlarmCommand marks a tracked command failed when its service call did not go through
+func (c *Coordinator) FailAlarmCommand(id, reason string) AlarmCommand {
+	return c.alarmCommands.fail(id, reason)
+}
+
+// AlarmCommands returns recent Alarmo commands, newest first
+func (c *Coordinator) AlarmCommands() []AlarmCommand {
+	return c.alarmCommands.list()
+}
+
+// AlarmCommandByID returns a single tracked command
+func (c *Coordinator) AlarmCommandByID(id string) (AlarmCommand, bool) {
+	return c.alarmCommands.get(id)
+}
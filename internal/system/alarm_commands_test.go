@@ -0,0 +1,117 @@
+package system
+
+import (
+	"smartdisplay-core/internal/ha/alarmo"
+	"testing"
+	"time"
+)
+
+// newTestTracker returns a tracker with a short window that reports resolved commands
+func newTestTracker(window time.Duration) (*alarmCommandTracker, <-chan AlarmCommand) {
+	resolved := make(chan AlarmCommand, 8)
+	t := newAlarmCommandTracker(func() time.Duration { return window }, func(cmd AlarmCommand) { resolved <- cmd })
+	return t, resolved
+}
+
+func expectResolved(t *testing.T, ch <-chan AlarmCommand, id string, status CommandStatus, reason string) AlarmCommand {
+	t.Helper()
+	select {
+	case cmd := <-ch:
+		if cmd.ID != id || cmd.Status != status || cmd.Reason != reason {
+			t.Fatalf("resolved %s %s (%q), want %s %s (%q)", cmd.ID, cmd.Status, cmd.Reason, id, status, reason)
+		}
+		if cmd.ResolvedAt == nil {
+			t.Errorf("resolved command %s has no resolution time", cmd.ID)
+		}
+		return cmd
+	case <-time.After(2 * time.Second):
+		t.Fatalf("command %s not resolved, want %s", id, status)
+	}
+	return AlarmCommand{}
+}
+
+func TestAlarmCommandConfirmedByMatchingState(t *testing.T) {
+	tracker, resolved := newTestTracker(time.Minute)
+	disarmed := alarmo.AlarmoState{Mode: "disarmed", RawState: "disarmed"}
+
+	tests := []struct {
+		action string
+		state  alarmo.AlarmoState
+	}{
+		{"arm_home", alarmo.AlarmoState{Mode: "armed", ArmedMode: "home", RawState: "armed_home"}},
+		{"arm_away", alarmo.AlarmoState{Mode: "armed", ArmedMode: "away", RawState: "armed_away"}},
+		{"arm_night", alarmo.AlarmoState{Mode: "armed", ArmedMode: "night", RawState: "armed_night"}},
+		{"arm_vacation", alarmo.AlarmoState{Mode: "armed", ArmedMode: "vacation", RawState: "armed_vacation"}},
+		{"arm_custom_bypass", alarmo.AlarmoState{Mode: "armed", ArmedMode: "custom_bypass", RawState: "armed_custom_bypass"}},
+	}
+	for _, tt := range tests {
+		cmd := tracker.track("main", tt.action, "ayse", disarmed)
+		if cmd.Status != CommandPending {
+			t.Fatalf("%s: tracked as %s, want pending", tt.action, cmd.Status)
+		}
+
+		// Another area and another mode do not confirm it
+		tracker.observe("garage", tt.state)
+		tracker.observe("main", alarmo.AlarmoState{Mode: "armed", ArmedMode: "other", RawState: "armed_other"})
+		if got, _ := tracker.get(cmd.ID); got.Status != CommandPending {
+			t.Fatalf("%s: resolved by an unrelated state: %+v", tt.action, got)
+		}
+
+		tracker.observe("main", tt.state)
+		if got := expectResolved(t, resolved, cmd.ID, CommandConfirmed, ""); got.FinalState != tt.state.RawState {
+			t.Errorf("%s: final state %q, want %q", tt.action, got.FinalState, tt.state.RawState)
+		}
+
+		// Back to disarmed for the next mode: the disarm confirms at once
+		disarm := tracker.track("main", "disarm", "ayse", tt.state)
+		tracker.observe("main", disarmed)
+		expectResolved(t, resolved, disarm.ID, CommandConfirmed, "")
+	}
+
+	// A panel already in the requested state confirms immediately
+	cmd := tracker.track("main", "disarm", "ayse", disarmed)
+	if cmd.Status != CommandConfirmed || cmd.Reason != "already in requested state" {
+		t.Errorf("disarm of a disarmed panel = %+v", cmd)
+	}
+	expectResolved(t, resolved, cmd.ID, CommandConfirmed, "already in requested state")
+}
+
+func TestAlarmCommandTimesOut(t *testing.T) {
+	tracker, resolved := newTestTracker(50 * time.Millisecond)
+	disarmed := alarmo.AlarmoState{Mode: "disarmed", RawState: "disarmed"}
+
+	cmd := tracker.track("main", "arm_away", "ayse", disarmed)
+	got := expectResolved(t, resolved, cmd.ID, CommandFailed, "timeout waiting for alarmo")
+	if got.FinalState != "" {
+		t.Errorf("timed out command has final state %q", got.FinalState)
+	}
+
+	// A late state does not revive it
+	tracker.observe("main", alarmo.AlarmoState{Mode: "armed", ArmedMode: "away", RawState: "armed_away"})
+	if got, _ := tracker.get(cmd.ID); got.Status != CommandFailed {
+		t.Errorf("late state changed a timed out command: %+v", got)
+	}
+	select {
+	case extra := <-resolved:
+		t.Errorf("resolved twice: %+v", extra)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestAlarmCommandFailedServiceCall(t *testing.T) {
+	tracker, resolved := newTestTracker(time.Minute)
+
+	cmd := tracker.track("main", "disarm", "ayse", alarmo.AlarmoState{Mode: "armed", ArmedMode: "away"})
+	if got := tracker.fail(cmd.ID, "service call failed"); got.Status != CommandFailed {
+		t.Fatalf("fail = %+v", got)
+	}
+	expectResolved(t, resolved, cmd.ID, CommandFailed, "service call failed")
+
+	tracker.observe("main", alarmo.AlarmoState{Mode: "disarmed", RawState: "disarmed"})
+	if got, _ := tracker.get(cmd.ID); got.Status != CommandFailed {
+		t.Errorf("state after a failed call changed the command: %+v", got)
+	}
+	if got := tracker.fail("cmd_unknown", "x"); got.ID != "" {
+		t.Errorf("fail of an unknown command = %+v", got)
+	}
+}
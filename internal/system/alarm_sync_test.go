@@ -0,0 +1,262 @@
+package system
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"smartdisplay-core/internal/alarm"
+	"smartdisplay-core/internal/ha/alarmo"
+	"smartdisplay-core/internal/logger"
+	"smartdisplay-core/internal/settings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMain(m *testing.M) {
+	os.MkdirAll("logs", 0755)
+	logger.Init()
+	os.Exit(m.Run())
+}
+
+// newSyncTestCoordinator builds the minimal coordinator needed by applyAlarmoUpdate
+func newSyncTestCoordinator(t *testing.T) (*Coordinator, alarmo.Panel) {
+	t.Helper()
+	adapter := alarmo.New("http://ha.local", "token")
+	c := &Coordinator{
+		Alarm:         alarm.NewStateMachine(),
+		AlarmoAdapter: adapter,
+		AlarmoAreas:   make(map[string]alarmo.AlarmoState),
+		alarmoAreaErr: make(map[string]string),
+	}
+	c.alarmCommands = newAlarmCommandTracker(func() time.Duration { return time.Minute }, nil)
+	return c, adapter.Primary()
+}
+
+// haState maps a raw HA state string through the same path as pushed events
+func haState(t *testing.T, raw string, prev alarmo.AlarmoState) alarmo.AlarmoState {
+	t.Helper()
+	st, ok := alarmo.StateFromEvent(map[string]interface{}{"state": raw}, prev)
+	if !ok {
+		t.Fatalf("StateFromEvent(%q) rejected", raw)
+	}
+	return st
+}
+
+func TestLocalAlarmReconcilesEveryAlarmoState(t *testing.T) {
+	cases := []struct {
+		raw       string
+		from      string // Local state before the update
+		wantState string
+		wantMode  string
+		screen    alarm.ScreenMode
+	}{
+		{"disarmed", alarm.ARMED, alarm.DISARMED, "", alarm.ModeDisarmed},
+		{"arming", alarm.DISARMED, alarm.ARMING, "", alarm.ModeArming},
+		{"pending", alarm.ARMED, alarm.PENDING, "", alarm.ModePending},
+		{"armed_home", alarm.DISARMED, alarm.ARMED, "home", alarm.ModeArmed},
+		{"armed_away", alarm.ARMING, alarm.ARMED, "away", alarm.ModeArmed},
+		{"armed_night", alarm.DISARMED, alarm.ARMED, "night", alarm.ModeArmed},
+		{"armed_vacation", alarm.DISARMED, alarm.ARMED, "vacation", alarm.ModeArmed},
+		{"armed_custom_bypass", alarm.DISARMED, alarm.ARMED, "custom_bypass", alarm.ModeArmed},
+		{"triggered", alarm.ARMED, alarm.TRIGGERED, "", alarm.ModeTriggered},
+		// No alarm information: local state is kept
+		{"unavailable", alarm.ARMED, alarm.ARMED, "", alarm.ModeArmed},
+		{"unknown", alarm.DISARMED, alarm.DISARMED, "", alarm.ModeDisarmed},
+		{"disarming", alarm.ARMED, alarm.ARMED, "", alarm.ModeArmed},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.raw, func(t *testing.T) {
+			c, panel := newSyncTestCoordinator(t)
+			c.Alarm.Sync(tc.from, "")
+
+			c.applyAlarmoUpdate(panel, haState(t, tc.raw, alarmo.AlarmoState{}), nil)
+
+			if got := c.Alarm.CurrentState(); got != tc.wantState {
+				t.Errorf("state = %s, want %s", got, tc.wantState)
+			}
+			if got := c.Alarm.ArmedMode(); got != tc.wantMode {
+				t.Errorf("armed mode = %q, want %q", got, tc.wantMode)
+			}
+
+			screen := alarm.NewScreenStateManager(
+				func() bool { return false },
+				c.Alarm.CurrentState,
+				func() bool { return false },
+				func() int { return 0 },
+				time.Now,
+				func() bool { return false },
+				func() (string, time.Time, time.Time) { return "", time.Now(), time.Now() },
+				func() bool { return false },
+				func() string { return "" },
+				time.Now,
+				func() int { return 0 },
+			)
+			if got := screen.EvaluateMode(); got != tc.screen {
+				t.Errorf("screen mode = %s, want %s", got, tc.screen)
+			}
+		})
+	}
+}
+
+func TestLocalAlarmFollowsAlarmoSequence(t *testing.T) {
+	c, panel := newSyncTestCoordinator(t)
+
+	steps := []struct {
+		raw       string
+		wantState string
+		wantMode  string
+	}{
+		{"arming", alarm.ARMING, ""},
+		{"armed_away", alarm.ARMED, "away"},
+		{"pending", alarm.PENDING, "away"}, // Entry delay keeps the armed mode
+		{"triggered", alarm.TRIGGERED, ""},
+		{"disarmed", alarm.DISARMED, ""},
+		{"armed_night", alarm.ARMED, "night"},
+	}
+
+	prev := alarmo.AlarmoState{}
+	for _, step := range steps {
+		prev = haState(t, step.raw, prev)
+		c.applyAlarmoUpdate(panel, prev, nil)
+
+		if got := c.Alarm.CurrentState(); got != step.wantState {
+			t.Fatalf("after %s: state = %s, want %s", step.raw, got, step.wantState)
+		}
+		if got := c.Alarm.ArmedMode(); got != step.wantMode {
+			t.Fatalf("after %s: armed mode = %q, want %q", step.raw, got, step.wantMode)
+		}
+	}
+	if c.Alarm.LastTriggerTime().IsZero() {
+		t.Error("trigger time not recorded from Alarmo transition")
+	}
+	if c.Alarm.LastEvent() != alarm.SYNC {
+		t.Errorf("last event = %s, want %s", c.Alarm.LastEvent(), alarm.SYNC)
+	}
+}
+
+func TestLocalAlarmIgnoresSecondaryAreas(t *testing.T) {
+	c, _ := newSyncTestCoordinator(t)
+	if err := c.AlarmoAdapter.SetPanels([]alarmo.Panel{
+		{Area: "house", EntityID: "alarm_control_panel.house"},
+		{Area: "garage", EntityID: "alarm_control_panel.garage"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	garage, _ := c.AlarmoAdapter.Panel("garage")
+
+	c.applyAlarmoUpdate(garage, haState(t, "triggered", alarmo.AlarmoState{}), nil)
+
+	if got := c.Alarm.CurrentState(); got != alarm.DISARMED {
+		t.Errorf("secondary area changed local state to %s", got)
+	}
+}
+
+func TestAlarmDelaysPerModeFallBackToShared(t *testing.T) {
+	sm := settings.NewSettingsManager(nil, nil, nil, nil, nil, nil, nil, func(string, string) {})
+	sm.SetUserRole(settings.RoleAdmin)
+	c := &Coordinator{Settings: sm}
+
+	set := func(id string, v float64) {
+		t.Helper()
+		if _, err := sm.ApplyFieldChange(&settings.FieldChangeRequest{FieldID: id, NewValue: v}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	set("alarm_exit_delay_s", 40)
+	set("alarm_entry_delay_s", 25)
+	set("alarm_exit_delay_home_s", 0)
+	set("alarm_entry_delay_night_s", 10)
+
+	tests := []struct {
+		mode string
+		want alarm.Delays
+	}{
+		{alarm.ArmHome, alarm.Delays{Exit: 0, Entry: 25}},
+		{alarm.ArmNight, alarm.Delays{Exit: 40, Entry: 10}},
+		{alarm.ArmAway, alarm.Delays{Exit: 40, Entry: 25}},
+	}
+	for _, tt := range tests {
+		if got := c.alarmDelays(tt.mode); got != tt.want {
+			t.Errorf("alarmDelays(%s) = %+v, want %+v", tt.mode, got, tt.want)
+		}
+	}
+}
+
+// waitAlarmo waits until the active source and the primary Alarmo state match
+func waitAlarmo(t *testing.T, c *Coordinator, source, raw string) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		c.AlarmoMu.RLock()
+		gotSource, gotRaw := c.alarmoSource, c.AlarmoState.RawState
+		c.AlarmoMu.RUnlock()
+		if gotSource == source && gotRaw == raw {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("source %q state %q, want %q %q", gotSource, gotRaw, source, raw)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestAlarmoSyncFallsBackToPollingAndBack(t *testing.T) {
+	var mu sync.Mutex
+	current := "armed_away"
+	setHAState := func(raw string) {
+		mu.Lock()
+		defer mu.Unlock()
+		current = raw
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		json.NewEncoder(w).Encode(map[string]interface{}{"state": current, "attributes": map[string]interface{}{}})
+	}))
+	defer srv.Close()
+
+	var connected atomic.Bool
+	c, _ := newSyncTestCoordinator(t)
+	c.AlarmoAdapter = alarmo.New(srv.URL, "token")
+	c.alarmoSwitch = make(chan struct{}, 1)
+	c.haConnected = connected.Load
+	entity := c.AlarmoAdapter.Primary().EntityID
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c.StartAlarmPolling(ctx)
+
+	// WebSocket down at start: the state comes from polling
+	waitAlarmo(t, c, alarmo.SourcePoll, "armed_away")
+
+	// WebSocket up: push takes over with a resync, then delivers events
+	setHAState("disarmed")
+	connected.Store(true)
+	c.handleHAConnectionChange(true)
+	waitAlarmo(t, c, alarmo.SourcePush, "disarmed")
+	c.alarmoPush.HandleStateChanged(entity, map[string]interface{}{"state": "triggered"})
+	waitAlarmo(t, c, alarmo.SourcePush, "triggered")
+
+	// WebSocket lost: polling picks up what push no longer sees
+	setHAState("disarmed")
+	connected.Store(false)
+	c.handleHAConnectionChange(false)
+	waitAlarmo(t, c, alarmo.SourcePoll, "disarmed")
+
+	// Back again: the change made while switching is caught by the resync
+	setHAState("armed_home")
+	connected.Store(true)
+	c.handleHAConnectionChange(true)
+	waitAlarmo(t, c, alarmo.SourcePush, "armed_home")
+
+	c.AlarmoMu.RLock()
+	defer c.AlarmoMu.RUnlock()
+	if c.alarmoResyncs != 2 {
+		t.Errorf("resyncs = %d, want one per switch to push", c.alarmoResyncs)
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"smartdisplay-core/internal/alarm/countdown"
 	"smartdisplay-core/internal/audit"
 	"smartdisplay-core/internal/config"
+	"smartdisplay-core/internal/eventbus"
 	"smartdisplay-core/internal/firstboot"
 	"smartdisplay-core/internal/guest"
 	"smartdisplay-core/internal/ha/alarmo"
@@ -23,7 +24,7 @@ import (
 	"smartdisplay-core/internal/platform"
 	"smartdisplay-core/internal/plugin"
 	"smartdisplay-core/internal/settings"
-	"strings"
+	"smartdisplay-core/internal/version"
 	"sync"
 	"time"
 )
@@ -48,24 +49,26 @@ type AlarmContext struct {
 
 // SelfCheckResult holds results of system self-check
 type SelfCheckResult struct {
-	HAConnected bool
-	AlarmValid  bool
-	AIRunning   bool
-	Details     []string
-	Hardware    []hal.DeviceHealth
+	HAConnected  bool
+	AlarmValid   bool
+	AIRunning    bool
+	AlarmoSource string // push | poll | "" (sync not running)
+	Details      []string
+	Hardware     []hal.DeviceHealth
 }
 
 // Coordinator manages system state and interactions between components
 type Coordinator struct {
 	// Core subsystems (D0-D7 design phases)
 	Alarm        *alarm.StateMachine
-	Guest        *guest.StateMachine
 	Countdown    *countdown.Countdown
 	FirstBoot    *firstboot.FirstBootManager // D0: First-boot flow manager
 	Home         *home.HomeStateManager      // D2: Home screen state machine
 	AlarmScreen  *alarm.ScreenStateManager   // D3: Alarm screen state exposure
 	GuestScreen  *guest.ScreenStateManager   // D4: Guest access flow state machine
-	GuestRequest *guest.Manager              // FAZ L2: Guest approval flow
+	GuestRequest *guest.Manager              // FAZ L2: Guest approval flow (one state machine per guest)
+	GuestPasses  *guest.PassBook             // Pre-created guest passes (code / RFID card)
+	GuestSigner  *guest.CallbackSigner       // Signs the decision buttons of HA guest notifications
 	Menu         *menu.MenuManager           // D5: Menu structure and role-based visibility
 	Logbook      *logbook.LogbookManager     // D6: History and logbook
 	Settings     *settings.SettingsManager   // D7: Settings management
@@ -79,6 +82,28 @@ type Coordinator struct {
 	AlarmoState   alarmo.AlarmoState // A2: Normalized alarm state (single source of truth)
 	AlarmoMu      sync.RWMutex       // A2: Protect AlarmoState updates
 
+	// A5: Per-area Alarmo state keyed by panel area; AlarmoState mirrors the primary panel
+	AlarmoAreas   map[string]alarmo.AlarmoState
+	alarmoAreaErr map[string]string // Last fetch error per area ("" = reachable)
+
+	// A5: Alarmo state sync (WebSocket push, REST polling fallback); guarded by AlarmoMu
+	alarmoPush         *alarmo.PushSource
+	alarmoSwitch       chan struct{} // Signalled when HA WebSocket goes up/down
+	haConnected        func() bool   // Reports whether push is live (nil = HA.IsConnected)
+	alarmoSource       string        // Active source name
+	alarmoSourceReason string        // Why the active source was chosen
+	alarmoLastResync   time.Time
+	alarmoResyncs      int
+
+	// A6: Arm/disarm commands awaiting confirmation from Alarmo state
+	alarmCommands *alarmCommandTracker
+
+	// Alarmo mode to restore once the last guest has left
+	guestRearm guestRearm
+
+	// Events carries alarm, guest, HAL and HA events to subscribers (AI, LEDs, notifications)
+	Events *eventbus.Bus
+
 	// AI & insights
 	AI          *ai.InsightEngine
 	lastInsight ai.Insight
@@ -91,10 +116,12 @@ type Coordinator struct {
 	// Internal managers
 	pluginRegistry *plugin.Registry
 	failsafe       FailsafeState
+	healthMu       sync.Mutex
+	deviceReady    map[string]bool // Last observed readiness per device (type:id)
 }
 
 // NewCoordinator creates a new Coordinator with all subsystems
-func NewCoordinator(a *alarm.StateMachine, g *guest.StateMachine, c *countdown.Countdown, ha *haadapter.Adapter, n hanotify.Notifier, halReg *hal.Registry, plat platform.Platform, haBaseURL string, haToken string) *Coordinator {
+func NewCoordinator(a *alarm.StateMachine, c *countdown.Countdown, ha *haadapter.Adapter, n hanotify.Notifier, halReg *hal.Registry, plat platform.Platform, haBaseURL string, haToken string) *Coordinator {
 	aiEngine := ai.NewInsightEngine()
 	cfg := config.Config{} // Will be populated by main
 
@@ -105,6 +132,20 @@ func NewCoordinator(a *alarm.StateMachine, g *guest.StateMachine, c *countdown.C
 		alarmoAdapter = alarmo.New(haBaseURL, haToken)
 	}
 
+	// FAZ L2: Concurrent guest requests, each with its own state machine
+	guests := guest.NewManager(60 * time.Second)
+	guestSigner := guest.NewCallbackSigner()
+
+	// A9: Exit/entry countdown of the alarm state machine, falling back to the shared countdown
+	activeCountdown := func() *countdown.Countdown {
+		if a != nil {
+			if cd := a.Countdown(); cd != nil {
+				return cd
+			}
+		}
+		return c
+	}
+
 	// Create home state manager with dependency injection
 	homeMgr := home.NewHomeStateManager(
 		func() bool { return false }, // FirstBoot placeholder
@@ -116,19 +157,24 @@ func NewCoordinator(a *alarm.StateMachine, g *guest.StateMachine, c *countdown.C
 			}
 			return ""
 		},
-		func() string { return g.CurrentState() },
-		func() bool { return c != nil }, // placeholder
-		func() int { return 0 },         // placeholder
+		func() string { return guests.CurrentState() },
+		func() bool { cd := activeCountdown(); return cd != nil && cd.IsActive() },
+		func() int {
+			if cd := activeCountdown(); cd != nil {
+				return cd.Remaining()
+			}
+			return 0
+		},
 	)
 
 	// Create alarm screen state manager with dependency injection (D3)
 	alarmScreenMgr := alarm.NewScreenStateManager(
 		func() bool { return false }, // FirstBoot placeholder
 		func() string { return a.CurrentState() },
-		func() bool { return c != nil && c.IsActive() }, // Countdown active check
-		func() int { return c.Remaining() },             // Countdown remaining seconds
-		func() time.Time { return time.Now() },          // Countdown started time (placeholder)
-		func() bool { return g.HasPendingRequest() },    // Guest request pending
+		func() bool { cd := activeCountdown(); return cd != nil && cd.IsActive() }, // Countdown active check
+		func() int { return activeCountdown().Remaining() },                        // Countdown remaining seconds
+		func() time.Time { return activeCountdown().StartedAt() },                  // Countdown started time
+		func() bool { return guests.HasPendingRequest() },                          // Guest request pending
 		func() (string, time.Time, time.Time) { // Guest request info (placeholder)
 			return "", time.Now(), time.Now()
 		},
@@ -138,6 +184,8 @@ func NewCoordinator(a *alarm.StateMachine, g *guest.StateMachine, c *countdown.C
 		func() int { return 0 },                // Failsafe estimate (placeholder)
 	)
 
+	alarmScreenMgr.SetCountdownTotalFn(func() int { return activeCountdown().Duration() })
+
 	// Create guest screen state manager with dependency injection (D4)
 	guestScreenMgr := guest.NewScreenStateManager(
 		func() bool { return false }, // FirstBoot placeholder
@@ -200,30 +248,57 @@ func NewCoordinator(a *alarm.StateMachine, g *guest.StateMachine, c *countdown.C
 
 	coord := &Coordinator{
 		Alarm:          a,
-		Guest:          g,
 		Countdown:      c,
 		HA:             ha,
 		Notifier:       n,
 		AI:             aiEngine,
-		FirstBoot:      firstboot.New(false),               // Placeholder, will be set from runtimeCfg at startup
-		Home:           homeMgr,                            // D2: Home state manager
-		AlarmScreen:    alarmScreenMgr,                     // D3: Alarm screen state manager
-		GuestScreen:    guestScreenMgr,                     // D4: Guest screen state manager
-		GuestRequest:   guest.NewManager(60 * time.Second), // FAZ L2: Guest approval flow
-		Menu:           menuMgr,                            // D5: Menu structure and role-based visibility
-		Logbook:        logbookMgr,                         // D6: History and logbook
-		Settings:       settingsMgr,                        // D7: Settings management
+		FirstBoot:      firstboot.New(false), // Placeholder, will be set from runtimeCfg at startup
+		Home:           homeMgr,              // D2: Home state manager
+		AlarmScreen:    alarmScreenMgr,       // D3: Alarm screen state manager
+		GuestScreen:    guestScreenMgr,       // D4: Guest screen state manager
+		GuestRequest:   guests,               // FAZ L2: Guest approval flow
+		GuestPasses:    guest.NewPassBook(),  // Scheduled guest passes
+		GuestSigner:    guestSigner,          // Signed HA notification buttons
+		Menu:           menuMgr,              // D5: Menu structure and role-based visibility
+		Logbook:        logbookMgr,           // D6: History and logbook
+		Settings:       settingsMgr,          // D7: Settings management
 		DeviceStates:   []string{"online"},
 		Cfg:            cfg,
 		HALRegistry:    halReg,
 		Platform:       plat,
 		AlarmoAdapter:  alarmoAdapter, // A2: Alarmo adapter
+		AlarmoAreas:    make(map[string]alarmo.AlarmoState),
+		alarmoAreaErr:  make(map[string]string),
+		alarmoSwitch:   make(chan struct{}, 1),
 		pluginRegistry: plugin.NewRegistry(),
+		deviceReady:    make(map[string]bool),
+	}
+
+	// Event bus and built-in subscribers
+	coord.Events = eventbus.New()
+	coord.setupEventSubscribers()
+
+	// A8: Local exit/entry delays follow the Security settings
+	if a != nil {
+		a.SetDelayProvider(coord.alarmDelays)
+		a.SetTransitionHandler(coord.onAlarmTransition)
 	}
 
+	// A6: Confirm Alarmo commands against subsequent state updates
+	coord.alarmCommands = newAlarmCommandTracker(coord.alarmCommandTimeout, coord.onAlarmCommandResolved)
+
 	// FAZ L3: Wire guest approval callbacks
 	coord.setupGuestApprovalCallbacks()
 
+	// The guest screen shows the re-arm countdown after the last guest left
+	coord.GuestScreen.SetRearmSource(coord.GuestRearmInfo)
+
+	// Route events pushed over the HA WebSocket session into the coordinator
+	if ha != nil {
+		ha.SetEventHandler(coord.HandleHAEvent)
+		ha.SetConnectionHandler(coord.handleHAConnectionChange)
+	}
+
 	// A2/A3: Initialize Alarmo state from first fetch
 	if coord.AlarmoAdapter != nil {
 		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
@@ -233,8 +308,11 @@ func NewCoordinator(a *alarm.StateMachine, g *guest.StateMachine, c *countdown.C
 			logger.Error("alarmo initial fetch failed: " + err.Error())
 			coord.failsafe.Active = true
 			coord.failsafe.Explanation = "Alarmo unreachable at startup"
+			coord.publishFailsafe()
 		} else {
 			coord.AlarmoState = state
+			coord.AlarmoAreas[coord.AlarmoAdapter.Primary().Area] = state
+			coord.syncLocalAlarm(state)
 			logger.Info("alarmo state loaded")
 		}
 	}
@@ -247,28 +325,46 @@ func NewCoordinator(a *alarm.StateMachine, g *guest.StateMachine, c *countdown.C
 		logger.Info("config: Guest access disabled")
 	}
 	coord.feedAI()
+	coord.Events.Publish(eventbus.TopicSystem, eventbus.SystemStarted, map[string]interface{}{"version": version.Version})
 	return coord
 }
 
 // === FIRST-BOOT MODE (D0) ===
 
-// HandleGuestAction handles guest state machine actions with first-boot blocking (D0)
-func (c *Coordinator) HandleGuestAction(action string) {
+// HandleGuestAction applies an action to one guest's state machine with first-boot blocking (D0).
+// Transitions are announced on the bus by the guest manager.
+func (c *Coordinator) HandleGuestAction(requestID, action string) error {
 	logger.Info("coordinator: handling guest action")
 
 	// First-boot mode: Block all guest actions (D0)
 	if c.FirstBoot != nil && c.FirstBoot.Active() {
 		logger.Info("firstboot: guest action blocked during setup")
-		return
+		return errors.New("guest actions blocked during setup")
 	}
 
-	c.Guest.Handle(action)
-	c.feedAI()
-	c.CheckSmartAlarmScenarios()
-
-	if action == "EXIT" {
+	switch action {
+	case guest.APPROVE:
+		return c.GuestRequest.ApproveRequest(requestID)
+	case guest.DENY:
+		return c.GuestRequest.RejectRequest(requestID)
+	case guest.EXIT:
+		if err := c.GuestRequest.ExitGuest(requestID); err != nil {
+			return err
+		}
 		c.LeavingHomeDetected("guest_exit")
+		return nil
 	}
+	return errors.New("invalid guest action")
+}
+
+// HandleGuestExit ends the guest screen session (D4) and the guest's access.
+// An empty requestID ends every active guest.
+func (c *Coordinator) HandleGuestExit(requestID string) error {
+	if err := c.GuestRequest.ExitGuest(requestID); err != nil {
+		return err
+	}
+	c.GuestScreen.OnExit()
+	return nil
 }
 
 // HandleAlarmAction handles alarm state machine actions with first-boot blocking (D0)
@@ -318,9 +414,8 @@ func (c *Coordinator) HandleAlarmAction(action string) {
 		return
 	}
 
+	// State change reaches AI/LEDs/notifications via onAlarmTransition -> event bus
 	c.Alarm.Handle(action)
-	c.feedAI()
-	c.CheckSmartAlarmScenarios()
 
 	if aiExplanation != "" && c.AI != nil {
 		c.lastInsight = c.AI.GetCurrentInsight()
@@ -342,8 +437,8 @@ func (c *Coordinator) EvaluateAlarmContext() AlarmContext {
 	}
 
 	// Guest presence/state
-	if c.Guest != nil {
-		ctx.GuestState = c.Guest.CurrentState()
+	if c.GuestRequest != nil {
+		ctx.GuestState = c.GuestRequest.CurrentState()
 		ctx.GuestPresent = ctx.GuestState == "APPROVED"
 	}
 
@@ -396,6 +491,33 @@ func (c *Coordinator) CheckSmartAlarmScenarios() {
 	}
 }
 
+// onAlarmTransition publishes every local alarm state change,
+// including countdown-driven ARM_COMPLETE/TRIGGER (A9)
+func (c *Coordinator) onAlarmTransition(from, to, event string) {
+	logger.Info("alarm state: " + from + " -> " + to + " (" + event + ")")
+	c.Events.Publish(eventbus.TopicAlarm, eventbus.AlarmStateChanged, map[string]interface{}{
+		"from":       from,
+		"to":         to,
+		"event":      event,
+		"armed_mode": c.Alarm.ArmedMode(),
+	})
+}
+
+// alarmDelays returns exit/entry delays for a local arm mode from Security settings (A8)
+// alarm_<kind>_delay_<mode>_s overrides the shared delay when set (>= 0); 0 arms/triggers immediately
+func (c *Coordinator) alarmDelays(mode string) alarm.Delays {
+	if c.Settings == nil {
+		return alarm.Delays{Exit: 30, Entry: 30}
+	}
+	delay := func(kind string) int {
+		if v := c.Settings.SecurityInt("alarm_"+kind+"_delay_"+mode+"_s", -1); v >= 0 {
+			return v
+		}
+		return c.Settings.SecurityInt("alarm_"+kind+"_delay_s", 30)
+	}
+	return alarm.Delays{Exit: delay("exit"), Entry: delay("entry")}
+}
+
 // IsQuietHours returns true if current time is within configured quiet hours
 // Note: Quiet hours not yet configurable, returns false for now
 func (c *Coordinator) IsQuietHours() bool {
@@ -481,16 +603,49 @@ func (c *Coordinator) ValidateHardwareProfile() (missing []string) {
 // BootHardwareValidation initializes all registered HAL devices at startup
 func (c *Coordinator) BootHardwareValidation() {
 	devices := c.ListDevices()
+	report := make([]hal.DeviceHealth, 0, len(devices))
 	for _, dev := range devices {
+		health := hal.DeviceHealth{ID: dev.ID(), Type: dev.Type()}
 		err := dev.Init()
 		if err != nil {
 			logger.Info("hardware init error: " + dev.Type() + " id=" + dev.ID() + " err=" + err.Error())
 			audit.Record("hardware_fault", dev.Type()+":"+dev.ID()+":"+err.Error())
+			health.Error = err.Error()
 		} else if !dev.IsReady() {
 			logger.Info("hardware not ready after init: " + dev.Type() + " id=" + dev.ID())
 			audit.Record("hardware_fault", dev.Type()+":"+dev.ID()+":not ready after init")
+			health.Error = "not ready after init"
 		} else {
 			logger.Info("hardware ready: " + dev.Type() + " id=" + dev.ID())
+			health.Ready = true
+		}
+		report = append(report, health)
+	}
+	c.observeDeviceHealth(report)
+}
+
+// observeDeviceHealth publishes readiness changes: a fault when a device is first seen
+// not ready or drops out, a recovery when it comes back
+func (c *Coordinator) observeDeviceHealth(report []hal.DeviceHealth) {
+	c.healthMu.Lock()
+	defer c.healthMu.Unlock()
+	for _, dev := range report {
+		key := dev.Type + ":" + dev.ID
+		wasReady, known := c.deviceReady[key]
+		c.deviceReady[key] = dev.Ready
+		switch {
+		case !dev.Ready && (!known || wasReady):
+			reason := dev.Error
+			if reason == "" {
+				reason = "not ready"
+			}
+			c.Events.Publish(eventbus.TopicHAL, eventbus.HALDeviceFault, map[string]interface{}{
+				"device_type": dev.Type, "id": dev.ID, "error": reason,
+			})
+		case dev.Ready && known && !wasReady:
+			c.Events.Publish(eventbus.TopicHAL, eventbus.HALDeviceRecovered, map[string]interface{}{
+				"device_type": dev.Type, "id": dev.ID,
+			})
 		}
 	}
 }
@@ -512,12 +667,14 @@ func (c *Coordinator) UpdateFailsafeState() {
 			c.failsafe.Active = true
 			c.failsafe.Explanation = "Failsafe Mode: Home Assistant is offline and hardware is degraded. Limited UI and manual alarm control only."
 			logger.Error(c.failsafe.Explanation)
+			c.publishFailsafe()
 		}
 	} else {
 		if c.failsafe.Active {
 			c.failsafe.Active = false
 			c.failsafe.Explanation = "System recovered: Failsafe Mode exited."
 			logger.Info(c.failsafe.Explanation)
+			c.publishFailsafe()
 		}
 	}
 }
@@ -532,6 +689,14 @@ func (c *Coordinator) FailsafeExplanation() string {
 	return c.failsafe.Explanation
 }
 
+// publishFailsafe announces a failsafe flip on the event bus
+func (c *Coordinator) publishFailsafe() {
+	c.Events.Publish(eventbus.TopicSystem, eventbus.FailsafeChanged, map[string]interface{}{
+		"active":      c.failsafe.Active,
+		"explanation": c.failsafe.Explanation,
+	})
+}
+
 // DegradedMode returns true if HA is offline or hardware is missing
 func (c *Coordinator) DegradedMode() bool {
 	haOffline := c.HA == nil || !c.HA.IsConnected()
@@ -566,6 +731,10 @@ func (c *Coordinator) StartHealthMonitor() {
 			} else {
 				disconnects = 0
 			}
+			// Device readiness changes (logbook, notifications)
+			if c.HALRegistry != nil {
+				c.observeDeviceHealth(c.HALRegistry.DeviceHealthReport())
+			}
 			// Memory monitor
 			memChecks++
 			if memChecks >= memCheckInterval {
@@ -581,83 +750,296 @@ func (c *Coordinator) StartHealthMonitor() {
 	}()
 }
 
-// StartAlarmPolling starts a goroutine to poll Alarmo state every 2 seconds
-// A2: Keep synchronized with HA Alarmo integration; stops when ctx is cancelled
+// StartAlarmPolling starts Alarmo state synchronization; stops when ctx is cancelled
+// A2: Keep synchronized with HA Alarmo integration
+// A5: Push-based via HA WebSocket state_changed while connected, REST polling (2s) as fallback.
+// Every switch to push triggers a REST resync so transitions missed while disconnected are not lost.
 func (c *Coordinator) StartAlarmPolling(ctx context.Context) {
 	if c.AlarmoAdapter == nil {
 		logger.Error("alarmo: adapter not initialized, polling disabled")
 		return
 	}
 
-	go func() {
-		ticker := time.NewTicker(2 * time.Second)
-		defer ticker.Stop()
+	// Sources read the adapter on every fetch: it is replaced when HA credentials change
+	push := alarmo.NewPushSource(c.alarmoAdapter)
+	poll := alarmo.NewPollSource(c.alarmoAdapter, 2*time.Second)
 
-		logger.Info("alarmo: polling started (2s interval)")
+	c.AlarmoMu.Lock()
+	c.alarmoPush = push
+	c.AlarmoMu.Unlock()
 
-		for {
-			select {
-			case <-ctx.Done():
-				logger.Info("alarmo: polling stopped")
-				return
-			case <-ticker.C:
-				// Snapshot previous state to preserve countdown when HA omits attributes
-				c.AlarmoMu.RLock()
-				prevState := c.AlarmoState
-				c.AlarmoMu.RUnlock()
-				// Fetch latest state
-				fetchCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-				newState, err := c.AlarmoAdapter.FetchState(fetchCtx, prevState)
-				cancel()
-
-				if err != nil {
-					// Check if it's a 404 error (Alarmo not installed)
-					if strings.Contains(err.Error(), "http 404") {
-						// Alarmo not installed - log once and reduce noise
-						c.AlarmoMu.Lock()
-						if !c.failsafe.Active {
-							c.failsafe.Active = true
-							c.failsafe.Explanation = "Alarmo not installed in Home Assistant"
-							logger.Info("alarmo: not found (404) - alarm features disabled")
-						}
-						c.AlarmoMu.Unlock()
-						// Slow down polling when Alarmo is not installed (reduce log spam)
-						time.Sleep(58 * time.Second) // Sleep to make it ~60s total with ticker
-						continue
-					}
-					// Other errors - log and activate failsafe
-					logger.Error("alarmo: fetch error: " + err.Error())
-					c.AlarmoMu.Lock()
-					if !c.failsafe.Active {
-						c.failsafe.Active = true
-						c.failsafe.Explanation = "Alarmo unreachable"
-						logger.Error("alarmo: failsafe activated")
-					}
-					c.AlarmoMu.Unlock()
-					continue
-				}
+	go c.runAlarmoSync(ctx, push, poll)
+}
 
-				// Update state (thread-safe)
-				c.AlarmoMu.Lock()
-				if c.AlarmoState.Mode != newState.Mode || c.AlarmoState.ArmedMode != newState.ArmedMode {
-					oldMode := c.AlarmoState.Mode
-					oldArmed := c.AlarmoState.ArmedMode
-					c.AlarmoState = newState
-					logger.Info(fmt.Sprintf("alarmo state change: %s/%s -> %s/%s",
-						oldMode, oldArmed, newState.Mode, newState.ArmedMode))
-				} else {
-					c.AlarmoState = newState // Always update for timestamp
-				}
+// runAlarmoSync runs exactly one state source at a time, switching on HA connection changes
+func (c *Coordinator) runAlarmoSync(ctx context.Context, push *alarmo.PushSource, poll *alarmo.PollSource) {
+	for {
+		var src alarmo.StateSource = poll
+		reason := "HA websocket not connected"
+		if c.pushLive() {
+			src = push
+			reason = "HA websocket connected"
+		}
 
-				// Clear failsafe on successful fetch
-				if c.failsafe.Active {
-					c.failsafe.Active = false
-					logger.Info("alarmo: failsafe cleared")
-				}
-				c.AlarmoMu.Unlock()
+		c.AlarmoMu.Lock()
+		previous := c.alarmoSource
+		c.alarmoSource = src.Name()
+		c.alarmoSourceReason = reason
+		if src == alarmo.StateSource(push) {
+			c.alarmoLastResync = time.Now()
+			c.alarmoResyncs++
+		}
+		c.AlarmoMu.Unlock()
+
+		if previous != src.Name() {
+			logger.Info("alarmo: state source " + src.Name() + " (" + reason + ")")
+		}
+		if src == alarmo.StateSource(push) {
+			push.Resync()
+		}
+
+		srcCtx, cancel := context.WithCancel(ctx)
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			src.Run(srcCtx, c.alarmoSnapshot, c.applyAlarmoUpdate)
+		}()
+
+		select {
+		case <-ctx.Done():
+			cancel()
+			<-done
+			c.AlarmoMu.Lock()
+			c.alarmoSource = ""
+			c.AlarmoMu.Unlock()
+			logger.Info("alarmo: polling stopped")
+			return
+		case <-c.alarmoSwitch:
+			cancel()
+			<-done
+		}
+	}
+}
+
+// pushLive reports whether the HA WebSocket session is up, so state_changed events arrive.
+// A session that stops answering pings is dropped by the HA adapter, which falls back to polling.
+func (c *Coordinator) pushLive() bool {
+	if c.haConnected != nil {
+		return c.haConnected()
+	}
+	return c.HA != nil && c.HA.IsConnected()
+}
+
+// handleHAConnectionChange is called by the HA adapter when the WebSocket session goes up/down
+func (c *Coordinator) handleHAConnectionChange(connected bool) {
+	if connected {
+		logger.Info("coordinator: HA websocket connected")
+	} else {
+		logger.Error("coordinator: HA websocket disconnected")
+	}
+	c.Events.Publish(eventbus.TopicHA, eventbus.HAConnection, map[string]interface{}{"connected": connected})
+	select {
+	case c.alarmoSwitch <- struct{}{}:
+	default:
+		// Switch already pending
+	}
+}
+
+// alarmoAdapter returns the current Alarmo adapter (thread-safe)
+func (c *Coordinator) alarmoAdapter() *alarmo.Adapter {
+	c.AlarmoMu.RLock()
+	defer c.AlarmoMu.RUnlock()
+	return c.AlarmoAdapter
+}
+
+// alarmoSnapshot returns the current AlarmoState of an area (thread-safe)
+func (c *Coordinator) alarmoSnapshot(area string) alarmo.AlarmoState {
+	c.AlarmoMu.RLock()
+	defer c.AlarmoMu.RUnlock()
+	return c.AlarmoAreas[area]
+}
+
+// applyAlarmoUpdate stores a panel update from the active source.
+// Failsafe follows the primary panel; other areas only track their own reachability.
+func (c *Coordinator) applyAlarmoUpdate(panel alarmo.Panel, newState alarmo.AlarmoState, err error) {
+	adapter := c.alarmoAdapter()
+	if adapter == nil {
+		return
+	}
+	primary := panel.Area == adapter.Primary().Area
+
+	if err != nil {
+		c.AlarmoMu.Lock()
+		defer c.AlarmoMu.Unlock()
+
+		if !primary {
+			if c.alarmoAreaErr[panel.Area] == "" {
+				logger.Error("alarmo: area " + panel.Area + " unavailable: " + err.Error())
 			}
+			c.alarmoAreaErr[panel.Area] = err.Error()
+			return
 		}
-	}()
+		c.alarmoAreaErr[panel.Area] = err.Error()
+
+		// Check if it's a 404 error (Alarmo not installed)
+		if alarmo.IsNotFound(err) {
+			// Alarmo not installed - log once and reduce noise
+			if !c.failsafe.Active {
+				c.failsafe.Active = true
+				c.failsafe.Explanation = "Alarmo not installed in Home Assistant"
+				logger.Info("alarmo: not found (404) - alarm features disabled")
+				c.publishFailsafe()
+			}
+			return
+		}
+		// Other errors - log and activate failsafe
+		logger.Error("alarmo: fetch error: " + err.Error())
+		if !c.failsafe.Active {
+			c.failsafe.Active = true
+			c.failsafe.Explanation = "Alarmo unreachable"
+			logger.Error("alarmo: failsafe activated")
+			c.publishFailsafe()
+		}
+		return
+	}
+
+	c.storeAlarmoState(panel, primary, newState)
+
+	// A7: Local state machine mirrors the primary panel
+	if primary {
+		c.syncLocalAlarm(newState)
+	}
+
+	// A6: Correlate with pending arm/disarm commands
+	c.alarmCommands.observe(panel.Area, newState)
+}
+
+// LocalAlarmState maps an Alarmo state to the local alarm.StateMachine state (A7).
+// ok is false for states that carry no alarm information (unavailable, unknown, disarming).
+func LocalAlarmState(st alarmo.AlarmoState) (state string, armedMode string, ok bool) {
+	switch st.Mode {
+	case "disarmed":
+		return alarm.DISARMED, "", true
+	case "arming":
+		return alarm.ARMING, "", true
+	case "armed":
+		return alarm.ARMED, st.ArmedMode, true
+	case "pending":
+		// Entry delay: empty armed mode keeps the mode the panel was in
+		return alarm.PENDING, st.ArmedMode, true
+	case "triggered":
+		return alarm.TRIGGERED, "", true
+	}
+	return "", "", false
+}
+
+// syncLocalAlarm drives the local state machine from the primary Alarmo panel.
+// Returns true if the local state changed.
+func (c *Coordinator) syncLocalAlarm(st alarmo.AlarmoState) bool {
+	if c.Alarm == nil {
+		return false
+	}
+	state, armedMode, ok := LocalAlarmState(st)
+	if !ok {
+		return false
+	}
+	return c.Alarm.Sync(state, armedMode)
+}
+
+// storeAlarmoState records a successful panel update (thread-safe)
+func (c *Coordinator) storeAlarmoState(panel alarmo.Panel, primary bool, newState alarmo.AlarmoState) {
+	c.AlarmoMu.Lock()
+	defer c.AlarmoMu.Unlock()
+
+	prev, known := c.AlarmoAreas[panel.Area]
+	if !known || prev.Mode != newState.Mode || prev.ArmedMode != newState.ArmedMode {
+		logger.Info(fmt.Sprintf("alarmo state change [%s]: %s/%s -> %s/%s",
+			panel.Area, prev.Mode, prev.ArmedMode, newState.Mode, newState.ArmedMode))
+		c.Events.Publish(eventbus.TopicAlarm, eventbus.AlarmoStateChanged, map[string]interface{}{
+			"area":       panel.Area,
+			"primary":    primary,
+			"mode":       newState.Mode,
+			"armed_mode": newState.ArmedMode,
+			"raw_state":  newState.RawState,
+		})
+	}
+	c.AlarmoAreas[panel.Area] = newState // Always update for timestamp
+	if c.alarmoAreaErr[panel.Area] != "" && !primary {
+		logger.Info("alarmo: area " + panel.Area + " available")
+	}
+	delete(c.alarmoAreaErr, panel.Area)
+
+	if !primary {
+		return
+	}
+	c.AlarmoState = newState
+
+	// Clear failsafe on successful fetch
+	if c.failsafe.Active {
+		c.failsafe.Active = false
+		logger.Info("alarmo: failsafe cleared")
+		c.publishFailsafe()
+	}
+}
+
+// AlarmoArea is a per-area Alarmo snapshot
+type AlarmoArea struct {
+	Panel     alarmo.Panel
+	State     alarmo.AlarmoState
+	Available bool   // False while the last fetch for this area failed
+	Error     string // Last fetch error (empty when available)
+	Primary   bool
+}
+
+// SetAlarmoPanels configures which Alarmo areas are followed (startup config)
+func (c *Coordinator) SetAlarmoPanels(panels []alarmo.Panel) error {
+	adapter := c.alarmoAdapter()
+	if adapter == nil {
+		return fmt.Errorf("alarmo adapter not initialized")
+	}
+	if err := adapter.SetPanels(panels); err != nil {
+		return err
+	}
+
+	// Primary may have changed: seed its area from the last known primary state
+	primary := adapter.Primary()
+	c.AlarmoMu.Lock()
+	if _, ok := c.AlarmoAreas[primary.Area]; !ok && c.AlarmoState.Mode != "" {
+		c.AlarmoAreas[primary.Area] = c.AlarmoState
+	}
+	push := c.alarmoPush
+	c.AlarmoMu.Unlock()
+
+	if push != nil {
+		push.Resync()
+	}
+	logger.Info(fmt.Sprintf("alarmo: following %d panel(s), primary=%s", len(adapter.Panels()), primary.Area))
+	return nil
+}
+
+// AlarmoAreaStates returns a snapshot of every configured area (primary first)
+func (c *Coordinator) AlarmoAreaStates() []AlarmoArea {
+	adapter := c.alarmoAdapter()
+	if adapter == nil {
+		return []AlarmoArea{}
+	}
+	panels := adapter.Panels()
+
+	c.AlarmoMu.RLock()
+	defer c.AlarmoMu.RUnlock()
+	out := make([]AlarmoArea, 0, len(panels))
+	for i, p := range panels {
+		state, known := c.AlarmoAreas[p.Area]
+		errMsg := c.alarmoAreaErr[p.Area]
+		out = append(out, AlarmoArea{
+			Panel:     p,
+			State:     state,
+			Available: known && errMsg == "",
+			Error:     errMsg,
+			Primary:   i == 0,
+		})
+	}
+	return out
 }
 
 // RequestAlarmAction sends a controlled arm/disarm request to Alarmo
@@ -666,43 +1048,77 @@ func (c *Coordinator) StartAlarmPolling(ctx context.Context) {
 // Returns error if request fails or validation fails
 // Caller must wait for polling to reflect changes
 func (c *Coordinator) RequestAlarmAction(ctx context.Context, action string) error {
-	if c.AlarmoAdapter == nil {
+	_, err := c.RequestAreaAlarmAction(ctx, AlarmActionRequest{Action: action})
+	return err
+}
+
+// AlarmActionRequest describes one arm/disarm request to Alarmo
+type AlarmActionRequest struct {
+	Area        string // "" = primary panel
+	Action      string // arm_home | arm_away | arm_night | disarm
+	Code        string // Alarmo user code ("" = none); passed through, never logged or audited
+	RequestedBy string // Username for logbook ("" = system)
+}
+
+// RequestAreaAlarmAction sends an arm/disarm request to one Alarmo area
+// A5: Empty area targets the primary panel; validation uses that area's own state
+// A6: Returns the tracked command; its status resolves when Alarmo state confirms it or times out
+func (c *Coordinator) RequestAreaAlarmAction(ctx context.Context, req AlarmActionRequest) (AlarmCommand, error) {
+	area, action, code := req.Area, req.Action, req.Code
+
+	adapter := c.alarmoAdapter()
+	if adapter == nil {
 		logger.Error("alarmo: adapter not initialized")
-		return fmt.Errorf("alarmo adapter not initialized")
+		return AlarmCommand{}, fmt.Errorf("alarmo adapter not initialized")
+	}
+
+	panel, ok := adapter.Panel(area)
+	if !ok {
+		logger.Error(fmt.Sprintf("alarmo action rejected: unknown area (area=%s action=%s)", area, action))
+		return AlarmCommand{}, fmt.Errorf("%w: %s", alarmo.ErrUnknownArea, area)
 	}
+	primary := panel.Area == adapter.Primary().Area
 
 	// Read current state for validation
 	c.AlarmoMu.RLock()
-	currentState := c.AlarmoState
-	alarmoReachable := !c.failsafe.Active
+	currentState := c.AlarmoAreas[panel.Area]
+	alarmoReachable := c.alarmoAreaErr[panel.Area] == ""
+	if primary {
+		currentState = c.AlarmoState
+		alarmoReachable = !c.failsafe.Active
+	}
 	c.AlarmoMu.RUnlock()
 
 	// Validation: reject if Alarmo is unreachable
 	if !alarmoReachable {
-		logger.Error(fmt.Sprintf("alarmo action rejected: alarmo unreachable (action=%s)", action))
-		return fmt.Errorf("alarmo unreachable")
+		logger.Error(fmt.Sprintf("alarmo action rejected: alarmo unreachable (area=%s action=%s)", panel.Area, action))
+		return AlarmCommand{}, fmt.Errorf("alarmo unreachable")
 	}
 
 	// Validation: reject arm/disarm if triggered
 	if currentState.Triggered || currentState.Mode == "triggered" {
-		logger.Error(fmt.Sprintf("alarmo action rejected: system triggered (action=%s)", action))
-		return fmt.Errorf("action blocked: system triggered")
+		logger.Error(fmt.Sprintf("alarmo action rejected: system triggered (area=%s action=%s)", panel.Area, action))
+		return AlarmCommand{}, fmt.Errorf("action blocked: system triggered")
 	}
 
 	// Log action request (INFO level, action name only)
-	logger.Info(fmt.Sprintf("alarmo action requested: %s", action))
-	audit.Record("alarmo_action", action)
+	logger.Info(fmt.Sprintf("alarmo action requested: %s (area=%s code_provided=%v)", action, panel.Area, code != ""))
+	audit.Record("alarmo_action", panel.Area+":"+action)
+
+	// Track before sending so a fast pushed state cannot be missed
+	cmd := c.alarmCommands.track(panel.Area, action, req.RequestedBy, currentState)
 
 	// Send request to Alarmo (non-blocking, does not modify state)
-	err := c.AlarmoAdapter.RequestAction(ctx, action)
+	err := adapter.RequestAreaAction(ctx, panel.Area, action, code)
 	if err != nil {
-		logger.Error(fmt.Sprintf("alarmo action failed: %s (error=%s)", action, err.Error()))
-		audit.Record("alarmo_action_failed", action)
-		return fmt.Errorf("alarmo action failed: %w", err)
+		logger.Error(fmt.Sprintf("alarmo action failed: %s (area=%s error=%s)", action, panel.Area, err.Error()))
+		audit.Record("alarmo_action_failed", panel.Area+":"+action)
+		cmd = c.alarmCommands.fail(cmd.ID, "service call failed")
+		return cmd, fmt.Errorf("alarmo action failed: %w", err)
 	}
 
-	logger.Info(fmt.Sprintf("alarmo action sent: %s (waiting for state change)", action))
-	return nil
+	logger.Info(fmt.Sprintf("alarmo action sent: %s (area=%s command=%s, waiting for state change)", action, panel.Area, cmd.ID))
+	return cmd, nil
 }
 
 // === SELF-CHECK & DIAGNOSTICS ===
@@ -738,7 +1154,27 @@ func (c *Coordinator) SelfCheck() SelfCheckResult {
 		details = append(details, "AI engine NOT running")
 	}
 
+	// A5: Report which Alarmo state source is active and when we last resynced
+	c.AlarmoMu.RLock()
+	alarmoSource := c.alarmoSource
+	alarmoReason := c.alarmoSourceReason
+	lastResync := c.alarmoLastResync
+	resyncs := c.alarmoResyncs
+	c.AlarmoMu.RUnlock()
+	switch alarmoSource {
+	case alarmo.SourcePush:
+		details = append(details, "Alarmo source: push (websocket)")
+	case alarmo.SourcePoll:
+		details = append(details, "Alarmo source: polling fallback ("+alarmoReason+")")
+	default:
+		details = append(details, "Alarmo source: not running")
+	}
+	if !lastResync.IsZero() {
+		details = append(details, fmt.Sprintf("Alarmo last resync: %s (%d total)", lastResync.UTC().Format(time.RFC3339), resyncs))
+	}
+
 	hardware := c.HALRegistry.DeviceHealthReport()
+	c.observeDeviceHealth(hardware)
 	for _, dev := range hardware {
 		if dev.Error != "" {
 			logger.Info("hardware error: " + dev.Type + " id=" + dev.ID + " err=" + dev.Error)
@@ -752,11 +1188,12 @@ func (c *Coordinator) SelfCheck() SelfCheckResult {
 	}
 
 	return SelfCheckResult{
-		HAConnected: haOk,
-		AlarmValid:  alarmOk,
-		AIRunning:   aiOk,
-		Details:     details,
-		Hardware:    hardware,
+		HAConnected:  haOk,
+		AlarmValid:   alarmOk,
+		AIRunning:    aiOk,
+		AlarmoSource: alarmoSource,
+		Details:      details,
+		Hardware:     hardware,
 	}
 }
 
@@ -764,9 +1201,27 @@ func (c *Coordinator) SelfCheck() SelfCheckResult {
 
 // HandleHAEvent handles events from Home Assistant adapter
 func (c *Coordinator) HandleHAEvent(event haadapter.Event) {
+	// state_changed arrives for every entity in HA: keep it quiet and skip the AI feed
+	if event.Type == haadapter.EventStateChanged {
+		entityID, _ := event.Payload["entity_id"].(string)
+		logger.Debug("coordinator: ha state_changed " + entityID)
+
+		// A5: Alarmo transitions are pushed straight to the state sync
+		c.AlarmoMu.RLock()
+		push := c.alarmoPush
+		c.AlarmoMu.RUnlock()
+		if push != nil {
+			newState, _ := event.Payload["new_state"].(map[string]interface{})
+			push.HandleStateChanged(entityID, newState)
+		}
+
+		c.HA.HandleEvent(event)
+		return
+	}
+
 	logger.Info("coordinator: handling HA event")
 	c.HA.HandleEvent(event)
-	c.feedAI()
+	c.Events.Publish(eventbus.TopicHA, eventbus.HAEvent, map[string]interface{}{"event_type": event.Type})
 }
 
 // ArrivalDetected is called when arrival is detected
@@ -837,6 +1292,9 @@ func (c *Coordinator) SetLEDState(ledID string, alarmState string) {
 	case "ARMED":
 		color = [3]uint8{255, 255, 0}
 		mode = "solid"
+	case "PENDING":
+		color = [3]uint8{255, 128, 0}
+		mode = "blink"
 	case "TRIGGERED":
 		color = [3]uint8{255, 0, 0}
 		mode = "blink"
@@ -855,6 +1313,9 @@ func (c *Coordinator) SetLEDState(ledID string, alarmState string) {
 func (c *Coordinator) HandleRFEvent(code string) {
 	if code != "" {
 		logger.Info("rf433 code: " + code)
+		c.Events.Publish(eventbus.TopicHAL, eventbus.HALSignal, map[string]interface{}{
+			"device_type": "rf433", "value": code,
+		})
 	}
 }
 
@@ -865,14 +1326,27 @@ func (c *Coordinator) HandleRF433Edges(id string, edges []interface{}) {
 	}
 	logger.Info("rf433 edges: " + id)
 	audit.Record("domain_event", "remote_signal: "+id)
+	c.Events.Publish(eventbus.TopicHAL, eventbus.HALSignal, map[string]interface{}{
+		"device_type": "rf433", "id": id, "edges": len(edges),
+	})
 }
 
 // HandleRFIDEvent handles RFID card scans
 func (c *Coordinator) HandleRFIDEvent(cardID string) {
 	if cardID != "" {
 		logger.Info("rfid scanned: " + cardID)
+		c.Events.Publish(eventbus.TopicHAL, eventbus.HALSignal, map[string]interface{}{
+			"device_type": "rfid", "value": cardID,
+		})
 		if cardID == "EXIT" {
 			c.LeavingHomeDetected("rfid_exit")
+			return
+		}
+		// A guest pass card admits its guest
+		if req, err := c.RedeemGuestPass("", cardID, "rfid"); err == nil {
+			logger.Info("rfid: guest pass redeemed, request_id=" + req.ID)
+		} else if !errors.Is(err, guest.ErrPassNotFound) {
+			logger.Error("rfid: guest pass refused: " + err.Error())
 		}
 	}
 }
@@ -886,7 +1360,7 @@ func (c *Coordinator) feedAI() {
 		return
 	}
 	alarmState := c.Alarm.CurrentState()
-	guestState := c.Guest.CurrentState()
+	guestState := c.GuestRequest.CurrentState()
 	c.AI.Observe(alarmState, guestState, c.DeviceStates...)
 	c.lastInsight = c.AI.GetCurrentInsight()
 	logger.Info("ai insight: " + c.lastInsight.Detail)
@@ -1038,18 +1512,26 @@ func (c *Coordinator) setupGuestApprovalCallbacks() {
 	c.GuestRequest.SetApprovedCallback(func(req *guest.GuestRequest) error {
 		logger.Info("guest approval callback triggered: request_id=" + req.ID)
 
-		// Step 1: Disarm alarm via Alarmo
-		if c.AlarmoAdapter != nil {
-			// Request Alarmo disarm action
+		// Remember the armed mode so it can be restored when the guest leaves
+		c.GuestScreen.OnApproval(c.rememberAlarmModeForGuest())
+
+		// Step 1: Disarm alarm via Alarmo, tracked like any other command.
+		// No RequestedBy: this is the system acting, not a user overriding the re-arm.
+		if c.alarmoAdapter() != nil {
+			code := c.guestAlarmoCode("guest approval")
 			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-			err := c.AlarmoAdapter.RequestAction(ctx, "disarm")
+			cmd, err := c.RequestAreaAlarmAction(ctx, AlarmActionRequest{Action: "disarm", Code: code})
 			cancel()
 
 			if err != nil {
-				logger.Error("guest approval: alarmo disarm failed: " + err.Error())
 				// Don't fail the approval - HA might be offline
+				msg := "guest approval: alarmo disarm failed: " + err.Error()
+				if cmd.ID != "" {
+					msg += " (command=" + cmd.ID + ")"
+				}
+				logger.Error(msg)
 			} else {
-				logger.Info("guest approval: alarmo disarm requested successfully")
+				logger.Info("guest approval: alarmo disarm requested (command=" + cmd.ID + " status=" + string(cmd.Status) + ")")
 			}
 		}
 
@@ -1067,6 +1549,34 @@ func (c *Coordinator) setupGuestApprovalCallbacks() {
 		return nil
 	})
 
+	// Concurrency and rate limits follow the Security settings
+	c.GuestRequest.SetLimits(c.guestLimits)
+
+	// Every guest's state machine transition goes on the bus (AI, logbook)
+	c.GuestRequest.SetActionCallback(func(req guest.GuestRequest, action, from string) {
+		c.Events.Publish(eventbus.TopicGuest, eventbus.GuestAction, map[string]interface{}{
+			"id":     req.ID,
+			"action": action,
+			"from":   from,
+			"state":  req.State,
+		})
+		// Exit, timeout or pass expiry: re-arm once the last guest is gone
+		if from == guest.APPROVED && (action == guest.EXIT || action == guest.TIMEOUT) {
+			go c.guestSessionEnded(req.ID + " " + action)
+		}
+	})
+
+	// Every request status change goes on the bus (logbook, UI stream)
+	c.GuestRequest.SetChangeCallback(func(req guest.GuestRequest) {
+		c.Events.Publish(eventbus.TopicGuest, eventbus.GuestRequestChanged, map[string]interface{}{
+			"id":          req.ID,
+			"status":      req.Status,
+			"target_user": req.TargetUser,
+			"pass_id":     req.PassID,
+			"guest_name":  req.GuestName,
+		})
+	})
+
 	// Rejected callback: Send HA notification
 	c.GuestRequest.SetRejectedCallback(func(req *guest.GuestRequest) error {
 		logger.Info("guest rejection callback triggered: request_id=" + req.ID)
@@ -1088,6 +1598,18 @@ func (c *Coordinator) setupGuestApprovalCallbacks() {
 	logger.Info("guest approval callbacks wired successfully")
 }
 
+// guestLimits reads the guest limits from settings
+func (c *Coordinator) guestLimits() guest.Limits {
+	if c.Settings == nil {
+		return guest.Limits{MaxActive: 1}
+	}
+	return guest.Limits{
+		MaxActive:      c.Settings.SecurityInt("guest_max_active", 1),
+		MaxPerHour:     c.Settings.SecurityInt("guest_max_requests_per_hour", 10),
+		RequestTimeout: time.Duration(c.Settings.SecurityInt("guest_request_timeout_s", 300)) * time.Second,
+	}
+}
+
 // sendHANotification sends a mobile notification to a specific HA user
 func (c *Coordinator) sendHANotification(targetUser string, payload map[string]interface{}) error {
 	if c.HA == nil {
@@ -0,0 +1,45 @@
+package system
+
+import (
+	"fmt"
+	"smartdisplay-core/internal/eventbus"
+	"smartdisplay-core/internal/hanotify"
+	"smartdisplay-core/internal/logger"
+)
+
+// === DURESS ALARM ===
+// A disarm with a duress PIN completes normally on the display; the help signal
+// goes out silently (HA notification + HA event) and only admins see it in the logbook.
+
+// DuressEventType is the HA event fired for a duress disarm, for HA automations
+const DuressEventType = "smartdisplay_duress"
+
+// SignalDuress raises the silent alarm for a disarm made under duress
+// The HA signal is sent from its own goroutine rather than a bus subscriber, so a
+// burst of other events can never drop it; the bus event feeds the logbook.
+func (c *Coordinator) SignalDuress(username, area string) {
+	payload := map[string]interface{}{
+		"username": username,
+		"area":     area,
+	}
+	go c.raiseDuressAlarm(payload)
+	c.Events.Publish(eventbus.TopicAlarm, eventbus.AlarmDuress, payload)
+}
+
+// raiseDuressAlarm sends the duress signal to HA
+func (c *Coordinator) raiseDuressAlarm(payload map[string]interface{}) {
+	if c.Notifier != nil {
+		if err := c.Notifier.Notify(hanotify.DuressAlarm, payload); err != nil {
+			logger.Error("duress notification failed: " + err.Error())
+		}
+	}
+	if c.HA == nil {
+		logger.Error("duress event not sent: HA adapter not available")
+		return
+	}
+	if err := c.HA.FireEvent(DuressEventType, payload); err != nil {
+		logger.Error("duress event failed: " + err.Error())
+		return
+	}
+	logger.Info(fmt.Sprintf("duress event sent: area=%v", payload["area"]))
+}
@@ -0,0 +1,46 @@
+package system
+
+import (
+	"smartdisplay-core/internal/eventbus"
+	"smartdisplay-core/internal/hanotify"
+	"testing"
+	"time"
+)
+
+// stuckNotifier blocks on hardware faults and reports duress alarms
+type stuckNotifier struct {
+	release chan struct{}
+	duress  chan map[string]interface{}
+}
+
+func (n *stuckNotifier) Notify(ntype string, payload map[string]interface{}) error {
+	if ntype == hanotify.DuressAlarm {
+		n.duress <- payload
+		return nil
+	}
+	<-n.release
+	return nil
+}
+
+func TestDuressSignalNotDroppedByBusyNotifications(t *testing.T) {
+	notifier := &stuckNotifier{release: make(chan struct{}), duress: make(chan map[string]interface{}, 1)}
+	c := &Coordinator{Events: eventbus.New(), Notifier: notifier}
+	c.setupEventSubscribers()
+	defer c.Events.Close()
+	defer close(notifier.release)
+
+	// The notifications queue is stuck on a slow HA call and overflows
+	for i := 0; i < 200; i++ {
+		c.Events.Publish(eventbus.TopicHAL, eventbus.HALDeviceFault, map[string]interface{}{"device_type": "rf433", "id": "rf_1"})
+	}
+	c.SignalDuress("ayse", "main")
+
+	select {
+	case payload := <-notifier.duress:
+		if payload["username"] != "ayse" || payload["area"] != "main" {
+			t.Errorf("duress payload = %v", payload)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("duress alarm dropped while notifications were busy")
+	}
+}
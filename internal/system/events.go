@@ -0,0 +1,90 @@
+package system
+
+import (
+	"smartdisplay-core/internal/eventbus"
+	"smartdisplay-core/internal/logger"
+)
+
+// === EVENT BUS WIRING ===
+// Coordinator publishes alarm, guest, HAL and HA events on c.Events;
+// AI, LEDs, notifications and the logbook react as bus subscribers.
+
+// setupEventSubscribers registers the built-in subscribers
+func (c *Coordinator) setupEventSubscribers() {
+	c.Events.Subscribe("ai", c.onEventForAI,
+		eventbus.Topics(eventbus.TopicAlarm, eventbus.TopicGuest, eventbus.TopicHA),
+		eventbus.Policy(eventbus.DropOldest))
+	c.Events.Subscribe("leds", c.onEventForLEDs,
+		eventbus.Topics(eventbus.TopicAlarm),
+		eventbus.Policy(eventbus.DropOldest))
+	c.Events.Subscribe("notifications", c.onEventForNotifications,
+		eventbus.Topics(eventbus.TopicAlarm, eventbus.TopicHAL))
+	c.Events.Subscribe("logbook", c.onEventForLogbook, eventbus.Buffer(256))
+}
+
+// Close stops the bus subscribers, waiting for handlers still running, then
+// the logbook and guest stores, so no append or rewrite is cut off by the exit.
+// Call it at shutdown once nothing publishes any more.
+func (c *Coordinator) Close() {
+	if c.Events != nil {
+		c.Events.Close()
+	}
+	if c.Logbook != nil {
+		if err := c.Logbook.Close(); err != nil {
+			logger.Error("logbook close: " + err.Error())
+		}
+	}
+	if c.GuestRequest != nil {
+		c.GuestRequest.Close()
+	}
+	if c.GuestPasses != nil {
+		c.GuestPasses.Close()
+	}
+}
+
+// onEventForAI refreshes insights and smart scenarios after state changes
+func (c *Coordinator) onEventForAI(ev eventbus.Event) {
+	switch ev.Type {
+	case eventbus.AlarmStateChanged, eventbus.GuestAction:
+		c.feedAI()
+		c.CheckSmartAlarmScenarios()
+	case eventbus.HAEvent:
+		c.feedAI()
+	}
+}
+
+// onEventForLEDs mirrors the local alarm state on every RGB LED
+func (c *Coordinator) onEventForLEDs(ev eventbus.Event) {
+	if ev.Type != eventbus.AlarmStateChanged || c.HALRegistry == nil {
+		return
+	}
+	state, _ := ev.Payload["to"].(string)
+	for _, dev := range c.ListDevices() {
+		if dev.Type() == "rgb_led" {
+			c.SetLEDState(dev.ID(), state)
+		}
+	}
+}
+
+// onEventForNotifications forwards alarm triggers and hardware faults to HA
+// (duress is sent directly by SignalDuress, never through this queue)
+func (c *Coordinator) onEventForNotifications(ev eventbus.Event) {
+	if c.Notifier == nil {
+		return
+	}
+	switch ev.Type {
+	case eventbus.AlarmStateChanged:
+		if ev.Payload["to"] != "TRIGGERED" {
+			return
+		}
+		c.Notifier.Notify("AlarmTriggered", map[string]interface{}{
+			"reason":      ev.Payload["event"],
+			"quiet_hours": false,
+		})
+	case eventbus.HALDeviceFault:
+		c.Notifier.Notify("HardwareFault", ev.Payload)
+	default:
+		return
+	}
+	logger.Info("notification sent for " + ev.Type)
+}
@@ -0,0 +1,50 @@
+package system
+
+import (
+	"errors"
+	"smartdisplay-core/internal/guest"
+	"smartdisplay-core/internal/logger"
+)
+
+// === GUEST PASSES ===
+// Passes are redeemed at the display (code) or on the RFID reader (card); the guest
+// is admitted like an approved request, so the approval callback disarms Alarmo and
+// notifies HA. When the pass's access runs out the house is re-armed like after
+// any other guest (see guest_rearm.go).
+
+// RedeemGuestPass admits the guest holding the pass with code or cardID
+func (c *Coordinator) RedeemGuestPass(code, cardID, source string) (*guest.GuestRequest, error) {
+	if c.FirstBoot != nil && c.FirstBoot.Active() {
+		logger.Info("firstboot: guest pass blocked during setup")
+		return nil, errors.New("guest passes blocked during setup")
+	}
+	if c.GuestPasses == nil || c.GuestRequest == nil {
+		return nil, errors.New("guest passes not available")
+	}
+	var admitted *guest.GuestRequest
+	_, err := c.GuestPasses.Redeem(code, cardID, func(p guest.Pass) error {
+		req, err := c.GuestRequest.Admit(p, source)
+		admitted = req
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return admitted, nil
+}
+
+// RemoveGuestPass deletes a pass and ends the access of guests admitted with it
+func (c *Coordinator) RemoveGuestPass(id string) error {
+	if err := c.GuestPasses.Remove(id); err != nil {
+		return err
+	}
+	for _, req := range c.GuestRequest.Requests() {
+		if req.PassID != id || req.Status != guest.StatusApproved {
+			continue
+		}
+		if err := c.GuestRequest.ExitGuest(req.ID); err != nil {
+			logger.Error("guest pass removed: ending access of " + req.ID + " failed: " + err.Error())
+		}
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+package system
+
+import (
+	"smartdisplay-core/internal/guest"
+	"testing"
+	"time"
+)
+
+func TestRemoveGuestPassEndsItsSessions(t *testing.T) {
+	c := &Coordinator{
+		GuestRequest: guest.NewManager(time.Minute),
+		GuestPasses:  guest.NewPassBook(),
+	}
+	now := time.Now()
+	pass, err := c.GuestPasses.Add(guest.Pass{
+		Name:       "Temizlikçi",
+		ValidFrom:  now.Add(-time.Hour),
+		ValidUntil: now.Add(time.Hour),
+		Recurring:  true,
+	}, "482913")
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, err := c.RedeemGuestPass("482913", "", "display")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.RemoveGuestPass(pass.ID); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := c.GuestRequest.Get(req.ID); got.Status != guest.StatusEnded {
+		t.Errorf("guest admitted with removed pass = %s, want ended", got.Status)
+	}
+	if _, err := c.RedeemGuestPass("482913", "", "display"); err == nil {
+		t.Error("removed pass still redeemable")
+	}
+}
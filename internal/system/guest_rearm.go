@@ -0,0 +1,304 @@
+package system
+
+import (
+	"context"
+	"errors"
+	"math"
+	"smartdisplay-core/internal/auth"
+	"smartdisplay-core/internal/eventbus"
+	"smartdisplay-core/internal/guest"
+	"smartdisplay-core/internal/ha/alarmo"
+	"smartdisplay-core/internal/logger"
+	"sync"
+	"time"
+)
+
+// === GUEST RE-ARM ===
+// Admitting a guest disarms Alarmo; the mode it was armed in is remembered. When the
+// last guest's access ends (exit, timeout or pass expiry) the house is armed back to
+// that mode after guest_rearm_delay_s, unless someone cancels it on the display.
+// The mode and any pending deadline are saved with the guest requests, so a restart
+// in between still re-arms (see RestoreGuestRearm).
+
+// Re-arm statuses published with eventbus.GuestRearm
+const (
+	RearmScheduled = "scheduled"
+	RearmCancelled = "cancelled"
+	RearmArmed     = "armed"
+	RearmFailed    = "failed"
+)
+
+// ErrNoRearmPending is returned when there is no re-arm to cancel
+var ErrNoRearmPending = errors.New("no re-arm pending")
+
+// guestRearm holds the mode to restore and the pending re-arm
+type guestRearm struct {
+	mu     sync.Mutex
+	action string // Alarmo action restoring the mode before the first guest ("" = was not armed)
+	before string // Alarmo raw state before the first guest
+	armAt  time.Time
+	timer  *time.Timer // Non-nil while a re-arm is pending
+	gen    int         // Identifies the pending timer, so a stale one does nothing
+}
+
+// armActionFor returns the Alarmo action that restores st ("" when not armed)
+func armActionFor(st alarmo.AlarmoState) string {
+	if st.Mode != "armed" {
+		return ""
+	}
+	switch st.ArmedMode {
+	case "home", "away", "night", "vacation", "custom_bypass":
+		return "arm_" + st.ArmedMode
+	}
+	return ""
+}
+
+// guestRearmDelay reads the exit delay before re-arming from settings
+func (c *Coordinator) guestRearmDelay() time.Duration {
+	if c.Settings == nil {
+		return 60 * time.Second
+	}
+	return time.Duration(c.Settings.SecurityInt("guest_rearm_delay_s", 60)) * time.Second
+}
+
+// rememberAlarmModeForGuest records the Alarmo mode before a guest is let in.
+// The first guest decides; a guest admitted during the exit delay stops the re-arm.
+func (c *Coordinator) rememberAlarmModeForGuest() string {
+	c.AlarmoMu.RLock()
+	st := c.AlarmoState
+	c.AlarmoMu.RUnlock()
+
+	r := &c.guestRearm
+	r.mu.Lock()
+	stopped := r.timer != nil
+	if stopped {
+		r.timer.Stop()
+		r.timer = nil
+		r.armAt = time.Time{}
+	} else if r.action == "" {
+		r.action = armActionFor(st)
+		r.before = st.RawState
+	}
+	before := r.before
+	c.saveGuestRearmLocked()
+	r.mu.Unlock()
+
+	if stopped {
+		logger.Info("guest re-arm: stopped, new guest admitted")
+		c.Events.Publish(eventbus.TopicGuest, eventbus.GuestRearm, map[string]interface{}{
+			"status": RearmCancelled,
+			"reason": "guest admitted",
+		})
+	}
+	return before
+}
+
+// guestSessionEnded starts the exit delay once no guest has access any more
+func (c *Coordinator) guestSessionEnded(reason string) {
+	if _, active := c.GuestRequest.Counts(); active > 0 {
+		return
+	}
+	delay := c.guestRearmDelay()
+
+	r := &c.guestRearm
+	r.mu.Lock()
+	if r.action == "" || r.timer != nil {
+		r.mu.Unlock()
+		return
+	}
+	action := r.action
+	armAt := c.scheduleRearmLocked(delay)
+	r.mu.Unlock()
+
+	logger.Info("guest re-arm: " + action + " scheduled at " + armAt.Format(time.RFC3339) + " (" + reason + ")")
+	c.Events.Publish(eventbus.TopicGuest, eventbus.GuestRearm, map[string]interface{}{
+		"status": RearmScheduled,
+		"action": action,
+		"arm_at": armAt.UTC().Format(time.RFC3339),
+		"reason": reason,
+	})
+}
+
+// scheduleRearmLocked starts the exit delay timer and saves its deadline
+func (c *Coordinator) scheduleRearmLocked(delay time.Duration) time.Time {
+	r := &c.guestRearm
+	r.gen++
+	gen := r.gen
+	r.armAt = time.Now().Add(delay)
+	r.timer = time.AfterFunc(delay, func() { c.rearmAfterGuests(gen) })
+	c.saveGuestRearmLocked()
+	return r.armAt
+}
+
+// saveGuestRearmLocked stores the re-arm state with the guest requests
+func (c *Coordinator) saveGuestRearmLocked() {
+	if c.GuestRequest == nil {
+		return
+	}
+	r := &c.guestRearm
+	if r.action == "" {
+		c.GuestRequest.SaveRearm(nil)
+		return
+	}
+	c.GuestRequest.SaveRearm(&guest.RearmState{Action: r.action, Before: r.before, ArmAt: r.armAt})
+}
+
+// RestoreGuestRearm picks up the re-arm saved before a restart: a pending one
+// resumes with its remaining time (or runs now if its deadline passed), and the
+// exit delay starts if the guests' access ran out while the system was down.
+// Call it after GuestRequest.Open.
+func (c *Coordinator) RestoreGuestRearm() {
+	st := c.GuestRequest.SavedRearm()
+	if st == nil || st.Action == "" {
+		return
+	}
+	r := &c.guestRearm
+	r.mu.Lock()
+	if r.action != "" {
+		r.mu.Unlock()
+		return
+	}
+	r.action, r.before = st.Action, st.Before
+	pending := !st.ArmAt.IsZero()
+	var armAt time.Time
+	if pending {
+		armAt = c.scheduleRearmLocked(max(time.Until(st.ArmAt), 0))
+	}
+	r.mu.Unlock()
+
+	if !pending {
+		logger.Info("guest re-arm: restored " + st.Action + ", waiting for the guests")
+		c.guestSessionEnded("restart")
+		return
+	}
+	logger.Info("guest re-arm: restored " + st.Action + " at " + armAt.Format(time.RFC3339))
+	c.Events.Publish(eventbus.TopicGuest, eventbus.GuestRearm, map[string]interface{}{
+		"status": RearmScheduled,
+		"action": st.Action,
+		"arm_at": armAt.UTC().Format(time.RFC3339),
+		"reason": "restart",
+	})
+}
+
+// guestAlarmoCode returns the Alarmo code sent when the system arms or disarms for
+// guests: that of the user set in guest_alarmo_user ("" = none set). The identity used
+// is logged, as Alarmo records the action under that user.
+func (c *Coordinator) guestAlarmoCode(purpose string) string {
+	username := ""
+	if c.Settings != nil {
+		username = c.Settings.SecurityString("guest_alarmo_user", "")
+	}
+	if username == "" {
+		logger.Info(purpose + ": no guest_alarmo_user set, sending no alarmo code")
+		return ""
+	}
+	code, err := auth.AlarmoCode(username)
+	if err != nil {
+		logger.Error(purpose + ": alarmo code lookup failed for " + username + ": " + err.Error())
+		return ""
+	}
+	if code == "" {
+		logger.Error(purpose + ": guest_alarmo_user " + username + " has no alarmo code, sending none")
+		return ""
+	}
+	logger.Info(purpose + ": using the alarmo code of " + username)
+	return code
+}
+
+// rearmAfterGuests arms Alarmo back to the remembered mode when the exit delay ends
+func (c *Coordinator) rearmAfterGuests(gen int) {
+	r := &c.guestRearm
+	r.mu.Lock()
+	if r.timer == nil || r.gen != gen {
+		r.mu.Unlock()
+		return
+	}
+	action := r.action
+	r.timer = nil
+	r.armAt = time.Time{}
+	r.action, r.before = "", ""
+	c.saveGuestRearmLocked()
+	r.mu.Unlock()
+
+	code := c.guestAlarmoCode("guest re-arm")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	payload := map[string]interface{}{"status": RearmArmed, "action": action}
+	if _, err := c.RequestAreaAlarmAction(ctx, AlarmActionRequest{Action: action, Code: code}); err != nil {
+		logger.Error("guest re-arm failed: " + err.Error())
+		payload["status"] = RearmFailed
+		payload["reason"] = err.Error()
+	} else {
+		logger.Info("guest re-arm: " + action + " requested")
+	}
+	c.Events.Publish(eventbus.TopicGuest, eventbus.GuestRearm, payload)
+}
+
+// CancelGuestRearm stops a pending re-arm; the house stays disarmed
+func (c *Coordinator) CancelGuestRearm(by string) error {
+	r := &c.guestRearm
+	r.mu.Lock()
+	if r.timer == nil {
+		r.mu.Unlock()
+		return ErrNoRearmPending
+	}
+	r.timer.Stop()
+	action := r.action
+	r.timer = nil
+	r.armAt = time.Time{}
+	r.action, r.before = "", ""
+	c.saveGuestRearmLocked()
+	r.mu.Unlock()
+
+	logger.Info("guest re-arm: cancelled by " + by)
+	c.Events.Publish(eventbus.TopicGuest, eventbus.GuestRearm, map[string]interface{}{
+		"status": RearmCancelled,
+		"action": action,
+		"by":     by,
+	})
+	return nil
+}
+
+// forgetGuestRearm drops the remembered mode and any pending re-arm after someone
+// armed or disarmed the house themselves: their choice is not overridden later
+func (c *Coordinator) forgetGuestRearm(by, action string) {
+	r := &c.guestRearm
+	r.mu.Lock()
+	if r.action == "" && r.timer == nil {
+		r.mu.Unlock()
+		return
+	}
+	if r.timer != nil {
+		r.timer.Stop()
+		r.timer = nil
+	}
+	r.armAt = time.Time{}
+	r.action, r.before = "", ""
+	c.saveGuestRearmLocked()
+	r.mu.Unlock()
+
+	logger.Info("guest re-arm: dropped, " + action + " by " + by)
+	c.Events.Publish(eventbus.TopicGuest, eventbus.GuestRearm, map[string]interface{}{
+		"status": RearmCancelled,
+		"by":     by,
+		"reason": action,
+	})
+}
+
+// GuestRearmInfo returns the pending re-arm for the display (nil = none)
+func (c *Coordinator) GuestRearmInfo() *guest.RearmInfo {
+	r := &c.guestRearm
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.timer == nil {
+		return nil
+	}
+	remaining := max(int(math.Ceil(time.Until(r.armAt).Seconds())), 0)
+	return &guest.RearmInfo{
+		Action:           r.action,
+		ArmAt:            r.armAt.UTC().Format(time.RFC3339),
+		RemainingSeconds: remaining,
+	}
+}
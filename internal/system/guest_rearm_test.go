@@ -0,0 +1,288 @@
+package system
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"smartdisplay-core/internal/auth"
+	"smartdisplay-core/internal/guest"
+	"smartdisplay-core/internal/ha/alarmo"
+	"smartdisplay-core/internal/settings"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGuestRearmRestoresModeAfterLastGuest(t *testing.T) {
+	c := &Coordinator{
+		GuestRequest: guest.NewManager(time.Minute),
+		AlarmoState:  alarmo.AlarmoState{Mode: "armed", ArmedMode: "night", RawState: "armed_night"},
+	}
+
+	if before := c.rememberAlarmModeForGuest(); before != "armed_night" {
+		t.Fatalf("before = %q", before)
+	}
+	// Alarmo is disarmed for the guest; a second guest must not overwrite the mode
+	c.AlarmoState = alarmo.AlarmoState{Mode: "disarmed", RawState: "disarmed"}
+	c.rememberAlarmModeForGuest()
+
+	c.guestSessionEnded("exit")
+	info := c.GuestRearmInfo()
+	if info == nil || info.Action != "arm_night" || info.RemainingSeconds < 59 {
+		t.Fatalf("pending re-arm = %+v", info)
+	}
+
+	// A guest let in during the exit delay stops it but keeps the mode
+	c.rememberAlarmModeForGuest()
+	if info := c.GuestRearmInfo(); info != nil {
+		t.Errorf("re-arm still pending after new guest: %+v", info)
+	}
+	c.guestSessionEnded("timeout")
+	gen := c.guestRearm.gen
+
+	// A stale timer does nothing
+	c.rearmAfterGuests(gen - 1)
+	if c.GuestRearmInfo() == nil {
+		t.Fatal("stale timer cleared the pending re-arm")
+	}
+
+	if err := c.CancelGuestRearm("ayse"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.CancelGuestRearm("ayse"); !errors.Is(err, ErrNoRearmPending) {
+		t.Errorf("second cancel = %v", err)
+	}
+	// Cancelling forgets the mode: the house stays disarmed
+	c.guestSessionEnded("exit")
+	if info := c.GuestRearmInfo(); info != nil {
+		t.Errorf("re-arm scheduled after cancel: %+v", info)
+	}
+}
+
+func TestGuestRearmSkipsWhenNotArmedOrGuestsRemain(t *testing.T) {
+	c := &Coordinator{
+		GuestRequest: guest.NewManager(time.Minute),
+		AlarmoState:  alarmo.AlarmoState{Mode: "disarmed", RawState: "disarmed"},
+	}
+	c.rememberAlarmModeForGuest()
+	c.guestSessionEnded("exit")
+	if info := c.GuestRearmInfo(); info != nil {
+		t.Errorf("re-arm scheduled though the house was disarmed: %+v", info)
+	}
+
+	c.AlarmoState = alarmo.AlarmoState{Mode: "armed", ArmedMode: "away", RawState: "armed_away"}
+	c.rememberAlarmModeForGuest()
+	req, _ := c.GuestRequest.CreateRequest("ayse", "display")
+	c.GuestRequest.ApproveRequest(req.ID)
+	c.guestSessionEnded("exit")
+	if info := c.GuestRearmInfo(); info != nil {
+		t.Errorf("re-arm scheduled while a guest still has access: %+v", info)
+	}
+}
+
+// newRearmHA returns a fake HA recording the alarm_control_panel services called,
+// as "<service> code=<code>"
+func newRearmHA(t *testing.T) (*alarmo.Adapter, <-chan string) {
+	t.Helper()
+	calls := make(chan string, 4)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Code string `json:"code"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		calls <- strings.TrimPrefix(r.URL.Path, "/api/services/alarm_control_panel/") + " code=" + body.Code
+		w.Write([]byte("[]"))
+	}))
+	t.Cleanup(srv.Close)
+	return alarmo.New(srv.URL, "token"), calls
+}
+
+func TestGuestRearmSurvivesRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "guest_requests.json")
+	start := func() *Coordinator {
+		t.Helper()
+		m := guest.NewManager(time.Minute)
+		if err := m.Open(path); err != nil {
+			t.Fatal(err)
+		}
+		adapter, _ := newRearmHA(t)
+		return &Coordinator{
+			GuestRequest:  m,
+			AlarmoAdapter: adapter,
+			alarmCommands: newAlarmCommandTracker(func() time.Duration { return time.Minute }, func(AlarmCommand) {}),
+		}
+	}
+
+	// Armed night, a guest is let in, then the system restarts
+	c := start()
+	c.AlarmoState = alarmo.AlarmoState{Mode: "armed", ArmedMode: "night", RawState: "armed_night"}
+	c.rememberAlarmModeForGuest()
+	req, _ := c.GuestRequest.CreateRequest("ayse", "display")
+	c.GuestRequest.ApproveRequest(req.ID)
+
+	c = start()
+	c.RestoreGuestRearm()
+	if info := c.GuestRearmInfo(); info != nil {
+		t.Fatalf("re-arm scheduled while the guest is still inside: %+v", info)
+	}
+	// The restored guest leaves: the remembered mode is still known
+	c.GuestRequest.ExitGuest(req.ID)
+	c.guestSessionEnded("exit")
+	info := c.GuestRearmInfo()
+	if info == nil || info.Action != "arm_night" {
+		t.Fatalf("re-arm after restored guest left = %+v", info)
+	}
+
+	// Restart during the exit delay: the deadline is kept
+	c = start()
+	c.RestoreGuestRearm()
+	if restored := c.GuestRearmInfo(); restored == nil || restored.ArmAt != info.ArmAt {
+		t.Fatalf("pending re-arm after restart = %+v, want %+v", restored, info)
+	}
+}
+
+func TestGuestRearmRunsWhenDeadlinePassedWhileDown(t *testing.T) {
+	// The re-arm uses the Alarmo code of guest_alarmo_user, not that of the first
+	// admin (users live in data/users.json)
+	t.Chdir(t.TempDir())
+	os.MkdirAll("data", 0755)
+	for _, u := range []struct{ name, pin, code string }{{"ilk", "2468", "1111"}, {"ev", "1357", "9876"}} {
+		if err := auth.AddUser(auth.User{Username: u.name, PIN: u.pin, Role: auth.Admin}); err != nil {
+			t.Fatal(err)
+		}
+		if err := auth.SetAlarmoCode(u.name, u.code); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sm := settings.NewSettingsManager(nil, nil, nil, nil, nil, nil, nil, func(string, string) {})
+	sm.SetUserRole(settings.RoleAdmin)
+	if _, err := sm.ApplyFieldChange(&settings.FieldChangeRequest{FieldID: "guest_alarmo_user", NewValue: "ev", Confirm: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "guest_requests.json")
+	m := guest.NewManager(time.Minute)
+	m.Open(path)
+	m.SaveRearm(&guest.RearmState{Action: "arm_away", Before: "armed_away", ArmAt: time.Now().Add(-time.Minute)})
+
+	reopened := guest.NewManager(time.Minute)
+	if err := reopened.Open(path); err != nil {
+		t.Fatal(err)
+	}
+	adapter, calls := newRearmHA(t)
+	c := &Coordinator{
+		GuestRequest:  reopened,
+		Settings:      sm,
+		AlarmoAdapter: adapter,
+		alarmCommands: newAlarmCommandTracker(func() time.Duration { return time.Minute }, func(AlarmCommand) {}),
+	}
+	c.RestoreGuestRearm()
+
+	select {
+	case service := <-calls:
+		if service != "alarm_arm_away code=9876" {
+			t.Errorf("re-arm called %q, want alarm_arm_away with the code of guest_alarmo_user", service)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("overdue re-arm not sent after restart")
+	}
+	if st := reopened.SavedRearm(); st != nil {
+		t.Errorf("re-arm state kept after arming: %+v", st)
+	}
+}
+
+func TestArmActionForEveryArmedMode(t *testing.T) {
+	tests := []struct {
+		st   alarmo.AlarmoState
+		want string
+	}{
+		{alarmo.AlarmoState{Mode: "armed", ArmedMode: "home"}, "arm_home"},
+		{alarmo.AlarmoState{Mode: "armed", ArmedMode: "away"}, "arm_away"},
+		{alarmo.AlarmoState{Mode: "armed", ArmedMode: "night"}, "arm_night"},
+		{alarmo.AlarmoState{Mode: "armed", ArmedMode: "vacation"}, "arm_vacation"},
+		{alarmo.AlarmoState{Mode: "armed", ArmedMode: "custom_bypass"}, "arm_custom_bypass"},
+		{alarmo.AlarmoState{Mode: "disarmed"}, ""},
+		{alarmo.AlarmoState{Mode: "arming", ArmedMode: "away"}, ""},
+	}
+	for _, tt := range tests {
+		if got := armActionFor(tt.st); got != tt.want {
+			t.Errorf("armActionFor(%s/%s) = %q, want %q", tt.st.Mode, tt.st.ArmedMode, got, tt.want)
+		}
+	}
+}
+
+func TestGuestRearmDroppedWhenUserChangesAlarm(t *testing.T) {
+	c := &Coordinator{
+		GuestRequest: guest.NewManager(time.Minute),
+		AlarmoState:  alarmo.AlarmoState{Mode: "armed", ArmedMode: "vacation", RawState: "armed_vacation"},
+	}
+	primary := alarmo.DefaultPanels()[0].Area
+	c.rememberAlarmModeForGuest()
+
+	// The system's own commands and other areas leave the remembered mode alone
+	c.onAlarmCommandResolved(AlarmCommand{Area: primary, Action: "disarm", Status: CommandConfirmed})
+	c.onAlarmCommandResolved(AlarmCommand{Area: primary, Action: "disarm", Status: CommandFailed, RequestedBy: "ayse"})
+	c.guestSessionEnded("exit")
+	if info := c.GuestRearmInfo(); info == nil || info.Action != "arm_vacation" {
+		t.Fatalf("pending re-arm = %+v", info)
+	}
+
+	// A user arming the house during the exit delay cancels the re-arm
+	c.onAlarmCommandResolved(AlarmCommand{Area: primary, Action: "arm_away", Status: CommandConfirmed, RequestedBy: "ayse"})
+	if info := c.GuestRearmInfo(); info != nil {
+		t.Fatalf("re-arm still pending after user armed: %+v", info)
+	}
+
+	// A user disarming while a guest is inside: nothing is re-armed when they leave
+	c.AlarmoState = alarmo.AlarmoState{Mode: "armed", ArmedMode: "night", RawState: "armed_night"}
+	c.rememberAlarmModeForGuest()
+	c.onAlarmCommandResolved(AlarmCommand{Area: primary, Action: "disarm", Status: CommandConfirmed, RequestedBy: "mehmet"})
+	c.guestSessionEnded("exit")
+	if info := c.GuestRearmInfo(); info != nil {
+		t.Errorf("re-arm scheduled after user disarmed: %+v", info)
+	}
+}
+
+func TestGuestApprovalDisarmIsTracked(t *testing.T) {
+	t.Chdir(t.TempDir())
+	adapter, calls := newRearmHA(t)
+	c := &Coordinator{
+		GuestRequest:  guest.NewManager(time.Minute),
+		GuestScreen:   guest.NewScreenStateManager(func() bool { return false }, func() string { return "" }, time.Now),
+		AlarmoAdapter: adapter,
+		AlarmoState:   alarmo.AlarmoState{Mode: "armed", ArmedMode: "away", RawState: "armed_away"},
+	}
+	c.alarmCommands = newAlarmCommandTracker(func() time.Duration { return time.Minute }, c.onAlarmCommandResolved)
+	c.setupGuestApprovalCallbacks()
+
+	req, _ := c.GuestRequest.CreateRequest("ayse", "display")
+	c.GuestRequest.ApproveRequest(req.ID)
+	select {
+	case service := <-calls:
+		// No guest_alarmo_user set: no code is sent
+		if service != "alarm_disarm code=" {
+			t.Fatalf("approval called %q, want alarm_disarm without a code", service)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("approval did not disarm")
+	}
+
+	cmds := c.AlarmCommands()
+	if len(cmds) != 1 || cmds[0].Action != "disarm" || cmds[0].RequestedBy != "" || cmds[0].Status != CommandPending {
+		t.Fatalf("tracked commands = %+v, want one pending system disarm", cmds)
+	}
+
+	// Its confirmation is not a user's choice: the re-arm is kept
+	c.alarmCommands.observe(cmds[0].Area, alarmo.AlarmoState{Mode: "disarmed", RawState: "disarmed"})
+	if got, _ := c.AlarmCommandByID(cmds[0].ID); got.Status != CommandConfirmed {
+		t.Errorf("disarm after state change = %+v", got)
+	}
+	c.GuestRequest.ExitGuest(req.ID)
+	c.guestSessionEnded("exit")
+	if info := c.GuestRearmInfo(); info == nil || info.Action != "arm_away" {
+		t.Errorf("re-arm after guest approval disarm = %+v", info)
+	}
+}
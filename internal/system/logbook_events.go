@@ -0,0 +1,216 @@
+package system
+
+import (
+	"fmt"
+	"smartdisplay-core/internal/alarm"
+	"smartdisplay-core/internal/eventbus"
+	"smartdisplay-core/internal/guest"
+	"smartdisplay-core/internal/logbook"
+	"time"
+)
+
+// === LOGBOOK POPULATION ===
+// The "logbook" bus subscriber records alarm, guest, HA, hardware, failsafe and
+// access events. Repeats are grouped by LogbookManager.AddEntry.
+
+// commandAttributionWindow is how long after confirmation a state change is
+// still attributed to the user who requested it
+const commandAttributionWindow = 2 * time.Minute
+
+// onEventForLogbook maps bus events to logbook entries
+func (c *Coordinator) onEventForLogbook(ev eventbus.Event) {
+	if c.Logbook == nil {
+		return
+	}
+	str := func(key string) string {
+		if v, ok := ev.Payload[key]; ok && v != nil {
+			return fmt.Sprint(v)
+		}
+		return ""
+	}
+
+	switch ev.Type {
+	case eventbus.AlarmStateChanged:
+		c.logAlarmTransition(str("from"), str("to"), str("event"), str("armed_mode"))
+
+	case eventbus.AlarmCommandResolved:
+		if str("status") != string(CommandFailed) {
+			return
+		}
+		c.Logbook.AddEntry(logbook.CategoryAlarm, logbook.AlarmCommandFailed, logbook.SeverityWarning,
+			"Alarm command not confirmed: "+str("action"), str("area"), logbook.EntryDetail{
+				Reason:   str("reason"),
+				Location: str("area"),
+				UserID:   str("requested_by"),
+				Extra:    map[string]interface{}{"command_id": str("id"), "action": str("action")},
+			}, logbook.RoleUser)
+
+	case eventbus.GuestRequestChanged:
+		detail := logbook.EntryDetail{GuestID: str("id"), UserID: str("target_user")}
+		switch str("status") {
+		case guest.StatusPending:
+			c.Logbook.AddEntry(logbook.CategoryGuest, logbook.GuestRequested, logbook.SeverityInfo,
+				"Guest access requested", "", detail, logbook.RoleUser)
+		case guest.StatusApproved:
+			if passID := str("pass_id"); passID != "" {
+				detail.Extra = map[string]interface{}{"pass_id": passID}
+				c.Logbook.AddEntry(logbook.CategoryGuest, logbook.GuestPassUsed, logbook.SeverityInfo,
+					"Guest pass used: "+str("guest_name"), "", detail, logbook.RoleUser)
+				return
+			}
+			c.Logbook.AddEntry(logbook.CategoryGuest, logbook.GuestApproved, logbook.SeverityInfo,
+				"Guest access approved", "", detail, logbook.RoleUser)
+		case guest.StatusRejected:
+			c.Logbook.AddEntry(logbook.CategoryGuest, logbook.GuestDenied, logbook.SeverityInfo,
+				"Guest access denied", "", detail, logbook.RoleUser)
+		case guest.StatusExpired:
+			c.Logbook.AddEntry(logbook.CategoryGuest, logbook.GuestExpired, logbook.SeverityWarning,
+				"Guest request expired without an answer", "", detail, logbook.RoleUser)
+		}
+
+	case eventbus.GuestAction:
+		switch str("action") {
+		case guest.EXIT:
+			c.Logbook.AddEntry(logbook.CategoryGuest, logbook.GuestExited, logbook.SeverityInfo,
+				"Guest exited", "", logbook.EntryDetail{GuestID: str("id")}, logbook.RoleUser)
+		case guest.TIMEOUT:
+			if str("from") == guest.APPROVED {
+				c.Logbook.AddEntry(logbook.CategoryGuest, logbook.GuestAutoExpired, logbook.SeverityInfo,
+					"Guest access ended automatically", "", logbook.EntryDetail{GuestID: str("id")}, logbook.RoleUser)
+			}
+		}
+
+	case eventbus.GuestRearm:
+		switch str("status") {
+		case RearmCancelled:
+			if str("by") == "" {
+				return // Stopped by a new guest; the mode is still remembered
+			}
+			c.Logbook.AddEntry(logbook.CategoryGuest, logbook.GuestRearmCancel, logbook.SeverityWarning,
+				"Re-arm after guest cancelled", "", logbook.EntryDetail{
+					UserID: str("by"),
+					Extra:  map[string]interface{}{"action": str("action")},
+				}, logbook.RoleUser)
+		case RearmFailed:
+			c.Logbook.AddEntry(logbook.CategoryAlarm, logbook.GuestRearmFailed, logbook.SeverityWarning,
+				"Alarm could not be re-armed after guest", "", logbook.EntryDetail{
+					Reason: str("reason"),
+					Extra:  map[string]interface{}{"action": str("action")},
+				}, logbook.RoleUser)
+		}
+
+	case eventbus.HAConnection:
+		if ev.Payload["connected"] == true {
+			c.Logbook.AddEntry(logbook.CategorySystem, logbook.HAConnected, logbook.SeverityInfo,
+				"Home Assistant connected", "", logbook.EntryDetail{}, logbook.RoleAdmin)
+			if c.InFailsafeMode() {
+				c.Logbook.AddEntry(logbook.CategorySafety, logbook.FailsafeRecovering, logbook.SeverityInfo,
+					"Home Assistant is back, leaving failsafe mode", "", logbook.EntryDetail{}, logbook.RoleAdmin)
+			}
+			return
+		}
+		c.Logbook.AddEntry(logbook.CategorySystem, logbook.HADisconnected, logbook.SeverityWarning,
+			"Home Assistant disconnected", "", logbook.EntryDetail{}, logbook.RoleAdmin)
+
+	case eventbus.HALDeviceFault:
+		c.Logbook.AddEntry(logbook.CategorySystem, logbook.DeviceOffline, logbook.SeverityWarning,
+			"Device offline: "+str("device_type")+" "+str("id"), "", logbook.EntryDetail{
+				DeviceName: str("id"),
+				DeviceType: str("device_type"),
+				Reason:     str("error"),
+			}, logbook.RoleAdmin)
+
+	case eventbus.HALDeviceRecovered:
+		c.Logbook.AddEntry(logbook.CategorySystem, logbook.DeviceOnline, logbook.SeverityInfo,
+			"Device online: "+str("device_type")+" "+str("id"), "", logbook.EntryDetail{
+				DeviceName: str("id"),
+				DeviceType: str("device_type"),
+			}, logbook.RoleAdmin)
+
+	case eventbus.FailsafeChanged:
+		detail := logbook.EntryDetail{Reason: str("explanation")}
+		if ev.Payload["active"] == true {
+			c.Logbook.AddEntry(logbook.CategorySafety, logbook.FailsafeActivated, logbook.SeverityCritical,
+				"Failsafe mode activated", "", detail, logbook.RoleAdmin)
+			return
+		}
+		c.Logbook.AddEntry(logbook.CategorySafety, logbook.FailsafeRecovered, logbook.SeverityInfo,
+			"Failsafe mode exited", "", detail, logbook.RoleAdmin)
+
+	case eventbus.PINScheduleDenied:
+		c.Logbook.AddEntry(logbook.CategorySystem, logbook.PINOutOfSchedule, logbook.SeverityWarning,
+			"PIN used outside its access times: "+str("username"), "", logbook.EntryDetail{
+				UserID: str("username"),
+				Reason: str("reason"),
+			}, logbook.RoleAdmin)
+
+	case eventbus.AlarmDuress:
+		// Safety entry: hidden from the user roles the intruder may be watching
+		c.Logbook.AddEntry(logbook.CategorySafety, logbook.DuressDisarm, logbook.SeverityCritical,
+			"Disarmed with duress PIN: "+str("username"), str("area"), logbook.EntryDetail{
+				UserID:   str("username"),
+				Location: str("area"),
+			}, logbook.RoleAdmin)
+
+	case eventbus.SystemStarted:
+		c.Logbook.AddEntry(logbook.CategorySystem, logbook.SystemStarted, logbook.SeverityInfo,
+			"System started", "", logbook.EntryDetail{Version: str("version")}, logbook.RoleAdmin)
+	}
+}
+
+// logAlarmTransition records a local alarm state change (A9), attributed to the
+// user whose Alarmo command caused it when there is one
+func (c *Coordinator) logAlarmTransition(from, to, event, mode string) {
+	requester := func(disarm bool) string {
+		if c.alarmCommands == nil {
+			return ""
+		}
+		if cmd, ok := c.alarmCommands.recent(disarm, commandAttributionWindow); ok {
+			return cmd.RequestedBy
+		}
+		return ""
+	}
+	delays := c.alarmDelays(mode)
+
+	switch to {
+	case alarm.ARMING:
+		c.Logbook.AddEntry(logbook.CategoryAlarm, logbook.AlarmCountdownStarted, logbook.SeverityInfo,
+			"Exit countdown started", "", logbook.EntryDetail{
+				UserID:          requester(false),
+				DurationSeconds: delays.Exit,
+				Extra:           map[string]interface{}{"armed_mode": mode},
+			}, logbook.RoleUser)
+	case alarm.PENDING:
+		c.Logbook.AddEntry(logbook.CategoryAlarm, logbook.AlarmCountdownStarted, logbook.SeverityWarning,
+			"Entry countdown started", "", logbook.EntryDetail{
+				Reason:          event,
+				DurationSeconds: delays.Entry,
+			}, logbook.RoleUser)
+	case alarm.ARMED:
+		msg := "Alarm armed"
+		if mode != "" {
+			msg += " (" + mode + ")"
+		}
+		c.Logbook.AddEntry(logbook.CategoryAlarm, logbook.AlarmArmed, logbook.SeverityInfo,
+			msg, "", logbook.EntryDetail{UserID: requester(false)}, logbook.RoleUser)
+	case alarm.TRIGGERED:
+		c.Logbook.AddEntry(logbook.CategoryAlarm, logbook.AlarmTriggered, logbook.SeverityCritical,
+			"Alarm triggered", "", logbook.EntryDetail{Reason: event}, logbook.RoleUser)
+		if c.InFailsafeMode() {
+			c.Logbook.AddEntry(logbook.CategorySafety, logbook.AlarmDuringFailsafe, logbook.SeverityCritical,
+				"Alarm triggered during failsafe mode", "", logbook.EntryDetail{Reason: c.FailsafeExplanation()}, logbook.RoleAdmin)
+		}
+	case alarm.DISARMED:
+		switch from {
+		case alarm.ARMING:
+			c.Logbook.AddEntry(logbook.CategoryAlarm, logbook.AlarmCountdownCancelled, logbook.SeverityInfo,
+				"Arming cancelled", "", logbook.EntryDetail{UserID: requester(true)}, logbook.RoleUser)
+		case alarm.TRIGGERED:
+			c.Logbook.AddEntry(logbook.CategoryAlarm, logbook.AlarmAcknowledged, logbook.SeverityInfo,
+				"Triggered alarm acknowledged", "", logbook.EntryDetail{UserID: requester(true)}, logbook.RoleUser)
+		default:
+			c.Logbook.AddEntry(logbook.CategoryAlarm, logbook.AlarmDisarmed, logbook.SeverityInfo,
+				"Alarm disarmed", "", logbook.EntryDetail{UserID: requester(true)}, logbook.RoleUser)
+		}
+	}
+}
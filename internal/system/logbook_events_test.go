@@ -0,0 +1,140 @@
+package system
+
+import (
+	"smartdisplay-core/internal/alarm"
+	"smartdisplay-core/internal/eventbus"
+	"smartdisplay-core/internal/ha/alarmo"
+	"smartdisplay-core/internal/logbook"
+	"testing"
+	"time"
+)
+
+// newLogbookTestCoordinator builds the minimal coordinator needed by onEventForLogbook
+func newLogbookTestCoordinator() *Coordinator {
+	c := &Coordinator{
+		Alarm:   alarm.NewStateMachine(),
+		Logbook: logbook.NewLogbookManager(30, 90),
+	}
+	c.alarmCommands = newAlarmCommandTracker(func() time.Duration { return time.Minute }, nil)
+	return c
+}
+
+// recordEvents feeds events to the logbook subscriber and returns the admin view
+// by entry type, checking how many entries were written
+func recordEvents(t *testing.T, c *Coordinator, want int, events ...eventbus.Event) map[logbook.EntryType]logbook.Entry {
+	t.Helper()
+	for _, ev := range events {
+		c.onEventForLogbook(ev)
+	}
+	byType := map[logbook.EntryType]logbook.Entry{}
+	resp := c.Logbook.Search(logbook.Query{Role: logbook.RoleAdmin, Limit: 100})
+	for _, e := range resp.Entries {
+		byType[e.Type] = e
+	}
+	if resp.Pagination.Total != want {
+		t.Errorf("entries = %d, want %d: %+v", resp.Pagination.Total, want, resp.Entries)
+	}
+	return byType
+}
+
+func TestLogbookRecordsAlarmEvents(t *testing.T) {
+	c := newLogbookTestCoordinator()
+	c.alarmCommands.track("area_1", "arm_away", "ayse", alarmo.AlarmoState{Mode: "disarmed"})
+
+	byType := recordEvents(t, c, 2,
+		eventbus.Event{Type: eventbus.AlarmStateChanged, Payload: map[string]interface{}{"from": alarm.ARMING, "to": alarm.ARMED, "event": "ARM_COMPLETE", "armed_mode": "away"}},
+		eventbus.Event{Type: eventbus.AlarmStateChanged, Payload: map[string]interface{}{"from": alarm.PENDING, "to": alarm.TRIGGERED, "event": "TRIGGER"}},
+		// Only failed commands are recorded
+		eventbus.Event{Type: eventbus.AlarmCommandResolved, Payload: map[string]interface{}{"id": "cmd_1", "status": "confirmed"}},
+	)
+	if e := byType[logbook.AlarmArmed]; e.Message != "Alarm armed (away)" || e.Details.UserID != "ayse" {
+		t.Errorf("armed entry = %+v", e)
+	}
+	if e := byType[logbook.AlarmTriggered]; e.Severity != logbook.SeverityCritical || e.Details.Reason != "TRIGGER" {
+		t.Errorf("triggered entry = %+v", e)
+	}
+}
+
+func TestLogbookRecordsGuestEvents(t *testing.T) {
+	c := newLogbookTestCoordinator()
+
+	byType := recordEvents(t, c, 2,
+		eventbus.Event{Type: eventbus.GuestRequestChanged, Payload: map[string]interface{}{"id": "req_1", "status": "approved", "target_user": "ayse"}},
+		eventbus.Event{Type: eventbus.GuestRequestChanged, Payload: map[string]interface{}{"id": "req_2", "status": "approved", "pass_id": "pass_1", "guest_name": "Cleaner"}},
+	)
+	if e := byType[logbook.GuestApproved]; e.Details.GuestID != "req_1" {
+		t.Errorf("guest entry = %+v", e)
+	}
+	if e := byType[logbook.GuestPassUsed]; e.Details.GuestID != "req_2" || e.Details.Extra["pass_id"] != "pass_1" {
+		t.Errorf("guest pass entry = %+v", e)
+	}
+}
+
+func TestLogbookRecordsSystemEvents(t *testing.T) {
+	c := newLogbookTestCoordinator()
+
+	byType := recordEvents(t, c, 4,
+		eventbus.Event{Type: eventbus.SystemStarted, Payload: map[string]interface{}{"version": "1.2.0"}},
+		eventbus.Event{Type: eventbus.HAConnection, Payload: map[string]interface{}{"connected": false}},
+		eventbus.Event{Type: eventbus.HALDeviceFault, Payload: map[string]interface{}{"device_type": "fan", "id": "fan_1", "error": "no response"}},
+		eventbus.Event{Type: eventbus.HALDeviceFault, Payload: map[string]interface{}{"device_type": "fan", "id": "fan_1", "error": "no response"}},
+		eventbus.Event{Type: eventbus.FailsafeChanged, Payload: map[string]interface{}{"active": true, "explanation": "HA offline"}},
+	)
+	if e := byType[logbook.DeviceOffline]; e.GroupCount != 2 || e.Details.DeviceName != "fan_1" || e.Details.Reason != "no response" {
+		t.Errorf("device entry = %+v", e)
+	}
+	if e := byType[logbook.FailsafeActivated]; e.Category != logbook.CategorySafety || e.Details.Reason != "HA offline" {
+		t.Errorf("failsafe entry = %+v", e)
+	}
+	if _, ok := byType[logbook.HADisconnected]; !ok {
+		t.Error("HA disconnect not recorded")
+	}
+	if e := byType[logbook.SystemStarted]; e.Details.Version != "1.2.0" {
+		t.Errorf("start entry = %+v", e)
+	}
+}
+
+func TestLogbookRecordsPINScheduleDenials(t *testing.T) {
+	c := newLogbookTestCoordinator()
+
+	byType := recordEvents(t, c, 1,
+		eventbus.Event{Type: eventbus.PINScheduleDenied, Payload: map[string]interface{}{"username": "temizlik", "reason": "outside_schedule"}},
+	)
+	if e := byType[logbook.PINOutOfSchedule]; e.Details.UserID != "temizlik" || e.Details.Reason != "outside_schedule" {
+		t.Errorf("schedule entry = %+v", e)
+	}
+}
+
+func TestLogbookRecordsDuressForAdminsOnly(t *testing.T) {
+	c := newLogbookTestCoordinator()
+
+	byType := recordEvents(t, c, 1,
+		eventbus.Event{Type: eventbus.AlarmDuress, Payload: map[string]interface{}{"username": "ayse", "area": "area_1"}},
+	)
+	if e := byType[logbook.DuressDisarm]; e.Category != logbook.CategorySafety || e.Details.UserID != "ayse" {
+		t.Errorf("duress entry = %+v", e)
+	}
+	// Users never see the duress entry
+	for _, e := range c.Logbook.Search(logbook.Query{Role: logbook.RoleUser, Limit: 100}).Entries {
+		if e.Type == logbook.DuressDisarm {
+			t.Error("duress entry visible to users")
+		}
+	}
+}
+
+func TestLogbookRecordsGuestRearmEvents(t *testing.T) {
+	c := newLogbookTestCoordinator()
+
+	byType := recordEvents(t, c, 2,
+		// Stopped by a new guest: nothing to record
+		eventbus.Event{Type: eventbus.GuestRearm, Payload: map[string]interface{}{"status": RearmCancelled, "reason": "guest admitted"}},
+		eventbus.Event{Type: eventbus.GuestRearm, Payload: map[string]interface{}{"status": RearmCancelled, "action": "arm_away", "by": "ayse"}},
+		eventbus.Event{Type: eventbus.GuestRearm, Payload: map[string]interface{}{"status": RearmFailed, "action": "arm_away", "reason": "alarmo unreachable"}},
+	)
+	if e := byType[logbook.GuestRearmCancel]; e.Details.UserID != "ayse" {
+		t.Errorf("re-arm cancel entry = %+v", e)
+	}
+	if e := byType[logbook.GuestRearmFailed]; e.Details.Reason != "alarmo unreachable" {
+		t.Errorf("re-arm failure entry = %+v", e)
+	}
+}
@@ -139,6 +139,11 @@ function route() {
     return;
   }
 
+  if (hash === '#/logout') {
+    fetch('/api/logout', { method: 'POST' }).finally(() => { location.hash = '#/login'; });
+    return;
+  }
+
   // Sadece ana sayfa ve alt sayfalarda layout'u render et
   let page = hash.replace('#/', '');
   renderLayout(page, currentUser);
@@ -216,15 +221,17 @@ function renderLogin() {
   async function tryLogin(pin) {
     document.getElementById('loginError').textContent = '';
     try {
-      const resp = await fetch('/api/ui/home/state', {
-        method: 'GET',
-        headers: { 'X-SmartDisplay-PIN': pin }
+      // Sunucu oturum cookie'si verir; PIN bellekte tutulmaz
+      const resp = await fetch('/api/login', {
+        method: 'POST',
+        headers: { 'Content-Type': 'application/json' },
+        body: JSON.stringify({ pin })
       });
       if (resp.ok) {
         location.hash = '#/home';
       } else {
         const data = await resp.json().catch(() => ({}));
-        document.getElementById('loginError').textContent = data.error || 'Hatalı PIN';
+        document.getElementById('loginError').textContent = data.message || 'Hatalı PIN';
         pin = '';
         dots.textContent = '••••';
       }
@@ -241,7 +248,9 @@ function renderLogin() {
     dots.textContent = '•'.repeat(pin.length).padEnd(4, '•');
 
     if (pin.length === 4) {
-      tryLogin(pin);
+      const entered = pin;
+      pin = '';
+      tryLogin(entered);
     }
   });
 }